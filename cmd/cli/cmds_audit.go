@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/faelmori/gkbxsrv/models"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/goccy/go-json"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func AuditCommand() *cobra.Command {
+	var connection, tenant string
+	var filters []string
+	var asJSON bool
+	var query models.Query
+
+	var auditExp = []string{
+		"gkbxsrv database audit product 42",
+		"gkbxsrv database audit warehouse --filter 'operation=delete'",
+		"gkbxsrv database audit --filter 'actor=alice' --filter 'created_at>=2025-01-01' --json",
+		"gkbxsrv database audit customer --tenant=acme",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "audit [model] [id]",
+		Aliases:     []string{"history"},
+		Args:        cobra.MaximumNArgs(2),
+		Example:     concatenateExamples(auditExp),
+		Annotations: getDescriptions([]string{"Show the audit history of the models or of a single record, newest first.", "Audit history"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsed, parseErr := models.ParseFilters(filters)
+			if parseErr != nil {
+				return parseErr
+			}
+			if len(args) > 0 {
+				parsed = append(parsed, models.Filter{Field: "model", Op: models.OpEq, Value: strings.ToLower(args[0])})
+			}
+			if len(args) > 1 {
+				parsed = append(parsed, models.Filter{Field: "record_id", Op: models.OpEq, Value: args[1]})
+			}
+			query.Filters = parsed
+
+			db, dbErr := backupConnection(connection)
+			if dbErr != nil {
+				return dbErr
+			}
+			logs, info, historyErr := databases.AuditHistory(tenantSession(db, tenant), query)
+			if historyErr != nil {
+				return historyErr
+			}
+			if asJSON {
+				data, marshalErr := json.MarshalIndent(map[string]interface{}{"items": logs, "total": info.Total, "next_cursor": info.NextCursor}, "", "  ")
+				if marshalErr != nil {
+					return marshalErr
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "TIME\tMODEL\tRECORD\tOPERATION\tACTOR\tCHANGES")
+			for _, entry := range logs {
+				actor := entry.Actor
+				if actor == "" {
+					actor = "-"
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.CreatedAt.Local().Format(time.DateTime), entry.Model, entry.RecordID, entry.Operation, actor, auditSummary(entry))
+			}
+			if info.NextCursor != "" {
+				_, _ = fmt.Fprintf(w, "\n%d of %d entries; more with --cursor=%s\n", len(logs), info.Total, info.NextCursor)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file")
+	cmd.Flags().StringVarP(&connection, "connection", "n", "", "named connection to read (default: the primary)")
+	cmd.Flags().StringArrayVarP(&filters, "filter", "f", nil, "filter on the audit entries, e.g. 'actor=alice' or 'operation:in=update,delete'")
+	cmd.Flags().IntVarP(&query.Limit, "limit", "l", models.DefaultPageSize, "entries per page")
+	cmd.Flags().StringVar(&query.Cursor, "cursor", "", "next_cursor of the previous page")
+	cmd.Flags().BoolVarP(&asJSON, "json", "j", false, "print the entries as JSON")
+	cmd.Flags().StringVarP(&tenant, "tenant", "t", "", "only show the entries of this tenant (default: every tenant)")
+
+	return cmd
+}
+
+// auditSummary lists the changed columns of an update; creates and deletes only count them.
+func auditSummary(entry databases.AuditLog) string {
+	changes, diffErr := entry.Diff()
+	if diffErr != nil {
+		return diffErr.Error()
+	}
+	columns := make([]string, 0, len(changes))
+	for column := range changes {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	if entry.Operation == databases.AuditCreate || (entry.Operation == databases.AuditDelete && len(columns) > 1) {
+		return fmt.Sprintf("%d columns", len(columns))
+	}
+	parts := make([]string, 0, len(columns))
+	for _, column := range columns {
+		parts = append(parts, fmt.Sprintf("%s: %v → %v", column, auditText(changes[column].Before), auditText(changes[column].After)))
+	}
+	return strings.Join(parts, ", ")
+}
+func auditText(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func BackupCommand() *cobra.Command {
+	var output, connection string
+	var modelNames []string
+
+	var backupExp = []string{
+		"gkbxsrv database backup",
+		"gkbxsrv database backup --output=kubex.jsonl.gz --models=user,product",
+		"gkbxsrv database backup --connection=reporting",
+	}
+
+	backupCmd := &cobra.Command{
+		Use:         "backup",
+		Aliases:     []string{"dump", "export"},
+		Example:     concatenateExamples(backupExp),
+		Annotations: getDescriptions([]string{"Back up every registered model to a portable compressed JSONL archive.", "Logical backup"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, dbErr := backupConnection(connection)
+			if dbErr != nil {
+				return dbErr
+			}
+			if output == "" {
+				output = "gkbxsrv-" + time.Now().Format("20060102-150405") + databases.BackupFileExtension
+			}
+			file, createErr := os.Create(output)
+			if createErr != nil {
+				return fmt.Errorf("error creating backup file: %v", createErr)
+			}
+			result, backupErr := databases.Backup(db, file, databases.BackupOptions{Models: modelNames})
+			if closeErr := file.Close(); backupErr == nil && closeErr != nil {
+				backupErr = closeErr
+			}
+			if backupErr != nil {
+				_ = os.Remove(output)
+				return backupErr
+			}
+			fmt.Printf("Backup written to %s (%s, schema version %d)\n", output, result.Manifest.Driver, result.Manifest.SchemaVersion)
+			printBackupCounts(result)
+			return nil
+		},
+	}
+
+	backupCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file")
+	backupCmd.Flags().StringVarP(&output, "output", "o", "", "archive path (default: gkbxsrv-<timestamp>.jsonl.gz)")
+	backupCmd.Flags().StringVarP(&connection, "connection", "n", "", "named connection to back up (default: the primary)")
+	backupCmd.Flags().StringSliceVarP(&modelNames, "models", "m", nil, "models to back up (default: all)")
+
+	return backupCmd
+}
+
+func RestoreCommand() *cobra.Command {
+	var connection string
+	var clean, force, skipMigrate bool
+
+	var restoreExp = []string{
+		"gkbxsrv database restore gkbxsrv-20250101-120000.jsonl.gz",
+		"gkbxsrv database restore kubex.jsonl.gz --clean",
+		"gkbxsrv database restore kubex.jsonl.gz --connection=laptop",
+	}
+
+	restoreCmd := &cobra.Command{
+		Use:         "restore <archive>",
+		Aliases:     []string{"load", "import"},
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(restoreExp),
+		Annotations: getDescriptions([]string{"Restore a backup archive into the database, whatever its driver.", "Restore backup"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, dbErr := backupConnection(connection)
+			if dbErr != nil {
+				return dbErr
+			}
+			if !skipMigrate {
+				dbaseObj := databases.NewDatabaseService(configFile)
+				if _, migrateErr := databases.NewMigrator(db, dbaseObj.MigrationsDir()).Up(0); migrateErr != nil {
+					return migrateErr
+				}
+			}
+			file, openErr := os.Open(args[0])
+			if openErr != nil {
+				return fmt.Errorf("error opening backup file: %v", openErr)
+			}
+			defer file.Close()
+			result, restoreErr := databases.Restore(db, file, databases.RestoreOptions{Clean: clean, Force: force})
+			if restoreErr != nil {
+				return restoreErr
+			}
+			fmt.Printf("Restored %s (%s, schema version %d, created %s)\n", args[0], result.Manifest.Driver,
+				result.Manifest.SchemaVersion, result.Manifest.CreatedAt.Local().Format(time.RFC3339))
+			printBackupCounts(result)
+			return nil
+		},
+	}
+
+	restoreCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file")
+	restoreCmd.Flags().StringVarP(&connection, "connection", "n", "", "named connection to restore into (default: the primary)")
+	restoreCmd.Flags().BoolVar(&clean, "clean", false, "delete the existing rows of the archived models first")
+	restoreCmd.Flags().BoolVar(&force, "force", false, "restore an archive newer than the database schema")
+	restoreCmd.Flags().BoolVar(&skipMigrate, "skip-migrate", false, "don't apply pending migrations before restoring")
+
+	return restoreCmd
+}
+
+func backupConnection(connection string) (*gorm.DB, error) {
+	dbaseObj := databases.NewDatabaseService(configFile)
+	return dbaseObj.GetConnection(connection)
+}
+
+func printBackupCounts(result databases.BackupResult) {
+	names := make([]string, 0, len(result.Counts))
+	for name := range result.Counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := make([]string, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, fmt.Sprintf("%s=%d", name, result.Counts[name]))
+	}
+	fmt.Printf("Rows: %s\n", strings.Join(rows, ", "))
+}
@@ -1,7 +1,10 @@
 package cli
 
 import (
+	"context"
+	"fmt"
 	"github.com/faelmori/gkbxsrv/internal/services"
+	gmodels "github.com/faelmori/gkbxsrv/models"
 	databases "github.com/faelmori/gkbxsrv/services"
 	l "github.com/faelmori/logz"
 	"github.com/spf13/cobra"
@@ -9,6 +12,7 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 )
 
 func BrokerCommands() []*cobra.Command {
@@ -28,10 +32,20 @@ func brokerCommand() *cobra.Command {
 
 	var brokerExp = []string{
 		"gkbxsrv broker start --config='config.json'",
+		"gkbxsrv broker --capture='broker.capture.jsonl'",
+		"gkbxsrv broker replay --file='broker.capture.jsonl' --speed=2 --diff",
+		"gkbxsrv broker --rpc=':5556'",
+		"gkbxsrv broker --rpc=':5556' --rpc-anonymous-reads",
+		"gkbxsrv broker --ws=':5557'",
 		"gkbxsrv broker stop",
 	}
 
 	var ws sync.WaitGroup
+	var dbService databases.DatabaseService
+	var capture string
+	var rpcAddr string
+	var rpcAnonymousReads bool
+	var wsAddr string
 
 	cmd := &cobra.Command{
 		Use:     "broker",
@@ -51,7 +65,8 @@ func brokerCommand() *cobra.Command {
 				l.GetLogger("GKBXSrv").Info("Starting broker...", map[string]interface{}{"configFile": configFile, "host": host, "port": port})
 
 				///if _, brkErr := services.NewBrokerConn(port); brkErr != nil {
-				if _, brkErr := services.NewBroker(true); brkErr != nil {
+				broker, brkErr := services.NewBrokerWithCapture(true, capture)
+				if brkErr != nil {
 					l.GetLogger("GKBXSrv").Fatalln("Error starting broker", map[string]interface{}{
 						"context":  "gkbxsrv",
 						"action":   "broker",
@@ -63,11 +78,74 @@ func brokerCommand() *cobra.Command {
 					chanSig <- syscall.SIGTERM
 					return
 				}
+
+				// One database service backs the token service, JSON-RPC and the supervisor.
+				dbService = databases.NewDatabaseService(configFile)
+
+				// JSON-RPC requests only get a tenant from a token validated by this service.
+				tokens, _, _, tokensErr := gmodels.LoadTokenCfg(nil, nil, nil, dbService)
+				if tokensErr != nil {
+					l.GetLogger("GKBXSrv").Warn("JSON-RPC authentication disabled: token service not available", map[string]interface{}{
+						"context": "gkbxsrv",
+						"action":  "broker",
+						"error":   tokensErr.Error(),
+					})
+					tokens = nil
+				} else {
+					broker.RPC().SetTokens(tokens)
+					broker.RPC().SetAnonymousReads(rpcAnonymousReads)
+				}
+
+				// JSON-RPC payloads reach the broker socket whether or not it is also served over HTTP.
+				if db, dbErr := dbService.GetDB(); dbErr != nil {
+					l.GetLogger("GKBXSrv").Warn("JSON-RPC model methods disabled: database not available", map[string]interface{}{
+						"context": "gkbxsrv",
+						"action":  "broker",
+						"error":   dbErr.Error(),
+					})
+				} else {
+					broker.RPC().SetDB(db)
+				}
+				// The supervisor keeps the connection of the broker up until it is stopped.
+				if supErr := dbService.StartSupervisor(context.Background()); supErr != nil {
+					l.GetLogger("GKBXSrv").Warn("Database health supervisor not started", map[string]interface{}{
+						"context": "gkbxsrv",
+						"action":  "broker",
+						"error":   supErr.Error(),
+					})
+				}
+
+				if rpcAddr != "" {
+					go func() {
+						if rpcErr := broker.RPC().ListenAndServe(rpcAddr); rpcErr != nil {
+							l.GetLogger("GKBXSrv").Error("Error serving JSON-RPC", map[string]interface{}{
+								"context": "gkbxsrv",
+								"action":  "broker",
+								"error":   rpcErr.Error(),
+								"address": rpcAddr,
+							})
+						}
+					}()
+				}
+
+				if wsAddr != "" {
+					if wsErr := startWSGateway(broker, tokens, wsAddr); wsErr != nil {
+						l.GetLogger("GKBXSrv").Error("Error starting WebSocket gateway", map[string]interface{}{
+							"context": "gkbxsrv",
+							"action":  "broker",
+							"error":   wsErr.Error(),
+							"address": wsAddr,
+						})
+					}
+				}
 			}()
 			l.GetLogger("GKBXSrv").Info("Broker started successfully!", nil)
 
 			<-chanSig
 			ws.Wait()
+			if dbService != nil {
+				dbService.StopSupervisor()
+			}
 			return nil
 		},
 	}
@@ -75,6 +153,87 @@ func brokerCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&configFile, "config", "c", defaultConfitFile, "config file")
 	cmd.Flags().StringVarP(&host, "host", "H", "", "host")
 	cmd.Flags().StringVarP(&port, "port", "P", "5555", "port")
+	cmd.Flags().StringVarP(&capture, "capture", "C", "", "append every frame crossing the broker to this capture file")
+	cmd.Flags().StringVarP(&rpcAddr, "rpc", "R", "", "also serve JSON-RPC 2.0 over HTTP on this address (path /rpc)")
+	cmd.Flags().BoolVar(&rpcAnonymousReads, "rpc-anonymous-reads", false, "let JSON-RPC requests without a token read (ping, get, list and query)")
+	cmd.Flags().StringVarP(&wsAddr, "ws", "W", "", "also serve the WebSocket gateway on this address (path /ws)")
+
+	cmd.AddCommand(brokerReplayCommand())
+
+	return cmd
+}
+
+func startWSGateway(broker *services.BrokerImpl, tokens gmodels.TokenService, addr string) error {
+	if tokens == nil {
+		return fmt.Errorf("token service not available")
+	}
+	client, clientErr := services.NewBrokerClient(broker.Address())
+	if clientErr != nil {
+		return clientErr
+	}
+	gateway, gatewayErr := services.NewWSGateway(client, broker.Events(), tokens)
+	if gatewayErr != nil {
+		_ = client.Close()
+		return gatewayErr
+	}
+	go func() {
+		if serveErr := gateway.ListenAndServe(addr); serveErr != nil {
+			l.GetLogger("GKBXSrv").Error("Error serving WebSocket gateway", map[string]interface{}{
+				"context": "gkbxsrv",
+				"action":  "broker",
+				"error":   serveErr.Error(),
+				"address": addr,
+			})
+		}
+		_ = client.Close()
+	}()
+	return nil
+}
+
+func brokerReplayCommand() *cobra.Command {
+	var file, address string
+	var speed float64
+	var diff bool
+	var wait time.Duration
+
+	cmd := &cobra.Command{
+		Use:     "replay",
+		Aliases: []string{"rpl"},
+		Short:   "Replay a broker capture file",
+		Long:    "Resend the client messages of a capture file against a broker, optionally diffing the replies",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if file == "" {
+				if len(args) == 0 {
+					return fmt.Errorf("capture file is required")
+				}
+				file = args[0]
+			}
+			result, replayErr := services.ReplayCapture(file, services.ReplayOptions{
+				Address: address,
+				Speed:   speed,
+				Diff:    diff,
+				Wait:    wait,
+				Output:  cmd.OutOrStdout(),
+			})
+			if replayErr != nil {
+				return replayErr
+			}
+			fmt.Printf("Sent: %d, received: %d\n", result.Sent, result.Received)
+			if diff {
+				fmt.Printf("Recorded replies: %d, mismatched: %d\n", result.Expected, result.Mismatched)
+				if result.Mismatched > 0 {
+					return fmt.Errorf("%d replies differ from the capture", result.Mismatched)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "capture file to replay")
+	cmd.Flags().StringVarP(&address, "address", "a", "tcp://127.0.0.1:5555", "broker address")
+	cmd.Flags().Float64VarP(&speed, "speed", "s", 1, "replay speed factor (1 = original timing, 0 = no delays)")
+	cmd.Flags().BoolVarP(&diff, "diff", "d", false, "compare replies with the recorded ones")
+	cmd.Flags().DurationVarP(&wait, "wait", "w", 2*time.Second, "time to wait for replies after the last message")
 
 	return cmd
 }
@@ -0,0 +1,362 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/faelmori/gkbxsrv/models"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func CatalogCmdsList() []*cobra.Command {
+	return []*cobra.Command{
+		catalogCategoriesCommand(),
+		catalogCategoryAddCommand(),
+		catalogCategoryMoveCommand(),
+		catalogCategoryRemoveCommand(),
+		catalogCategoryProductsCommand(),
+		catalogVariantsCommand(),
+		catalogVariantAddCommand(),
+		catalogBarcodeCommand(),
+	}
+}
+
+func catalogCategoriesCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var categoriesExp = []string{
+		"gkbxsrv catalog categories",
+		"gkbxsrv catalog categories --json",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "categories",
+		Aliases:     []string{"tree"},
+		Example:     concatenateExamples(categoriesExp),
+		Annotations: getDescriptions([]string{"Show the category tree.", "Category tree"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			tree, treeErr := models.NewCategoryRepo(session).Tree()
+			if treeErr != nil {
+				return treeErr
+			}
+			return flags.print(tree, func(w *tabwriter.Writer) {
+				_, _ = fmt.Fprintln(w, "ID\tCATEGORY")
+				printCategoryTree(w, tree, 0)
+			})
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func catalogCategoryAddCommand() *cobra.Command {
+	var flags inventoryFlags
+	var parentID uint
+	var description string
+
+	var categoryAddExp = []string{
+		"gkbxsrv catalog category-add Clothing",
+		"gkbxsrv catalog category-add Shirts --parent=1",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "category-add <name>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(categoryAddExp),
+		Annotations: getDescriptions([]string{"Add a category, at the top level or under a parent.", "Add a category"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			category := &models.Category{Name: args[0], Description: description}
+			if parentID != 0 {
+				category.ParentID = &parentID
+			}
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			if createErr := session.Create(category).Error; createErr != nil {
+				return createErr
+			}
+			return flags.print(category, func(w *tabwriter.Writer) {
+				printCategories(w, []*models.Category{category})
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().UintVarP(&parentID, "parent", "p", 0, "parent category")
+	cmd.Flags().StringVar(&description, "description", "", "description of the category")
+
+	return cmd
+}
+
+func catalogCategoryMoveCommand() *cobra.Command {
+	var flags inventoryFlags
+	var parentID uint
+
+	var categoryMoveExp = []string{
+		"gkbxsrv catalog category-move 4 --parent=2",
+		"gkbxsrv catalog category-move 4",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "category-move <category>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(categoryMoveExp),
+		Annotations: getDescriptions([]string{"Move a category with its subcategories under another parent, or to the top level without --parent.", "Move a category"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, parseErr := strconv.ParseUint(args[0], 10, 64)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing category: %v", parseErr)
+			}
+			var parent *uint
+			if parentID != 0 {
+				parent = &parentID
+			}
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			category, moveErr := models.NewCategoryRepo(session).Move(uint(id), parent)
+			if moveErr != nil {
+				return moveErr
+			}
+			return flags.print(category, func(w *tabwriter.Writer) {
+				printCategories(w, []*models.Category{category})
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().UintVarP(&parentID, "parent", "p", 0, "new parent category (default: the top level)")
+
+	return cmd
+}
+
+func catalogCategoryRemoveCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var categoryRemoveExp = []string{
+		"gkbxsrv catalog category-remove 4",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "category-remove <category>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(categoryRemoveExp),
+		Annotations: getDescriptions([]string{"Remove a category without subcategories or products.", "Remove a category"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, parseErr := strconv.ParseUint(args[0], 10, 64)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing category: %v", parseErr)
+			}
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			if deleteErr := models.NewCategoryRepo(session).Delete(uint(id)); deleteErr != nil {
+				return deleteErr
+			}
+			fmt.Printf("Removed category %d\n", id)
+			return nil
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func catalogCategoryProductsCommand() *cobra.Command {
+	var flags inventoryFlags
+	var subcategories bool
+
+	var categoryProductsExp = []string{
+		"gkbxsrv catalog category-products 1 --subcategories",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "category-products <category>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(categoryProductsExp),
+		Annotations: getDescriptions([]string{"List the products of a category, and of the categories below it with --subcategories.", "Category products"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, parseErr := strconv.ParseUint(args[0], 10, 64)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing category: %v", parseErr)
+			}
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			products, findErr := models.NewProductRepo(session).FindAllInCategory(uint(id), subcategories)
+			if findErr != nil {
+				return findErr
+			}
+			return flags.print(products, func(w *tabwriter.Writer) {
+				_, _ = fmt.Fprintln(w, "ID\tNAME\tCATEGORY\tPRICE")
+				for _, product := range products {
+					categoryID := ""
+					if product.CategoryID != nil {
+						categoryID = strconv.Itoa(int(*product.CategoryID))
+					}
+					_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%.2f\n", product.ID, product.Name, categoryID, product.Price)
+				}
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().BoolVarP(&subcategories, "subcategories", "s", false, "include the products of the categories below")
+
+	return cmd
+}
+
+func catalogVariantsCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var variantsExp = []string{
+		"gkbxsrv catalog variants 42",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "variants <product>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(variantsExp),
+		Annotations: getDescriptions([]string{"List the variants of a product.", "Product variants"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			productID, parseErr := strconv.ParseUint(args[0], 10, 64)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing product: %v", parseErr)
+			}
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			variants, findErr := models.NewProductVariantRepo(session).FindAllByProduct(uint(productID))
+			if findErr != nil {
+				return findErr
+			}
+			return flags.print(variants, func(w *tabwriter.Writer) {
+				printVariants(w, variants)
+			})
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func catalogVariantAddCommand() *cobra.Command {
+	var flags inventoryFlags
+	var variant models.ProductVariant
+
+	var variantAddExp = []string{
+		"gkbxsrv catalog variant-add 42 TSHIRT-M-RED --size=M --color=red --barcode=4006381333931",
+		"gkbxsrv catalog variant-add 42 TSHIRT-L-RED --size=L --color=red --barcode=036000291452 --price=21.90",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "variant-add <product> <sku>",
+		Args:        cobra.ExactArgs(2),
+		Example:     concatenateExamples(variantAddExp),
+		Annotations: getDescriptions([]string{"Add a variant to a product, with its SKU and an optional EAN-13 or UPC-A barcode.", "Add a variant"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			productID, parseErr := strconv.ParseUint(args[0], 10, 64)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing product: %v", parseErr)
+			}
+			variant.ProductID, variant.SKU = uint(productID), args[1]
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			if createErr := session.Create(&variant).Error; createErr != nil {
+				return createErr
+			}
+			return flags.print(&variant, func(w *tabwriter.Writer) {
+				printVariants(w, []*models.ProductVariant{&variant})
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&variant.Name, "name", "", "name of the variant")
+	cmd.Flags().StringVar(&variant.Size, "size", "", "size of the variant")
+	cmd.Flags().StringVar(&variant.Color, "color", "", "colour of the variant")
+	cmd.Flags().StringVarP(&variant.Barcode, "barcode", "b", "", "EAN-13 or UPC-A barcode of the variant")
+	cmd.Flags().Float64Var(&variant.Price, "price", 0, "price of the variant (default: the price of the product)")
+
+	return cmd
+}
+
+func catalogBarcodeCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var barcodeExp = []string{
+		"gkbxsrv catalog barcode 4006381333931",
+		"gkbxsrv catalog barcode 036000291452 --json",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "barcode <code>",
+		Aliases:     []string{"scan"},
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(barcodeExp),
+		Annotations: getDescriptions([]string{"Find the variant of an EAN-13 or UPC-A barcode, with its product and its stock in every warehouse.", "Find by barcode"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			variant, findErr := models.NewProductVariantRepo(session).FindByBarcode(args[0])
+			if findErr != nil {
+				return findErr
+			}
+			product, productErr := models.NewProductRepo(session).FindOne("id = ?", variant.ProductID)
+			if productErr != nil {
+				return productErr
+			}
+			stock, stockErr := databases.NewInventoryService(session).VariantStock(session.Statement.Context, variant.ID, "")
+			if stockErr != nil {
+				return stockErr
+			}
+			result := map[string]interface{}{"variant": variant, "product": product, "stock": stock}
+			return flags.print(result, func(w *tabwriter.Writer) {
+				_, _ = fmt.Fprintf(w, "Product %d: %s\n\n", product.ID, product.Name)
+				printVariants(w, []*models.ProductVariant{variant})
+				_, _ = fmt.Fprintln(w)
+				printStock(w, stock)
+			})
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func printCategoryTree(w *tabwriter.Writer, nodes []*models.CategoryNode, depth int) {
+	for _, node := range nodes {
+		_, _ = fmt.Fprintf(w, "%d\t%s%s\n", node.ID, strings.Repeat("  ", depth), node.Name)
+		printCategoryTree(w, node.Children, depth+1)
+	}
+}
+
+func printCategories(w *tabwriter.Writer, categories []*models.Category) {
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tPARENT\tPATH")
+	for _, category := range categories {
+		parentID := ""
+		if category.ParentID != nil {
+			parentID = strconv.Itoa(int(*category.ParentID))
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", category.ID, category.Name, parentID, category.Path)
+	}
+}
+
+func printVariants(w *tabwriter.Writer, variants []*models.ProductVariant) {
+	_, _ = fmt.Fprintln(w, "ID\tSKU\tNAME\tSIZE\tCOLOR\tBARCODE\tPRICE")
+	for _, variant := range variants {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%.2f\n", variant.ID, variant.SKU, variant.Name, variant.Size, variant.Color, models.FormatBarcode(variant.Barcode, variant.BarcodeType), variant.Price)
+	}
+}
@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/faelmori/gkbxsrv/models"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+func InventoryCountCommand() *cobra.Command {
+	countCmd := &cobra.Command{
+		Use:         "count",
+		Aliases:     []string{"counts", "cc"},
+		Annotations: getDescriptions([]string{"Cycle counts: freeze the stock of a warehouse, enter the counted quantities, review the variances and post them as adjustments.", "Cycle counts"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("you must specify a subcommand")
+		},
+	}
+
+	countCmd.AddCommand(countCommands()...)
+
+	return countCmd
+}
+
+func countCommands() []*cobra.Command {
+	return []*cobra.Command{
+		countStartCommand(),
+		countRecordCommand(),
+		countImportCommand(),
+		countReportCommand(),
+		countPostCommand(),
+		countCancelCommand(),
+		countListCommand(),
+	}
+}
+
+func countStartCommand() *cobra.Command {
+	var flags inventoryFlags
+	var in databases.CountStartInput
+
+	var startExp = []string{
+		"gkbxsrv inventory count start <warehouse-id> --reference=CC-2025-03",
+		"gkbxsrv inventory count start <warehouse-id> --product=<product-id> --product=<product-id>",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "start <warehouse>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(startExp),
+		Annotations: getDescriptions([]string{"Start a count of a warehouse, freezing the expected quantities of its products.", "Start a count"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in.WarehouseID = args[0]
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			svc := databases.NewCountService(session)
+			started, startErr := svc.Start(session.Statement.Context, in)
+			if startErr != nil {
+				return startErr
+			}
+			report, reportErr := svc.Report(session.Statement.Context, started.ID)
+			if reportErr != nil {
+				return reportErr
+			}
+			return flags.print(report, func(w *tabwriter.Writer) {
+				printCountReport(w, report)
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringArrayVarP(&in.ProductIDs, "product", "p", nil, "count only these products (default: every product of the warehouse)")
+	cmd.Flags().StringVarP(&in.Reference, "reference", "r", "", "reference of the count, recorded on its adjustments")
+	cmd.Flags().StringVar(&in.Notes, "notes", "", "notes on the count")
+
+	return cmd
+}
+
+func countRecordCommand() *cobra.Command {
+	var flags inventoryFlags
+	var count databases.CountInput
+	var byBarcode bool
+
+	var recordExp = []string{
+		"gkbxsrv inventory count record <session-id> <product-id> 12",
+		"gkbxsrv inventory count record <session-id> <product-id> 0 --reason='not found on shelf'",
+		"gkbxsrv inventory count record <session-id> 4006381333931 3 --barcode",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "record <session> <product|barcode> <counted>",
+		Args:        cobra.ExactArgs(3),
+		Example:     concatenateExamples(recordExp),
+		Annotations: getDescriptions([]string{"Enter the counted quantity of a product; counting it again replaces the count.", "Record a count"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			counted, parseErr := strconv.ParseFloat(args[2], 64)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing counted quantity: %v", parseErr)
+			}
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			count.Counted = counted
+			if byBarcode {
+				count.Barcode = args[1]
+			} else {
+				count.ProductID = args[1]
+			}
+			lines, recordErr := databases.NewCountService(session).Record(session.Statement.Context, args[0], []databases.CountInput{count})
+			if recordErr != nil {
+				return recordErr
+			}
+			return flags.print(lines, func(w *tabwriter.Writer) {
+				printCountLines(w, lines)
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&count.VariantID, "variant", "", "variant of the product counted")
+	cmd.Flags().BoolVar(&byBarcode, "barcode", false, "the product is given by the barcode of its variant")
+	cmd.Flags().StringVar(&count.Reason, "reason", "", "reason of the variance, recorded on its adjustment")
+
+	return cmd
+}
+
+func countImportCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var importExp = []string{
+		"gkbxsrv inventory count import <session-id> counts.csv",
+		"cat counts.csv | gkbxsrv inventory count import <session-id> -",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "import <session> <file.csv>",
+		Args:        cobra.ExactArgs(2),
+		Example:     concatenateExamples(importExp),
+		Annotations: getDescriptions([]string{"Enter counts from a CSV file of product_id,counted[,reason] records, or with a header naming product_id or barcode, counted, and optionally variant_id and reason; '-' reads stdin.", "Import counts"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var input io.Reader = os.Stdin
+			if args[1] != "-" {
+				file, openErr := os.Open(args[1])
+				if openErr != nil {
+					return fmt.Errorf("error opening counts file: %v", openErr)
+				}
+				defer func(file *os.File) {
+					_ = file.Close()
+				}(file)
+				input = file
+			}
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			lines, importErr := databases.NewCountService(session).Import(session.Statement.Context, args[0], input)
+			if importErr != nil {
+				return importErr
+			}
+			return flags.print(lines, func(w *tabwriter.Writer) {
+				printCountLines(w, lines)
+				_, _ = fmt.Fprintf(w, "\n%d counts recorded\n", len(lines))
+			})
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func countReportCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var reportExp = []string{
+		"gkbxsrv inventory count report <session-id>",
+		"gkbxsrv inventory count report <session-id> --json",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "report <session>",
+		Aliases:     []string{"variances"},
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(reportExp),
+		Annotations: getDescriptions([]string{"Show the expected and counted quantities of a count and their variances.", "Variance report"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			report, reportErr := databases.NewCountService(session).Report(session.Statement.Context, args[0])
+			if reportErr != nil {
+				return reportErr
+			}
+			return flags.print(report, func(w *tabwriter.Writer) {
+				printCountReport(w, report)
+			})
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func countPostCommand() *cobra.Command {
+	var flags inventoryFlags
+	var actor string
+	var in databases.CountPostInput
+
+	var postExp = []string{
+		"gkbxsrv inventory count post <session-id>",
+		"gkbxsrv inventory count post <session-id> --zero-uncounted --reason='annual count' --actor=alice",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "post <session>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(postExp),
+		Annotations: getDescriptions([]string{"Post the variances of a count as inventory adjustments and close it.", "Post a count"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			ctx := session.Statement.Context
+			if actor != "" {
+				ctx = databases.WithActor(ctx, actor)
+			}
+			report, postErr := databases.NewCountService(session).Post(ctx, args[0], in)
+			if postErr != nil {
+				return postErr
+			}
+			return flags.print(report, func(w *tabwriter.Writer) {
+				printCountReport(w, report)
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&in.Reason, "reason", "", "reason of the adjustments whose counts give none (default: "+databases.CountAdjustmentReason+")")
+	cmd.Flags().BoolVar(&in.ZeroUncounted, "zero-uncounted", false, "take the products that were not counted as counted at zero")
+	cmd.Flags().StringVar(&actor, "actor", "", "who posts the count")
+
+	return cmd
+}
+
+func countCancelCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var cancelExp = []string{
+		"gkbxsrv inventory count cancel <session-id>",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "cancel <session>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(cancelExp),
+		Annotations: getDescriptions([]string{"Cancel an open count without adjusting the stock.", "Cancel a count"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			cancelled, cancelErr := databases.NewCountService(session).Cancel(session.Statement.Context, args[0])
+			if cancelErr != nil {
+				return cancelErr
+			}
+			return flags.print(cancelled, func(w *tabwriter.Writer) {
+				printCountSessions(w, []*models.CountSession{cancelled})
+			})
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func countListCommand() *cobra.Command {
+	var flags inventoryFlags
+	var warehouseID, status string
+
+	var listExp = []string{
+		"gkbxsrv inventory count list --warehouse=<warehouse-id> --status=open",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "list",
+		Aliases:     []string{"ls", "sessions"},
+		Example:     concatenateExamples(listExp),
+		Annotations: getDescriptions([]string{"List the count sessions, newest first.", "List counts"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			sessions, listErr := databases.NewCountService(session).Sessions(session.Statement.Context, warehouseID, models.CountStatus(status))
+			if listErr != nil {
+				return listErr
+			}
+			return flags.print(sessions, func(w *tabwriter.Writer) {
+				printCountSessions(w, sessions)
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVarP(&warehouseID, "warehouse", "w", "", "sessions of this warehouse")
+	cmd.Flags().StringVar(&status, "status", "", "sessions in this status: open, posted or cancelled")
+
+	return cmd
+}
+
+func printCountSessions(w *tabwriter.Writer, sessions []*models.CountSession) {
+	_, _ = fmt.Fprintln(w, "ID\tWAREHOUSE\tSTATUS\tREFERENCE\tSTARTED\tPOSTED")
+	for _, session := range sessions {
+		posted := ""
+		if session.PostedAt != nil {
+			posted = session.PostedAt.Local().Format(time.DateTime)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", session.ID, session.WarehouseID, session.Status, session.Reference, session.CreatedAt.Local().Format(time.DateTime), posted)
+	}
+}
+
+func printCountLines(w *tabwriter.Writer, lines []*models.CountLine) {
+	_, _ = fmt.Fprintln(w, "PRODUCT\tVARIANT\tEXPECTED\tCOUNTED\tVARIANCE\tREASON")
+	for _, line := range lines {
+		counted, variance := "-", ""
+		if line.IsCounted() {
+			counted, variance = fmt.Sprintf("%.3f", *line.Counted), fmt.Sprintf("%+.3f", line.Variance)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%.3f\t%s\t%s\t%s\n", line.ProductID, line.VariantID, line.Expected, counted, variance, line.Reason)
+	}
+}
+
+func printCountReport(w *tabwriter.Writer, report *databases.CountReport) {
+	printCountSessions(w, []*models.CountSession{report.Session})
+	_, _ = fmt.Fprintln(w)
+	printCountLines(w, report.Lines)
+	_, _ = fmt.Fprintf(w, "\n%d counted, %d not counted, %d with variance (%.1f%% accurate)\n", report.Counted, report.Uncounted, report.Variances, report.Accuracy*100)
+	_, _ = fmt.Fprintf(w, "shortage %.3f, overage %+.3f, net %+.3f\n", report.Shortage, report.Overage, report.NetVariance)
+}
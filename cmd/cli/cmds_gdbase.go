@@ -32,6 +32,11 @@ func gdbaseCommand() *cobra.Command {
 	var gdbaseExp = []string{
 		"gkbxsrv database auth --username=foo --password=bar --host=localhost --port=5432 --database=kubex_db",
 		"gkbxsrv database user add-user --username='foo' --password='bar' --name='Foo' --email='foo@bar.com'",
+		"gkbxsrv database migrate status",
+		"gkbxsrv database stats --watch=5s",
+		"gkbxsrv database backup --output=kubex.jsonl.gz",
+		"gkbxsrv database seed --init",
+		"gkbxsrv database audit product 42",
 	}
 
 	cmd := &cobra.Command{
@@ -81,6 +86,12 @@ func gdbaseCommand() *cobra.Command {
 	cmd = AuthenticationRootCommand(cmd)
 	cmd.AddCommand(UserRootCommand())
 	cmd.AddCommand(RolesRootCommand())
+	cmd.AddCommand(MigrateRootCommand())
+	cmd.AddCommand(StatsCommand())
+	cmd.AddCommand(BackupCommand())
+	cmd.AddCommand(RestoreCommand())
+	cmd.AddCommand(SeedCommand())
+	cmd.AddCommand(AuditCommand())
 
 	return cmd
 }
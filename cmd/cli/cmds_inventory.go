@@ -0,0 +1,402 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/models"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/goccy/go-json"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+func InventoryCmdsList() []*cobra.Command {
+	return []*cobra.Command{
+		inventoryStockCommand(),
+		inventoryLedgerCommand(),
+		inventoryMoveCommand("receive", "Receive stock of a product into a warehouse.", "Receive stock"),
+		inventoryMoveCommand("issue", "Issue stock of a product from a warehouse.", "Issue stock"),
+		inventoryMoveCommand("adjust", "Adjust the stock of a product in a warehouse by a signed quantity; a reason is required.", "Adjust stock"),
+		inventoryTransferCommand(),
+		inventoryReserveCommand(),
+		inventoryReleaseCommand(),
+		inventoryExpireCommand(),
+		InventoryCountCommand(),
+	}
+}
+
+// inventoryFlags are the flags shared by the inventory commands.
+type inventoryFlags struct {
+	connection string
+	tenant     string
+	asJSON     bool
+}
+
+func (f *inventoryFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file")
+	cmd.Flags().StringVarP(&f.connection, "connection", "n", "", "named connection to use (default: the primary)")
+	cmd.Flags().StringVarP(&f.tenant, "tenant", "t", "", "tenant of the stock (default: every tenant)")
+	cmd.Flags().BoolVarP(&f.asJSON, "json", "j", false, "print the result as JSON")
+}
+
+func (f *inventoryFlags) service() (databases.InventoryService, context.Context, error) {
+	session, sessionErr := f.session()
+	if sessionErr != nil {
+		return nil, nil, sessionErr
+	}
+	return databases.NewInventoryService(session), session.Statement.Context, nil
+}
+
+// session opens the connection in the tenant of the flags.
+func (f *inventoryFlags) session() (*gorm.DB, error) {
+	db, dbErr := backupConnection(f.connection)
+	if dbErr != nil {
+		return nil, dbErr
+	}
+	return tenantSession(db, f.tenant), nil
+}
+
+func (f *inventoryFlags) print(v interface{}, table func(w *tabwriter.Writer)) error {
+	if f.asJSON {
+		data, marshalErr := json.MarshalIndent(v, "", "  ")
+		if marshalErr != nil {
+			return marshalErr
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func inventoryStockCommand() *cobra.Command {
+	var flags inventoryFlags
+	var variantID string
+
+	var stockExp = []string{
+		"gkbxsrv inventory stock <product-id> <warehouse-id>",
+		"gkbxsrv inventory stock <product-id> <warehouse-id> --tenant=acme --json",
+		"gkbxsrv inventory stock <product-id> <warehouse-id> --variant=<variant-id>",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "stock <product> <warehouse>",
+		Args:        cobra.ExactArgs(2),
+		Example:     concatenateExamples(stockExp),
+		Annotations: getDescriptions([]string{"Show the on-hand, reserved and available quantities of a product in a warehouse.", "Show stock"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			svc, ctx, svcErr := flags.service()
+			if svcErr != nil {
+				return svcErr
+			}
+			var inventories []*models.Inventory
+			if variantID == "" {
+				inv, stockErr := svc.Stock(ctx, args[0], args[1])
+				if stockErr != nil {
+					return stockErr
+				}
+				inventories = []*models.Inventory{inv}
+			} else {
+				var stockErr error
+				if inventories, stockErr = svc.VariantStock(ctx, variantID, args[1]); stockErr != nil {
+					return stockErr
+				}
+				if len(inventories) == 0 || inventories[0].ProductID != args[0] {
+					return fmt.Errorf("no stock of variant %s of product %s in warehouse %s", variantID, args[0], args[1])
+				}
+			}
+			return flags.print(inventories[0], func(w *tabwriter.Writer) {
+				printStock(w, inventories)
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&variantID, "variant", "", "stock of this variant of the product")
+
+	return cmd
+}
+
+func inventoryLedgerCommand() *cobra.Command {
+	var flags inventoryFlags
+	var filters, sortKeys []string
+	var query models.Query
+
+	var ledgerExp = []string{
+		"gkbxsrv inventory ledger --filter 'product_id=<product-id>'",
+		"gkbxsrv inventory ledger --filter 'movement_type:in=transfer_out,transfer_in' --limit=50",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "ledger",
+		Aliases:     []string{"movements"},
+		Example:     concatenateExamples(ledgerExp),
+		Annotations: getDescriptions([]string{"Show the inventory movements, newest first.", "Inventory ledger"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsed, parseErr := models.ParseFilters(filters)
+			if parseErr != nil {
+				return parseErr
+			}
+			query.Filters, query.Sort = parsed, sortKeys
+
+			svc, ctx, svcErr := flags.service()
+			if svcErr != nil {
+				return svcErr
+			}
+			page, ledgerErr := svc.Ledger(ctx, query)
+			if ledgerErr != nil {
+				return ledgerErr
+			}
+			return flags.print(page, func(w *tabwriter.Writer) {
+				printMovements(w, page.Items)
+				if page.NextCursor != "" {
+					_, _ = fmt.Fprintf(w, "\n%d of %d movements; more with --cursor=%s\n", len(page.Items), page.Total, page.NextCursor)
+				}
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringArrayVarP(&filters, "filter", "f", nil, "filter on the movements, e.g. 'warehouse_id=<id>' or 'created_at>=2025-01-01'")
+	cmd.Flags().StringSliceVarP(&sortKeys, "sort", "s", nil, "sort keys, '-' for descending (default: -created_at)")
+	cmd.Flags().IntVarP(&query.Limit, "limit", "l", models.DefaultPageSize, "movements per page")
+	cmd.Flags().StringVar(&query.Cursor, "cursor", "", "next_cursor of the previous page")
+
+	return cmd
+}
+
+func inventoryMoveCommand(name, description, short string) *cobra.Command {
+	var flags inventoryFlags
+	var in databases.StockInput
+
+	var moveExp = []string{
+		fmt.Sprintf("gkbxsrv inventory %s <product-id> <warehouse-id> 10 --reference=PO-1001", name),
+	}
+	if name == "adjust" {
+		moveExp = []string{"gkbxsrv inventory adjust <product-id> <warehouse-id> -- -2 --reason='damaged in handling'"}
+	}
+
+	cmd := &cobra.Command{
+		Use:         name + " <product> <warehouse> <quantity>",
+		Args:        cobra.ExactArgs(3),
+		Example:     concatenateExamples(moveExp),
+		Annotations: getDescriptions([]string{description, short}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			quantity, parseErr := strconv.ParseFloat(args[2], 64)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing quantity: %v", parseErr)
+			}
+			in.ProductID, in.WarehouseID, in.Quantity = args[0], args[1], quantity
+
+			svc, ctx, svcErr := flags.service()
+			if svcErr != nil {
+				return svcErr
+			}
+			var movement *models.InventoryMovement
+			var moveErr error
+			switch name {
+			case "receive":
+				movement, moveErr = svc.Receive(ctx, in)
+			case "issue":
+				movement, moveErr = svc.Issue(ctx, in)
+			default:
+				movement, moveErr = svc.Adjust(ctx, in)
+			}
+			if moveErr != nil {
+				return moveErr
+			}
+			return flags.print(movement, func(w *tabwriter.Writer) {
+				printMovements(w, []*models.InventoryMovement{movement})
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&in.VariantID, "variant", "", "variant of the product whose stock moves")
+	cmd.Flags().StringVarP(&in.Reference, "reference", "r", "", "reference document, e.g. a purchase order")
+	cmd.Flags().StringVar(&in.Reason, "reason", "", "reason of the movement")
+	cmd.Flags().BoolVar(&in.AllowNegative, "allow-negative", false, "let the stock go below zero")
+
+	return cmd
+}
+
+func inventoryTransferCommand() *cobra.Command {
+	var flags inventoryFlags
+	var in databases.TransferInput
+
+	var transferExp = []string{
+		"gkbxsrv inventory transfer <product-id> <from-warehouse> <to-warehouse> 5 --reference=TR-7",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "transfer <product> <from> <to> <quantity>",
+		Args:        cobra.ExactArgs(4),
+		Example:     concatenateExamples(transferExp),
+		Annotations: getDescriptions([]string{"Transfer stock of a product between two warehouses.", "Transfer stock"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			quantity, parseErr := strconv.ParseFloat(args[3], 64)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing quantity: %v", parseErr)
+			}
+			in.ProductID, in.FromWarehouseID, in.ToWarehouseID, in.Quantity = args[0], args[1], args[2], quantity
+
+			svc, ctx, svcErr := flags.service()
+			if svcErr != nil {
+				return svcErr
+			}
+			movements, transferErr := svc.Transfer(ctx, in)
+			if transferErr != nil {
+				return transferErr
+			}
+			return flags.print(movements, func(w *tabwriter.Writer) {
+				printMovements(w, movements)
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&in.VariantID, "variant", "", "variant of the product to transfer")
+	cmd.Flags().StringVarP(&in.Reference, "reference", "r", "", "reference document of the transfer")
+	cmd.Flags().StringVar(&in.Reason, "reason", "", "reason of the transfer")
+	cmd.Flags().BoolVar(&in.AllowNegative, "allow-negative", false, "let the stock of the source warehouse go below zero")
+
+	return cmd
+}
+
+func inventoryReserveCommand() *cobra.Command {
+	var flags inventoryFlags
+	var in databases.ReservationInput
+
+	var reserveExp = []string{
+		"gkbxsrv inventory reserve <product-id> <warehouse-id> 2 --order=<order-id> --ttl=2h",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "reserve <product> <warehouse> <quantity>",
+		Args:        cobra.ExactArgs(3),
+		Example:     concatenateExamples(reserveExp),
+		Annotations: getDescriptions([]string{"Reserve stock of a product in a warehouse for an order until the reservation expires.", "Reserve stock"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			quantity, parseErr := strconv.ParseFloat(args[2], 64)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing quantity: %v", parseErr)
+			}
+			in.ProductID, in.WarehouseID, in.Quantity = args[0], args[1], quantity
+
+			svc, ctx, svcErr := flags.service()
+			if svcErr != nil {
+				return svcErr
+			}
+			reservation, reserveErr := svc.Reserve(ctx, in)
+			if reserveErr != nil {
+				return reserveErr
+			}
+			return flags.print(reservation, func(w *tabwriter.Writer) {
+				printReservations(w, []*models.StockReservation{reservation})
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&in.VariantID, "variant", "", "variant of the product to reserve")
+	cmd.Flags().StringVarP(&in.OrderID, "order", "o", "", "order the stock is reserved for")
+	cmd.Flags().DurationVar(&in.TTL, "ttl", databases.InventoryReservationTTL, "how long the reservation holds the stock")
+	cmd.Flags().BoolVar(&in.AllowNegative, "allow-negative", false, "reserve more than is available")
+
+	return cmd
+}
+
+func inventoryReleaseCommand() *cobra.Command {
+	var flags inventoryFlags
+	var orderID string
+
+	var releaseExp = []string{
+		"gkbxsrv inventory release <reservation-id>",
+		"gkbxsrv inventory release --order=<order-id>",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "release [reservation]",
+		Args:        cobra.MaximumNArgs(1),
+		Example:     concatenateExamples(releaseExp),
+		Annotations: getDescriptions([]string{"Release a reservation, or every active reservation of an order.", "Release reservations"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 0) == (orderID == "") {
+				return fmt.Errorf("give either a reservation or --order")
+			}
+			svc, ctx, svcErr := flags.service()
+			if svcErr != nil {
+				return svcErr
+			}
+			if orderID != "" {
+				released, releaseErr := svc.ReleaseOrder(ctx, orderID)
+				if releaseErr != nil {
+					return releaseErr
+				}
+				fmt.Printf("Released %d reservations of order %s\n", released, orderID)
+				return nil
+			}
+			reservation, releaseErr := svc.Release(ctx, args[0])
+			if releaseErr != nil {
+				return releaseErr
+			}
+			return flags.print(reservation, func(w *tabwriter.Writer) {
+				printReservations(w, []*models.StockReservation{reservation})
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVarP(&orderID, "order", "o", "", "release the reservations of this order")
+
+	return cmd
+}
+
+func inventoryExpireCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var expireExp = []string{
+		"gkbxsrv inventory expire",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "expire",
+		Example:     concatenateExamples(expireExp),
+		Annotations: getDescriptions([]string{"Release the reservations past their expiry.", "Expire reservations"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			svc, ctx, svcErr := flags.service()
+			if svcErr != nil {
+				return svcErr
+			}
+			expired, expireErr := svc.ExpireReservations(ctx, time.Now())
+			if expireErr != nil {
+				return expireErr
+			}
+			fmt.Printf("Expired %d reservations\n", expired)
+			return nil
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func printStock(w *tabwriter.Writer, inventories []*models.Inventory) {
+	_, _ = fmt.Fprintln(w, "PRODUCT\tVARIANT\tWAREHOUSE\tON HAND\tRESERVED\tAVAILABLE\tSTATUS")
+	for _, inv := range inventories {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%.3f\t%.3f\t%.3f\t%s\n", inv.ProductID, inv.VariantID, inv.WarehouseID, inv.Quantity, inv.Reserved, inv.Available(), inv.Status)
+	}
+}
+
+func printMovements(w *tabwriter.Writer, movements []*models.InventoryMovement) {
+	_, _ = fmt.Fprintln(w, "TIME\tTYPE\tPRODUCT\tVARIANT\tWAREHOUSE\tQUANTITY\tBALANCE\tREFERENCE")
+	for _, movement := range movements {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%+.3f\t%.3f\t%s\n", movement.CreatedAt.Local().Format(time.DateTime), movement.MovementType, movement.ProductID, movement.VariantID, movement.WarehouseID, movement.Quantity, movement.Balance, movement.ReferenceDocument)
+	}
+}
+
+func printReservations(w *tabwriter.Writer, reservations []*models.StockReservation) {
+	_, _ = fmt.Fprintln(w, "ID\tORDER\tPRODUCT\tVARIANT\tWAREHOUSE\tQUANTITY\tSTATUS\tEXPIRES")
+	for _, reservation := range reservations {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%.3f\t%s\t%s\n", reservation.ID, reservation.OrderID, reservation.ProductID, reservation.VariantID, reservation.WarehouseID, reservation.Quantity, reservation.Status, reservation.ExpiresAt.Local().Format(time.DateTime))
+	}
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"fmt"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func MigrateRootCommand() *cobra.Command {
+	var migrateExp = []string{
+		"gkbxsrv database migrate up",
+		"gkbxsrv database migrate down --steps=2",
+		"gkbxsrv database migrate status",
+		"gkbxsrv database migrate create add_product_sku --driver=postgres",
+	}
+
+	migrateCmd := &cobra.Command{
+		Use:         "migrate",
+		Aliases:     []string{"migration", "migrations", "mig"},
+		Example:     concatenateExamples(migrateExp),
+		Annotations: getDescriptions([]string{"Versioned schema migrations for the database.", "Schema migrations"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("you must specify a subcommand")
+		},
+	}
+
+	migrateCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "config file")
+	migrateCmd.AddCommand(migrateCommands()...)
+
+	return migrateCmd
+}
+
+func migrateCommands() []*cobra.Command {
+	return []*cobra.Command{
+		migrateUpCommand(),
+		migrateDownCommand(),
+		migrateStatusCommand(),
+		migrateCreateCommand(),
+	}
+}
+
+// newMigrator opens the primary connection without the automatic migration done by OpenDB, so the
+// command alone decides what runs.
+func newMigrator() (*databases.Migrator, error) {
+	viper.Set("database.auto_migrate", false)
+	dbaseObj := databases.NewDatabaseService(configFile)
+	return dbaseObj.Migrator()
+}
+
+func migrateUpCommand() *cobra.Command {
+	var steps int
+
+	cmd := &cobra.Command{
+		Use:         "up",
+		Annotations: getDescriptions([]string{"Apply pending migrations.", "Apply migrations"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			migrator, migratorErr := newMigrator()
+			if migratorErr != nil {
+				return migratorErr
+			}
+			applied, upErr := migrator.Up(steps)
+			for _, migration := range applied {
+				fmt.Printf("Applied %d_%s\n", migration.Version, migration.Name)
+			}
+			if upErr != nil {
+				return upErr
+			}
+			if len(applied) == 0 {
+				fmt.Println("Database is up to date")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&steps, "steps", "n", 0, "number of migrations to apply (0 = all pending)")
+
+	return cmd
+}
+
+func migrateDownCommand() *cobra.Command {
+	var steps int
+
+	cmd := &cobra.Command{
+		Use:         "down",
+		Annotations: getDescriptions([]string{"Revert the latest applied migrations.", "Revert migrations"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			migrator, migratorErr := newMigrator()
+			if migratorErr != nil {
+				return migratorErr
+			}
+			reverted, downErr := migrator.Down(steps)
+			for _, migration := range reverted {
+				fmt.Printf("Reverted %d_%s\n", migration.Version, migration.Name)
+			}
+			if downErr != nil {
+				return downErr
+			}
+			if len(reverted) == 0 {
+				fmt.Println("No migration to revert")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&steps, "steps", "n", 1, "number of migrations to revert")
+
+	return cmd
+}
+
+func migrateStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "status",
+		Annotations: getDescriptions([]string{"Show applied and pending migrations.", "Migrations status"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			migrator, migratorErr := newMigrator()
+			if migratorErr != nil {
+				return migratorErr
+			}
+			status, statusErr := migrator.Status()
+			if statusErr != nil {
+				return statusErr
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSOURCE\tSTATUS\tAPPLIED AT")
+			for _, st := range status {
+				state, appliedAt := "pending", ""
+				if st.Applied {
+					state = "applied"
+					appliedAt = st.AppliedAt.Local().Format(time.RFC3339)
+				}
+				_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", st.Version, st.Name, st.Source, state, appliedAt)
+			}
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
+
+func migrateCreateCommand() *cobra.Command {
+	var driver, dir string
+
+	cmd := &cobra.Command{
+		Use:         "create <name>",
+		Args:        cobra.ExactArgs(1),
+		Annotations: getDescriptions([]string{"Create empty up/down SQL files for a new migration.", "Create migration"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dir == "" {
+				viper.Set("database.auto_migrate", false)
+				dir = databases.NewDatabaseService(configFile).MigrationsDir()
+			}
+			upPath, downPath, createErr := databases.CreateMigration(dir, args[0], driver)
+			if createErr != nil {
+				return createErr
+			}
+			fmt.Printf("Created %s\nCreated %s\n", upPath, downPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&driver, "driver", "d", "", "restrict the migration to one driver (postgres, mysql, sqlite, sqlserver, oracle)")
+	cmd.Flags().StringVarP(&dir, "dir", "D", "", "migrations directory (default: next to the config file)")
+
+	return cmd
+}
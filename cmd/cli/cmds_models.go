@@ -1,9 +1,189 @@
 package cli
 
 import (
+	"context"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/models"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/goccy/go-json"
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"text/tabwriter"
 )
 
 func ModelsCmdsList() []*cobra.Command {
-	return []*cobra.Command{}
+	return []*cobra.Command{
+		modelsListCommand(),
+		modelsGenerateCommand(),
+		modelsQueryCommand(),
+	}
+}
+
+func modelsListCommand() *cobra.Command {
+	var modelsListExp = []string{
+		"gkbxsrv models list",
+		"gkbxsrv models list --config=/etc/kubex/config.json",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "list",
+		Aliases:     []string{"ls"},
+		Example:     concatenateExamples(modelsListExp),
+		Annotations: getDescriptions([]string{"List the registered models, built-in and declared in the models directory.", "List models"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbaseObj := databases.NewDatabaseService(configFile)
+			if _, loadErr := dbaseObj.LoadModels(); loadErr != nil {
+				return loadErr
+			}
+
+			registry := models.RegisteredModels()
+			names := make([]string, 0, len(registry))
+			for name := range registry {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			cache := &sync.Map{}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "MODEL\tTABLE\tSOURCE\tFIELDS")
+			for _, name := range names {
+				typ := registry[name]
+				source, table := "built-in", ""
+				if def, ok := models.DynamicModelDefinition(typ); ok {
+					source, table = "dynamic", def.TableName()
+				} else if sch, parseErr := schema.Parse(reflect.New(typ).Interface(), cache, schema.NamingStrategy{}); parseErr == nil {
+					table = sch.Table
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", name, table, source, typ.NumField())
+			}
+			_, _ = fmt.Fprintf(w, "\nModels directory: %s\n", dbaseObj.ModelsDir())
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file")
+
+	return cmd
+}
+
+func modelsGenerateCommand() *cobra.Command {
+	var connection, output, pkg string
+	var tables []string
+	var force, dryRun bool
+
+	var modelsGenerateExp = []string{
+		"gkbxsrv models generate --connection=legacy --output=./internal/legacy --package=legacy",
+		"gkbxsrv models generate --tables=clientes,pedidos --output=./models",
+		"gkbxsrv models generate --tables=clientes --dry-run",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "generate",
+		Aliases:     []string{"gen", "codegen"},
+		Example:     concatenateExamples(modelsGenerateExp),
+		Annotations: getDescriptions([]string{"Generate Go models and repositories from the tables of an existing database.", "Generate models"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbaseObj := databases.NewDatabaseService(configFile)
+			db, dbErr := dbaseObj.GetConnection(connection)
+			if dbErr != nil {
+				return dbErr
+			}
+			files, genErr := databases.GenerateModels(db, databases.ModelGenOptions{Package: pkg, Tables: tables})
+			if genErr != nil {
+				return genErr
+			}
+			if dryRun {
+				for _, file := range files {
+					fmt.Printf("// %s\n%s\n", file.File, file.Source)
+				}
+				return nil
+			}
+			paths, writeErr := databases.WriteGeneratedModels(output, files, force)
+			for i, path := range paths {
+				fmt.Printf("%s -> %s (%s)\n", files[i].Table, path, files[i].Name)
+			}
+			return writeErr
+		},
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file")
+	cmd.Flags().StringVarP(&connection, "connection", "n", "", "named connection to introspect (default: the primary)")
+	cmd.Flags().StringSliceVarP(&tables, "tables", "t", nil, "tables to generate (default: all)")
+	cmd.Flags().StringVarP(&output, "output", "o", ".", "output directory")
+	cmd.Flags().StringVarP(&pkg, "package", "p", "models", "package of the generated files")
+	cmd.Flags().BoolVar(&force, "force", false, "replace existing files")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the generated code instead of writing it")
+
+	return cmd
+}
+
+func modelsQueryCommand() *cobra.Command {
+	var connection, tenant string
+	var filters, sortKeys []string
+	var query models.Query
+
+	var modelsQueryExp = []string{
+		"gkbxsrv models query product --filter 'price>10' --sort=-price --limit=20",
+		"gkbxsrv models query order --filter 'status:in=paid,shipped' --filter 'total>=100|priority=true'",
+		"gkbxsrv models query supplier --filter 'name~acme' --cursor=<next_cursor>",
+		"gkbxsrv models query customer --tenant=acme",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "query <model>",
+		Aliases:     []string{"find", "q"},
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(modelsQueryExp),
+		Annotations: getDescriptions([]string{"Query the records of a model with filters, sort keys and pagination, printed as JSON.", "Query a model"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsed, parseErr := models.ParseFilters(filters)
+			if parseErr != nil {
+				return parseErr
+			}
+			query.Filters, query.Sort = parsed, sortKeys
+
+			dbaseObj := databases.NewDatabaseService(configFile)
+			if _, loadErr := dbaseObj.LoadModels(); loadErr != nil {
+				return loadErr
+			}
+			db, dbErr := dbaseObj.GetConnection(connection)
+			if dbErr != nil {
+				return dbErr
+			}
+			page, queryErr := models.QueryModel(tenantSession(db, tenant), args[0], query)
+			if queryErr != nil {
+				return queryErr
+			}
+			data, marshalErr := json.MarshalIndent(page, "", "  ")
+			if marshalErr != nil {
+				return marshalErr
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file")
+	cmd.Flags().StringVarP(&connection, "connection", "n", "", "named connection to query (default: the primary)")
+	cmd.Flags().StringArrayVarP(&filters, "filter", "f", nil, "filter such as 'price>10', 'name~kit', 'status:in=a,b' or 'a=1|b=2' (repeat to combine with AND)")
+	cmd.Flags().StringSliceVarP(&sortKeys, "sort", "s", nil, "sort fields, prefixed with - for descending order")
+	cmd.Flags().IntVarP(&query.Limit, "limit", "l", models.DefaultPageSize, "records per page")
+	cmd.Flags().IntVar(&query.Offset, "offset", 0, "records to skip")
+	cmd.Flags().StringVar(&query.Cursor, "cursor", "", "next_cursor of the previous page")
+	cmd.Flags().StringVarP(&tenant, "tenant", "t", "", "only read the records of this tenant (default: every tenant)")
+
+	return cmd
+}
+
+// tenantSession scopes db to tenant. Without one, the CLI is an operator tool and sees every tenant.
+func tenantSession(db *gorm.DB, tenant string) *gorm.DB {
+	if tenant == "" {
+		return db.WithContext(models.SystemContext(context.Background()))
+	}
+	return db.WithContext(models.WithTenant(context.Background(), tenant))
 }
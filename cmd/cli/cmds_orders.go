@@ -0,0 +1,254 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/faelmori/gkbxsrv/models"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func OrdersCmdsList() []*cobra.Command {
+	return []*cobra.Command{
+		ordersTransitionCommand(),
+		ordersHistoryCommand(),
+		ordersItemsCommand(),
+		ordersAddItemCommand(),
+		ordersRemoveItemCommand(),
+	}
+}
+
+func ordersTransitionCommand() *cobra.Command {
+	var flags inventoryFlags
+	var actor string
+	var reserve []string
+	var in databases.TransitionInput
+
+	var transitionExp = []string{
+		"gkbxsrv orders transition <order-id> pending",
+		"gkbxsrv orders transition <order-id> confirmed --warehouse=<warehouse-id> --ttl=24h",
+		"gkbxsrv orders transition <order-id> confirmed --reserve=<product-id>:<warehouse-id>:2",
+		"gkbxsrv orders transition <order-id> cancelled --reason='customer request' --actor=alice",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "transition <order> <status>",
+		Aliases:     []string{"status"},
+		Args:        cobra.ExactArgs(2),
+		Example:     concatenateExamples(transitionExp),
+		Annotations: getDescriptions([]string{"Move an order to another status, running the guards and side effects of the transition.", "Transition an order"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, entry := range reserve {
+				reservation, parseErr := parseReservation(entry)
+				if parseErr != nil {
+					return parseErr
+				}
+				in.Reserve = append(in.Reserve, reservation)
+			}
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			ctx := session.Statement.Context
+			if actor != "" {
+				ctx = databases.WithActor(ctx, actor)
+			}
+			order, transitionErr := databases.NewOrderService(session).Transition(ctx, args[0], models.OrderStatus(args[1]), in)
+			if transitionErr != nil {
+				return transitionErr
+			}
+			return flags.print(order, func(w *tabwriter.Writer) {
+				_, _ = fmt.Fprintln(w, "ORDER\tNUMBER\tSTATUS\tVERSION")
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", order.ID, order.OrderNumber, order.Status, order.Version)
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&in.Reason, "reason", "", "reason recorded in the status history")
+	cmd.Flags().StringVar(&actor, "actor", "", "who makes the transition, recorded in the status history")
+	cmd.Flags().StringArrayVar(&reserve, "reserve", nil, "stock to reserve for the order, as product:warehouse:quantity")
+	cmd.Flags().StringVar(&in.WarehouseID, "warehouse", "", "reserve the items of the order from this warehouse")
+	cmd.Flags().DurationVar(&in.TTL, "ttl", databases.InventoryReservationTTL, "how long the reservations hold the stock")
+
+	return cmd
+}
+
+func ordersHistoryCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var historyExp = []string{
+		"gkbxsrv orders history <order-id>",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "history <order>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(historyExp),
+		Annotations: getDescriptions([]string{"Show the status transitions of an order, oldest first.", "Order status history"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			history, historyErr := databases.NewOrderService(session).History(session.Statement.Context, args[0])
+			if historyErr != nil {
+				return historyErr
+			}
+			return flags.print(history, func(w *tabwriter.Writer) {
+				_, _ = fmt.Fprintln(w, "TIME\tFROM\tTO\tACTOR\tREASON")
+				for _, entry := range history {
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.CreatedAt.Local().Format(time.DateTime), entry.FromStatus, entry.ToStatus, entry.Actor, entry.Reason)
+				}
+			})
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func ordersItemsCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var itemsExp = []string{
+		"gkbxsrv orders items <order-id>",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "items <order>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(itemsExp),
+		Annotations: getDescriptions([]string{"Show the items of an order and its totals.", "Order items"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			items, itemsErr := databases.NewOrderService(session).Items(session.Statement.Context, args[0])
+			if itemsErr != nil {
+				return itemsErr
+			}
+			totals, sumErr := models.SumOrderItems(items)
+			if sumErr != nil {
+				return sumErr
+			}
+			return flags.print(map[string]interface{}{"items": items, "totals": totals}, func(w *tabwriter.Writer) {
+				_, _ = fmt.Fprintln(w, "ITEM\tPRODUCT\tQUANTITY\tUNIT PRICE\tSUBTOTAL\tDISCOUNT\tTAX\tTOTAL")
+				for _, item := range items {
+					_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", item.ID, item.ProductID, item.Quantity, item.UnitPrice.StringFixed(2),
+						item.Subtotal.StringFixed(2), item.DiscountTotal.StringFixed(2), item.TaxAmount.StringFixed(2), item.Total.StringFixed(2))
+				}
+				_, _ = fmt.Fprintf(w, "\t\t\t\t%s\t%s\t%s\t%s\n", totals.Subtotal.StringFixed(2), totals.DiscountTotal.StringFixed(2), totals.TaxTotal.StringFixed(2), totals.Total.StringFixed(2))
+			})
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+func ordersAddItemCommand() *cobra.Command {
+	var flags inventoryFlags
+	var description, variantID, discountRate, discountAmount, taxRate string
+
+	var addItemExp = []string{
+		"gkbxsrv orders add-item <order-id> 42 3 19.90 --tax-rate=0.2",
+		"gkbxsrv orders add-item <order-id> 42 1 250 --discount-rate=0.1 --discount=5",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "add-item <order> <product> <quantity> <unit-price>",
+		Args:        cobra.ExactArgs(4),
+		Example:     concatenateExamples(addItemExp),
+		Annotations: getDescriptions([]string{"Add an item to an order; the totals of the order are recalculated.", "Add an order item"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			productID, productErr := strconv.ParseUint(args[1], 10, 64)
+			if productErr != nil {
+				return fmt.Errorf("error parsing product: %v", productErr)
+			}
+			item := &models.OrderItem{OrderID: args[0], ProductID: uint(productID), VariantID: variantID, Description: description}
+			for _, value := range []struct {
+				target *models.Decimal
+				text   string
+				name   string
+			}{
+				{&item.Quantity, args[2], "quantity"},
+				{&item.UnitPrice, args[3], "unit price"},
+				{&item.DiscountRate, discountRate, "discount rate"},
+				{&item.DiscountAmount, discountAmount, "discount"},
+				{&item.TaxRate, taxRate, "tax rate"},
+			} {
+				parsed, parseErr := models.ParseDecimal(value.text)
+				if parseErr != nil {
+					return fmt.Errorf("error parsing %s: %v", value.name, parseErr)
+				}
+				*value.target = parsed
+			}
+
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			if createErr := session.Create(item).Error; createErr != nil {
+				return createErr
+			}
+			return flags.print(item, func(w *tabwriter.Writer) {
+				_, _ = fmt.Fprintln(w, "ITEM\tSUBTOTAL\tDISCOUNT\tTAX\tTOTAL")
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", item.ID, item.Subtotal.StringFixed(2), item.DiscountTotal.StringFixed(2), item.TaxAmount.StringFixed(2), item.Total.StringFixed(2))
+			})
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&description, "description", "", "description of the item")
+	cmd.Flags().StringVar(&variantID, "variant", "", "variant of the product ordered")
+	cmd.Flags().StringVar(&discountRate, "discount-rate", "0", "discount as a fraction of the subtotal, e.g. 0.1")
+	cmd.Flags().StringVar(&discountAmount, "discount", "0", "discount amount taken off the item")
+	cmd.Flags().StringVar(&taxRate, "tax-rate", "0", "tax as a fraction of the discounted subtotal, e.g. 0.2")
+
+	return cmd
+}
+
+func ordersRemoveItemCommand() *cobra.Command {
+	var flags inventoryFlags
+
+	var removeItemExp = []string{
+		"gkbxsrv orders remove-item <item-id>",
+	}
+
+	cmd := &cobra.Command{
+		Use:         "remove-item <item>",
+		Args:        cobra.ExactArgs(1),
+		Example:     concatenateExamples(removeItemExp),
+		Annotations: getDescriptions([]string{"Remove an item from its order; the totals of the order are recalculated.", "Remove an order item"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, sessionErr := flags.session()
+			if sessionErr != nil {
+				return sessionErr
+			}
+			if deleteErr := models.NewOrderItemRepo(session).Delete(args[0]); deleteErr != nil {
+				return deleteErr
+			}
+			fmt.Printf("Removed order item %s\n", args[0])
+			return nil
+		},
+	}
+	flags.register(cmd)
+
+	return cmd
+}
+
+// parseReservation reads a product:warehouse:quantity reservation.
+func parseReservation(entry string) (databases.ReservationInput, error) {
+	parts := strings.Split(entry, ":")
+	if len(parts) != 3 {
+		return databases.ReservationInput{}, fmt.Errorf("invalid reservation %q: expected product:warehouse:quantity", entry)
+	}
+	quantity, parseErr := strconv.ParseFloat(parts[2], 64)
+	if parseErr != nil {
+		return databases.ReservationInput{}, fmt.Errorf("invalid reservation %q: %v", entry, parseErr)
+	}
+	return databases.ReservationInput{ProductID: parts[0], WarehouseID: parts[1], Quantity: quantity}, nil
+}
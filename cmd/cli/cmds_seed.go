@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/spf13/cobra"
+	"sort"
+)
+
+func SeedCommand() *cobra.Command {
+	var dir, connection, adminPassword string
+	var initFiles bool
+
+	var seedExp = []string{
+		"gkbxsrv database seed",
+		"gkbxsrv database seed --init --admin-password='secret123'",
+		"gkbxsrv database seed --dir=./fixtures --connection=laptop",
+	}
+
+	seedCmd := &cobra.Command{
+		Use:         "seed",
+		Aliases:     []string{"seeds", "fixtures"},
+		Example:     concatenateExamples(seedExp),
+		Annotations: getDescriptions([]string{"Apply the seed files of the config dir; records that already exist are kept as they are.", "Seed data"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbaseObj := databases.NewDatabaseService(configFile)
+			if dir == "" {
+				dir = dbaseObj.SeedsDir()
+			}
+			if initFiles {
+				generated := adminPassword == ""
+				if generated {
+					var keyErr error
+					if adminPassword, keyErr = databases.NewCertService("", "").GenerateRandomKey(16); keyErr != nil {
+						return keyErr
+					}
+				}
+				path, writeErr := databases.WriteDefaultSeeds(dir, adminPassword)
+				if writeErr != nil {
+					return writeErr
+				}
+				if path == "" {
+					fmt.Printf("Seed files already exist in %s\n", dir)
+				} else if generated {
+					fmt.Printf("Created %s (admin password: %s)\n", path, adminPassword)
+				} else {
+					fmt.Printf("Created %s\n", path)
+				}
+			}
+
+			db, dbErr := dbaseObj.GetConnection(connection)
+			if dbErr != nil {
+				return dbErr
+			}
+			result, seedErr := databases.Seed(db, dir)
+			if seedErr != nil {
+				return seedErr
+			}
+			if len(result.Files) == 0 {
+				fmt.Printf("No seed files in %s (use --init to create the base one)\n", dir)
+				return nil
+			}
+			models := make([]string, 0, len(result.Created)+len(result.Skipped))
+			for model := range result.Created {
+				models = append(models, model)
+			}
+			for model := range result.Skipped {
+				if _, ok := result.Created[model]; !ok {
+					models = append(models, model)
+				}
+			}
+			sort.Strings(models)
+			for _, model := range models {
+				fmt.Printf("%s: %d created, %d already present\n", model, result.Created[model], result.Skipped[model])
+			}
+			return nil
+		},
+	}
+
+	seedCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file")
+	seedCmd.Flags().StringVarP(&dir, "dir", "D", "", "seeds directory (default: next to the config file)")
+	seedCmd.Flags().StringVarP(&connection, "connection", "n", "", "named connection to seed (default: the primary)")
+	seedCmd.Flags().BoolVar(&initFiles, "init", false, "write the base seed file first when the directory has none")
+	seedCmd.Flags().StringVar(&adminPassword, "admin-password", "", "admin password for --init (default: generated)")
+
+	return seedCmd
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"fmt"
+	databases "github.com/faelmori/gkbxsrv/services"
+	"github.com/goccy/go-json"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func StatsCommand() *cobra.Command {
+	var watch time.Duration
+	var asJSON bool
+
+	var statsExp = []string{
+		"gkbxsrv database stats",
+		"gkbxsrv database stats --watch=5s",
+		"gkbxsrv database stats --json",
+	}
+
+	statsCmd := &cobra.Command{
+		Use:         "stats",
+		Aliases:     []string{"pool", "pool-stats"},
+		Example:     concatenateExamples(statsExp),
+		Annotations: getDescriptions([]string{"Show the connection pool statistics of the database.", "Pool statistics"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbaseObj := databases.NewDatabaseService(configFile)
+			for {
+				stats, statsErr := dbaseObj.Stats()
+				if statsErr != nil {
+					return statsErr
+				}
+				if asJSON {
+					data, marshalErr := json.Marshal(stats)
+					if marshalErr != nil {
+						return marshalErr
+					}
+					fmt.Println(string(data))
+				} else if printErr := printPoolStats(stats); printErr != nil {
+					return printErr
+				}
+				if watch <= 0 {
+					return nil
+				}
+				time.Sleep(watch)
+			}
+		},
+	}
+
+	statsCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file")
+	statsCmd.Flags().DurationVarP(&watch, "watch", "w", 0, "sample again at this interval until interrupted")
+	statsCmd.Flags().BoolVarP(&asJSON, "json", "j", false, "print each sample as JSON")
+
+	return statsCmd
+}
+
+func printPoolStats(stats databases.PoolStats) error {
+	cur, delta := stats.Current, stats.Delta
+	fmt.Printf("Pool stats at %s", stats.SampledAt.Format(time.RFC3339))
+	if stats.Interval > 0 {
+		fmt.Printf(" (delta over %s)", stats.Interval.Round(time.Millisecond))
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "METRIC\tCURRENT\tDELTA")
+	rows := []struct {
+		name       string
+		cur, delta interface{}
+	}{
+		{"max_open_connections", cur.MaxOpenConnections, delta.MaxOpenConnections},
+		{"open_connections", cur.OpenConnections, delta.OpenConnections},
+		{"in_use", cur.InUse, delta.InUse},
+		{"idle", cur.Idle, delta.Idle},
+		{"wait_count", cur.WaitCount, delta.WaitCount},
+		{"wait_duration", cur.WaitDuration, delta.WaitDuration},
+		{"max_idle_closed", cur.MaxIdleClosed, delta.MaxIdleClosed},
+		{"max_idle_time_closed", cur.MaxIdleTimeClosed, delta.MaxIdleTimeClosed},
+		{"max_lifetime_closed", cur.MaxLifetimeClosed, delta.MaxLifetimeClosed},
+	}
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(w, "%s\t%v\t%v\n", row.name, row.cur, row.delta)
+	}
+	return w.Flush()
+}
@@ -90,6 +90,58 @@ func (m *GKBXSrv) Command() *cobra.Command {
 	dataCmd.AddCommand(cmdDataList...)
 	cmd.AddCommand(dataCmd)
 
+	// Models command
+	modelsCmd := &cobra.Command{
+		Use:         "models",
+		Aliases:     []string{"model", "m"},
+		Short:       "Models module",
+		Annotations: m.getDescriptions([]string{"Models module is a set of tools to help you manage the data models.", "Models module"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("you must specify a subcommand")
+		},
+	}
+	modelsCmd.AddCommand(cli.ModelsCmdsList()...)
+	cmd.AddCommand(modelsCmd)
+
+	// Inventory command
+	inventoryCmd := &cobra.Command{
+		Use:         "inventory",
+		Aliases:     []string{"inv"},
+		Short:       "Inventory module",
+		Annotations: m.getDescriptions([]string{"Inventory module is a set of tools to help you move and reserve stock.", "Inventory module"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("you must specify a subcommand")
+		},
+	}
+	inventoryCmd.AddCommand(cli.InventoryCmdsList()...)
+	cmd.AddCommand(inventoryCmd)
+
+	// Orders command
+	ordersCmd := &cobra.Command{
+		Use:         "orders",
+		Aliases:     []string{"order", "o"},
+		Short:       "Orders module",
+		Annotations: m.getDescriptions([]string{"Orders module is a set of tools to help you move orders through their lifecycle.", "Orders module"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("you must specify a subcommand")
+		},
+	}
+	ordersCmd.AddCommand(cli.OrdersCmdsList()...)
+	cmd.AddCommand(ordersCmd)
+
+	// Catalog command
+	catalogCmd := &cobra.Command{
+		Use:         "catalog",
+		Aliases:     []string{"cat"},
+		Short:       "Catalog module",
+		Annotations: m.getDescriptions([]string{"Catalog module is a set of tools to help you manage categories, product variants and barcodes.", "Catalog module"}, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("you must specify a subcommand")
+		},
+	}
+	catalogCmd.AddCommand(cli.CatalogCmdsList()...)
+	cmd.AddCommand(catalogCmd)
+
 	// Paths command
 	pathsCmd := &cobra.Command{
 		Use:         "fs",
@@ -29,6 +29,7 @@ const DefaultGoSpyderConfigPath = "$HOME/.kubex/gospyder/config/config.json"
 const DefaultKeyPath = "$HOME/.kubex/kubex-key.pem"
 const DefaultCertPath = "$HOME/.kubex/kubex-cert.pem"
 
+// Deprecated: GenericRepo is untyped and has no implementation; use models.Repository[T].
 type GenericRepo interface {
 	Create(u interface{}) (interface{}, error)
 	FindOne(where ...interface{}) (interface{}, error)
@@ -54,6 +55,15 @@ type Database struct {
 	Username         string      `gorm:"omitempty" json:"username"`
 	Password         string      `gorm:"omitempty" json:"password"`
 	Name             string      `gorm:"omitempty" json:"name"`
+	TimeZone         string      `gorm:"omitempty" json:"timezone"`
+	SSLMode          string      `gorm:"omitempty" json:"ssl_mode"`
+	MaxOpenConns     int         `gorm:"omitempty" json:"max_open_conns"`
+	MaxIdleConns     int         `gorm:"omitempty" json:"max_idle_conns"`
+	ConnMaxLifetime  string      `gorm:"omitempty" json:"conn_max_lifetime"`
+	ConnMaxIdleTime  string      `gorm:"omitempty" json:"conn_max_idle_time"`
+	SeedOnSetup      bool        `gorm:"omitempty" json:"seed_on_setup"`
+	Audit            bool        `gorm:"omitempty" json:"audit"`
+	MultiTenant      bool        `gorm:"omitempty" json:"multi_tenant"`
 }
 type JWT struct {
 	RefreshSecret         string `gorm:"omitempty" json:"refresh_secret"`
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+type BarcodeType string
+
+const (
+	BarcodeEAN13 BarcodeType = "ean13"
+	BarcodeUPCA  BarcodeType = "upca"
+)
+
+// NormalizeBarcode checks the check digit of an EAN-13 or UPC-A barcode and returns it as a 13 digit
+// GTIN: UPC-A codes get a leading zero, the EAN-13 form they are scanned as by most readers, so both
+// forms of a code find the same variant. Spaces and dashes are ignored.
+func NormalizeBarcode(code string) (string, BarcodeType, error) {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", "", &ValidationError{Field: "barcode", Message: fmt.Sprintf("Barcode %q must only have digits", code)}
+		}
+	}
+	var barcodeType BarcodeType
+	switch len(digits) {
+	case 12:
+		barcodeType, digits = BarcodeUPCA, "0"+digits
+	case 13:
+		barcodeType = BarcodeEAN13
+		if digits[0] == '0' {
+			barcodeType = BarcodeUPCA
+		}
+	default:
+		return "", "", &ValidationError{Field: "barcode", Message: fmt.Sprintf("Barcode %q must be an EAN-13 (13 digits) or a UPC-A (12 digits)", code)}
+	}
+	if check := BarcodeCheckDigit(digits[:12]); int(digits[12]-'0') != check {
+		return "", "", &ValidationError{Field: "barcode", Message: fmt.Sprintf("Barcode %q has an invalid check digit, expected %d", code, check)}
+	}
+	return digits, barcodeType, nil
+}
+
+// BarcodeCheckDigit returns the GS1 check digit of digits, the code without it: digits are weighted
+// 3 and 1 alternately from the right, and the check digit brings the sum to a multiple of 10.
+func BarcodeCheckDigit(digits string) int {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return (10 - sum%10) % 10
+}
+
+// FormatBarcode returns a normalized barcode as it is printed: 12 digits for UPC-A.
+func FormatBarcode(gtin string, barcodeType BarcodeType) string {
+	if barcodeType == BarcodeUPCA && len(gtin) == 13 && gtin[0] == '0' {
+		return gtin[1:]
+	}
+	return gtin
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeBarcode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		want     string
+		wantType BarcodeType
+		wantErr  bool
+	}{
+		{"ean13", "4006381333931", "4006381333931", BarcodeEAN13, false},
+		{"ean13 with dashes", "978-0-306-40615-7", "9780306406157", BarcodeEAN13, false},
+		{"upca", "036000291452", "0036000291452", BarcodeUPCA, false},
+		{"upca as ean13", "0036000291452", "0036000291452", BarcodeUPCA, false},
+		{"upca with spaces", " 0 36000 29145 2 ", "0036000291452", BarcodeUPCA, false},
+		{"invalid check digit", "4006381333932", "", "", true},
+		{"letters", "40063813339AB", "", "", true},
+		{"too short", "12345", "", "", true},
+		{"ean8", "96385074", "", "", true},
+		{"empty", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotType, err := NormalizeBarcode(tt.code)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeBarcode(%q) error = %v, wantErr %v", tt.code, err, tt.wantErr)
+			}
+			var validationErr *ValidationError
+			if err != nil && !errors.As(err, &validationErr) {
+				t.Errorf("NormalizeBarcode(%q) error = %T, want a *ValidationError", tt.code, err)
+			}
+			if got != tt.want || gotType != tt.wantType {
+				t.Errorf("NormalizeBarcode(%q) = %q, %q, want %q, %q", tt.code, got, gotType, tt.want, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestBarcodeCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   int
+	}{
+		{"400638133393", 1},
+		{"978030640615", 7},
+		{"03600029145", 2},
+		{"003600029145", 2},
+		{"000000000000", 0},
+	}
+	for _, tt := range tests {
+		if got := BarcodeCheckDigit(tt.digits); got != tt.want {
+			t.Errorf("BarcodeCheckDigit(%q) = %d, want %d", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBarcode(t *testing.T) {
+	tests := []struct {
+		gtin        string
+		barcodeType BarcodeType
+		want        string
+	}{
+		{"0036000291452", BarcodeUPCA, "036000291452"},
+		{"4006381333931", BarcodeEAN13, "4006381333931"},
+		{"0036000291452", BarcodeEAN13, "0036000291452"},
+		{"036000291452", BarcodeUPCA, "036000291452"},
+	}
+	for _, tt := range tests {
+		if got := FormatBarcode(tt.gtin, tt.barcodeType); got != tt.want {
+			t.Errorf("FormatBarcode(%q, %q) = %q, want %q", tt.gtin, tt.barcodeType, got, tt.want)
+		}
+	}
+}
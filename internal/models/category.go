@@ -0,0 +1,292 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CategoryRepoImpl struct {
+	*Repository[Category]
+}
+
+func NewCategoryRepo(db *gorm.DB) *CategoryRepoImpl {
+	return &CategoryRepoImpl{NewRepository[Category](db)}
+}
+
+func (g *CategoryRepoImpl) List(where ...interface{}) (*TableHandler, error) {
+	categories, err := g.FindAll(where...)
+	if err != nil {
+		return nil, err
+	}
+	tableHandlerMap := make(map[int]map[string]string)
+	for i, c := range categories {
+		parentID := ""
+		if c.ParentID != nil {
+			parentID = strconv.Itoa(int(*c.ParentID))
+		}
+		tableHandlerMap[i] = map[string]string{
+			"id":        strconv.Itoa(int(c.ID)),
+			"parent_id": parentID,
+			"name":      c.Name,
+			"path":      c.Path,
+		}
+	}
+	return &TableHandler{rows: tableHandlerMap}, nil
+}
+
+// Delete removes a category without subcategories or products.
+func (g *CategoryRepoImpl) Delete(id uint) error {
+	var children, products int64
+	if countErr := g.DB.Model(&Category{}).Where("parent_id = ?", id).Count(&children).Error; countErr != nil {
+		return countErr
+	}
+	if children > 0 {
+		return &ValidationError{Field: "id", Message: fmt.Sprintf("Category %d has %d subcategories", id, children)}
+	}
+	if countErr := g.DB.Model(&Product{}).Where("category_id = ?", id).Count(&products).Error; countErr != nil {
+		return countErr
+	}
+	if products > 0 {
+		return &ValidationError{Field: "id", Message: fmt.Sprintf("Category %d has %d products", id, products)}
+	}
+	return g.Repository.Delete(id)
+}
+
+// Roots returns the top level categories.
+func (g *CategoryRepoImpl) Roots() ([]*Category, error) {
+	var categories []*Category
+	if findErr := g.DB.Where("parent_id IS NULL").Order("name").Find(&categories).Error; findErr != nil {
+		return nil, findErr
+	}
+	return categories, nil
+}
+
+func (g *CategoryRepoImpl) Children(id uint) ([]*Category, error) {
+	var categories []*Category
+	if findErr := g.DB.Where("parent_id = ?", id).Order("name").Find(&categories).Error; findErr != nil {
+		return nil, findErr
+	}
+	return categories, nil
+}
+
+// Ancestors returns the categories above id, from the root down to its parent.
+func (g *CategoryRepoImpl) Ancestors(id uint) ([]*Category, error) {
+	category, findErr := g.FindByID(id)
+	if findErr != nil {
+		return nil, findErr
+	}
+	ids := category.AncestorIDs()
+	if len(ids) == 0 {
+		return []*Category{}, nil
+	}
+	var categories []*Category
+	if findErr = g.DB.Where("id IN ?", ids).Order("depth").Find(&categories).Error; findErr != nil {
+		return nil, findErr
+	}
+	return categories, nil
+}
+
+// Descendants returns every category below id, depth first.
+func (g *CategoryRepoImpl) Descendants(id uint) ([]*Category, error) {
+	category, findErr := g.FindByID(id)
+	if findErr != nil {
+		return nil, findErr
+	}
+	var categories []*Category
+	if findErr = g.DB.Where("path LIKE ? AND id <> ?", category.Path+"%", id).Order("path").Find(&categories).Error; findErr != nil {
+		return nil, findErr
+	}
+	return categories, nil
+}
+
+// Tree returns the category tree, each level sorted by name.
+func (g *CategoryRepoImpl) Tree() ([]*CategoryNode, error) {
+	var categories []*Category
+	if findErr := g.DB.Order("depth, name").Find(&categories).Error; findErr != nil {
+		return nil, findErr
+	}
+	return NewCategoryTree(categories), nil
+}
+
+// Move puts category id under parentID, or at the top level when parentID is nil, with its subtree.
+func (g *CategoryRepoImpl) Move(id uint, parentID *uint) (*Category, error) {
+	category, findErr := g.FindByID(id)
+	if findErr != nil {
+		return nil, findErr
+	}
+	category.ParentID = parentID
+	if saveErr := g.DB.Save(category).Error; saveErr != nil {
+		return nil, saveErr
+	}
+	return category, nil
+}
+
+// Category is a node of the product category tree. Path lists the IDs from the root down to the
+// category itself ("/1/4/9/") so a subtree is a prefix match; Path and Depth are kept by the hooks,
+// also for the subtree of a category moved to another parent.
+type Category struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ParentID    *uint     `gorm:"index" json:"parent_id"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	Path        string    `gorm:"type:varchar(255);not null;default:'/';index" json:"path"`
+	Depth       int       `gorm:"not null;default:0" json:"depth"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"not null" json:"updated_at"`
+	Tenant
+	Versioned
+
+	movedFrom string
+}
+
+func (c *Category) TableName() string {
+	return "categories"
+}
+
+func (c *Category) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return &ValidationError{Field: "name", Message: "Name is required"}
+	}
+	if c.ParentID != nil && c.ID != 0 && *c.ParentID == c.ID {
+		return &ValidationError{Field: "parent_id", Message: "A category cannot be its own parent"}
+	}
+	return nil
+}
+
+// BeforeSave checks the parent, which must not be in the subtree of the category, and the name,
+// unique among its siblings, and derives Path and Depth.
+func (c *Category) BeforeSave(tx *gorm.DB) (err error) {
+	if _, columns := tx.Statement.Dest.(map[string]interface{}); columns {
+		return nil
+	}
+	if validateErr := c.Validate(); validateErr != nil {
+		return validateErr
+	}
+	db := tx.Session(&gorm.Session{NewDB: true})
+	prefix := "/"
+	if c.ParentID != nil {
+		var parent Category
+		result := db.Where("id = ?", *c.ParentID).Limit(1).Find(&parent)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return &ValidationError{Field: "parent_id", Message: fmt.Sprintf("Category %d not found", *c.ParentID)}
+		}
+		if c.ID != 0 && strings.Contains(parent.Path, fmt.Sprintf("/%d/", c.ID)) {
+			return &ValidationError{Field: "parent_id", Message: fmt.Sprintf("Category %d is below category %d", parent.ID, c.ID)}
+		}
+		prefix = parent.Path
+	}
+
+	siblings := db.Model(&Category{}).Where("name = ? AND tenant_id = ? AND id <> ?", c.Name, c.TenantID, c.ID)
+	if c.ParentID == nil {
+		siblings = siblings.Where("parent_id IS NULL")
+	} else {
+		siblings = siblings.Where("parent_id = ?", *c.ParentID)
+	}
+	var duplicates int64
+	if countErr := siblings.Count(&duplicates).Error; countErr != nil {
+		return countErr
+	}
+	if duplicates > 0 {
+		return &ValidationError{Field: "name", Message: fmt.Sprintf("Category %q already exists there", c.Name)}
+	}
+
+	c.Depth = strings.Count(prefix, "/") - 1
+	if c.ID == 0 {
+		c.Path = prefix // completed with the ID after the insert
+		return nil
+	}
+	var stored []string
+	if pluckErr := db.Model(&Category{}).Where("id = ?", c.ID).Limit(1).Pluck("path", &stored).Error; pluckErr != nil {
+		return pluckErr
+	}
+	c.Path = fmt.Sprintf("%s%d/", prefix, c.ID)
+	if len(stored) > 0 && stored[0] != c.Path {
+		c.movedFrom = stored[0]
+	}
+	return nil
+}
+
+func (c *Category) AfterCreate(tx *gorm.DB) (err error) {
+	own := fmt.Sprintf("%d/", c.ID)
+	if strings.HasSuffix(c.Path, "/"+own) {
+		return nil
+	}
+	c.Path += own
+	return tx.Session(&gorm.Session{NewDB: true}).Table(c.TableName()).Where("id = ?", c.ID).UpdateColumn("path", c.Path).Error
+}
+
+// AfterSave moves the subtree of a category that changed parent.
+func (c *Category) AfterSave(tx *gorm.DB) (err error) {
+	if c.movedFrom == "" {
+		return nil
+	}
+	from := c.movedFrom
+	c.movedFrom = ""
+	db := tx.Session(&gorm.Session{NewDB: true})
+	var subtree []*Category
+	if findErr := db.Where("path LIKE ? AND id <> ?", from+"%", c.ID).Find(&subtree).Error; findErr != nil {
+		return findErr
+	}
+	for _, child := range subtree {
+		path := c.Path + strings.TrimPrefix(child.Path, from)
+		if updateErr := db.Table(c.TableName()).Where("id = ?", child.ID).UpdateColumns(map[string]interface{}{"path": path, "depth": strings.Count(path, "/") - 2}).Error; updateErr != nil {
+			return updateErr
+		}
+	}
+	return nil
+}
+
+// AncestorIDs returns the IDs of the categories above c, from the root down, as recorded in its Path.
+func (c *Category) AncestorIDs() []uint {
+	var ids []uint
+	for _, part := range strings.Split(strings.Trim(c.Path, "/"), "/") {
+		id, parseErr := strconv.ParseUint(part, 10, 64)
+		if parseErr != nil || uint(id) == c.ID {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
+
+// CategoryNode is a category with its subcategories.
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children,omitempty"`
+}
+
+// NewCategoryTree nests categories under their parents; categories whose parent is not among them are
+// roots. Each level is sorted by name.
+func NewCategoryTree(categories []*Category) []*CategoryNode {
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{Category: *category}
+	}
+	var roots []*CategoryNode
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, found := nodes[*category.ParentID]; found {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	var sortNodes func(level []*CategoryNode)
+	sortNodes = func(level []*CategoryNode) {
+		sort.Slice(level, func(i, j int) bool { return level[i].Name < level[j].Name })
+		for _, node := range level {
+			sortNodes(node.Children)
+		}
+	}
+	sortNodes(roots)
+	return roots
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// CountSession is a cycle count of a warehouse. Starting it freezes the quantity of each inventory of
+// the warehouse, or of the products it is limited to, as the Expected quantity of a CountLine. Counts
+// are entered against the lines while it is open, and posting it adjusts the stock by the variances.
+type CountSession struct {
+	ID          string      `gorm:"type:uuid;primaryKey" json:"id"`
+	WarehouseID string      `gorm:"type:uuid;not null;index" json:"warehouse_id"`
+	Status      CountStatus `gorm:"type:varchar(20);not null;default:'open';index" json:"status"`
+	Reference   string      `gorm:"type:varchar(100)" json:"reference"`
+	Notes       string      `gorm:"type:text" json:"notes"`
+	// Partial sessions count only the products they were started with.
+	Partial   bool       `gorm:"not null;default:false" json:"partial"`
+	StartedBy string     `gorm:"type:varchar(255)" json:"started_by"`
+	PostedBy  string     `gorm:"type:varchar(255)" json:"posted_by,omitempty"`
+	PostedAt  *time.Time `json:"posted_at,omitempty"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"not null" json:"updated_at"`
+	Tenant
+	Versioned
+}
+
+type CountStatus string
+
+const (
+	CountOpen      CountStatus = "open"
+	CountPosted    CountStatus = "posted"
+	CountCancelled CountStatus = "cancelled"
+)
+
+func (s *CountSession) TableName() string {
+	return "count_sessions"
+}
+
+func (s *CountSession) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// CountLine is the count of a product, or of a variant of it, in a count session. Counted is nil until
+// it is counted; Variance is Counted minus Expected. MovementID is the adjustment posted for the
+// variance.
+type CountLine struct {
+	ID          string     `gorm:"type:uuid;primaryKey" json:"id"`
+	SessionID   string     `gorm:"type:uuid;not null;uniqueIndex:idx_count_lines_session_product,priority:1" json:"session_id"`
+	ProductID   string     `gorm:"type:uuid;not null;uniqueIndex:idx_count_lines_session_product,priority:2" json:"product_id"`
+	VariantID   string     `gorm:"type:varchar(36);not null;default:'';uniqueIndex:idx_count_lines_session_product,priority:3" json:"variant_id"`
+	InventoryID string     `gorm:"type:varchar(36)" json:"inventory_id"`
+	Expected    float64    `gorm:"type:decimal(15,3);not null;default:0" json:"expected"`
+	Counted     *float64   `gorm:"type:decimal(15,3)" json:"counted"`
+	Variance    float64    `gorm:"type:decimal(15,3);not null;default:0" json:"variance"`
+	Reason      string     `gorm:"type:text" json:"reason"`
+	CountedBy   string     `gorm:"type:varchar(255)" json:"counted_by,omitempty"`
+	CountedAt   *time.Time `json:"counted_at,omitempty"`
+	MovementID  string     `gorm:"type:varchar(36)" json:"movement_id,omitempty"`
+	CreatedAt   time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"not null" json:"updated_at"`
+	Tenant
+}
+
+func (l *CountLine) TableName() string {
+	return "count_lines"
+}
+
+func (l *CountLine) BeforeCreate(tx *gorm.DB) (err error) {
+	if l.ID == "" {
+		l.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// IsCounted reports whether the product of the line was counted.
+func (l *CountLine) IsCounted() bool {
+	return l.Counted != nil
+}
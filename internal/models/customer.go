@@ -36,6 +36,9 @@ type CustomerImpl struct {
 	Score   int    `gorm:"score;omitempty" json:"score"`
 	Seller  int    `gorm:"seller;default:0" json:"seller"`
 	Active  bool   `gorm:"active;default:true" json:"active"`
+	SoftDelete
+	Tenant
+	Versioned
 }
 
 func (c *CustomerImpl) TableName() string                    { return "customers" }
@@ -78,7 +81,7 @@ func (g *CustomerRepoImpl) Create(c *Customer) (*Customer, error) {
 }
 func (g *CustomerRepoImpl) FindOne(where ...interface{}) (*Customer, error) {
 	var c Customer
-	err := g.DB.Where(where[0], where[1:]).First(&c).Error
+	err := whereScope(g.DB, where).First(&c).Error
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +89,7 @@ func (g *CustomerRepoImpl) FindOne(where ...interface{}) (*Customer, error) {
 }
 func (g *CustomerRepoImpl) FindAll(where ...interface{}) ([]*Customer, error) {
 	var customers []*Customer
-	err := g.DB.Where(where[0], where[1:]).Find(&customers).Error
+	err := whereScope(g.DB, where).Find(&customers).Error
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +118,7 @@ func (g *CustomerRepoImpl) Close() error {
 }
 func (g *CustomerRepoImpl) List(where ...interface{}) (*TableHandler, error) {
 	var customers []*CustomerImpl
-	err := g.DB.Where(where[0], where[1:]).Find(&customers).Error
+	err := whereScope(g.DB, where).Find(&customers).Error
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DecimalScale is the number of fractional digits a Decimal keeps.
+const DecimalScale = 4
+
+var decimalUnit = big.NewInt(10000) // 10^DecimalScale
+
+// Decimal is a fixed-point number with DecimalScale fractional digits, for money, quantities and
+// rates that must add up exactly. Results are rounded half away from zero, so the same inputs always
+// give the same totals on every database. It is stored as a decimal column and encoded in JSON as a
+// number; the zero value is 0.
+type Decimal struct {
+	units int64
+}
+
+// DecimalFromInt returns n as a Decimal, or an error when n is out of range.
+func DecimalFromInt(n int64) (Decimal, error) {
+	return decimalFromInt(new(big.Int).Mul(big.NewInt(n), decimalUnit), "%d", n)
+}
+
+// DecimalFromFloat returns the shortest decimal representation of f, rounded to DecimalScale.
+func DecimalFromFloat(f float64) Decimal {
+	d, _ := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	return d
+}
+
+// ParseDecimal reads a decimal number such as "-12.5" or "0.0725", rounding digits past DecimalScale.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.Contains(s, "/") {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return decimalFromRat(r)
+}
+
+// MustParseDecimal is ParseDecimal for constants; it panics on invalid input.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func decimalFromRat(r *big.Rat) (Decimal, error) {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(decimalUnit))
+	units := roundHalfAway(scaled.Num(), scaled.Denom())
+	if !units.IsInt64() {
+		return Decimal{}, fmt.Errorf("decimal %s out of range", r.FloatString(DecimalScale))
+	}
+	return Decimal{units: units.Int64()}, nil
+}
+
+// roundHalfAway divides num by den, rounding half away from zero.
+func roundHalfAway(num, den *big.Int) *big.Int {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	if twice.Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign()*den.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return quo
+}
+
+// decimalFromInt returns units as a Decimal, or an error describing the operation in format and
+// args when units is out of range.
+func decimalFromInt(units *big.Int, format string, args ...interface{}) (Decimal, error) {
+	if !units.IsInt64() {
+		return Decimal{}, fmt.Errorf("decimal overflow: "+format, args...)
+	}
+	return Decimal{units: units.Int64()}, nil
+}
+
+// Add returns d + o, or an error when the sum is out of range.
+func (d Decimal) Add(o Decimal) (Decimal, error) {
+	sum := new(big.Int).Add(big.NewInt(d.units), big.NewInt(o.units))
+	return decimalFromInt(sum, "%s + %s", d, o)
+}
+
+// Sub returns d − o, or an error when the difference is out of range.
+func (d Decimal) Sub(o Decimal) (Decimal, error) {
+	difference := new(big.Int).Sub(big.NewInt(d.units), big.NewInt(o.units))
+	return decimalFromInt(difference, "%s − %s", d, o)
+}
+
+// Neg returns −d, or an error when d is the smallest Decimal.
+func (d Decimal) Neg() (Decimal, error) {
+	return decimalFromInt(new(big.Int).Neg(big.NewInt(d.units)), "−%s", d)
+}
+
+// Mul returns d × o rounded to DecimalScale, or an error when the product is out of range.
+func (d Decimal) Mul(o Decimal) (Decimal, error) {
+	product := new(big.Int).Mul(big.NewInt(d.units), big.NewInt(o.units))
+	return decimalFromInt(roundHalfAway(product, decimalUnit), "%s × %s", d, o)
+}
+
+// Round rounds d to places fractional digits, half away from zero. The few values within half a
+// step of the end of the range round toward zero instead, as the result would be out of range.
+func (d Decimal) Round(places int) Decimal {
+	if places >= DecimalScale {
+		return d
+	}
+	if places < 0 {
+		places = 0
+	}
+	step := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(DecimalScale-places)), nil)
+	rounded := roundHalfAway(big.NewInt(d.units), step)
+	rounded.Mul(rounded, step)
+	if !rounded.IsInt64() {
+		rounded.Quo(big.NewInt(d.units), step)
+		rounded.Mul(rounded, step)
+	}
+	return Decimal{units: rounded.Int64()}
+}
+
+// FitsDigits reports whether the integer part of d has at most n digits, so that it fits a decimal
+// column with n digits before the point.
+func (d Decimal) FitsDigits(n int) bool {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n+DecimalScale)), nil)
+	return new(big.Int).Abs(big.NewInt(d.units)).Cmp(limit) < 0
+}
+
+func (d Decimal) Cmp(o Decimal) int {
+	switch {
+	case d.units < o.units:
+		return -1
+	case d.units > o.units:
+		return 1
+	}
+	return 0
+}
+func (d Decimal) Sign() int {
+	return d.Cmp(Decimal{})
+}
+func (d Decimal) IsZero() bool { return d.units == 0 }
+
+// Float64 returns the nearest float64, for display and interop only.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.StringFixed(DecimalScale), 64)
+	return f
+}
+
+// StringFixed formats d with exactly places fractional digits.
+func (d Decimal) StringFixed(places int) string {
+	if places > DecimalScale {
+		return d.StringFixed(DecimalScale) + strings.Repeat("0", places-DecimalScale)
+	}
+	r := d.Round(places)
+	sign := ""
+	units := r.units
+	if units < 0 {
+		sign, units = "-", -units
+	}
+	unit := decimalUnit.Int64()
+	whole := strconv.FormatInt(units/unit, 10)
+	if places <= 0 {
+		return sign + whole
+	}
+	frac := fmt.Sprintf("%0*d", DecimalScale, units%unit)[:places]
+	return sign + whole + "." + frac
+}
+
+// String formats d without trailing zeros.
+func (d Decimal) String() string {
+	s := d.StringFixed(DecimalScale)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.StringFixed(DecimalScale), nil
+}
+
+func (d *Decimal) Scan(value interface{}) error {
+	var parsed Decimal
+	var parseErr error
+	switch v := value.(type) {
+	case nil:
+		*d = Decimal{}
+		return nil
+	case int64:
+		parsed, parseErr = DecimalFromInt(v)
+	case float64:
+		parsed = DecimalFromFloat(v)
+	case []byte:
+		parsed, parseErr = ParseDecimal(string(v))
+	case string:
+		parsed, parseErr = ParseDecimal(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a decimal", value)
+	}
+	if parseErr != nil {
+		return parseErr
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a number, a string holding a number, or null.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "null" {
+		*d = Decimal{}
+		return nil
+	}
+	if unquoted, unquoteErr := strconv.Unquote(text); unquoteErr == nil {
+		text = unquoted
+	}
+	parsed, parseErr := ParseDecimal(text)
+	if parseErr != nil {
+		return parseErr
+	}
+	*d = parsed
+	return nil
+}
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"0", "0", false},
+		{" 12.5 ", "12.5", false},
+		{"-0.0725", "-0.0725", false},
+		{"1.00005", "1.0001", false},
+		{"-1.00005", "-1.0001", false},
+		{"1.00004", "1", false},
+		{"1e3", "1000", false},
+		{"922337203685477.5807", "922337203685477.5807", false},
+		{"922337203685477.5808", "", true},
+		{"1/3", "", true},
+		{"abc", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDecimal(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDecimal(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("ParseDecimal(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalFromInt(t *testing.T) {
+	tests := []struct {
+		in      int64
+		want    string
+		wantErr bool
+	}{
+		{0, "0", false},
+		{-42, "-42", false},
+		{922337203685477, "922337203685477", false},
+		{922337203685478, "", true},
+		{math.MinInt64, "", true},
+	}
+	for _, tt := range tests {
+		got, err := DecimalFromInt(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("DecimalFromInt(%d) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if !tt.wantErr && got.String() != tt.want {
+			t.Errorf("DecimalFromInt(%d) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	max := Decimal{units: math.MaxInt64}
+	min := Decimal{units: math.MinInt64}
+	one := MustParseDecimal("1")
+	tests := []struct {
+		name    string
+		op      func() (Decimal, error)
+		want    string
+		wantErr bool
+	}{
+		{"add", func() (Decimal, error) { return MustParseDecimal("0.1").Add(MustParseDecimal("0.2")) }, "0.3", false},
+		{"add overflow", func() (Decimal, error) { return max.Add(MustParseDecimal("0.0001")) }, "", true},
+		{"sub", func() (Decimal, error) { return MustParseDecimal("1").Sub(MustParseDecimal("1.25")) }, "-0.25", false},
+		{"sub overflow", func() (Decimal, error) { return min.Sub(MustParseDecimal("0.0001")) }, "", true},
+		{"neg", func() (Decimal, error) { return MustParseDecimal("2.5").Neg() }, "-2.5", false},
+		{"neg overflow", func() (Decimal, error) { return min.Neg() }, "", true},
+		{"mul", func() (Decimal, error) { return MustParseDecimal("3").Mul(MustParseDecimal("19.99")) }, "59.97", false},
+		{"mul rounds half away", func() (Decimal, error) { return MustParseDecimal("0.0005").Mul(MustParseDecimal("0.5")) }, "0.0003", false},
+		{"mul rounds negative half away", func() (Decimal, error) { return MustParseDecimal("-0.0005").Mul(MustParseDecimal("0.5")) }, "-0.0003", false},
+		{"mul by one", func() (Decimal, error) { return max.Mul(one) }, max.String(), false},
+		{"mul overflow", func() (Decimal, error) { return max.Mul(MustParseDecimal("2")) }, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.op()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalRound(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int
+		want   string
+	}{
+		{"1.005", 2, "1.01"},
+		{"-1.005", 2, "-1.01"},
+		{"1.0049", 2, "1"},
+		{"2.5", 0, "3"},
+		{"-2.5", 0, "-3"},
+		{"2.5", -1, "3"},
+		{"1.2345", 6, "1.2345"},
+		{"922337203685477.5807", 0, "922337203685477"},
+		{"-922337203685477.5808", 2, "-922337203685477.58"},
+	}
+	for _, tt := range tests {
+		if got := MustParseDecimal(tt.in).Round(tt.places); got.String() != tt.want {
+			t.Errorf("Round(%s, %d) = %s, want %s", tt.in, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalStringFixed(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int
+		want   string
+	}{
+		{"0", 2, "0.00"},
+		{"12.5", 2, "12.50"},
+		{"-0.005", 2, "-0.01"},
+		{"0.0001", 0, "0"},
+		{"1.5", 6, "1.500000"},
+	}
+	for _, tt := range tests {
+		if got := MustParseDecimal(tt.in).StringFixed(tt.places); got != tt.want {
+			t.Errorf("StringFixed(%s, %d) = %q, want %q", tt.in, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{"nil", nil, "0", false},
+		{"int64", int64(7), "7", false},
+		{"int64 overflow", int64(math.MaxInt64), "", true},
+		{"float64", 0.1, "0.1", false},
+		{"bytes", []byte("12.3400"), "12.34", false},
+		{"string", "-3", "-3", false},
+		{"invalid string", "x", "", true},
+		{"unsupported type", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Decimal
+			err := d.Scan(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && d.String() != tt.want {
+				t.Errorf("Scan(%v) = %s, want %s", tt.value, d, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"12.5", "12.5", false},
+		{`"0.0725"`, "0.0725", false},
+		{"null", "0", false},
+		{`"x"`, "", true},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		err := d.UnmarshalJSON([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("UnmarshalJSON(%s) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		data, _ := d.MarshalJSON()
+		if string(data) != tt.want {
+			t.Errorf("UnmarshalJSON(%s) then MarshalJSON() = %s, want %s", tt.in, data, tt.want)
+		}
+	}
+}
+
+func TestOrderItemCalculate(t *testing.T) {
+	tests := []struct {
+		name                           string
+		item                           OrderItem
+		subtotal, discount, tax, total string
+		wantErr                        bool
+	}{
+		{
+			name:     "discount and tax",
+			item:     OrderItem{Quantity: MustParseDecimal("3"), UnitPrice: MustParseDecimal("19.99"), DiscountRate: MustParseDecimal("0.1"), TaxRate: MustParseDecimal("0.2")},
+			subtotal: "59.97", discount: "6", tax: "10.79", total: "64.76",
+		},
+		{
+			name:     "discount capped at the subtotal",
+			item:     OrderItem{Quantity: MustParseDecimal("1"), UnitPrice: MustParseDecimal("5"), DiscountAmount: MustParseDecimal("8"), TaxRate: MustParseDecimal("0.2")},
+			subtotal: "5", discount: "5", tax: "0", total: "0",
+		},
+		{
+			name:    "subtotal out of range",
+			item:    OrderItem{Quantity: MustParseDecimal("999999999999"), UnitPrice: MustParseDecimal("99999999999")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.item.Calculate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Calculate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := []string{tt.item.Subtotal.String(), tt.item.DiscountTotal.String(), tt.item.TaxAmount.String(), tt.item.Total.String()}
+			want := []string{tt.subtotal, tt.discount, tt.tax, tt.total}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("Calculate() amounts = %v, want %v", got, want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -12,17 +12,39 @@ type Model interface {
 }
 
 var ModelList = []interface{}{
+	&RoleImpl{},
 	&UserImpl{},
 	&Product{},
 	&CustomerImpl{},
 	&Order{},
+	&Warehouse{},
+	&Inventory{},
+	&InventoryMovement{},
+	&StockReservation{},
+	&OrderStatusHistory{},
+	&OrderItem{},
+	&CountSession{},
+	&CountLine{},
+	&Category{},
+	&ProductVariant{},
 }
 var ModelRegistryMap = map[string]reflect.Type{
-	strings.ToLower("User"):     reflect.TypeOf(UserImpl{}),
-	strings.ToLower("Product"):  reflect.TypeOf(Product{}),
-	strings.ToLower("Customer"): reflect.TypeOf(CustomerImpl{}),
-	strings.ToLower("Order"):    reflect.TypeOf(Order{}),
-	strings.ToLower("Ping"):     reflect.TypeOf(PingImpl{}),
+	strings.ToLower("User"):               reflect.TypeOf(UserImpl{}),
+	strings.ToLower("Product"):            reflect.TypeOf(Product{}),
+	strings.ToLower("Customer"):           reflect.TypeOf(CustomerImpl{}),
+	strings.ToLower("Order"):              reflect.TypeOf(Order{}),
+	strings.ToLower("Ping"):               reflect.TypeOf(PingImpl{}),
+	strings.ToLower("Role"):               reflect.TypeOf(RoleImpl{}),
+	strings.ToLower("Warehouse"):          reflect.TypeOf(Warehouse{}),
+	strings.ToLower("Inventory"):          reflect.TypeOf(Inventory{}),
+	strings.ToLower("InventoryMovement"):  reflect.TypeOf(InventoryMovement{}),
+	strings.ToLower("StockReservation"):   reflect.TypeOf(StockReservation{}),
+	strings.ToLower("OrderStatusHistory"): reflect.TypeOf(OrderStatusHistory{}),
+	strings.ToLower("OrderItem"):          reflect.TypeOf(OrderItem{}),
+	strings.ToLower("CountSession"):       reflect.TypeOf(CountSession{}),
+	strings.ToLower("CountLine"):          reflect.TypeOf(CountLine{}),
+	strings.ToLower("Category"):           reflect.TypeOf(Category{}),
+	strings.ToLower("ProductVariant"):     reflect.TypeOf(ProductVariant{}),
 }
 
 type ModelRegistryImpl struct {
@@ -44,7 +66,11 @@ func (m *ModelRegistryImpl) GetType() (reflect.Type, error) {
 	}
 }
 func (m *ModelRegistryImpl) FromModel(model interface{}) ModelRegistryInterface {
-	m.Tp = strings.ToLower(reflect.TypeOf(model).Name())
+	if def, ok := DynamicModelDefinition(reflect.TypeOf(model)); ok {
+		m.Tp = def.Name
+	} else {
+		m.Tp = strings.ToLower(reflect.TypeOf(model).Name())
+	}
 	m.Dt = model
 	return m
 }
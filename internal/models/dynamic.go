@@ -0,0 +1,307 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelDefinition declares an entity without a Go struct. BuildModelType turns it into a struct type
+// with an "id" primary key, the declared fields and, optionally, created_at/updated_at, a deleted_at
+// soft delete column, a tenant_id column isolating its rows by tenant (see Tenant) and a version column
+// for optimistic locking (see Versioned).
+type ModelDefinition struct {
+	Name        string            `json:"name"`
+	Table       string            `json:"table,omitempty"`
+	Description string            `json:"description,omitempty"`
+	IDType      string            `json:"id_type,omitempty"` // "uuid" (default) or "serial"
+	Timestamps  bool              `json:"timestamps,omitempty"`
+	SoftDelete  bool              `json:"soft_delete,omitempty"`
+	Tenant      bool              `json:"tenant,omitempty"`
+	Versioned   bool              `json:"versioned,omitempty"`
+	Fields      []FieldDefinition `json:"fields"`
+	Indexes     []IndexDefinition `json:"indexes,omitempty"`
+}
+
+// FieldDefinition is a column of a ModelDefinition. Type is one of the keys of DynamicFieldTypes.
+type FieldDefinition struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Size      int    `json:"size,omitempty"`
+	Precision int    `json:"precision,omitempty"`
+	Scale     int    `json:"scale,omitempty"`
+	Required  bool   `json:"required,omitempty"`
+	Unique    bool   `json:"unique,omitempty"`
+	Index     bool   `json:"index,omitempty"`
+	Default   string `json:"default,omitempty"`
+}
+
+// IndexDefinition is an index over one or more fields, in order.
+type IndexDefinition struct {
+	Name   string   `json:"name,omitempty"`
+	Fields []string `json:"fields"`
+	Unique bool     `json:"unique,omitempty"`
+}
+
+const (
+	DynamicIDTypeUUID   = "uuid"
+	DynamicIDTypeSerial = "serial"
+)
+
+// DynamicFieldTypes maps the field types accepted in a ModelDefinition to their Go type.
+var DynamicFieldTypes = map[string]reflect.Type{
+	"string":    reflect.TypeOf(""),
+	"varchar":   reflect.TypeOf(""),
+	"text":      reflect.TypeOf(""),
+	"uuid":      reflect.TypeOf(""),
+	"int":       reflect.TypeOf(int(0)),
+	"integer":   reflect.TypeOf(int(0)),
+	"bigint":    reflect.TypeOf(int64(0)),
+	"float":     reflect.TypeOf(float64(0)),
+	"decimal":   reflect.TypeOf(float64(0)),
+	"numeric":   reflect.TypeOf(float64(0)),
+	"bool":      reflect.TypeOf(false),
+	"boolean":   reflect.TypeOf(false),
+	"date":      reflect.TypeOf(time.Time{}),
+	"datetime":  reflect.TypeOf(time.Time{}),
+	"timestamp": reflect.TypeOf(time.Time{}),
+	"bytes":     reflect.TypeOf([]byte(nil)),
+	"blob":      reflect.TypeOf([]byte(nil)),
+}
+
+var (
+	dynamicNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	dynamicReserved    = map[string]bool{"id": true, "created_at": true, "updated_at": true, "deleted_at": true, "tenant_id": true}
+)
+
+type dynamicModel struct {
+	def ModelDefinition
+	typ reflect.Type
+}
+
+var (
+	dynamicModelsMu sync.RWMutex
+	dynamicModels   = map[string]dynamicModel{}
+	dynamicByType   = map[reflect.Type]string{}
+)
+
+// TableName defaults to the model name followed by an "s", like the built-in models.
+func (d ModelDefinition) TableName() string {
+	if d.Table != "" {
+		return d.Table
+	}
+	return strings.ToLower(d.Name) + "s"
+}
+
+// Validate checks names, types and index fields of the definition.
+func (d ModelDefinition) Validate() error {
+	if !dynamicNamePattern.MatchString(d.Name) {
+		return &ValidationError{Field: "name", Message: fmt.Sprintf("invalid model name %q (lowercase letters, digits and _)", d.Name)}
+	}
+	if d.Table != "" && !dynamicNamePattern.MatchString(d.Table) {
+		return &ValidationError{Field: "table", Message: fmt.Sprintf("invalid table name %q", d.Table)}
+	}
+	switch d.IDType {
+	case "", DynamicIDTypeUUID, DynamicIDTypeSerial:
+	default:
+		return &ValidationError{Field: "id_type", Message: fmt.Sprintf("unknown id type %q (uuid or serial)", d.IDType)}
+	}
+	if len(d.Fields) == 0 {
+		return &ValidationError{Field: "fields", Message: fmt.Sprintf("model %s has no fields", d.Name)}
+	}
+	seen := make(map[string]bool, len(d.Fields))
+	goNames := map[string]bool{"ID": true, "CreatedAt": true, "UpdatedAt": true, "DeletedAt": true, "TenantID": true}
+	for _, field := range d.Fields {
+		if !dynamicNamePattern.MatchString(field.Name) || dynamicReserved[field.Name] {
+			return &ValidationError{Field: "fields", Message: fmt.Sprintf("invalid field name %q in model %s", field.Name, d.Name)}
+		}
+		if d.Versioned && field.Name == "version" {
+			return &ValidationError{Field: "fields", Message: fmt.Sprintf("field version of model %s is the version column of versioned models", d.Name)}
+		}
+		if seen[field.Name] || goNames[dynamicGoName(field.Name)] {
+			return &ValidationError{Field: "fields", Message: fmt.Sprintf("field %s declared twice in model %s", field.Name, d.Name)}
+		}
+		seen[field.Name], goNames[dynamicGoName(field.Name)] = true, true
+		if _, ok := DynamicFieldTypes[strings.ToLower(field.Type)]; !ok {
+			return &ValidationError{Field: "fields", Message: fmt.Sprintf("unknown type %q for field %s of model %s", field.Type, field.Name, d.Name)}
+		}
+	}
+	for _, index := range d.Indexes {
+		if len(index.Fields) == 0 {
+			return &ValidationError{Field: "indexes", Message: fmt.Sprintf("index %s of model %s has no fields", index.Name, d.Name)}
+		}
+		for _, name := range index.Fields {
+			if !seen[name] {
+				return &ValidationError{Field: "indexes", Message: fmt.Sprintf("index of model %s uses unknown field %s", d.Name, name)}
+			}
+		}
+	}
+	return nil
+}
+
+// BuildModelType validates d and returns its struct type. Fields are exported in CamelCase, with the
+// declared name as column and JSON name.
+func BuildModelType(d ModelDefinition) (reflect.Type, error) {
+	if validateErr := d.Validate(); validateErr != nil {
+		return nil, validateErr
+	}
+	table := d.TableName()
+
+	// Composite indexes are declared by repeating the same named index tag on each field.
+	indexTags := make(map[string][]string)
+	for _, index := range d.Indexes {
+		name := index.Name
+		if name == "" {
+			name = "idx_" + table + "_" + strings.Join(index.Fields, "_")
+		}
+		kind := "index"
+		if index.Unique {
+			kind = "uniqueIndex"
+		}
+		for priority, field := range index.Fields {
+			indexTags[field] = append(indexTags[field], fmt.Sprintf("%s:%s,priority:%d", kind, name, priority+1))
+		}
+	}
+
+	// The model tag keeps the types of two models with the same fields distinct.
+	idField := reflect.StructField{Name: "ID", Type: reflect.TypeOf(""), Tag: reflect.StructTag(fmt.Sprintf(`gorm:"primaryKey;size:36" json:"id" model:"%s"`, d.Name))}
+	if d.IDType == DynamicIDTypeSerial {
+		idField.Type = reflect.TypeOf(uint(0))
+		idField.Tag = reflect.StructTag(fmt.Sprintf(`gorm:"primaryKey" json:"id" model:"%s"`, d.Name))
+	}
+	structFields := []reflect.StructField{idField}
+
+	for _, field := range d.Fields {
+		fieldType := strings.ToLower(field.Type)
+		goType := DynamicFieldTypes[fieldType]
+		tags := []string{"column:" + field.Name}
+		switch fieldType {
+		case "string", "varchar":
+			size := field.Size
+			if size <= 0 {
+				size = 255
+			}
+			tags = append(tags, fmt.Sprintf("size:%d", size))
+		case "uuid":
+			tags = append(tags, "size:36")
+		case "text":
+			tags = append(tags, "type:text")
+		case "decimal", "numeric":
+			precision, scale := field.Precision, field.Scale
+			if precision <= 0 {
+				precision, scale = 18, 4
+			}
+			tags = append(tags, fmt.Sprintf("type:decimal(%d,%d)", precision, scale))
+		case "date", "datetime", "timestamp":
+			if !field.Required {
+				goType = reflect.PointerTo(goType)
+			}
+		}
+		if field.Required {
+			tags = append(tags, "not null")
+		}
+		if field.Unique {
+			tags = append(tags, "unique")
+		}
+		if field.Default != "" {
+			tags = append(tags, "default:"+field.Default)
+		}
+		if field.Index {
+			tags = append(tags, fmt.Sprintf("index:idx_%s_%s", table, field.Name))
+		}
+		tags = append(tags, indexTags[field.Name]...)
+		structFields = append(structFields, reflect.StructField{
+			Name: dynamicGoName(field.Name),
+			Type: goType,
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"%s" json:"%s"`, strings.Join(tags, ";"), field.Name)),
+		})
+	}
+
+	if d.Timestamps {
+		structFields = append(structFields,
+			reflect.StructField{Name: "CreatedAt", Type: reflect.TypeOf(time.Time{}), Tag: `json:"created_at"`},
+			reflect.StructField{Name: "UpdatedAt", Type: reflect.TypeOf(time.Time{}), Tag: `json:"updated_at"`},
+		)
+	}
+	if d.SoftDelete {
+		structFields = append(structFields, reflect.StructField{
+			Name: "DeletedAt", Type: reflect.TypeOf(gorm.DeletedAt{}), Tag: reflect.StructTag(fmt.Sprintf(`gorm:"index:idx_%s_deleted_at" json:"deleted_at,omitempty"`, table)),
+		})
+	}
+	if d.Versioned {
+		structFields = append(structFields, reflect.StructField{
+			Name: "Version", Type: reflect.TypeOf(int64(0)), Tag: `gorm:"not null;default:1" json:"version" version:"lock"`,
+		})
+	}
+	if d.Tenant {
+		structFields = append(structFields, reflect.StructField{
+			Name: "TenantID", Type: reflect.TypeOf(""), Tag: reflect.StructTag(fmt.Sprintf(`gorm:"size:64;not null;default:'';index:idx_%s_tenant_id" json:"tenant_id" tenant:"scope"`, table)),
+		})
+	}
+	return reflect.StructOf(structFields), nil
+}
+
+// RegisterDynamicModel builds the type of d and adds it to ModelRegistryMap under d.Name. Registering
+// the same definition again returns the type already registered.
+func RegisterDynamicModel(d ModelDefinition) (reflect.Type, error) {
+	typ, buildErr := BuildModelType(d)
+	if buildErr != nil {
+		return nil, buildErr
+	}
+	dynamicModelsMu.Lock()
+	defer dynamicModelsMu.Unlock()
+	if existing, ok := dynamicModels[d.Name]; ok {
+		if existing.typ == typ && existing.def.TableName() == d.TableName() {
+			return typ, nil
+		}
+		return nil, fmt.Errorf("model %s já registrado com outra definição", d.Name)
+	}
+	if registerErr := RegisterModel(d.Name, typ); registerErr != nil {
+		return nil, registerErr
+	}
+	dynamicModels[d.Name] = dynamicModel{def: d, typ: typ}
+	dynamicByType[typ] = d.Name
+	return typ, nil
+}
+
+// DynamicModelDefinition returns the definition typ was built from, if it is a dynamic model.
+func DynamicModelDefinition(typ reflect.Type) (ModelDefinition, bool) {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	dynamicModelsMu.RLock()
+	defer dynamicModelsMu.RUnlock()
+	name, ok := dynamicByType[typ]
+	if !ok {
+		return ModelDefinition{}, false
+	}
+	return dynamicModels[name].def, true
+}
+
+// DynamicModelDefinitions returns the registered definitions ordered by name.
+func DynamicModelDefinitions() []ModelDefinition {
+	dynamicModelsMu.RLock()
+	defer dynamicModelsMu.RUnlock()
+	defs := make([]ModelDefinition, 0, len(dynamicModels))
+	for _, model := range dynamicModels {
+		defs = append(defs, model.def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+func dynamicGoName(name string) string {
+	var sb strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
@@ -18,66 +18,19 @@ type InventoryRepo interface {
 }
 
 type InventoryRepoImpl struct {
-	*gorm.DB
+	*Repository[Inventory]
 }
 
 func NewInventoryRepo(db *gorm.DB) *InventoryRepoImpl {
-	return &InventoryRepoImpl{db}
-}
-
-func (g *InventoryRepoImpl) Create(i *Inventory) (*Inventory, error) {
-	err := g.DB.Create(i).Error
-	if err != nil {
-		return nil, err
-	}
-	return i, nil
-}
-
-func (g *InventoryRepoImpl) FindOne(where ...interface{}) (*Inventory, error) {
-	var i Inventory
-	err := g.DB.Where(where[0], where[1:]...).First(&i).Error
-	if err != nil {
-		return nil, err
-	}
-	return &i, nil
-}
-
-func (g *InventoryRepoImpl) FindAll(where ...interface{}) ([]*Inventory, error) {
-	var inventories []*Inventory
-	err := g.DB.Where(where[0], where[1:]...).Find(&inventories).Error
-	if err != nil {
-		return nil, err
-	}
-	return inventories, nil
-}
-
-func (g *InventoryRepoImpl) Update(i *Inventory) (*Inventory, error) {
-	err := g.DB.Save(i).Error
-	if err != nil {
-		return nil, err
-	}
-	return i, nil
+	return &InventoryRepoImpl{NewRepository[Inventory](db)}
 }
 
 func (g *InventoryRepoImpl) Delete(id string) error {
-	err := g.DB.Delete(&Inventory{}, id).Error
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (g *InventoryRepoImpl) Close() error {
-	sqlDB, err := g.DB.DB()
-	if err != nil {
-		return err
-	}
-	return sqlDB.Close()
+	return g.Repository.Delete(id)
 }
 
 func (g *InventoryRepoImpl) List(where ...interface{}) (*TableHandler, error) {
-	var inventories []*Inventory
-	err := g.DB.Where(where[0], where[1:]...).Find(&inventories).Error
+	inventories, err := g.FindAll(where...)
 	if err != nil {
 		return nil, err
 	}
@@ -86,23 +39,31 @@ func (g *InventoryRepoImpl) List(where ...interface{}) (*TableHandler, error) {
 		tableHandlerMap[i] = map[string]string{
 			"id":           inv.ID,
 			"product_id":   inv.ProductID,
+			"variant_id":   inv.VariantID,
 			"warehouse_id": inv.WarehouseID,
 			"quantity":     strconv.FormatFloat(inv.Quantity, 'f', 3, 64),
+			"reserved":     strconv.FormatFloat(inv.Reserved, 'f', 3, 64),
 			"status":       string(inv.Status),
 		}
 	}
 	return &TableHandler{rows: tableHandlerMap}, nil
 }
 
+// Inventory is the stock of a product in a warehouse; stock kept per variant has the VariantID of the
+// ProductVariant, and stock of the product itself none.
 type Inventory struct {
 	ID            string          `gorm:"type:uuid;primaryKey" json:"id"`
-	ProductID     string          `gorm:"type:uuid;not null" json:"product_id"`
-	WarehouseID   string          `gorm:"type:uuid;not null" json:"warehouse_id"`
+	ProductID     string          `gorm:"type:uuid;not null;uniqueIndex:idx_inventory_product_warehouse,priority:1" json:"product_id"`
+	VariantID     string          `gorm:"type:varchar(36);not null;default:'';uniqueIndex:idx_inventory_product_warehouse,priority:2" json:"variant_id"`
+	WarehouseID   string          `gorm:"type:uuid;not null;uniqueIndex:idx_inventory_product_warehouse,priority:3" json:"warehouse_id"`
 	Quantity      float64         `gorm:"type:decimal(15,3);not null;default:0" json:"quantity"`
+	Reserved      float64         `gorm:"type:decimal(15,3);not null;default:0" json:"reserved"`
 	Status        InventoryStatus `gorm:"type:varchar(50);not null;default:'available'" json:"status"`
 	LastCountDate time.Time       `gorm:"type:timestamp;not null;default:current_timestamp" json:"last_count_date"`
 	CreatedAt     time.Time       `gorm:"type:timestamp;not null;default:current_timestamp" json:"created_at"`
 	UpdatedAt     time.Time       `gorm:"type:timestamp;not null;default:current_timestamp" json:"updated_at"`
+	Tenant
+	Versioned
 }
 
 type InventoryStatus string
@@ -114,6 +75,11 @@ const (
 	InventoryStatusExpired   InventoryStatus = "expired"
 )
 
+// Available is the quantity not held by reservations.
+func (i *Inventory) Available() float64 {
+	return i.Quantity - i.Reserved
+}
+
 func (i *Inventory) TableName() string {
 	return "inventory"
 }
@@ -18,66 +18,19 @@ type InventoryMovementRepo interface {
 }
 
 type InventoryMovementRepoImpl struct {
-	*gorm.DB
+	*Repository[InventoryMovement]
 }
 
 func NewInventoryMovementRepo(db *gorm.DB) *InventoryMovementRepoImpl {
-	return &InventoryMovementRepoImpl{db}
-}
-
-func (g *InventoryMovementRepoImpl) Create(im *InventoryMovement) (*InventoryMovement, error) {
-	err := g.DB.Create(im).Error
-	if err != nil {
-		return nil, err
-	}
-	return im, nil
-}
-
-func (g *InventoryMovementRepoImpl) FindOne(where ...interface{}) (*InventoryMovement, error) {
-	var im InventoryMovement
-	err := g.DB.Where(where[0], where[1:]...).First(&im).Error
-	if err != nil {
-		return nil, err
-	}
-	return &im, nil
-}
-
-func (g *InventoryMovementRepoImpl) FindAll(where ...interface{}) ([]*InventoryMovement, error) {
-	var inventoryMovements []*InventoryMovement
-	err := g.DB.Where(where[0], where[1:]...).Find(&inventoryMovements).Error
-	if err != nil {
-		return nil, err
-	}
-	return inventoryMovements, nil
-}
-
-func (g *InventoryMovementRepoImpl) Update(im *InventoryMovement) (*InventoryMovement, error) {
-	err := g.DB.Save(im).Error
-	if err != nil {
-		return nil, err
-	}
-	return im, nil
+	return &InventoryMovementRepoImpl{NewRepository[InventoryMovement](db)}
 }
 
 func (g *InventoryMovementRepoImpl) Delete(id string) error {
-	err := g.DB.Delete(&InventoryMovement{}, id).Error
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (g *InventoryMovementRepoImpl) Close() error {
-	sqlDB, err := g.DB.DB()
-	if err != nil {
-		return err
-	}
-	return sqlDB.Close()
+	return g.Repository.Delete(id)
 }
 
 func (g *InventoryMovementRepoImpl) List(where ...interface{}) (*TableHandler, error) {
-	var inventoryMovements []*InventoryMovement
-	err := g.DB.Where(where[0], where[1:]...).Find(&inventoryMovements).Error
+	inventoryMovements, err := g.FindAll(where...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,22 +41,59 @@ func (g *InventoryMovementRepoImpl) List(where ...interface{}) (*TableHandler, e
 			"inventory_id": im.InventoryID,
 			"product_id":   im.ProductID,
 			"quantity":     strconv.FormatFloat(im.Quantity, 'f', 3, 64),
-			"type":         im.MovementType,
+			"warehouse_id": im.WarehouseID,
+			"type":         string(im.MovementType),
+			"balance":      strconv.FormatFloat(im.Balance, 'f', 3, 64),
 		}
 	}
 	return &TableHandler{rows: tableHandlerMap}, nil
 }
 
+// InventoryMovement is an entry of the stock ledger: Quantity is signed (negative for issues and
+// outgoing transfers) and Balance is the quantity of the inventory right after the movement. The two
+// legs of a transfer share TransferID.
 type InventoryMovement struct {
-	ID                string    `gorm:"type:uuid;primaryKey" json:"id"`
-	InventoryID       string    `gorm:"type:uuid;not null" json:"inventory_id"`
-	ProductID         string    `gorm:"type:uuid;not null" json:"product_id"`
-	Quantity          float64   `gorm:"type:decimal(15,3);not null" json:"quantity"`
-	MovementType      string    `gorm:"type:varchar(50);not null" json:"movement_type"` // entrada, saída, ajuste, transferência
-	ReferenceDocument string    `gorm:"type:varchar(100)" json:"reference_document"`
-	Reason            string    `gorm:"type:text" json:"reason"`
-	CreatedAt         time.Time `gorm:"type:timestamp;not null;default:current_timestamp" json:"created_at"`
-	UpdatedAt         time.Time `gorm:"type:timestamp;not null;default:current_timestamp" json:"updated_at"`
+	ID                string       `gorm:"type:uuid;primaryKey" json:"id"`
+	InventoryID       string       `gorm:"type:uuid;not null;index" json:"inventory_id"`
+	ProductID         string       `gorm:"type:uuid;not null;index" json:"product_id"`
+	VariantID         string       `gorm:"type:varchar(36);index" json:"variant_id,omitempty"`
+	WarehouseID       string       `gorm:"type:uuid;index" json:"warehouse_id"`
+	Quantity          float64      `gorm:"type:decimal(15,3);not null" json:"quantity"`
+	Balance           float64      `gorm:"type:decimal(15,3);not null;default:0" json:"balance"`
+	MovementType      MovementType `gorm:"type:varchar(50);not null" json:"movement_type"`
+	TransferID        string       `gorm:"type:varchar(36);index" json:"transfer_id,omitempty"`
+	ReferenceDocument string       `gorm:"type:varchar(100)" json:"reference_document"`
+	Reason            string       `gorm:"type:text" json:"reason"`
+	CreatedAt         time.Time    `gorm:"type:timestamp;not null;default:current_timestamp" json:"created_at"`
+	UpdatedAt         time.Time    `gorm:"type:timestamp;not null;default:current_timestamp" json:"updated_at"`
+	Tenant
+}
+
+type MovementType string
+
+const (
+	MovementReceipt     MovementType = "receipt"
+	MovementIssue       MovementType = "issue"
+	MovementAdjustment  MovementType = "adjustment"
+	MovementTransferOut MovementType = "transfer_out"
+	MovementTransferIn  MovementType = "transfer_in"
+)
+
+// LegacyMovementTypes maps the free-text types of movements recorded before MovementType; transfers
+// are split by the sign of their quantity.
+var LegacyMovementTypes = map[string]MovementType{
+	"entrada": MovementReceipt,
+	"saída":   MovementIssue,
+	"saida":   MovementIssue,
+	"ajuste":  MovementAdjustment,
+}
+
+func (t MovementType) Valid() bool {
+	switch t {
+	case MovementReceipt, MovementIssue, MovementAdjustment, MovementTransferOut, MovementTransferIn:
+		return true
+	}
+	return false
 }
 
 func (im *InventoryMovement) TableName() string {
@@ -18,66 +18,19 @@ type OrderRepo interface {
 }
 
 type OrderRepoImpl struct {
-	*gorm.DB
+	*Repository[Order]
 }
 
 func NewOrderRepo(db *gorm.DB) *OrderRepoImpl {
-	return &OrderRepoImpl{db}
-}
-
-func (g *OrderRepoImpl) Create(o *Order) (*Order, error) {
-	err := g.DB.Create(o).Error
-	if err != nil {
-		return nil, err
-	}
-	return o, nil
-}
-
-func (g *OrderRepoImpl) FindOne(where ...interface{}) (*Order, error) {
-	var o Order
-	err := g.DB.Where(where[0], where[1:]...).First(&o).Error
-	if err != nil {
-		return nil, err
-	}
-	return &o, nil
-}
-
-func (g *OrderRepoImpl) FindAll(where ...interface{}) ([]*Order, error) {
-	var orders []*Order
-	err := g.DB.Where(where[0], where[1:]...).Find(&orders).Error
-	if err != nil {
-		return nil, err
-	}
-	return orders, nil
-}
-
-func (g *OrderRepoImpl) Update(o *Order) (*Order, error) {
-	err := g.DB.Save(o).Error
-	if err != nil {
-		return nil, err
-	}
-	return o, nil
+	return &OrderRepoImpl{NewRepository[Order](db)}
 }
 
 func (g *OrderRepoImpl) Delete(id string) error {
-	err := g.DB.Delete(&Order{}, id).Error
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (g *OrderRepoImpl) Close() error {
-	sqlDB, err := g.DB.DB()
-	if err != nil {
-		return err
-	}
-	return sqlDB.Close()
+	return g.Repository.Delete(id)
 }
 
 func (g *OrderRepoImpl) List(where ...interface{}) (*TableHandler, error) {
-	var orders []*Order
-	err := g.DB.Where(where[0], where[1:]...).Find(&orders).Error
+	orders, err := g.FindAll(where...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,12 +41,14 @@ func (g *OrderRepoImpl) List(where ...interface{}) (*TableHandler, error) {
 			"order_number": o.OrderNumber,
 			"customer_id":  fmt.Sprintf("%d", o.CustomerID),
 			"status":       string(o.Status),
-			"total_amount": fmt.Sprintf("%.2f", o.TotalAmount),
+			"total_amount": o.TotalAmount.StringFixed(2),
 		}
 	}
 	return &TableHandler{rows: tableHandlerMap}, nil
 }
 
+// Order is a sales order. Its totals are the sums of its OrderItem lines, recalculated whenever they
+// change; its status changes through transitions (see OrderTransitions).
 type Order struct {
 	ID                string      `gorm:"type:uuid;primaryKey" json:"id"`
 	OrderNumber       string      `gorm:"type:varchar(50);unique;not null" json:"order_number"`
@@ -102,9 +57,15 @@ type Order struct {
 	OrderDate         time.Time   `gorm:"type:timestamp;not null;default:current_timestamp" json:"order_date"`
 	EstimatedDelivery time.Time   `gorm:"type:timestamp" json:"estimated_delivery"`
 	ActualDelivery    time.Time   `gorm:"type:timestamp" json:"actual_delivery"`
-	TotalAmount       float64     `gorm:"type:decimal(15,2);not null;default:0" json:"total_amount"`
+	Subtotal          Decimal     `gorm:"type:decimal(15,2);not null;default:0" json:"subtotal"`
+	DiscountTotal     Decimal     `gorm:"type:decimal(15,2);not null;default:0" json:"discount_total"`
+	TaxTotal          Decimal     `gorm:"type:decimal(15,2);not null;default:0" json:"tax_total"`
+	TotalAmount       Decimal     `gorm:"type:decimal(15,2);not null;default:0" json:"total_amount"`
 	CreatedAt         time.Time   `gorm:"type:timestamp;not null;default:current_timestamp" json:"created_at"`
 	UpdatedAt         time.Time   `gorm:"type:timestamp;not null;default:current_timestamp" json:"updated_at"`
+	SoftDelete
+	Tenant
+	Versioned
 }
 
 type OrderStatus string
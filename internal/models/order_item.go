@@ -0,0 +1,212 @@
+package models
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type OrderItemRepoImpl struct {
+	*Repository[OrderItem]
+}
+
+func NewOrderItemRepo(db *gorm.DB) *OrderItemRepoImpl {
+	return &OrderItemRepoImpl{NewRepository[OrderItem](db)}
+}
+
+func (g *OrderItemRepoImpl) List(where ...interface{}) (*TableHandler, error) {
+	items, err := g.FindAll(where...)
+	if err != nil {
+		return nil, err
+	}
+	tableHandlerMap := make(map[int]map[string]string)
+	for i, item := range items {
+		tableHandlerMap[i] = map[string]string{
+			"id":         item.ID,
+			"order_id":   item.OrderID,
+			"product_id": fmt.Sprintf("%d", item.ProductID),
+			"quantity":   item.Quantity.StringFixed(3),
+			"unit_price": item.UnitPrice.StringFixed(2),
+			"total":      item.Total.StringFixed(2),
+		}
+	}
+	return &TableHandler{rows: tableHandlerMap}, nil
+}
+
+// orderAmountDigits is the number of integer digits of the decimal(15,2) amount columns.
+const orderAmountDigits = 13
+
+// OrderItem is a line of an order. DiscountRate and TaxRate are fractions (0.2 is 20%) and
+// DiscountAmount is taken off the line after the rate. Subtotal, DiscountTotal, TaxAmount and Total
+// are derived by Calculate and kept up to date, with the totals of the order, on every write. Items
+// only change while their order is a draft.
+type OrderItem struct {
+	ID             string    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrderID        string    `gorm:"type:uuid;not null;index" json:"order_id"`
+	ProductID      uint      `gorm:"not null;index" json:"product_id"`
+	VariantID      string    `gorm:"type:varchar(36)" json:"variant_id,omitempty"`
+	Description    string    `gorm:"type:varchar(255)" json:"description"`
+	Quantity       Decimal   `gorm:"type:decimal(15,3);not null" json:"quantity"`
+	UnitPrice      Decimal   `gorm:"type:decimal(15,4);not null" json:"unit_price"`
+	DiscountRate   Decimal   `gorm:"type:decimal(7,4);not null;default:0" json:"discount_rate"`
+	DiscountAmount Decimal   `gorm:"type:decimal(15,2);not null;default:0" json:"discount_amount"`
+	TaxRate        Decimal   `gorm:"type:decimal(7,4);not null;default:0" json:"tax_rate"`
+	Subtotal       Decimal   `gorm:"type:decimal(15,2);not null;default:0" json:"subtotal"`
+	DiscountTotal  Decimal   `gorm:"type:decimal(15,2);not null;default:0" json:"discount_total"`
+	TaxAmount      Decimal   `gorm:"type:decimal(15,2);not null;default:0" json:"tax_amount"`
+	Total          Decimal   `gorm:"type:decimal(15,2);not null;default:0" json:"total"`
+	CreatedAt      time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"not null" json:"updated_at"`
+	Tenant
+}
+
+func (i *OrderItem) TableName() string {
+	return "order_items"
+}
+
+func (i *OrderItem) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeSave checks the line, its product and variant, and derives its amounts from its quantity,
+// price, discounts and tax. Column updates from maps are derived again after the write.
+func (i *OrderItem) BeforeSave(tx *gorm.DB) (err error) {
+	if _, columns := tx.Statement.Dest.(map[string]interface{}); columns {
+		return nil
+	}
+	if validateErr := i.Validate(); validateErr != nil {
+		return validateErr
+	}
+	var products int64
+	if countErr := tx.Session(&gorm.Session{NewDB: true}).Model(&Product{}).Where("id = ?", i.ProductID).Count(&products).Error; countErr != nil {
+		return countErr
+	}
+	if products == 0 {
+		return &ValidationError{Field: "product_id", Message: fmt.Sprintf("Product %d not found", i.ProductID)}
+	}
+	if i.VariantID != "" {
+		var variants int64
+		if countErr := tx.Session(&gorm.Session{NewDB: true}).Model(&ProductVariant{}).Where("id = ? AND product_id = ?", i.VariantID, i.ProductID).Count(&variants).Error; countErr != nil {
+			return countErr
+		}
+		if variants == 0 {
+			return &ValidationError{Field: "variant_id", Message: fmt.Sprintf("Variant %s of product %d not found", i.VariantID, i.ProductID)}
+		}
+	}
+	return i.Calculate()
+}
+
+func (i *OrderItem) Validate() error {
+	if i.OrderID == "" {
+		return &ValidationError{Field: "order_id", Message: "Order is required"}
+	}
+	if i.ProductID == 0 {
+		return &ValidationError{Field: "product_id", Message: "Product is required"}
+	}
+	if i.Quantity.Sign() <= 0 {
+		return &ValidationError{Field: "quantity", Message: "Quantity must be positive"}
+	}
+	if !i.Quantity.FitsDigits(12) {
+		return &ValidationError{Field: "quantity", Message: "Quantity must be below 10^12"}
+	}
+	if i.UnitPrice.Sign() < 0 {
+		return &ValidationError{Field: "unit_price", Message: "Unit price must not be negative"}
+	}
+	if !i.UnitPrice.FitsDigits(11) {
+		return &ValidationError{Field: "unit_price", Message: "Unit price must be below 10^11"}
+	}
+	one := Decimal{units: decimalUnit.Int64()}
+	if i.DiscountRate.Sign() < 0 || i.DiscountRate.Cmp(one) > 0 {
+		return &ValidationError{Field: "discount_rate", Message: "Discount rate must be between 0 and 1"}
+	}
+	if i.DiscountAmount.Sign() < 0 {
+		return &ValidationError{Field: "discount_amount", Message: "Discount amount must not be negative"}
+	}
+	if !i.DiscountAmount.FitsDigits(orderAmountDigits) {
+		return &ValidationError{Field: "discount_amount", Message: "Discount amount must be below 10^13"}
+	}
+	if i.TaxRate.Sign() < 0 || i.TaxRate.Cmp(one) > 0 {
+		return &ValidationError{Field: "tax_rate", Message: "Tax rate must be between 0 and 1"}
+	}
+	return nil
+}
+
+// Calculate derives the amounts of the line, each rounded to cents:
+//
+//	subtotal = quantity × unit price
+//	discount = subtotal × discount rate + discount amount, at most the subtotal
+//	tax      = (subtotal − discount) × tax rate
+//	total    = subtotal − discount + tax
+//
+// It fails when an amount does not fit its column.
+func (i *OrderItem) Calculate() error {
+	subtotal, mulErr := i.Quantity.Mul(i.UnitPrice)
+	if mulErr != nil || !subtotal.FitsDigits(orderAmountDigits) {
+		return &ValidationError{Field: "subtotal", Message: "Subtotal must be below 10^13"}
+	}
+	i.Subtotal = subtotal.Round(2)
+	rated, mulErr := i.Subtotal.Mul(i.DiscountRate)
+	if mulErr != nil {
+		return mulErr
+	}
+	discount, addErr := rated.Round(2).Add(i.DiscountAmount.Round(2))
+	if addErr != nil {
+		return addErr
+	}
+	if discount.Cmp(i.Subtotal) > 0 {
+		discount = i.Subtotal
+	}
+	i.DiscountTotal = discount
+	net, subErr := i.Subtotal.Sub(discount)
+	if subErr != nil {
+		return subErr
+	}
+	tax, mulErr := net.Mul(i.TaxRate)
+	if mulErr != nil {
+		return mulErr
+	}
+	i.TaxAmount = tax.Round(2)
+	total, addErr := net.Add(i.TaxAmount)
+	if addErr != nil || !total.FitsDigits(orderAmountDigits) {
+		return &ValidationError{Field: "total", Message: "Total must be below 10^13"}
+	}
+	i.Total = total
+	return nil
+}
+
+// OrderTotals are the sums of the amounts of the lines of an order.
+type OrderTotals struct {
+	Subtotal      Decimal `json:"subtotal"`
+	DiscountTotal Decimal `json:"discount_total"`
+	TaxTotal      Decimal `json:"tax_total"`
+	Total         Decimal `json:"total"`
+}
+
+// SumOrderItems adds up the amounts of items, which must have been calculated. It fails when a
+// sum does not fit the columns of the order.
+func SumOrderItems(items []*OrderItem) (OrderTotals, error) {
+	var totals OrderTotals
+	for _, item := range items {
+		for _, amount := range []struct {
+			field      string
+			sum        *Decimal
+			itemAmount Decimal
+		}{
+			{"subtotal", &totals.Subtotal, item.Subtotal},
+			{"discount_total", &totals.DiscountTotal, item.DiscountTotal},
+			{"tax_total", &totals.TaxTotal, item.TaxAmount},
+			{"total_amount", &totals.Total, item.Total},
+		} {
+			sum, addErr := amount.sum.Add(amount.itemAmount)
+			if addErr != nil || !sum.FitsDigits(orderAmountDigits) {
+				return OrderTotals{}, &ValidationError{Field: amount.field, Message: "Order amounts must be below 10^13"}
+			}
+			*amount.sum = sum
+		}
+	}
+	return totals, nil
+}
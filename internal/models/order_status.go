@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OrderTransitions are the statuses an order may move to from each status. Delivered and cancelled
+// orders are final.
+var OrderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusDraft:      {OrderStatusPending, OrderStatusCancelled},
+	OrderStatusPending:    {OrderStatusDraft, OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed:  {OrderStatusProcessing, OrderStatusCancelled},
+	OrderStatusProcessing: {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:    {OrderStatusDelivered},
+	OrderStatusDelivered:  {},
+	OrderStatusCancelled:  {},
+}
+
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+// TransitionError is the error of a status change of an order that is not in OrderTransitions, or
+// whose guard failed; Reason tells which.
+type TransitionError struct {
+	From   OrderStatus
+	To     OrderStatus
+	Reason string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%v from %q to %q: %s", ErrInvalidTransition, e.From, e.To, e.Reason)
+}
+func (e *TransitionError) Unwrap() error { return ErrInvalidTransition }
+
+func (s OrderStatus) Valid() bool {
+	_, ok := OrderTransitions[s]
+	return ok
+}
+
+// CanTransition tells whether an order in status s may move to status to.
+func (s OrderStatus) CanTransition(to OrderStatus) bool {
+	for _, next := range OrderTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderStatusHistory records a status transition of an order.
+type OrderStatusHistory struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	OrderID    string      `gorm:"type:varchar(36);not null;index" json:"order_id"`
+	FromStatus OrderStatus `gorm:"type:varchar(50);not null" json:"from_status"`
+	ToStatus   OrderStatus `gorm:"type:varchar(50);not null" json:"to_status"`
+	Actor      string      `gorm:"type:varchar(255)" json:"actor"`
+	Reason     string      `gorm:"type:text" json:"reason"`
+	CreatedAt  time.Time   `gorm:"not null;index" json:"created_at"`
+	Tenant
+}
+
+func (h *OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOrderStatusValid(t *testing.T) {
+	for status := range OrderTransitions {
+		if !status.Valid() {
+			t.Errorf("%q.Valid() = false, want true", status)
+		}
+	}
+	for _, status := range []OrderStatus{"", "DRAFT", "archived"} {
+		if status.Valid() {
+			t.Errorf("%q.Valid() = true, want false", status)
+		}
+	}
+}
+
+func TestOrderStatusCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{OrderStatusDraft, OrderStatusPending, true},
+		{OrderStatusDraft, OrderStatusCancelled, true},
+		{OrderStatusDraft, OrderStatusConfirmed, false},
+		{OrderStatusPending, OrderStatusDraft, true},
+		{OrderStatusPending, OrderStatusConfirmed, true},
+		{OrderStatusConfirmed, OrderStatusProcessing, true},
+		{OrderStatusConfirmed, OrderStatusShipped, false},
+		{OrderStatusProcessing, OrderStatusShipped, true},
+		{OrderStatusShipped, OrderStatusDelivered, true},
+		{OrderStatusShipped, OrderStatusCancelled, false},
+		{OrderStatusDelivered, OrderStatusCancelled, false},
+		{OrderStatusCancelled, OrderStatusDraft, false},
+		{OrderStatusDraft, OrderStatusDraft, false},
+		{"archived", OrderStatusDraft, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransition(tt.to); got != tt.want {
+				t.Errorf("CanTransition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderTransitionsTargets(t *testing.T) {
+	for from, targets := range OrderTransitions {
+		for _, to := range targets {
+			if !to.Valid() {
+				t.Errorf("OrderTransitions[%q] holds unknown status %q", from, to)
+			}
+		}
+	}
+}
+
+func TestTransitionError(t *testing.T) {
+	err := error(&TransitionError{From: OrderStatusDraft, To: OrderStatusShipped, Reason: "not allowed"})
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("errors.Is(%v, ErrInvalidTransition) = false", err)
+	}
+	if want := `invalid order status transition from "draft" to "shipped": not allowed`; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
@@ -16,69 +16,23 @@ type ProductRepo interface {
 
 	FindAllByDepart(depart string) ([]*Product, error)
 	FindAllByCategory(category string) ([]*Product, error)
+	FindAllInCategory(categoryID uint, subcategories bool) ([]*Product, error)
 }
 
 type ProductRepoImpl struct {
-	*gorm.DB
+	*Repository[Product]
 }
 
 func NewGormProductRepo(db *gorm.DB) *ProductRepoImpl {
-	return &ProductRepoImpl{db}
-}
-
-func (g *ProductRepoImpl) Create(p *Product) (*Product, error) {
-	err := g.DB.Create(p).Error
-	if err != nil {
-		return nil, err
-	}
-	return p, nil
-}
-
-func (g *ProductRepoImpl) FindOne(where ...interface{}) (*Product, error) {
-	var p Product
-	err := g.DB.Where(where[0], where[1:]...).First(&p).Error
-	if err != nil {
-		return nil, err
-	}
-	return &p, nil
-}
-
-func (g *ProductRepoImpl) FindAll(where ...interface{}) ([]*Product, error) {
-	var products []*Product
-	err := g.DB.Where(where[0], where[1:]...).Find(&products).Error
-	if err != nil {
-		return nil, err
-	}
-	return products, nil
-}
-
-func (g *ProductRepoImpl) Update(p *Product) (*Product, error) {
-	err := g.DB.Save(p).Error
-	if err != nil {
-		return nil, err
-	}
-	return p, nil
+	return &ProductRepoImpl{NewRepository[Product](db)}
 }
 
 func (g *ProductRepoImpl) Delete(id uint) error {
-	err := g.DB.Delete(&Product{}, id).Error
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (g *ProductRepoImpl) Close() error {
-	sqlDB, err := g.DB.DB()
-	if err != nil {
-		return err
-	}
-	return sqlDB.Close()
+	return g.Repository.Delete(id)
 }
 
 func (g *ProductRepoImpl) List(where ...interface{}) (*TableHandler, error) {
-	var products []*Product
-	err := g.DB.Where(where[0], where[1:]...).Find(&products).Error
+	products, err := g.FindAll(where...)
 	if err != nil {
 		return nil, err
 	}
@@ -119,18 +73,42 @@ func (g *ProductRepoImpl) FindAllByCategory(category string) ([]*Product, error)
 	return products, nil
 }
 
+// FindAllInCategory returns the products of a category of the tree, and of the categories below it
+// with subcategories.
+func (g *ProductRepoImpl) FindAllInCategory(categoryID uint, subcategories bool) ([]*Product, error) {
+	var products []*Product
+	query := g.DB.Where("category_id = ?", categoryID)
+	if subcategories {
+		var category Category
+		if findErr := g.DB.First(&category, "id = ?", categoryID).Error; findErr != nil {
+			return nil, findErr
+		}
+		query = g.DB.Where("category_id IN (?)", g.DB.Model(&Category{}).Select("id").Where("path LIKE ?", category.Path+"%"))
+	}
+	if findErr := query.Find(&products).Error; findErr != nil {
+		return nil, findErr
+	}
+	return products, nil
+}
+
+// Product is an item of the catalog. CategoryID places it in the category tree; Depart and Category
+// are the names it was filed under before the tree. Products sold in several versions have variants.
 type Product struct {
-	ID       uint    `json:"id" gorm:"primaryKey"`
-	Name     string  `json:"name" gorm:"not null"`
-	Depart   string  `json:"depart" gorm:"not null"`
-	Category string  `json:"category" gorm:"not null"`
-	Price    float64 `json:"price" gorm:"not null"`
-	Cost     float64 `json:"cost" gorm:"not null"`
-	Stock    int     `json:"stock" gorm:"not null"`
-	Reserve  int     `json:"reserve" gorm:"not null"`
-	Balance  int     `json:"balance" gorm:"not null"`
-	Synced   bool    `json:"synced" gorm:"not null"`
-	LastSync string  `json:"last_sync" gorm:"not null"`
+	ID         uint    `json:"id" gorm:"primaryKey"`
+	Name       string  `json:"name" gorm:"not null"`
+	Depart     string  `json:"depart" gorm:"not null"`
+	Category   string  `json:"category" gorm:"not null"`
+	CategoryID *uint   `json:"category_id" gorm:"index"`
+	Price      float64 `json:"price" gorm:"not null"`
+	Cost       float64 `json:"cost" gorm:"not null"`
+	Stock      int     `json:"stock" gorm:"not null"`
+	Reserve    int     `json:"reserve" gorm:"not null"`
+	Balance    int     `json:"balance" gorm:"not null"`
+	Synced     bool    `json:"synced" gorm:"not null"`
+	LastSync   string  `json:"last_sync" gorm:"not null"`
+	SoftDelete
+	Tenant
+	Versioned
 }
 
 func (p *Product) TableName() string {
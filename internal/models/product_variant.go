@@ -0,0 +1,146 @@
+package models
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+type ProductVariantRepoImpl struct {
+	*Repository[ProductVariant]
+}
+
+func NewProductVariantRepo(db *gorm.DB) *ProductVariantRepoImpl {
+	return &ProductVariantRepoImpl{NewRepository[ProductVariant](db)}
+}
+
+func (g *ProductVariantRepoImpl) List(where ...interface{}) (*TableHandler, error) {
+	variants, err := g.FindAll(where...)
+	if err != nil {
+		return nil, err
+	}
+	tableHandlerMap := make(map[int]map[string]string)
+	for i, v := range variants {
+		tableHandlerMap[i] = map[string]string{
+			"id":         v.ID,
+			"product_id": strconv.Itoa(int(v.ProductID)),
+			"sku":        v.SKU,
+			"size":       v.Size,
+			"color":      v.Color,
+			"barcode":    FormatBarcode(v.Barcode, v.BarcodeType),
+		}
+	}
+	return &TableHandler{rows: tableHandlerMap}, nil
+}
+
+// FindByBarcode finds the variant of an EAN-13 or UPC-A barcode, in either form.
+func (g *ProductVariantRepoImpl) FindByBarcode(code string) (*ProductVariant, error) {
+	gtin, _, normalizeErr := NormalizeBarcode(code)
+	if normalizeErr != nil {
+		return nil, normalizeErr
+	}
+	return g.FindOne("barcode = ?", gtin)
+}
+
+func (g *ProductVariantRepoImpl) FindBySKU(sku string) (*ProductVariant, error) {
+	return g.FindOne("sku = ?", sku)
+}
+
+func (g *ProductVariantRepoImpl) FindAllByProduct(productID uint) ([]*ProductVariant, error) {
+	var variants []*ProductVariant
+	if findErr := g.DB.Where("product_id = ?", productID).Order("sku").Find(&variants).Error; findErr != nil {
+		return nil, findErr
+	}
+	return variants, nil
+}
+
+// ProductVariant is a sellable version of a product, e.g. a size and colour, with its own SKU, barcode
+// and stock: inventories with its ID as VariantID. Barcode is stored as a 13 digit GTIN (see
+// NormalizeBarcode); SKUs and barcodes are unique within a tenant. A zero Price is the price of the
+// product.
+type ProductVariant struct {
+	ID          string      `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID   uint        `gorm:"not null;index" json:"product_id"`
+	SKU         string      `gorm:"type:varchar(64);not null;index" json:"sku"`
+	Name        string      `gorm:"type:varchar(255)" json:"name"`
+	Size        string      `gorm:"type:varchar(50)" json:"size"`
+	Color       string      `gorm:"type:varchar(50)" json:"color"`
+	Barcode     string      `gorm:"type:varchar(14);index" json:"barcode"`
+	BarcodeType BarcodeType `gorm:"type:varchar(10)" json:"barcode_type"`
+	Price       float64     `gorm:"type:decimal(15,2);not null;default:0" json:"price"`
+	CreatedAt   time.Time   `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time   `gorm:"not null" json:"updated_at"`
+	Tenant
+	Versioned
+}
+
+func (v *ProductVariant) TableName() string {
+	return "product_variants"
+}
+
+func (v *ProductVariant) BeforeCreate(tx *gorm.DB) (err error) {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (v *ProductVariant) Validate() error {
+	if v.ProductID == 0 {
+		return &ValidationError{Field: "product_id", Message: "Product is required"}
+	}
+	if v.SKU == "" {
+		return &ValidationError{Field: "sku", Message: "SKU is required"}
+	}
+	if v.Price < 0 {
+		return &ValidationError{Field: "price", Message: "Price must not be negative"}
+	}
+	return nil
+}
+
+// BeforeSave normalizes the barcode and checks the product and the uniqueness of the SKU and the
+// barcode.
+func (v *ProductVariant) BeforeSave(tx *gorm.DB) (err error) {
+	if _, columns := tx.Statement.Dest.(map[string]interface{}); columns {
+		return nil
+	}
+	if validateErr := v.Validate(); validateErr != nil {
+		return validateErr
+	}
+	if v.Barcode != "" {
+		gtin, barcodeType, normalizeErr := NormalizeBarcode(v.Barcode)
+		if normalizeErr != nil {
+			return normalizeErr
+		}
+		v.Barcode, v.BarcodeType = gtin, barcodeType
+	} else {
+		v.BarcodeType = ""
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true})
+	var products int64
+	if countErr := db.Model(&Product{}).Where("id = ?", v.ProductID).Count(&products).Error; countErr != nil {
+		return countErr
+	}
+	if products == 0 {
+		return &ValidationError{Field: "product_id", Message: fmt.Sprintf("Product %d not found", v.ProductID)}
+	}
+	var duplicates int64
+	if countErr := db.Model(&ProductVariant{}).Where("sku = ? AND tenant_id = ? AND id <> ?", v.SKU, v.TenantID, v.ID).Count(&duplicates).Error; countErr != nil {
+		return countErr
+	}
+	if duplicates > 0 {
+		return &ValidationError{Field: "sku", Message: fmt.Sprintf("SKU %s is already in use", v.SKU)}
+	}
+	if v.Barcode != "" {
+		if countErr := db.Model(&ProductVariant{}).Where("barcode = ? AND tenant_id = ? AND id <> ?", v.Barcode, v.TenantID, v.ID).Count(&duplicates).Error; countErr != nil {
+			return countErr
+		}
+		if duplicates > 0 {
+			return &ValidationError{Field: "barcode", Message: fmt.Sprintf("Barcode %s is already in use", FormatBarcode(v.Barcode, v.BarcodeType))}
+		}
+	}
+	return nil
+}
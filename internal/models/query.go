@@ -0,0 +1,493 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query is a structured query over a model: filters, sort keys and offset or cursor pagination. Field
+// names are checked against the model schema before anything reaches the SQL, so a Query can come
+// straight from a CLI flag or a broker message, e.g.
+//
+//	{"filters": [{"field": "price", "op": "gt", "value": 10},
+//	             {"or": [{"field": "stock", "op": "eq", "value": 0}, {"field": "name", "op": "like", "value": "%kit%"}]}],
+//	 "sort": ["-price"], "limit": 20}
+//
+// Limit defaults to DefaultPageSize and is capped at MaxPageSize. Cursor takes the NextCursor of the
+// previous page and cannot be combined with Offset.
+type Query struct {
+	Filters []Filter `json:"filters,omitempty"`
+	Sort    []string `json:"sort,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+	Offset  int      `json:"offset,omitempty"`
+	Cursor  string   `json:"cursor,omitempty"`
+}
+
+// Filter is either a condition, Field Op Value, or a group: all the filters in And and at least one of
+// those in Or must match. The filters of a Query are combined with AND.
+type Filter struct {
+	Field string      `json:"field,omitempty"`
+	Op    string      `json:"op,omitempty"`
+	Value interface{} `json:"value"`
+	And   []Filter    `json:"and,omitempty"`
+	Or    []Filter    `json:"or,omitempty"`
+}
+
+// PageInfo describes a page of results. Page is 0 for pages reached with a cursor, and NextCursor is
+// set whenever the page is full.
+type PageInfo struct {
+	Total      int64  `json:"total"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	Pages      int    `json:"pages"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ModelPage is a page of a model only known by its name in ModelRegistryMap.
+type ModelPage struct {
+	Items interface{} `json:"items"`
+	PageInfo
+}
+
+// Filter operators. OpIn and OpNotIn take a list; OpNull takes true (IS NULL) or false (IS NOT NULL).
+const (
+	OpEq    = "eq"
+	OpNe    = "ne"
+	OpGt    = "gt"
+	OpGte   = "gte"
+	OpLt    = "lt"
+	OpLte   = "lte"
+	OpLike  = "like"
+	OpIn    = "in"
+	OpNotIn = "nin"
+	OpNull  = "null"
+)
+
+var (
+	filterOps     = map[string]bool{OpEq: true, OpNe: true, OpGt: true, OpGte: true, OpLt: true, OpLte: true, OpLike: true, OpIn: true, OpNotIn: true, OpNull: true}
+	filterSymbols = map[string]string{"=": OpEq, "==": OpEq, "!=": OpNe, ">": OpGt, ">=": OpGte, "<": OpLt, "<=": OpLte, "~": OpLike}
+	filterPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?::([a-z]+)\s*=|(>=|<=|!=|==|=|>|<|~))\s*(.*?)\s*$`)
+	timeLayouts   = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+)
+
+// ParseFilter parses the filter syntax of the CLI: a condition such as "price>10", "name~kit" (like,
+// wrapped in % unless the pattern has one), "status:in=paid,shipped" or "deleted_at:null=true", or
+// conditions separated by "|", any of which must match. The symbols are =, !=, >, >=, <, <= and ~;
+// every operator is also available as field:op=value.
+func ParseFilter(expr string) (Filter, error) {
+	if parts := strings.Split(expr, "|"); len(parts) > 1 {
+		group := Filter{Or: make([]Filter, 0, len(parts))}
+		for _, part := range parts {
+			filter, parseErr := ParseFilter(part)
+			if parseErr != nil {
+				return Filter{}, parseErr
+			}
+			group.Or = append(group.Or, filter)
+		}
+		return group, nil
+	}
+	match := filterPattern.FindStringSubmatch(expr)
+	if match == nil {
+		return Filter{}, &ValidationError{Field: "filters", Message: fmt.Sprintf("invalid filter %q", expr)}
+	}
+	op := match[2]
+	if op == "" {
+		op = filterSymbols[match[3]]
+	}
+	if !filterOps[op] {
+		return Filter{}, &ValidationError{Field: "filters", Message: fmt.Sprintf("unknown filter operator %q in %q", op, expr)}
+	}
+	value := unquoteFilterValue(match[4])
+	filter := Filter{Field: match[1], Op: op, Value: value}
+	switch {
+	case op == OpIn || op == OpNotIn:
+		values := make([]interface{}, 0)
+		for _, item := range strings.Split(value, ",") {
+			values = append(values, unquoteFilterValue(strings.TrimSpace(item)))
+		}
+		filter.Value = values
+	case op == OpNull && value == "":
+		filter.Value = true
+	case match[3] == "~" && !strings.ContainsAny(value, "%_"):
+		filter.Value = "%" + value + "%"
+	}
+	return filter, nil
+}
+
+// ParseFilters parses each expression with ParseFilter, for filters that must all match.
+func ParseFilters(exprs []string) ([]Filter, error) {
+	filters := make([]Filter, 0, len(exprs))
+	for _, expr := range exprs {
+		filter, parseErr := ParseFilter(expr)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func unquoteFilterValue(value string) string {
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// FindQuery runs q on the model of dest, a pointer to a slice of structs or struct pointers, and fills
+// it with the page found. Conditions already on db are kept.
+func FindQuery(db *gorm.DB, dest interface{}, q Query) (PageInfo, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	if q.Offset < 0 || (q.Offset > 0 && q.Cursor != "") {
+		return PageInfo{}, &ValidationError{Field: "offset", Message: "offset must be positive and cannot be combined with a cursor"}
+	}
+	stmt := &gorm.Statement{DB: db}
+	if parseErr := stmt.Parse(dest); parseErr != nil {
+		return PageInfo{}, parseErr
+	}
+	sch := stmt.Schema
+	conds, condsErr := filterConditions(sch, q.Filters)
+	if condsErr != nil {
+		return PageInfo{}, condsErr
+	}
+	keys, keysErr := sortKeys(sch, q.Sort)
+	if keysErr != nil {
+		return PageInfo{}, keysErr
+	}
+	if pk := sch.PrioritizedPrimaryField; pk != nil && !hasSortKey(keys, pk) {
+		// The primary key breaks ties, so pages never overlap and cursors point to a single row.
+		keys = append(keys, sortKey{field: pk})
+	}
+
+	// Every chain below starts from its own copy of the statement.
+	db = db.Session(&gorm.Session{})
+	base := func() *gorm.DB {
+		tx := db.Model(reflect.New(sch.ModelType).Interface())
+		if len(conds) > 0 {
+			tx = tx.Where(clause.And(conds...))
+		}
+		return tx
+	}
+
+	info := PageInfo{PageSize: q.Limit}
+	if countErr := base().Count(&info.Total).Error; countErr != nil {
+		return PageInfo{}, countErr
+	}
+	info.Pages = int((info.Total + int64(q.Limit) - 1) / int64(q.Limit))
+
+	tx := base()
+	if q.Cursor != "" {
+		after, cursorErr := cursorCondition(keys, q.Cursor)
+		if cursorErr != nil {
+			return PageInfo{}, cursorErr
+		}
+		tx = tx.Where(after)
+	} else {
+		info.Page = q.Offset/q.Limit + 1
+	}
+	if len(keys) > 0 {
+		tx = tx.Order(clause.OrderBy{Columns: orderColumns(keys)})
+	}
+	if findErr := tx.Limit(q.Limit).Offset(q.Offset).Find(dest).Error; findErr != nil {
+		return PageInfo{}, findErr
+	}
+
+	items := reflect.Indirect(reflect.ValueOf(dest))
+	if items.Len() == q.Limit && sch.PrioritizedPrimaryField != nil {
+		cursor, cursorErr := encodeCursor(db, keys, reflect.Indirect(items.Index(items.Len()-1)))
+		if cursorErr != nil {
+			return PageInfo{}, cursorErr
+		}
+		info.NextCursor = cursor
+	}
+	return info, nil
+}
+
+// QueryModel runs q on the model registered as name and returns its page of instances.
+func QueryModel(db *gorm.DB, name string, q Query) (*ModelPage, error) {
+	tp, ok := ModelRegistryMap[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("model %s not found", name)
+	}
+	items := reflect.New(reflect.SliceOf(reflect.PointerTo(tp)))
+	info, findErr := FindQuery(db, items.Interface(), q)
+	if findErr != nil {
+		return nil, findErr
+	}
+	return &ModelPage{Items: items.Elem().Interface(), PageInfo: info}, nil
+}
+
+func filterConditions(sch *schema.Schema, filters []Filter) ([]clause.Expression, error) {
+	conds := make([]clause.Expression, 0, len(filters))
+	for _, filter := range filters {
+		cond, condErr := filter.expression(sch)
+		if condErr != nil {
+			return nil, condErr
+		}
+		conds = append(conds, cond)
+	}
+	return conds, nil
+}
+
+func (f Filter) expression(sch *schema.Schema) (clause.Expression, error) {
+	if f.Field == "" {
+		if len(f.And) == 0 && len(f.Or) == 0 {
+			return nil, &ValidationError{Field: "filters", Message: "filter without a field, and or or"}
+		}
+		conds, andErr := filterConditions(sch, f.And)
+		if andErr != nil {
+			return nil, andErr
+		}
+		if len(f.Or) > 0 {
+			anyOf, orErr := filterConditions(sch, f.Or)
+			if orErr != nil {
+				return nil, orErr
+			}
+			conds = append(conds, clause.Or(anyOf...))
+		}
+		return clause.And(conds...), nil
+	}
+	if len(f.And) > 0 || len(f.Or) > 0 {
+		return nil, &ValidationError{Field: "filters", Message: fmt.Sprintf("filter on %s cannot also be a group", f.Field)}
+	}
+
+	field := sch.LookUpField(f.Field)
+	if field == nil || field.DBName == "" {
+		return nil, &ValidationError{Field: "filters", Message: fmt.Sprintf("unknown filter field %q", f.Field)}
+	}
+	column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	op := strings.ToLower(f.Op)
+	if op == "" {
+		op = OpEq
+	}
+
+	switch op {
+	case OpIn, OpNotIn:
+		list := reflect.ValueOf(f.Value)
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array || list.Len() == 0 {
+			return nil, &ValidationError{Field: "filters", Message: fmt.Sprintf("filter %s on %s needs a list of values", op, f.Field)}
+		}
+		values := make([]interface{}, list.Len())
+		for i := range values {
+			value, valueErr := filterValue(field, list.Index(i).Interface())
+			if valueErr != nil {
+				return nil, valueErr
+			}
+			values[i] = value
+		}
+		if op == OpNotIn {
+			return clause.Not(clause.IN{Column: column, Values: values}), nil
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	case OpNull:
+		isNull := true
+		switch v := f.Value.(type) {
+		case nil:
+		case bool:
+			isNull = v
+		case string:
+			parsed, parseErr := strconv.ParseBool(v)
+			if parseErr != nil {
+				return nil, &ValidationError{Field: "filters", Message: fmt.Sprintf("filter null on %s takes true or false", f.Field)}
+			}
+			isNull = parsed
+		default:
+			return nil, &ValidationError{Field: "filters", Message: fmt.Sprintf("filter null on %s takes true or false", f.Field)}
+		}
+		if isNull {
+			return clause.Eq{Column: column, Value: nil}, nil
+		}
+		return clause.Neq{Column: column, Value: nil}, nil
+	case OpLike:
+		pattern, ok := f.Value.(string)
+		if !ok {
+			return nil, &ValidationError{Field: "filters", Message: fmt.Sprintf("filter like on %s needs a text pattern", f.Field)}
+		}
+		return clause.Like{Column: column, Value: pattern}, nil
+	}
+
+	if !filterOps[op] {
+		return nil, &ValidationError{Field: "filters", Message: fmt.Sprintf("unknown filter operator %q", f.Op)}
+	}
+	value, valueErr := filterValue(field, f.Value)
+	if valueErr != nil {
+		return nil, valueErr
+	}
+	return compareColumn(op, column, value), nil
+}
+
+func compareColumn(op string, column clause.Column, value interface{}) clause.Expression {
+	switch op {
+	case OpNe:
+		return clause.Neq{Column: column, Value: value}
+	case OpGt:
+		return clause.Gt{Column: column, Value: value}
+	case OpGte:
+		return clause.Gte{Column: column, Value: value}
+	case OpLt:
+		return clause.Lt{Column: column, Value: value}
+	case OpLte:
+		return clause.Lte{Column: column, Value: value}
+	}
+	return clause.Eq{Column: column, Value: value}
+}
+
+// filterValue converts text values, from the CLI or a cursor, to the type of field.
+func filterValue(field *schema.Field, value interface{}) (interface{}, error) {
+	if number, ok := value.(json.Number); ok {
+		value = number.String()
+	}
+	text, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	var converted interface{}
+	var convertErr error
+	switch field.DataType {
+	case schema.Int:
+		converted, convertErr = strconv.ParseInt(text, 10, 64)
+	case schema.Uint:
+		converted, convertErr = strconv.ParseUint(text, 10, 64)
+	case schema.Float:
+		converted, convertErr = strconv.ParseFloat(text, 64)
+	case schema.Bool:
+		converted, convertErr = strconv.ParseBool(text)
+	case schema.Time:
+		for _, layout := range timeLayouts {
+			if converted, convertErr = time.Parse(layout, text); convertErr == nil {
+				break
+			}
+		}
+	default:
+		return text, nil
+	}
+	if convertErr != nil {
+		return nil, &ValidationError{Field: "filters", Message: fmt.Sprintf("invalid value %q for field %s", text, field.Name)}
+	}
+	return converted, nil
+}
+
+type sortKey struct {
+	field *schema.Field
+	desc  bool
+}
+
+// sortKeys resolves sort keys, by field name or column and optionally prefixed with "-" for
+// descending order, against the fields of sch.
+func sortKeys(sch *schema.Schema, sort []string) ([]sortKey, error) {
+	keys := make([]sortKey, 0, len(sort)+1)
+	for _, key := range sort {
+		key = strings.TrimSpace(key)
+		desc := strings.HasPrefix(key, "-")
+		name := strings.TrimPrefix(key, "-")
+		field := sch.LookUpField(name)
+		if field == nil || field.DBName == "" {
+			return nil, &ValidationError{Field: "sort", Message: fmt.Sprintf("unknown sort field %q", name)}
+		}
+		keys = append(keys, sortKey{field: field, desc: desc})
+	}
+	return keys, nil
+}
+func hasSortKey(keys []sortKey, field *schema.Field) bool {
+	for _, key := range keys {
+		if key.field.DBName == field.DBName {
+			return true
+		}
+	}
+	return false
+}
+func orderColumns(keys []sortKey) []clause.OrderByColumn {
+	columns := make([]clause.OrderByColumn, 0, len(keys))
+	for _, key := range keys {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: key.field.DBName}, Desc: key.desc})
+	}
+	return columns
+}
+
+// A cursor holds the sort keys and their values in the last row of a page, base64 encoded.
+type queryCursor struct {
+	Sort   []string      `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+func cursorSort(keys []sortKey) []string {
+	sort := make([]string, len(keys))
+	for i, key := range keys {
+		sort[i] = key.field.DBName
+		if key.desc {
+			sort[i] = "-" + sort[i]
+		}
+	}
+	return sort
+}
+func encodeCursor(db *gorm.DB, keys []sortKey, last reflect.Value) (string, error) {
+	cursor := queryCursor{Sort: cursorSort(keys), Values: make([]interface{}, len(keys))}
+	for i, key := range keys {
+		cursor.Values[i], _ = key.field.ValueOf(db.Statement.Context, last)
+	}
+	data, marshalErr := json.Marshal(cursor)
+	if marshalErr != nil {
+		return "", fmt.Errorf("error encoding cursor: %v", marshalErr)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// cursorCondition matches the rows after the cursor in the order of keys: greater on the first key,
+// or equal on it and greater on the next one, and so on, with smaller for descending keys. Cursor
+// pagination needs sort fields without NULLs.
+func cursorCondition(keys []sortKey, encoded string) (clause.Expression, error) {
+	invalid := &ValidationError{Field: "cursor", Message: "invalid cursor"}
+	data, decodeErr := base64.RawURLEncoding.DecodeString(encoded)
+	if decodeErr != nil {
+		return nil, invalid
+	}
+	var cursor queryCursor
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if unmarshalErr := decoder.Decode(&cursor); unmarshalErr != nil || len(cursor.Values) != len(keys) {
+		return nil, invalid
+	}
+	if strings.Join(cursor.Sort, ",") != strings.Join(cursorSort(keys), ",") {
+		return nil, &ValidationError{Field: "cursor", Message: "cursor was issued for another sort order"}
+	}
+
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if cursor.Values[i] == nil {
+			return nil, &ValidationError{Field: "cursor", Message: fmt.Sprintf("cursor pagination cannot sort on %s, it has null values", key.field.Name)}
+		}
+		value, valueErr := filterValue(key.field, cursor.Values[i])
+		if valueErr != nil {
+			return nil, invalid
+		}
+		values[i] = value
+	}
+	anyOf := make([]clause.Expression, 0, len(keys))
+	for i, key := range keys {
+		allOf := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			allOf = append(allOf, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: keys[j].field.DBName}, Value: values[j]})
+		}
+		op := OpGt
+		if key.desc {
+			op = OpLt
+		}
+		allOf = append(allOf, compareColumn(op, clause.Column{Table: clause.CurrentTable, Name: key.field.DBName}, values[i]))
+		anyOf = append(anyOf, clause.And(allOf...))
+	}
+	return clause.Or(anyOf...), nil
+}
@@ -0,0 +1,230 @@
+package models
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// Repository is the typed CRUD shared by the model repositories; T is the model struct, handled as
+// *T. It embeds the connection like the repositories built on it, and WithContext scopes it to a
+// context the way gorm.DB.WithContext does.
+type Repository[T any] struct {
+	*gorm.DB
+}
+
+// PageOptions selects a page of results. Page starts at 1 and PageSize defaults to DefaultPageSize.
+// Sort lists fields, by name or column, optionally prefixed with "-" for descending order.
+type PageOptions struct {
+	Page     int      `json:"page"`
+	PageSize int      `json:"page_size"`
+	Sort     []string `json:"sort,omitempty"`
+}
+
+type Page[T any] struct {
+	Items []*T `json:"items"`
+	PageInfo
+}
+
+func NewRepository[T any](db *gorm.DB) *Repository[T] {
+	return &Repository[T]{db}
+}
+
+func (r *Repository[T]) WithContext(ctx context.Context) *Repository[T] {
+	return &Repository[T]{r.DB.WithContext(ctx)}
+}
+
+// ForTenant scopes the repository to the rows of tenantID, see Tenant.
+func (r *Repository[T]) ForTenant(tenantID string) *Repository[T] {
+	return r.WithContext(WithTenant(r.DB.Statement.Context, tenantID))
+}
+
+// query starts a statement on the model table, filtered by where when one is given.
+func (r *Repository[T]) query(where []interface{}) *gorm.DB {
+	return whereScope(r.DB.Model(new(T)), where)
+}
+
+// whereScope applies the conditions of the where ...interface{} arguments of the repositories, if any.
+func whereScope(db *gorm.DB, where []interface{}) *gorm.DB {
+	if len(where) == 0 {
+		return db
+	}
+	return db.Where(where[0], where[1:]...)
+}
+
+func (r *Repository[T]) Create(m *T) (*T, error) {
+	if err := r.DB.Create(m).Error; err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CreateInBatches inserts items batchSize rows per statement, all in one transaction.
+func (r *Repository[T]) CreateInBatches(items []*T, batchSize int) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultPageSize
+	}
+	return r.DB.CreateInBatches(items, batchSize).Error
+}
+
+func (r *Repository[T]) FindByID(id interface{}) (*T, error) {
+	var m T
+	if err := r.DB.Where(primaryKeyEq(id)).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *Repository[T]) FindOne(where ...interface{}) (*T, error) {
+	var m T
+	if err := r.query(where).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *Repository[T]) FindAll(where ...interface{}) ([]*T, error) {
+	var list []*T
+	if err := r.query(where).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *Repository[T]) Update(m *T) (*T, error) {
+	if err := r.DB.Save(m).Error; err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Modify loads the row with primary key id, applies fn to it and updates it. When another writer
+// updated a Versioned row in between, it starts over, up to DefaultConflictRetries times.
+func (r *Repository[T]) Modify(id interface{}, fn func(m *T) error) (*T, error) {
+	var m *T
+	retryErr := RetryOnConflict(DefaultConflictRetries, func() error {
+		var findErr error
+		if m, findErr = r.FindByID(id); findErr != nil {
+			return findErr
+		}
+		if fnErr := fn(m); fnErr != nil {
+			return fnErr
+		}
+		_, updateErr := r.Update(m)
+		return updateErr
+	})
+	if retryErr != nil {
+		return nil, retryErr
+	}
+	return m, nil
+}
+
+// Delete removes the row with primary key id, or marks it deleted when T embeds SoftDelete, and returns
+// gorm.ErrRecordNotFound when there is none.
+func (r *Repository[T]) Delete(id interface{}) error {
+	result := r.DB.Where(primaryKeyEq(id)).Delete(new(T))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Restore undeletes a soft-deleted row; T must embed SoftDelete. See RestoreRecord.
+func (r *Repository[T]) Restore(id interface{}) error {
+	return RestoreRecord(r.DB, new(T), id)
+}
+
+// ForceDelete removes the row with primary key id even when T has soft delete.
+func (r *Repository[T]) ForceDelete(id interface{}) error {
+	return (&Repository[T]{r.DB.Unscoped()}).Delete(id)
+}
+
+func (r *Repository[T]) Count(where ...interface{}) (int64, error) {
+	var count int64
+	if err := r.query(where).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Paginate returns the page of opts among the rows matching where, with the total row count.
+func (r *Repository[T]) Paginate(opts PageOptions, where ...interface{}) (*Page[T], error) {
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	if opts.PageSize > MaxPageSize {
+		opts.PageSize = MaxPageSize
+	}
+	order, orderErr := r.orderBy(opts.Sort)
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	page := &Page[T]{PageInfo: PageInfo{Page: opts.Page, PageSize: opts.PageSize}}
+	if err := r.query(where).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+	page.Pages = int((page.Total + int64(opts.PageSize) - 1) / int64(opts.PageSize))
+
+	query := r.query(where)
+	if len(order) > 0 {
+		query = query.Order(clause.OrderBy{Columns: order})
+	}
+	if err := query.Limit(opts.PageSize).Offset((opts.Page - 1) * opts.PageSize).Find(&page.Items).Error; err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Search runs a structured Query; see FindQuery.
+func (r *Repository[T]) Search(q Query) (*Page[T], error) {
+	page := &Page[T]{}
+	info, err := FindQuery(r.DB, &page.Items, q)
+	if err != nil {
+		return nil, err
+	}
+	page.PageInfo = info
+	return page, nil
+}
+
+func (r *Repository[T]) Close() error {
+	sqlDB, err := r.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// orderBy resolves sort keys against the fields of T, so they never reach the SQL unchecked.
+func (r *Repository[T]) orderBy(sort []string) ([]clause.OrderByColumn, error) {
+	if len(sort) == 0 {
+		return nil, nil
+	}
+	stmt := &gorm.Statement{DB: r.DB}
+	if parseErr := stmt.Parse(new(T)); parseErr != nil {
+		return nil, parseErr
+	}
+	keys, keysErr := sortKeys(stmt.Schema, sort)
+	if keysErr != nil {
+		return nil, keysErr
+	}
+	return orderColumns(keys), nil
+}
+
+func primaryKeyEq(id interface{}) clause.Eq {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}, Value: id}
+}
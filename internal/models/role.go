@@ -26,7 +26,7 @@ func (g *RoleRepoImpl) Create(u *Role) (*Role, error) {
 }
 func (g *RoleRepoImpl) FindOne(where ...interface{}) (*Role, error) {
 	var u Role
-	err := g.DB.Where(where[0], where[1:]).First(&u).Error // Use a pointer to u
+	err := whereScope(g.DB, where).First(&u).Error // Use a pointer to u
 	if err != nil {
 		//return nil, logz.ErrorLog(fmt.Sprintf("RoleImpl repository: failed to find RoleImpl: %v", err), "GDBase")
 		return nil, fmt.Errorf("RoleImpl repository: failed to find RoleImpl: %w", err)
@@ -35,7 +35,7 @@ func (g *RoleRepoImpl) FindOne(where ...interface{}) (*Role, error) {
 }
 func (g *RoleRepoImpl) FindAll(where ...interface{}) ([]*Role, error) {
 	var roles []*Role
-	err := g.DB.Where(where[0], where[1:]).Find(&roles).Error
+	err := whereScope(g.DB, where).Find(&roles).Error
 	if err != nil {
 		return nil, fmt.Errorf("RoleImpl repository: failed to find all roles: %w", err)
 	}
@@ -64,7 +64,7 @@ func (g *RoleRepoImpl) Close() error {
 }
 func (g *RoleRepoImpl) List(where ...interface{}) (*TableHandler, error) {
 	var roles []Role
-	err := g.DB.Where(where[0], where[1:]).Find(&roles).Error
+	err := whereScope(g.DB, where).Find(&roles).Error
 	if err != nil {
 		return nil, fmt.Errorf("RoleImpl repository: failed to list roles: %w", err)
 	}
@@ -103,10 +103,10 @@ type Role interface {
 	Validate() error
 }
 type RoleImpl struct {
-	ID          string `gorm:"primaryKey" json:"id;omitempty" form:"id;omitempty"`
-	Name        string `gorm:"unique" json:"name;omitempty" form:"name;omitempty"`
-	Description string `json:"description;omitempty" form:"description;omitempty"`
-	Active      bool   `gorm:"default:true" json:"active;omitempty" form:"active;omitempty"`
+	ID          string `gorm:"primaryKey" json:"id,omitempty" form:"id,omitempty"`
+	Name        string `gorm:"unique" json:"name,omitempty" form:"name,omitempty"`
+	Description string `json:"description,omitempty" form:"description,omitempty"`
+	Active      bool   `gorm:"default:true" json:"active,omitempty" form:"active,omitempty"`
 }
 
 func (u *RoleImpl) GetID() string                     { return u.ID }
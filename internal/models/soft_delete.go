@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+// SoftDelete opts a model into soft delete when embedded: Delete sets deleted_at instead of removing
+// the row, queries skip deleted rows unless Unscoped, and RestoreRecord brings them back. Dynamic
+// models opt in with soft_delete: true.
+type SoftDelete struct {
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// SoftDeleteField returns the gorm.DeletedAt field of sch, or nil when the model has no soft delete.
+func SoftDeleteField(sch *schema.Schema) *schema.Field {
+	for _, field := range sch.Fields {
+		if field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) && field.DBName != "" {
+			return field
+		}
+	}
+	return nil
+}
+
+// RestoreRecord undeletes the soft-deleted row of model with primary key id. It returns
+// gorm.ErrRecordNotFound when no deleted row has that key.
+func RestoreRecord(db *gorm.DB, model interface{}, id interface{}) error {
+	stmt := &gorm.Statement{DB: db}
+	if parseErr := stmt.Parse(model); parseErr != nil {
+		return parseErr
+	}
+	field := SoftDeleteField(stmt.Schema)
+	if field == nil {
+		return &ValidationError{Field: "model", Message: fmt.Sprintf("model %s has no soft delete", stmt.Schema.Name)}
+	}
+	result := db.Unscoped().Model(reflect.New(stmt.Schema.ModelType).Interface()).
+		Where(primaryKeyEq(id)).
+		Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: nil}).
+		Update(field.DBName, nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// StockReservation holds Quantity of an inventory for an order until ExpiresAt. While active it is
+// counted in Inventory.Reserved; it ends consumed (issued for the order), released or expired.
+type StockReservation struct {
+	ID          string            `gorm:"type:uuid;primaryKey" json:"id"`
+	InventoryID string            `gorm:"type:uuid;not null;index" json:"inventory_id"`
+	ProductID   string            `gorm:"type:uuid;not null" json:"product_id"`
+	VariantID   string            `gorm:"type:varchar(36)" json:"variant_id,omitempty"`
+	WarehouseID string            `gorm:"type:uuid;not null" json:"warehouse_id"`
+	OrderID     string            `gorm:"type:varchar(36);index" json:"order_id"`
+	Quantity    float64           `gorm:"type:decimal(15,3);not null" json:"quantity"`
+	Status      ReservationStatus `gorm:"type:varchar(20);not null;default:'active';index:idx_stock_reservations_expiry,priority:1" json:"status"`
+	ExpiresAt   time.Time         `gorm:"not null;index:idx_stock_reservations_expiry,priority:2" json:"expires_at"`
+	CreatedAt   time.Time         `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time         `gorm:"not null" json:"updated_at"`
+	Tenant
+	Versioned
+}
+
+type ReservationStatus string
+
+const (
+	ReservationActive   ReservationStatus = "active"
+	ReservationConsumed ReservationStatus = "consumed"
+	ReservationReleased ReservationStatus = "released"
+	ReservationExpired  ReservationStatus = "expired"
+)
+
+func (r *StockReservation) TableName() string {
+	return "stock_reservations"
+}
+
+func (r *StockReservation) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Tenant opts a model into tenant isolation when embedded. With database.multi_tenant enabled, its
+// rows are only read, written and deleted within the tenant of the context (WithTenant), and new rows
+// take that tenant. Rows of every tenant are only reachable with a SystemContext.
+type Tenant struct {
+	TenantID string `gorm:"type:varchar(64);not null;default:'';index" json:"tenant_id" tenant:"scope"`
+}
+
+var (
+	ErrTenantRequired = errors.New("tenant required: use WithTenant, or SystemContext for cross-tenant access")
+	ErrTenantMismatch = errors.New("record belongs to another tenant")
+)
+
+type tenantCtxKey struct{}
+type systemCtxKey struct{}
+
+// WithTenant scopes the queries run with db.WithContext(ctx) to tenantID.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set with WithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenantID, ok := ctx.Value(tenantCtxKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// SystemContext lifts tenant isolation for the queries run with db.WithContext(ctx), e.g. for
+// migrations, backups or an operator tool. A tenant set on ctx is ignored.
+func SystemContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, systemCtxKey{}, true)
+}
+
+func IsSystemContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	system, _ := ctx.Value(systemCtxKey{}).(bool)
+	return system
+}
+
+// TenantField returns the tenant column of sch, or nil when the model is shared by all tenants.
+func TenantField(sch *schema.Schema) *schema.Field {
+	for _, field := range sch.Fields {
+		if field.Tag.Get("tenant") == "scope" && field.DBName != "" {
+			return field
+		}
+	}
+	return nil
+}
+
+// TenantScope is a GORM scope restricting a query to the rows of tenantID, for explicit use under a
+// SystemContext; otherwise the tenant of the context is applied on its own.
+func TenantScope(tenantID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		stmt := &gorm.Statement{DB: db}
+		model := db.Statement.Model
+		if model == nil {
+			model = db.Statement.Dest
+		}
+		if model == nil || stmt.Parse(model) != nil {
+			_ = db.AddError(errors.New("tenant scope needs a model"))
+			return db
+		}
+		field := TenantField(stmt.Schema)
+		if field == nil {
+			return db
+		}
+		return db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenantID})
+	}
+}
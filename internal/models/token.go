@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	//"github.com/faelmori/logz"
+	"github.com/goccy/go-json"
 	"github.com/google/uuid"
 	"log"
 	"strings"
@@ -16,6 +17,23 @@ type idTokenCustomClaims struct {
 	User User `json:"UserImpl"`
 	jwt.StandardClaims
 }
+
+// UnmarshalJSON decodes the embedded user as a UserImpl, since the User interface can't be decoded.
+func (c *idTokenCustomClaims) UnmarshalJSON(data []byte) error {
+	var claims struct {
+		User *UserImpl `json:"UserImpl"`
+		jwt.StandardClaims
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+	c.StandardClaims = claims.StandardClaims
+	if claims.User != nil {
+		c.User = claims.User
+	}
+	return nil
+}
+
 type TokenService interface {
 	NewPairFromUser(ctx context.Context, u User, prevTokenID string) (*TokenPair, error)
 	SignOut(ctx context.Context, uid string) error
@@ -16,66 +16,52 @@ type UserRepo interface {
 	Close() error
 	List(where ...interface{}) (TableHandler, error)
 }
-type UserRepoImpl struct{ *gorm.DB }
+type UserRepoImpl struct{ *Repository[UserImpl] }
 
 func NewUserRepo(db *gorm.DB) UserRepo {
-	return &UserRepoImpl{db}
+	return &UserRepoImpl{NewRepository[UserImpl](db)}
 }
 
 func (g *UserRepoImpl) Create(u User) (User, error) {
-	iUser := u.getUserObj()
-
-	err := g.DB.Create(&iUser).Error
+	iUser, err := g.Repository.Create(u.getUserObj())
 	if err != nil {
 		return nil, fmt.Errorf("UserImpl repository: failed to create UserImpl: %w", err)
 	}
 	return iUser, nil
 }
 func (g *UserRepoImpl) FindOne(where ...interface{}) (User, error) {
-	var u UserImpl
-	err := g.DB.Where(where[0], where[1:]...).First(&u).Error
+	u, err := g.Repository.FindOne(where...)
 	if err != nil {
 		return nil, fmt.Errorf("UserImpl repository: failed to find UserImpl: %w", err)
 	}
-	return &u, nil
+	return u, nil
 }
 func (g *UserRepoImpl) FindAll(where ...interface{}) ([]User, error) {
-	var us []UserImpl
-	err := g.DB.Where(where[0], where[1:]...).Find(&us).Error
+	us, err := g.Repository.FindAll(where...)
 	if err != nil {
 		return nil, fmt.Errorf("UserImpl repository: failed to find all users: %w", err)
 	}
 	ius := make([]User, len(us))
 	for i, usr := range us {
-		ius[i] = &usr
+		ius[i] = usr
 	}
 	return ius, nil
 }
 func (g *UserRepoImpl) Update(u User) (User, error) {
-	usr := u.getUserObj()
-	err := g.DB.Save(&usr).Error
+	usr, err := g.Repository.Update(u.getUserObj())
 	if err != nil {
 		return nil, fmt.Errorf("UserImpl repository: failed to update UserImpl: %w", err)
 	}
 	return usr, nil
 }
 func (g *UserRepoImpl) Delete(id string) error {
-	err := g.DB.Delete(&UserImpl{}, id).Error
-	if err != nil {
+	if err := g.Repository.Delete(id); err != nil {
 		return fmt.Errorf("UserImpl repository: failed to delete UserImpl: %w", err)
 	}
 	return nil
 }
-func (g *UserRepoImpl) Close() error {
-	sqlDB, err := g.DB.DB()
-	if err != nil {
-		return err
-	}
-	return sqlDB.Close()
-}
 func (g *UserRepoImpl) List(where ...interface{}) (TableHandler, error) {
-	var users []UserImpl
-	err := g.DB.Where(where[0], where[1:]...).Find(&users).Error
+	users, err := g.Repository.FindAll(where...)
 	if err != nil {
 		return TableHandler{}, fmt.Errorf("UserImpl repository: failed to list users: %w", err)
 	}
@@ -108,6 +94,7 @@ type User interface {
 	GetAvatar() string
 	GetPicture() string
 	GetActive() bool
+	GetTenantID() string
 	SetName(name string)
 	SetUsername(username string)
 	SetPassword(password string) error
@@ -133,7 +120,7 @@ type UserImpl struct {
 	ID       string `gorm:"type:uuid;primaryKey" json:"id"`
 	Name     string `gorm:"type:varchar(255);not null" json:"name"`
 	Username string `gorm:"type:varchar(255);unique;not null" json:"username"`
-	Password string `gorm:"type:varchar(255);not null" json:"password"`
+	Password string `gorm:"type:varchar(255);not null" json:"password" audit:"-"`
 	Email    string `gorm:"type:varchar(255);unique;not null" json:"email"`
 	Phone    string `gorm:"type:varchar(20)" json:"phone"`
 	RoleID   uint   `gorm:"type:integer;default:2" json:"role_id"`
@@ -147,6 +134,7 @@ type UserImpl struct {
 	Avatar   string `gorm:"type:varchar(255)" json:"avatar"`
 	Picture  string `gorm:"type:varchar(255)" json:"picture"`
 	Active   bool   `gorm:"type:boolean;default:true" json:"active"`
+	Tenant
 }
 
 func (u *UserImpl) TableName() string {
@@ -301,6 +289,9 @@ func (u *UserImpl) GetPicture() string {
 func (u *UserImpl) GetActive() bool {
 	return u.Active
 }
+func (u *UserImpl) GetTenantID() string {
+	return u.TenantID
+}
 func (u *UserImpl) CheckPasswordHash(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	return err == nil
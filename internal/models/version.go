@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultConflictRetries is how many times RetryOnConflict and Repository.Modify run a
+// read-modify-write closure before giving up.
+const DefaultConflictRetries = 3
+
+// Versioned opts a model into optimistic locking when embedded. An update of a model whose version is
+// set only applies to the row at that version and increments it: when another writer changed the row
+// since it was read, the update fails with a *ConflictError. Updates of a model without the version
+// (zero) are not checked; column updates from maps still increment it. Dynamic models opt in with
+// versioned: true.
+type Versioned struct {
+	Version int64 `gorm:"not null;default:1" json:"version" version:"lock"`
+}
+
+var ErrConflict = errors.New("record was changed by another writer")
+
+// ConflictError is the error of an update that lost a race: the row of table with primary key ID is
+// no longer at Version.
+type ConflictError struct {
+	Table   string
+	ID      interface{}
+	Version int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %v is no longer at version %d: %v", e.Table, e.ID, e.Version, ErrConflict)
+}
+func (e *ConflictError) Unwrap() error { return ErrConflict }
+
+// VersionField returns the version column of sch, or nil when the model has no optimistic locking.
+func VersionField(sch *schema.Schema) *schema.Field {
+	for _, field := range sch.Fields {
+		if field.Tag.Get("version") == "lock" && field.DBName != "" {
+			return field
+		}
+	}
+	return nil
+}
+
+// RetryOnConflict runs fn, which should read the record, change it and update it, again while it
+// fails with a ConflictError, up to attempts times.
+func RetryOnConflict(attempts int, fn func() error) error {
+	if attempts <= 0 {
+		attempts = DefaultConflictRetries
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
@@ -17,66 +17,19 @@ type WarehouseRepo interface {
 }
 
 type WarehouseRepoImpl struct {
-	*gorm.DB
+	*Repository[Warehouse]
 }
 
 func NewWarehouseRepo(db *gorm.DB) *WarehouseRepoImpl {
-	return &WarehouseRepoImpl{db}
-}
-
-func (g *WarehouseRepoImpl) Create(w *Warehouse) (*Warehouse, error) {
-	err := g.DB.Create(w).Error
-	if err != nil {
-		return nil, err
-	}
-	return w, nil
-}
-
-func (g *WarehouseRepoImpl) FindOne(where ...interface{}) (*Warehouse, error) {
-	var w Warehouse
-	err := g.DB.Where(where[0], where[1:]...).First(&w).Error
-	if err != nil {
-		return nil, err
-	}
-	return &w, nil
-}
-
-func (g *WarehouseRepoImpl) FindAll(where ...interface{}) ([]*Warehouse, error) {
-	var warehouses []*Warehouse
-	err := g.DB.Where(where[0], where[1:]...).Find(&warehouses).Error
-	if err != nil {
-		return nil, err
-	}
-	return warehouses, nil
-}
-
-func (g *WarehouseRepoImpl) Update(w *Warehouse) (*Warehouse, error) {
-	err := g.DB.Save(w).Error
-	if err != nil {
-		return nil, err
-	}
-	return w, nil
+	return &WarehouseRepoImpl{NewRepository[Warehouse](db)}
 }
 
 func (g *WarehouseRepoImpl) Delete(id string) error {
-	err := g.DB.Delete(&Warehouse{}, id).Error
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (g *WarehouseRepoImpl) Close() error {
-	sqlDB, err := g.DB.DB()
-	if err != nil {
-		return err
-	}
-	return sqlDB.Close()
+	return g.Repository.Delete(id)
 }
 
 func (g *WarehouseRepoImpl) List(where ...interface{}) (*TableHandler, error) {
-	var warehouses []*Warehouse
-	err := g.DB.Where(where[0], where[1:]...).Find(&warehouses).Error
+	warehouses, err := g.FindAll(where...)
 	if err != nil {
 		return nil, err
 	}
@@ -106,6 +59,9 @@ type Warehouse struct {
 	Country    string `gorm:"type:varchar(50);default:'Brasil'" json:"country"`
 	PostalCode string `gorm:"type:varchar(20)" json:"postal_code"`
 	Active     bool   `gorm:"type:boolean;default:true" json:"active"`
+	SoftDelete
+	Tenant
+	Versioned
 }
 
 func (w *Warehouse) TableName() string {
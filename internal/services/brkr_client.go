@@ -0,0 +1,233 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pebbe/zmq4"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	BrokerRequestTimeout = 10 * time.Second
+)
+
+// BrokerClient is a DEALER connection to the broker FRONTEND. The socket is owned by a single loop
+// goroutine; requests and stream frames are queued to it and replies are dispatched back. Requests
+// are sent after a frame with their ID, which the worker echoes, so each reply goes to the request
+// waiting for it and a reply arriving after its request gave up is dropped.
+type BrokerClient struct {
+	context  *zmq4.Context
+	socket   *zmq4.Socket
+	address  string
+	outbound chan []interface{}
+	pending  map[string]chan string
+	streams  map[string]*brokerStream
+	mu       sync.Mutex
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewBrokerClient(address string) (*BrokerClient, error) {
+	if address == "" {
+		address = "tcp://127.0.0.1:5555"
+	}
+	ctx, err := zmq4.NewContext()
+	if err != nil {
+		return nil, fmt.Errorf("error creating ZMQ context: %v", err)
+	}
+	socket, err := ctx.NewSocket(zmq4.DEALER)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client (DEALER): %v", err)
+	}
+	if connErr := socket.Connect(address); connErr != nil {
+		_ = socket.Close()
+		return nil, fmt.Errorf("error connecting to broker %s: %v", address, connErr)
+	}
+
+	c := &BrokerClient{
+		context:  ctx,
+		socket:   socket,
+		address:  address,
+		outbound: make(chan []interface{}, 256),
+		pending:  make(map[string]chan string),
+		streams:  make(map[string]*brokerStream),
+		done:     make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.loop()
+	return c, nil
+}
+
+func (c *BrokerClient) loop() {
+	defer c.wg.Done()
+	poller := zmq4.NewPoller()
+	poller.Add(c.socket, zmq4.POLLIN)
+
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		c.flushOutbound()
+		polled, pollErr := poller.Poll(StreamPollInterval)
+		if pollErr != nil || len(polled) == 0 {
+			continue
+		}
+		msg, recvErr := c.socket.RecvMessage(0)
+		if recvErr != nil || len(msg) == 0 {
+			continue
+		}
+		if _, frame, isStream, streamErr := splitStreamMessage(msg); isStream {
+			if streamErr != nil {
+				continue
+			}
+			c.mu.Lock()
+			stream := c.streams[frame.header.ID]
+			c.mu.Unlock()
+			if stream != nil {
+				stream.deliver(frame)
+			}
+			continue
+		}
+		if len(msg) < 2 {
+			continue
+		}
+		c.mu.Lock()
+		reply := c.pending[msg[0]]
+		delete(c.pending, msg[0])
+		c.mu.Unlock()
+		if reply != nil {
+			reply <- msg[len(msg)-1]
+		}
+	}
+}
+func (c *BrokerClient) flushOutbound() {
+	for {
+		select {
+		case parts := <-c.outbound:
+			_, _ = c.socket.SendMessage(parts...)
+		default:
+			return
+		}
+	}
+}
+
+// Request sends a payload (usually a serialized model registry) and waits for its reply.
+func (c *BrokerClient) Request(payload string) (string, error) {
+	id := uuid.New().String()
+	reply := make(chan string, 1)
+	c.mu.Lock()
+	c.pending[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	select {
+	case c.outbound <- []interface{}{id, payload}:
+	case <-c.done:
+		return "", fmt.Errorf("broker client closed")
+	}
+	select {
+	case r := <-reply:
+		return r, nil
+	case <-c.done:
+		return "", fmt.Errorf("broker client closed")
+	case <-time.After(BrokerRequestTimeout):
+		return "", fmt.Errorf("timeout waiting for broker reply from %s", c.address)
+	}
+}
+
+// Send sends a payload that gets no reply, e.g. a JSON-RPC notification, without waiting.
+func (c *BrokerClient) Send(payload string) error {
+	select {
+	case c.outbound <- []interface{}{uuid.New().String(), payload}:
+		return nil
+	case <-c.done:
+		return fmt.Errorf("broker client closed")
+	}
+}
+
+// OpenStream starts a streamed request of the given type. Everything written to the returned writer
+// is sent to the worker in chunks; the reply is read from the returned reader until io.EOF. Once the
+// reply has ended, writes fail with ErrStreamPeerClosed.
+func (c *BrokerClient) OpenStream(tp string) (*StreamWriter, *StreamReader, error) {
+	id := uuid.New().String()
+	stream := newBrokerStream(id, func(h StreamHeader, body []byte) error {
+		parts, encodeErr := encodeStreamFrame(h, body)
+		if encodeErr != nil {
+			return encodeErr
+		}
+		select {
+		case c.outbound <- parts:
+			return nil
+		case <-c.done:
+			return fmt.Errorf("broker client closed")
+		}
+	}, func() {
+		c.mu.Lock()
+		delete(c.streams, id)
+		c.mu.Unlock()
+	})
+
+	c.mu.Lock()
+	c.streams[id] = stream
+	c.mu.Unlock()
+
+	writer := newStreamWriter(stream, tp)
+	if openErr := writer.open(); openErr != nil {
+		stream.finish()
+		return nil, nil, openErr
+	}
+	reader := newStreamReader(stream)
+	reader.final = true
+	return writer, reader, nil
+}
+
+// StreamRequest copies body to a new stream of type tp while copying the reply into reply, so large
+// payloads never need to be held in memory on either side. A reply that ends before the whole body
+// was sent is complete: the rest of the body is not sent.
+func (c *BrokerClient) StreamRequest(tp string, body io.Reader, reply io.Writer) error {
+	writer, reader, openErr := c.OpenStream(tp)
+	if openErr != nil {
+		return openErr
+	}
+	writeErrCh := make(chan error, 1)
+	go func() {
+		if _, copyErr := io.Copy(writer, body); copyErr != nil {
+			if !errors.Is(copyErr, ErrStreamPeerClosed) {
+				_ = writer.Abort(copyErr)
+			}
+			writeErrCh <- copyErr
+			return
+		}
+		writeErrCh <- writer.Close()
+	}()
+	if _, readErr := io.Copy(reply, reader); readErr != nil {
+		return readErr
+	}
+	if writeErr := <-writeErrCh; writeErr != nil && !errors.Is(writeErr, ErrStreamPeerClosed) {
+		return writeErr
+	}
+	return nil
+}
+
+func (c *BrokerClient) Close() error {
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	close(c.done)
+	c.wg.Wait()
+	if closeErr := c.socket.Close(); closeErr != nil {
+		return closeErr
+	}
+	return c.context.Term()
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"github.com/faelmori/logz"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EventBufferSize = 64
+)
+
+// BrokerEvent is a notification published on a topic. Topics are dot separated ("model.product.created");
+// subscriptions match a topic exactly, by prefix with a trailing ".*" ("model.*") or everything with "*".
+// Events of tenant data carry the tenant, and are only relayed to the clients of that tenant.
+type BrokerEvent struct {
+	Topic  string      `json:"topic"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data,omitempty"`
+	Tenant string      `json:"tenant,omitempty"`
+}
+
+type EventHub struct {
+	mu   sync.RWMutex
+	subs map[*EventSubscription]struct{}
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subs: make(map[*EventSubscription]struct{})}
+}
+
+// Publish delivers the event to every matching subscription without blocking; a subscriber whose
+// buffer is full misses the event.
+func (h *EventHub) Publish(topic string, data interface{}) {
+	h.PublishTenant("", topic, data)
+}
+
+// PublishTenant publishes an event about data of tenantID; an empty tenantID is Publish.
+func (h *EventHub) PublishTenant(tenantID, topic string, data interface{}) {
+	if h == nil {
+		return
+	}
+	event := BrokerEvent{Topic: topic, Time: time.Now(), Data: data, Tenant: tenantID}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if !sub.Matches(topic) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			logz.Debug("Event dropped for slow subscriber", map[string]interface{}{
+				"context": "EventHub",
+				"topic":   topic,
+			})
+		}
+	}
+}
+func (h *EventHub) Subscribe(topics ...string) *EventSubscription {
+	sub := &EventSubscription{
+		hub:    h,
+		topics: make(map[string]struct{}),
+		events: make(chan BrokerEvent, EventBufferSize),
+	}
+	sub.Add(topics...)
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+type EventSubscription struct {
+	hub       *EventHub
+	mu        sync.RWMutex
+	topics    map[string]struct{}
+	events    chan BrokerEvent
+	closeOnce sync.Once
+}
+
+// Events returns the channel events are delivered on; it is closed by Close.
+func (s *EventSubscription) Events() <-chan BrokerEvent { return s.events }
+
+func (s *EventSubscription) Add(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, topic := range topics {
+		if topic != "" {
+			s.topics[topic] = struct{}{}
+		}
+	}
+}
+func (s *EventSubscription) Remove(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, topic := range topics {
+		delete(s.topics, topic)
+	}
+}
+func (s *EventSubscription) Topics() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	topics := make([]string, 0, len(s.topics))
+	for topic := range s.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+func (s *EventSubscription) Matches(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for pattern := range s.topics {
+		if matchTopic(pattern, topic) {
+			return true
+		}
+	}
+	return false
+}
+func (s *EventSubscription) Close() {
+	s.closeOnce.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subs, s)
+		s.hub.mu.Unlock()
+		close(s.events)
+	})
+}
+
+func matchTopic(pattern, topic string) bool {
+	if pattern == "*" || pattern == topic {
+		return true
+	}
+	if strings.HasSuffix(pattern, ".*") {
+		return strings.HasPrefix(topic, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"github.com/goccy/go-json"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	CaptureDirectionIn  = "in"  // client -> broker
+	CaptureDirectionOut = "out" // broker -> client
+)
+
+// CaptureRecord is one line of a capture file: a message seen by the broker proxy, without the
+// routing identity frame, which is kept hex encoded apart so replays can group by client.
+type CaptureRecord struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Identity  string    `json:"identity"`
+	Frames    [][]byte  `json:"frames"`
+}
+
+type TrafficRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+}
+
+func NewTrafficRecorder(path string) (*TrafficRecorder, error) {
+	file, openErr := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if openErr != nil {
+		return nil, fmt.Errorf("error opening capture file: %v", openErr)
+	}
+	buf := bufio.NewWriter(file)
+	return &TrafficRecorder{file: file, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+func (r *TrafficRecorder) Record(direction string, msg [][]byte) error {
+	if r == nil || len(msg) == 0 {
+		return nil
+	}
+	record := CaptureRecord{
+		Time:      time.Now(),
+		Direction: direction,
+		Identity:  hex.EncodeToString(msg[0]),
+		Frames:    msg[1:],
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if encodeErr := r.enc.Encode(record); encodeErr != nil {
+		return fmt.Errorf("error writing capture record: %v", encodeErr)
+	}
+	return r.buf.Flush()
+}
+
+func (r *TrafficRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if flushErr := r.buf.Flush(); flushErr != nil {
+		return flushErr
+	}
+	return r.file.Close()
+}
+
+func LoadCapture(path string) ([]CaptureRecord, error) {
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return nil, fmt.Errorf("error opening capture file: %v", openErr)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var records []CaptureRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record CaptureRecord
+		if unmarshalErr := json.Unmarshal(scanner.Bytes(), &record); unmarshalErr != nil {
+			return nil, fmt.Errorf("error reading capture line %d: %v", line, unmarshalErr)
+		}
+		records = append(records, record)
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, fmt.Errorf("error reading capture file: %v", scanErr)
+	}
+	return records, nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pebbe/zmq4"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+type ReplayOptions struct {
+	Address string
+	// Speed scales the original gaps between requests: 1 keeps them, 2 halves them and 0 sends
+	// everything back to back.
+	Speed float64
+	// Diff compares the replies received during the replay with the ones in the capture.
+	Diff bool
+	// Wait is how long to keep collecting replies after the last request was sent.
+	Wait   time.Duration
+	Output io.Writer
+}
+
+type ReplayResult struct {
+	Sent       int
+	Received   int
+	Expected   int
+	Mismatched int
+}
+
+type replayPeer struct {
+	socket   *zmq4.Socket
+	expected [][][]byte
+	received [][][]byte
+}
+
+// ReplayCapture resends every client message of a capture against a broker. Each client identity of
+// the capture gets its own connection so replies are routed back the same way they were recorded.
+func ReplayCapture(path string, opts ReplayOptions) (*ReplayResult, error) {
+	records, loadErr := LoadCapture(path)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	if opts.Address == "" {
+		opts.Address = "tcp://127.0.0.1:5555"
+	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = 2 * time.Second
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+
+	ctx, ctxErr := zmq4.NewContext()
+	if ctxErr != nil {
+		return nil, fmt.Errorf("error creating ZMQ context: %v", ctxErr)
+	}
+	defer func() {
+		_ = ctx.Term()
+	}()
+
+	peers := make(map[string]*replayPeer)
+	poller := zmq4.NewPoller()
+	defer func() {
+		for _, peer := range peers {
+			_ = peer.socket.SetLinger(0)
+			_ = peer.socket.Close()
+		}
+	}()
+	for _, record := range records {
+		peer, exists := peers[record.Identity]
+		if !exists {
+			socket, socketErr := ctx.NewSocket(zmq4.DEALER)
+			if socketErr != nil {
+				return nil, fmt.Errorf("error creating replay socket: %v", socketErr)
+			}
+			if connErr := socket.Connect(opts.Address); connErr != nil {
+				_ = socket.Close()
+				return nil, fmt.Errorf("error connecting to broker %s: %v", opts.Address, connErr)
+			}
+			peer = &replayPeer{socket: socket}
+			peers[record.Identity] = peer
+			poller.Add(socket, zmq4.POLLIN)
+		}
+		if record.Direction == CaptureDirectionOut {
+			peer.expected = append(peer.expected, record.Frames)
+		}
+	}
+
+	result := &ReplayResult{}
+	var previous time.Time
+	for _, record := range records {
+		if record.Direction != CaptureDirectionIn {
+			continue
+		}
+		if !previous.IsZero() && opts.Speed > 0 {
+			gap := time.Duration(float64(record.Time.Sub(previous)) / opts.Speed)
+			if collectErr := collectReplies(poller, peers, result, gap); collectErr != nil {
+				return result, collectErr
+			}
+		}
+		previous = record.Time
+
+		parts := make([]interface{}, len(record.Frames))
+		for i, frame := range record.Frames {
+			parts[i] = frame
+		}
+		if _, sendErr := peers[record.Identity].socket.SendMessage(parts...); sendErr != nil {
+			return result, fmt.Errorf("error replaying message: %v", sendErr)
+		}
+		result.Sent++
+	}
+
+	deadline := time.Now().Add(opts.Wait)
+	for time.Now().Before(deadline) {
+		if opts.Diff && result.Received >= countExpected(peers) {
+			break
+		}
+		if collectErr := collectReplies(poller, peers, result, StreamPollInterval); collectErr != nil {
+			return result, collectErr
+		}
+	}
+
+	if opts.Diff {
+		result.Expected = countExpected(peers)
+		identities := make([]string, 0, len(peers))
+		for identity := range peers {
+			identities = append(identities, identity)
+		}
+		sort.Strings(identities)
+		for _, identity := range identities {
+			result.Mismatched += diffReplies(opts.Output, identity, peers[identity])
+		}
+	}
+	return result, nil
+}
+
+func collectReplies(poller *zmq4.Poller, peers map[string]*replayPeer, result *ReplayResult, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		remaining := time.Until(deadline)
+		if remaining < 0 {
+			remaining = 0
+		}
+		polled, pollErr := poller.Poll(remaining)
+		if pollErr != nil {
+			return fmt.Errorf("error polling replay sockets: %v", pollErr)
+		}
+		for _, p := range polled {
+			msg, recvErr := p.Socket.RecvMessageBytes(0)
+			if recvErr != nil {
+				continue
+			}
+			for _, peer := range peers {
+				if peer.socket == p.Socket {
+					peer.received = append(peer.received, msg)
+					result.Received++
+					break
+				}
+			}
+		}
+		if remaining == 0 {
+			return nil
+		}
+	}
+}
+
+func countExpected(peers map[string]*replayPeer) int {
+	total := 0
+	for _, peer := range peers {
+		total += len(peer.expected)
+	}
+	return total
+}
+
+func diffReplies(out io.Writer, identity string, peer *replayPeer) int {
+	mismatched := 0
+	total := len(peer.expected)
+	if len(peer.received) > total {
+		total = len(peer.received)
+	}
+	for i := 0; i < total; i++ {
+		var expected, received [][]byte
+		if i < len(peer.expected) {
+			expected = peer.expected[i]
+		}
+		if i < len(peer.received) {
+			received = peer.received[i]
+		}
+		if framesEqual(expected, received) {
+			continue
+		}
+		mismatched++
+		_, _ = fmt.Fprintf(out, "client %s reply #%d differs\n  recorded: %s\n  replayed: %s\n",
+			identity, i+1, formatFrames(expected), formatFrames(received))
+	}
+	return mismatched
+}
+
+func framesEqual(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatFrames(frames [][]byte) string {
+	if frames == nil {
+		return "<none>"
+	}
+	return fmt.Sprintf("%q", frames)
+}
@@ -0,0 +1,716 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/faelmori/logz"
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC 2.0 compatibility mode. Requests reach the RPCServer either over HTTP (RPCHTTPPath) or as
+// a broker payload whose body is a JSON-RPC object or batch. Method names are either registered
+// handlers ("ping") or model operations in the "<model>.<operation>" form, where the model is a key
+// of models.ModelRegistryMap and the operation one of create, get, list, query, update, delete or
+// restore. query takes a models.Query and answers a models.ModelPage; update writes the members it
+// is sent to an existing record; restore takes an id, like get, and undeletes a soft-deleted record.
+// The models of rpcReadOnlyModels only answer get, list and query: their rows are written by the
+// services keeping them consistent, such as InventoryService. Requests may carry the ID token of the
+// caller besides the JSON-RPC members, or get the bearer token of the HTTP request: model operations
+// then run in the tenant of its user and are audited as made by that user. Once a TokenService is
+// attached, requests without a token are refused, but for the reads (ping, get, list and query) of a
+// server with SetAnonymousReads; these run in no tenant, so tenant-scoped models are refused, and are
+// audited without an actor.
+const (
+	JSONRPCVersion = "2.0"
+	RPCHTTPPath    = "/rpc"
+)
+
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	RPCServerError    = -32000
+	RPCNotFound       = -32001
+	RPCUnavailable    = -32002
+	RPCForbidden      = -32003
+	RPCConflict       = -32004
+)
+
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Token   string          `json:"token,omitempty"`
+}
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON keeps "result" on successful responses even when it is null, and drops it on errors.
+func (r RPCResponse) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			Error   *RPCError       `json:"error"`
+			ID      json.RawMessage `json:"id"`
+		}{r.JSONRPC, r.Error, r.ID})
+	}
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Result  interface{}     `json:"result"`
+		ID      json.RawMessage `json:"id"`
+	}{r.JSONRPC, r.Result, r.ID})
+}
+
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+func NewRPCError(code int, message string, data interface{}) *RPCError {
+	return &RPCError{Code: code, Message: message, Data: data}
+}
+
+// RPCHandler serves one JSON-RPC method. Returning an *RPCError keeps its code; any other error is
+// reported as RPCServerError.
+type RPCHandler func(params json.RawMessage) (interface{}, error)
+
+var (
+	rpcHandlersMu sync.RWMutex
+	rpcHandlers   = map[string]RPCHandler{
+		"ping": func(params json.RawMessage) (interface{}, error) {
+			return map[string]string{"ping": "pong"}, nil
+		},
+	}
+)
+
+// rpcReadOnlyModels are the models whose rows only change through a service: inventories, their
+// movements and the reservations holding stock are written by InventoryService, and the status
+// history of orders by OrderService.Transition.
+var rpcReadOnlyModels = map[string]bool{
+	"inventory":          true,
+	"inventorymovement":  true,
+	"stockreservation":   true,
+	"orderstatushistory": true,
+}
+
+func RegisterRPCHandler(method string, handler RPCHandler) error {
+	rpcHandlersMu.Lock()
+	defer rpcHandlersMu.Unlock()
+	if _, exists := rpcHandlers[method]; exists {
+		return fmt.Errorf("rpc handler %s already registered", method)
+	}
+	rpcHandlers[method] = handler
+	return nil
+}
+func getRPCHandler(method string) (RPCHandler, bool) {
+	rpcHandlersMu.RLock()
+	defer rpcHandlersMu.RUnlock()
+	handler, ok := rpcHandlers[method]
+	return handler, ok
+}
+
+type RPCServer struct {
+	mu             sync.RWMutex
+	db             *gorm.DB
+	tokens         models.TokenService
+	anonymousReads bool
+	events         *EventHub
+}
+
+func NewRPCServer(db *gorm.DB) *RPCServer {
+	return &RPCServer{db: db}
+}
+
+// SetDB attaches the connection used by model operations; without one they answer RPCUnavailable.
+func (s *RPCServer) SetDB(db *gorm.DB) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db = db
+}
+func (s *RPCServer) getDB() *gorm.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db
+}
+
+// SetTokens attaches the service validating the tokens of requests, which then need one to write;
+// without one requests carrying a token answer RPCUnavailable.
+func (s *RPCServer) SetTokens(tokens models.TokenService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = tokens
+}
+func (s *RPCServer) getTokens() models.TokenService {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tokens
+}
+
+// SetAnonymousReads lets requests without a token read (ping, get, list and query) when a
+// TokenService is attached. Writes always need a valid token then.
+func (s *RPCServer) SetAnonymousReads(allow bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.anonymousReads = allow
+}
+func (s *RPCServer) allowsAnonymousReads() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.anonymousReads
+}
+
+// Handle processes a single request or a batch and returns the encoded reply. It returns nil when
+// nothing must be sent back, i.e. the payload only carried notifications.
+func (s *RPCServer) Handle(payload []byte) []byte {
+	return s.handle(payload, "")
+}
+
+// handle is Handle with token used for the requests that carry none.
+func (s *RPCServer) handle(payload []byte, token string) []byte {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) == 0 {
+		return encodeRPC(rpcErrorResponse(nil, NewRPCError(RPCInvalidRequest, "Invalid Request", nil)))
+	}
+
+	if trimmed[0] != '[' {
+		response := s.handleRaw(trimmed, token)
+		if response == nil {
+			return nil
+		}
+		return encodeRPC(response)
+	}
+
+	var batch []json.RawMessage
+	if unmarshalErr := json.Unmarshal(trimmed, &batch); unmarshalErr != nil {
+		return encodeRPC(rpcErrorResponse(nil, NewRPCError(RPCParseError, "Parse error", unmarshalErr.Error())))
+	}
+	if len(batch) == 0 {
+		return encodeRPC(rpcErrorResponse(nil, NewRPCError(RPCInvalidRequest, "Invalid Request", "empty batch")))
+	}
+	responses := make([]*RPCResponse, 0, len(batch))
+	for _, raw := range batch {
+		if response := s.handleRaw(raw, token); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return encodeRPC(responses)
+}
+func (s *RPCServer) handleRaw(raw json.RawMessage, token string) *RPCResponse {
+	var req RPCRequest
+	if unmarshalErr := json.Unmarshal(raw, &req); unmarshalErr != nil {
+		var probe interface{}
+		if json.Unmarshal(raw, &probe) != nil {
+			return rpcErrorResponse(nil, NewRPCError(RPCParseError, "Parse error", unmarshalErr.Error()))
+		}
+		return rpcErrorResponse(nil, NewRPCError(RPCInvalidRequest, "Invalid Request", unmarshalErr.Error()))
+	}
+	if req.JSONRPC != JSONRPCVersion || req.Method == "" {
+		return rpcErrorResponse(req.ID, NewRPCError(RPCInvalidRequest, "Invalid Request", nil))
+	}
+
+	if req.Token != "" {
+		token = req.Token
+	}
+	ctx, authErr := s.authenticate(context.Background(), token, req.Method)
+	if authErr != nil {
+		if req.ID == nil {
+			return nil
+		}
+		return rpcErrorResponse(req.ID, authErr)
+	}
+	result, callErr := s.callRecovered(ctx, req.Method, req.Params)
+	if req.ID == nil {
+		// Notification: executed, never answered.
+		if callErr != nil {
+			logz.Debug("Error in JSON-RPC notification", map[string]interface{}{
+				"context": "rpc",
+				"method":  req.Method,
+				"error":   callErr.Error(),
+			})
+		}
+		return nil
+	}
+	if callErr != nil {
+		return rpcErrorResponse(req.ID, toRPCError(callErr))
+	}
+	return &RPCResponse{JSONRPC: JSONRPCVersion, Result: result, ID: req.ID}
+}
+
+// callRecovered is CallContext that turns a panic of the method into an internal error, so that one
+// bad request cannot take down the worker serving it.
+func (s *RPCServer) callRecovered(ctx context.Context, method string, params json.RawMessage) (result interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logz.Error("Panic in JSON-RPC method", map[string]interface{}{
+				"context": "rpc",
+				"method":  method,
+				"panic":   fmt.Sprint(recovered),
+			})
+			result, err = nil, NewRPCError(RPCInternalError, "Internal error", nil)
+		}
+	}()
+	return s.CallContext(ctx, method, params)
+}
+
+// authenticate scopes ctx to the tenant of the user of token and makes that user the actor. A request
+// without a token keeps ctx as is when no TokenService is attached, or when it reads and anonymous
+// reads are allowed; it is refused otherwise.
+func (s *RPCServer) authenticate(ctx context.Context, token string, method string) (context.Context, *RPCError) {
+	tokens := s.getTokens()
+	if token == "" {
+		if tokens == nil || (s.allowsAnonymousReads() && isRPCRead(method)) {
+			return ctx, nil
+		}
+		return nil, NewRPCError(RPCForbidden, "Authentication required", method)
+	}
+	if tokens == nil {
+		return nil, NewRPCError(RPCUnavailable, "Authentication not available", nil)
+	}
+	user, validateErr := tokens.ValidateIDToken(token)
+	if validateErr != nil {
+		return nil, NewRPCError(RPCForbidden, "Invalid token", validateErr.Error())
+	}
+	if tenantID := user.GetTenantID(); tenantID != "" {
+		ctx = models.WithTenant(ctx, tenantID)
+	}
+	return WithUser(ctx, user), nil
+}
+
+// Call runs a method without any JSON-RPC framing. Registered handlers take precedence over model
+// operations of the same name.
+func (s *RPCServer) Call(method string, params json.RawMessage) (interface{}, error) {
+	return s.CallContext(context.Background(), method, params)
+}
+
+// CallContext is Call with the model operations run with db.WithContext(ctx), e.g. in the tenant of
+// models.WithTenant.
+func (s *RPCServer) CallContext(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	if handler, ok := getRPCHandler(method); ok {
+		return handler(params)
+	}
+	modelName, operation, found := strings.Cut(method, ".")
+	if !found {
+		return nil, NewRPCError(RPCMethodNotFound, "Method not found", method)
+	}
+	tp, ok := models.ModelRegistryMap[strings.ToLower(modelName)]
+	if !ok || (rpcReadOnlyModels[strings.ToLower(modelName)] && !isRPCReadOperation(operation)) {
+		return nil, NewRPCError(RPCMethodNotFound, "Method not found", method)
+	}
+	db := s.getDB()
+	if db == nil {
+		return nil, NewRPCError(RPCUnavailable, "Database not available", nil)
+	}
+	if tenantErr := rpcRequireTenant(ctx, db, tp); tenantErr != nil {
+		return nil, tenantErr
+	}
+	db = db.WithContext(ctx)
+
+	var result interface{}
+	var opErr error
+	var event string
+	switch operation {
+	case "create":
+		result, opErr = rpcCreate(db, tp, params)
+		event = "created"
+	case "get":
+		return rpcGet(db, tp, params)
+	case "list":
+		return rpcList(db, tp, params)
+	case "query":
+		return rpcQuery(db, modelName, params)
+	case "update":
+		result, opErr = rpcUpdate(db, tp, params)
+		event = "updated"
+	case "delete":
+		result, opErr = rpcDelete(db, tp, params)
+		event = "deleted"
+	case "restore":
+		result, opErr = rpcRestore(db, tp, params)
+		event = "restored"
+	case "transition":
+		if tp != reflect.TypeOf(models.Order{}) {
+			return nil, NewRPCError(RPCMethodNotFound, "Method not found", method)
+		}
+		result, opErr = rpcTransition(ctx, db, params)
+		event = "transitioned"
+	default:
+		return nil, NewRPCError(RPCMethodNotFound, "Method not found", method)
+	}
+	if opErr != nil {
+		return nil, opErr
+	}
+	tenantID, _ := models.TenantFromContext(ctx)
+	s.events.PublishTenant(tenantID, "model."+strings.ToLower(modelName)+"."+event, result)
+	return result, nil
+}
+
+// rpcRequireTenant refuses, before anything runs, the calls on a tenant-scoped model made without a
+// tenant when db isolates tenants.
+func rpcRequireTenant(ctx context.Context, db *gorm.DB, tp reflect.Type) error {
+	if !tenantsEnabled(db) || models.IsSystemContext(ctx) {
+		return nil
+	}
+	if _, ok := models.TenantFromContext(ctx); ok {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: db}
+	if stmt.Parse(reflect.New(tp).Interface()) != nil || models.TenantField(stmt.Schema) == nil {
+		return nil
+	}
+	return NewRPCError(RPCForbidden, "Forbidden", models.ErrTenantRequired.Error())
+}
+
+// ServeHTTP accepts POSTed JSON-RPC payloads, authenticated by a bearer Authorization header.
+// Notification-only payloads are answered with 204.
+func (s *RPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, readErr := io.ReadAll(io.LimitReader(r.Body, 32<<20))
+	if readErr != nil {
+		http.Error(w, readErr.Error(), http.StatusBadRequest)
+		return
+	}
+	reply := s.handle(body, bearerToken(r))
+	if reply == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(reply)
+}
+
+// ListenAndServe exposes the server over HTTP on addr at RPCHTTPPath. It blocks like http.ListenAndServe.
+func (s *RPCServer) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(RPCHTTPPath, s)
+	logz.Info(fmt.Sprintf("JSON-RPC listening on %s%s", addr, RPCHTTPPath), nil)
+	return http.ListenAndServe(addr, mux)
+}
+
+// isRPCPayload tells JSON-RPC payloads apart from model registry envelopes on the broker.
+func isRPCPayload(payload string) bool {
+	trimmed := strings.TrimSpace(payload)
+	if strings.HasPrefix(trimmed, "[") {
+		return true
+	}
+	return strings.HasPrefix(trimmed, "{") && strings.Contains(trimmed, `"jsonrpc"`)
+}
+
+// isRPCRead tells the methods anonymous callers may be allowed: ping and the model reads.
+func isRPCRead(method string) bool {
+	if method == "ping" {
+		return true
+	}
+	if _, isHandler := getRPCHandler(method); isHandler {
+		return false
+	}
+	_, operation, _ := strings.Cut(method, ".")
+	return isRPCReadOperation(operation)
+}
+
+// isRPCReadOperation tells the model operations that change nothing apart from the others.
+func isRPCReadOperation(operation string) bool {
+	switch operation {
+	case "get", "list", "query":
+		return true
+	}
+	return false
+}
+
+type rpcIDParams struct {
+	ID interface{} `json:"id"`
+}
+type rpcListParams struct {
+	Where  map[string]interface{} `json:"where"`
+	Limit  int                    `json:"limit"`
+	Offset int                    `json:"offset"`
+}
+
+func rpcCreate(db *gorm.DB, tp reflect.Type, params json.RawMessage) (interface{}, error) {
+	instance, decodeErr := decodeRPCModel(tp, params)
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	if validateErr := validateRPCModel(instance); validateErr != nil {
+		return nil, validateErr
+	}
+	if createErr := db.Create(instance).Error; createErr != nil {
+		return nil, createErr
+	}
+	return instance, nil
+}
+func rpcGet(db *gorm.DB, tp reflect.Type, params json.RawMessage) (interface{}, error) {
+	id, idErr := decodeRPCID(params)
+	if idErr != nil {
+		return nil, idErr
+	}
+	instance := reflect.New(tp).Interface()
+	pk, pkErr := primaryKeyColumn(db, instance)
+	if pkErr != nil {
+		return nil, pkErr
+	}
+	if findErr := db.Where(pk+" = ?", id).First(instance).Error; findErr != nil {
+		return nil, findErr
+	}
+	return instance, nil
+}
+func rpcList(db *gorm.DB, tp reflect.Type, params json.RawMessage) (interface{}, error) {
+	var lp rpcListParams
+	if len(params) > 0 && string(params) != "null" {
+		if unmarshalErr := json.Unmarshal(params, &lp); unmarshalErr != nil {
+			return nil, NewRPCError(RPCInvalidParams, "Invalid params", unmarshalErr.Error())
+		}
+	}
+	list := reflect.New(reflect.SliceOf(reflect.PointerTo(tp))).Interface()
+	query := db.Model(reflect.New(tp).Interface())
+	if len(lp.Where) > 0 {
+		query = query.Where(lp.Where)
+	}
+	if lp.Limit > 0 {
+		query = query.Limit(lp.Limit)
+	}
+	if lp.Offset > 0 {
+		query = query.Offset(lp.Offset)
+	}
+	if findErr := query.Find(list).Error; findErr != nil {
+		return nil, findErr
+	}
+	return list, nil
+}
+func rpcQuery(db *gorm.DB, modelName string, params json.RawMessage) (interface{}, error) {
+	var q models.Query
+	if len(params) > 0 && string(params) != "null" {
+		if unmarshalErr := json.Unmarshal(params, &q); unmarshalErr != nil {
+			return nil, NewRPCError(RPCInvalidParams, "Invalid params", unmarshalErr.Error())
+		}
+	}
+	return models.QueryModel(db, modelName, q)
+}
+
+// rpcUpdate writes the members of params to the record with their id; the columns left out keep
+// their values. The record is validated as it is after the update.
+func rpcUpdate(db *gorm.DB, tp reflect.Type, params json.RawMessage) (interface{}, error) {
+	var members map[string]json.RawMessage
+	if unmarshalErr := json.Unmarshal(params, &members); unmarshalErr != nil || members == nil {
+		return nil, NewRPCError(RPCInvalidParams, "Invalid params", "model data is required")
+	}
+	id, idErr := decodeRPCID(params)
+	if idErr != nil {
+		return nil, idErr
+	}
+	instance := reflect.New(tp).Interface()
+	stmt := &gorm.Statement{DB: db}
+	if parseErr := stmt.Parse(instance); parseErr != nil {
+		return nil, parseErr
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, NewRPCError(RPCInternalError, "Internal error", "model has no primary key")
+	}
+	findErr := UsePrimary(db).Where(pk.DBName+" = ?", id).First(instance).Error
+	if errors.Is(findErr, gorm.ErrRecordNotFound) {
+		return nil, NewRPCError(RPCNotFound, "Record not found", id)
+	} else if findErr != nil {
+		return nil, findErr
+	}
+	if unmarshalErr := json.Unmarshal(params, instance); unmarshalErr != nil {
+		return nil, NewRPCError(RPCInvalidParams, "Invalid params", unmarshalErr.Error())
+	}
+	if validateErr := validateRPCModel(instance); validateErr != nil {
+		return nil, validateErr
+	}
+
+	// The version is checked and moved on by the versioning callbacks, from the one of params if any.
+	version := models.VersionField(stmt.Schema)
+	rv := reflect.ValueOf(instance).Elem()
+	columns := map[string]interface{}{}
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || field == pk || field == version || !rpcMemberSent(members, field) {
+			continue
+		}
+		columns[field.DBName], _ = field.ValueOf(db.Statement.Context, rv)
+	}
+	if len(columns) > 0 {
+		if updateErr := db.Model(instance).Updates(columns).Error; updateErr != nil {
+			return nil, updateErr
+		}
+	}
+	return rpcGet(UsePrimary(db), tp, params)
+}
+
+// rpcMemberSent tells whether members carry field, by the name it has in JSON.
+func rpcMemberSent(members map[string]json.RawMessage, field *schema.Field) bool {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	_, sent := members[name]
+	return sent
+}
+func rpcDelete(db *gorm.DB, tp reflect.Type, params json.RawMessage) (interface{}, error) {
+	id, idErr := decodeRPCID(params)
+	if idErr != nil {
+		return nil, idErr
+	}
+	instance := reflect.New(tp).Interface()
+	pk, pkErr := primaryKeyColumn(db, instance)
+	if pkErr != nil {
+		return nil, pkErr
+	}
+	res := db.Where(pk+" = ?", id).Delete(instance)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, NewRPCError(RPCNotFound, "Record not found", id)
+	}
+	return map[string]interface{}{"id": id, "deleted": res.RowsAffected}, nil
+}
+func rpcRestore(db *gorm.DB, tp reflect.Type, params json.RawMessage) (interface{}, error) {
+	id, idErr := decodeRPCID(params)
+	if idErr != nil {
+		return nil, idErr
+	}
+	if restoreErr := models.RestoreRecord(db, reflect.New(tp).Interface(), id); restoreErr != nil {
+		return nil, restoreErr
+	}
+	return rpcGet(UsePrimary(db), tp, params)
+}
+
+func decodeRPCModel(tp reflect.Type, params json.RawMessage) (interface{}, error) {
+	instance := reflect.New(tp).Interface()
+	if len(params) == 0 {
+		return nil, NewRPCError(RPCInvalidParams, "Invalid params", "model data is required")
+	}
+	if unmarshalErr := json.Unmarshal(params, instance); unmarshalErr != nil {
+		return nil, NewRPCError(RPCInvalidParams, "Invalid params", unmarshalErr.Error())
+	}
+	return instance, nil
+}
+
+// rpcTransition moves an order to another status, with params {"id", "status", "reason", "reserve"}.
+func rpcTransition(ctx context.Context, db *gorm.DB, params json.RawMessage) (interface{}, error) {
+	var in struct {
+		ID     string             `json:"id"`
+		Status models.OrderStatus `json:"status"`
+		TransitionInput
+	}
+	if unmarshalErr := json.Unmarshal(params, &in); unmarshalErr != nil || in.ID == "" || in.Status == "" {
+		return nil, NewRPCError(RPCInvalidParams, "Invalid params", "id and status are required")
+	}
+	return NewOrderService(db).Transition(ctx, in.ID, in.Status, in.TransitionInput)
+}
+
+// decodeRPCID accepts both by-name ({"id": 1}) and by-position ([1]) params.
+func decodeRPCID(params json.RawMessage) (interface{}, error) {
+	trimmed := bytes.TrimSpace(params)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var positional []interface{}
+		if unmarshalErr := json.Unmarshal(trimmed, &positional); unmarshalErr == nil && len(positional) > 0 && positional[0] != nil {
+			return positional[0], nil
+		}
+	} else if len(trimmed) > 0 {
+		var named rpcIDParams
+		if unmarshalErr := json.Unmarshal(trimmed, &named); unmarshalErr == nil && named.ID != nil {
+			return named.ID, nil
+		}
+	}
+	return nil, NewRPCError(RPCInvalidParams, "Invalid params", "id is required")
+}
+func validateRPCModel(instance interface{}) error {
+	model, ok := instance.(models.Model)
+	if !ok {
+		return nil
+	}
+	if validateErr := model.Validate(); validateErr != nil {
+		return NewRPCError(RPCInvalidParams, "Invalid params", validateErr.Error())
+	}
+	return nil
+}
+func primaryKeyColumn(db *gorm.DB, instance interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if parseErr := stmt.Parse(instance); parseErr != nil {
+		return "", parseErr
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return "", NewRPCError(RPCInternalError, "Internal error", "model has no primary key")
+	}
+	return stmt.Schema.PrioritizedPrimaryField.DBName, nil
+}
+
+func toRPCError(err error) *RPCError {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NewRPCError(RPCNotFound, "Record not found", nil)
+	}
+	var conflictErr *models.ConflictError
+	if errors.As(err, &conflictErr) {
+		return NewRPCError(RPCConflict, "Conflict", map[string]interface{}{"id": conflictErr.ID, "version": conflictErr.Version})
+	}
+	var transitionErr *models.TransitionError
+	if errors.As(err, &transitionErr) {
+		return NewRPCError(RPCConflict, "Conflict", map[string]interface{}{"from": transitionErr.From, "to": transitionErr.To, "reason": transitionErr.Reason})
+	}
+	var stockErr *InsufficientStockError
+	if errors.As(err, &stockErr) {
+		return NewRPCError(RPCConflict, "Conflict", map[string]interface{}{"product_id": stockErr.ProductID, "variant_id": stockErr.VariantID, "warehouse_id": stockErr.WarehouseID, "available": stockErr.Available, "requested": stockErr.Requested})
+	}
+	if errors.Is(err, ErrReservationClosed) || errors.Is(err, ErrReservationExpired) || errors.Is(err, ErrOrderItemsLocked) {
+		return NewRPCError(RPCConflict, "Conflict", err.Error())
+	}
+	if errors.Is(err, models.ErrTenantRequired) || errors.Is(err, models.ErrTenantMismatch) {
+		return NewRPCError(RPCForbidden, "Forbidden", err.Error())
+	}
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		return NewRPCError(RPCInvalidParams, "Invalid params", validationErr.FieldError())
+	}
+	return NewRPCError(RPCServerError, "Server error", err.Error())
+}
+func rpcErrorResponse(id json.RawMessage, rpcErr *RPCError) *RPCResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &RPCResponse{JSONRPC: JSONRPCVersion, Error: rpcErr, ID: id}
+}
+func encodeRPC(v interface{}) []byte {
+	data, marshalErr := json.Marshal(v)
+	if marshalErr != nil {
+		data, _ = json.Marshal(rpcErrorResponse(nil, NewRPCError(RPCInternalError, "Internal error", marshalErr.Error())))
+	}
+	return data
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	glb "github.com/faelmori/gkbxsrv/internal/globals"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/goccy/go-json"
+)
+
+// rpcErrorCode is the code a JSON-RPC reply would carry for err, or 0 without one.
+func rpcErrorCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return toRPCError(err).Code
+}
+
+// rpcTestTokens accepts the tokens of users and refuses any other.
+type rpcTestTokens struct {
+	models.TokenService
+	users map[string]*models.UserImpl
+}
+
+func (s rpcTestTokens) ValidateIDToken(token string) (models.User, error) {
+	if user, ok := s.users[token]; ok {
+		return user, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// rpcReplyCode sends one JSON-RPC request through server as a client would, and returns the code of
+// the error of its reply, or 0 on success.
+func rpcReplyCode(t *testing.T, server *RPCServer, method, params, token string) int {
+	t.Helper()
+	reply := server.handle([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":%q,"params":%s}`, method, params)), token)
+	var response struct {
+		Error *RPCError `json:"error"`
+	}
+	if err := json.Unmarshal(reply, &response); err != nil {
+		t.Fatalf("reply %s: %v", reply, err)
+	}
+	if response.Error == nil {
+		return 0
+	}
+	return response.Error.Code
+}
+
+func TestRPCAuthentication(t *testing.T) {
+	db := openOrderTestDB(t)
+	tokens := rpcTestTokens{users: map[string]*models.UserImpl{"valid": {ID: "u1", Username: "admin"}}}
+	tests := []struct {
+		name           string
+		tokens         models.TokenService
+		anonymousReads bool
+		token          string
+		method         string
+		params         string
+		wantCode       int
+	}{
+		{"no token service", nil, false, "", "role.create", `{"id":"r1","name":"open"}`, 0},
+		{"anonymous create", tokens, false, "", "role.create", `{"id":"r2","name":"anonymous"}`, RPCForbidden},
+		{"anonymous user create", tokens, false, "", "user.create", `{"username":"intruder"}`, RPCForbidden},
+		{"anonymous delete", tokens, true, "", "role.delete", `{"id":"r1"}`, RPCForbidden},
+		{"anonymous update with reads allowed", tokens, true, "", "role.update", `{"id":"r1","name":"renamed"}`, RPCForbidden},
+		{"anonymous list", tokens, false, "", "role.list", `{}`, RPCForbidden},
+		{"anonymous list with reads allowed", tokens, true, "", "role.list", `{}`, 0},
+		{"anonymous get with reads allowed", tokens, true, "", "role.get", `{"id":"r1"}`, 0},
+		{"anonymous ping with reads allowed", tokens, true, "", "ping", `{}`, 0},
+		{"invalid token", tokens, true, "forged", "role.list", `{}`, RPCForbidden},
+		{"valid token", tokens, false, "valid", "role.create", `{"id":"r3","name":"signed"}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewRPCServer(db)
+			if tt.tokens != nil {
+				server.SetTokens(tt.tokens)
+			}
+			server.SetAnonymousReads(tt.anonymousReads)
+			if got := rpcReplyCode(t, server, tt.method, tt.params, tt.token); got != tt.wantCode {
+				t.Errorf("%s error code = %d, want %d", tt.method, got, tt.wantCode)
+			}
+		})
+	}
+	var roles int64
+	if err := db.Model(&models.RoleImpl{}).Count(&roles).Error; err != nil || roles != 2 {
+		t.Errorf("roles = %d, %v, want the 2 authorized creates", roles, err)
+	}
+}
+
+func TestRPCReadOnlyModels(t *testing.T) {
+	db := openOrderTestDB(t)
+	in := StockInput{ProductID: "7d1c6a8e-0000-4000-8000-000000000001", WarehouseID: "7d1c6a8e-0000-4000-8000-000000000002", Quantity: 5}
+	if _, err := NewInventoryService(db).Receive(context.Background(), in); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	var inv models.Inventory
+	if err := db.First(&inv).Error; err != nil {
+		t.Fatalf("First() error = %v", err)
+	}
+	server := NewRPCServer(db)
+	tests := []struct {
+		method   string
+		params   string
+		wantCode int
+	}{
+		{"inventory.get", `{"id":"` + inv.ID + `"}`, 0},
+		{"inventory.list", `{}`, 0},
+		{"inventorymovement.query", `{}`, 0},
+		{"stockreservation.list", `{}`, 0},
+		{"inventory.update", `{"id":"` + inv.ID + `","quantity":1000}`, RPCMethodNotFound},
+		{"inventory.create", `{"product_id":"` + in.ProductID + `","warehouse_id":"` + in.WarehouseID + `"}`, RPCMethodNotFound},
+		{"inventory.delete", `{"id":"` + inv.ID + `"}`, RPCMethodNotFound},
+		{"inventorymovement.create", `{"inventory_id":"` + inv.ID + `","quantity":10}`, RPCMethodNotFound},
+		{"stockreservation.create", `{"order_id":"order","quantity":5}`, RPCMethodNotFound},
+		{"stockreservation.restore", `{"id":"x"}`, RPCMethodNotFound},
+		{"orderstatushistory.list", `{}`, 0},
+		{"orderstatushistory.create", `{"order_id":"order","to_status":"delivered"}`, RPCMethodNotFound},
+		{"orderstatushistory.delete", `{"id":"x"}`, RPCMethodNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			_, err := server.Call(tt.method, json.RawMessage(tt.params))
+			if got := rpcErrorCode(err); got != tt.wantCode || (tt.wantCode == 0 && err != nil) {
+				t.Errorf("Call(%s) error = %v, want code %d", tt.method, err, tt.wantCode)
+			}
+		})
+	}
+	var stored models.Inventory
+	if err := db.First(&stored, "id = ?", inv.ID).Error; err != nil || stored.Quantity != 5 {
+		t.Errorf("inventory after refused writes = %v, %v, want quantity 5", stored.Quantity, err)
+	}
+}
+
+func TestRPCUpdate(t *testing.T) {
+	db := openOrderTestDB(t)
+	product := &models.Product{Name: "P", Depart: "D", Category: "C", Price: 2, Cost: 1, Stock: 1, Reserve: 1, Balance: 1}
+	if err := db.Create(product).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	server := NewRPCServer(db)
+	tests := []struct {
+		name      string
+		params    string
+		wantCode  int
+		wantName  string
+		wantPrice float64
+	}{
+		{"partial", fmt.Sprintf(`{"id":%d,"price":3.5}`, product.ID), 0, "P", 3.5},
+		{"another partial", fmt.Sprintf(`{"id":%d,"name":"Q"}`, product.ID), 0, "Q", 3.5},
+		{"current version", fmt.Sprintf(`{"id":%d,"price":4,"version":3}`, product.ID), 0, "Q", 4},
+		{"stale version", fmt.Sprintf(`{"id":%d,"price":9,"version":1}`, product.ID), RPCConflict, "Q", 4},
+		{"invalid result", fmt.Sprintf(`{"id":%d,"name":""}`, product.ID), RPCInvalidParams, "Q", 4},
+		{"missing id", `{"price":9}`, RPCInvalidParams, "Q", 4},
+		{"unknown id", `{"id":999,"price":9}`, RPCNotFound, "Q", 4},
+		{"positional params", fmt.Sprintf(`[%d]`, product.ID), RPCInvalidParams, "Q", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := server.Call("product.update", json.RawMessage(tt.params))
+			if got := rpcErrorCode(err); got != tt.wantCode {
+				t.Fatalf("Call(product.update) error = %v, want code %d", err, tt.wantCode)
+			}
+			var products []models.Product
+			if err := db.Find(&products).Error; err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if len(products) != 1 || products[0].Name != tt.wantName || products[0].Price != tt.wantPrice || products[0].Cost != 1 {
+				t.Errorf("products = %+v, want only %s at %v costing 1", products, tt.wantName, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestRPCTenantRequired(t *testing.T) {
+	dir := t.TempDir()
+	db, openErr := OpenConnection(glb.Database{Driver: "sqlite", Path: dir + "/tenants.db", MultiTenant: true})
+	if openErr != nil {
+		t.Fatalf("OpenConnection() error = %v", openErr)
+	}
+	if _, upErr := NewMigrator(db, dir).Up(0); upErr != nil {
+		t.Fatalf("Up() error = %v", upErr)
+	}
+	product := &models.Product{Name: "P", Depart: "D", Category: "C", Price: 2, Cost: 1, Stock: 1, Reserve: 1, Balance: 1}
+	product.TenantID = "a"
+	if err := db.WithContext(models.SystemContext(context.Background())).Create(product).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	server := NewRPCServer(db)
+	server.SetTokens(rpcTestTokens{users: map[string]*models.UserImpl{
+		"tenant-a":  {ID: "u1", Tenant: models.Tenant{TenantID: "a"}},
+		"tenant-b":  {ID: "u2", Tenant: models.Tenant{TenantID: "b"}},
+		"no-tenant": {ID: "u3"},
+	}})
+	server.SetAnonymousReads(true)
+	tests := []struct {
+		name      string
+		token     string
+		method    string
+		params    string
+		wantCode  int
+		wantCount int
+	}{
+		{"anonymous list", "", "product.list", `{}`, RPCForbidden, 0},
+		{"anonymous query", "", "product.query", `{}`, RPCForbidden, 0},
+		{"user without tenant", "no-tenant", "product.list", `{}`, RPCForbidden, 0},
+		{"user without tenant creating", "no-tenant", "product.create", `{"name":"X","depart":"D","category":"C","price":1,"cost":1,"stock":1,"reserve":1,"balance":1}`, RPCForbidden, 0},
+		{"user without tenant on a shared model", "no-tenant", "role.list", `{}`, 0, 0},
+		{"anonymous shared model", "", "role.list", `{}`, 0, 0},
+		{"own tenant", "tenant-a", "product.list", `{}`, 0, 1},
+		{"other tenant", "tenant-b", "product.list", `{}`, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := server.handle([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":%q,"params":%s}`, tt.method, tt.params)), tt.token)
+			var response struct {
+				Result []json.RawMessage `json:"result"`
+				Error  *RPCError         `json:"error"`
+			}
+			if err := json.Unmarshal(reply, &response); err != nil {
+				t.Fatalf("reply %s: %v", reply, err)
+			}
+			gotCode := 0
+			if response.Error != nil {
+				gotCode = response.Error.Code
+			}
+			if gotCode != tt.wantCode || len(response.Result) != tt.wantCount {
+				t.Errorf("%s = %s, want code %d and %d records", tt.method, reply, tt.wantCode, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestRPCHandleBatch(t *testing.T) {
+	db := openOrderTestDB(t)
+	server := NewRPCServer(db)
+	tests := []struct {
+		name    string
+		payload string
+		// wantCodes holds the error code of each reply in order, 0 for a result; nil wants no reply.
+		wantCodes []int
+	}{
+		{"single request", `{"jsonrpc":"2.0","id":1,"method":"ping"}`, []int{0}},
+		{"single notification", `{"jsonrpc":"2.0","method":"role.create","params":{"id":"n1","name":"notified"}}`, nil},
+		{"failing notification", `{"jsonrpc":"2.0","method":"nosuch.method"}`, nil},
+		{"empty payload", `  `, []int{RPCInvalidRequest}},
+		{"parse error", `{"jsonrpc":`, []int{RPCParseError}},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"method":"ping"}`, []int{RPCInvalidRequest}},
+		{"empty batch", `[]`, []int{RPCInvalidRequest}},
+		{"malformed batch", `[{"jsonrpc":"2.0"`, []int{RPCParseError}},
+		{"batch", `[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"nosuch.method"},1]`,
+			[]int{0, RPCMethodNotFound, RPCInvalidRequest}},
+		{"batch skips notifications", `[{"jsonrpc":"2.0","method":"role.create","params":{"id":"n2","name":"batched"}},{"jsonrpc":"2.0","id":3,"method":"ping"}]`,
+			[]int{0}},
+		{"notification-only batch", `[{"jsonrpc":"2.0","method":"ping"},{"jsonrpc":"2.0","method":"ping"}]`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := server.Handle([]byte(tt.payload))
+			if tt.wantCodes == nil {
+				if reply != nil {
+					t.Fatalf("Handle() = %s, want no reply", reply)
+				}
+				return
+			}
+			var responses []struct {
+				Error *RPCError `json:"error"`
+			}
+			if len(reply) > 0 && reply[0] != '[' {
+				reply = append(append([]byte{'['}, reply...), ']')
+			}
+			if err := json.Unmarshal(reply, &responses); err != nil {
+				t.Fatalf("Handle() = %s: %v", reply, err)
+			}
+			if len(responses) != len(tt.wantCodes) {
+				t.Fatalf("Handle() = %s, want %d replies", reply, len(tt.wantCodes))
+			}
+			for i, response := range responses {
+				code := 0
+				if response.Error != nil {
+					code = response.Error.Code
+				}
+				if code != tt.wantCodes[i] {
+					t.Errorf("Handle() reply %d code = %d, want %d", i, code, tt.wantCodes[i])
+				}
+			}
+		})
+	}
+
+	// Notifications are executed even though they are never answered.
+	var roles int64
+	if err := db.Model(&models.RoleImpl{}).Where("id IN ?", []string{"n1", "n2"}).Count(&roles).Error; err != nil || roles != 2 {
+		t.Errorf("roles created by notifications = %d, %v, want 2", roles, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/goccy/go-json"
 	"github.com/pebbe/zmq4"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -26,6 +27,12 @@ type BrokerImpl struct {
 	heartbeatAt time.Time
 	brokerInfo  *BrokerInfoLock
 	verbose     bool
+	streams     map[string]*brokerStream
+	streamsMu   sync.Mutex
+	outbound    chan []interface{}
+	recorder    *TrafficRecorder
+	rpc         *RPCServer
+	events      *EventHub
 }
 type Service struct {
 	name     string
@@ -65,6 +72,20 @@ func NewBrokerConn(port string) (*zmq4.Socket, error) {
 	return frontend, nil
 }
 func NewBroker(verbose bool) (*BrokerImpl, error) {
+	return NewBrokerWithCapture(verbose, "")
+}
+
+// NewBrokerWithCapture starts a broker that, when capturePath is set, appends every frame crossing
+// the proxy to that file so the traffic can be replayed later with ReplayCapture.
+func NewBrokerWithCapture(verbose bool, capturePath string) (*BrokerImpl, error) {
+	var recorder *TrafficRecorder
+	if capturePath != "" {
+		var recorderErr error
+		if recorder, recorderErr = NewTrafficRecorder(capturePath); recorderErr != nil {
+			return nil, recorderErr
+		}
+	}
+
 	ctx, err := zmq4.NewContext()
 	if err != nil {
 		return nil, fmt.Errorf("error creating ZMQ context: %v", err)
@@ -105,7 +126,13 @@ func NewBroker(verbose bool) (*BrokerImpl, error) {
 		waiting:     []*Worker{},
 		heartbeatAt: time.Now().Add(HeartbeatInterval),
 		verbose:     verbose,
+		streams:     make(map[string]*brokerStream),
+		outbound:    make(chan []interface{}, 256),
+		recorder:    recorder,
+		rpc:         NewRPCServer(nil),
+		events:      NewEventHub(),
 	}
+	broker.rpc.events = broker.events
 
 	if broker.brokerInfo == nil {
 		logz.Error("Error creating broker", nil)
@@ -141,6 +168,10 @@ func NewBroker(verbose bool) (*BrokerImpl, error) {
 
 func (b *BrokerImpl) startProxy() {
 	logz.Info("Starting proxy between FRONTEND and BACKEND...", nil)
+	if b.recorder != nil {
+		b.startRecordingProxy()
+		return
+	}
 	err := zmq4.Proxy(b.frontend, b.backend, nil)
 	if err != nil {
 		logz.Error("Error in proxy between FRONTEND and BACKEND", map[string]interface{}{
@@ -148,6 +179,42 @@ func (b *BrokerImpl) startProxy() {
 		})
 	}
 }
+func (b *BrokerImpl) startRecordingProxy() {
+	poller := zmq4.NewPoller()
+	poller.Add(b.frontend, zmq4.POLLIN)
+	poller.Add(b.backend, zmq4.POLLIN)
+
+	for {
+		polled, pollErr := poller.Poll(-1)
+		if pollErr != nil {
+			logz.Error("Error in recording proxy between FRONTEND and BACKEND", map[string]interface{}{
+				"error": pollErr,
+			})
+			return
+		}
+		for _, p := range polled {
+			from, to, direction := b.frontend, b.backend, CaptureDirectionIn
+			if p.Socket == b.backend {
+				from, to, direction = b.backend, b.frontend, CaptureDirectionOut
+			}
+			msg, recvErr := from.RecvMessageBytes(0)
+			if recvErr != nil {
+				continue
+			}
+			if recordErr := b.recorder.Record(direction, msg); recordErr != nil {
+				logz.Error("Error recording broker traffic", map[string]interface{}{
+					"error": recordErr.Error(),
+				})
+			}
+			if _, sendErr := to.SendMessage(msg); sendErr != nil {
+				logz.Error("Error forwarding message in recording proxy", map[string]interface{}{
+					"direction": direction,
+					"error":     sendErr.Error(),
+				})
+			}
+		}
+	}
+}
 func (b *BrokerImpl) workerTask() {
 	worker, err := b.context.NewSocket(zmq4.DEALER)
 	if err != nil {
@@ -167,16 +234,50 @@ func (b *BrokerImpl) workerTask() {
 		return
 	}
 
+	poller := zmq4.NewPoller()
+	poller.Add(worker, zmq4.POLLIN)
+
 	for {
+		b.flushOutbound(worker)
+		polled, pollErr := poller.Poll(StreamPollInterval)
+		if pollErr != nil || len(polled) == 0 {
+			continue
+		}
+
 		msg, _ := worker.RecvMessage(0)
 		if len(msg) < 2 {
 			logz.Debug("Malformed message received in WORKER", nil)
 			continue
 		}
 
+		if streamID, frame, isStream, streamErr := splitStreamMessage(msg); isStream {
+			if streamErr != nil {
+				logz.Error("Error decoding stream frame in WORKER", map[string]interface{}{
+					"context": "workerTask",
+					"error":   streamErr.Error(),
+				})
+				continue
+			}
+			b.handleStreamFrame(streamID, frame)
+			continue
+		}
+
 		id, msg := splitMessage(msg)
 
-		payload := msg[len(msg)-1]
+		// Frames between the routing envelope and the payload, e.g. the request ID of a BrokerClient,
+		// are echoed in the reply so the client can match it with its request.
+		payload, echo := msg[len(msg)-1], msg[:len(msg)-1]
+		if isRPCPayload(payload) {
+			if reply := b.rpc.Handle([]byte(payload)); reply != nil {
+				if _, workerSendMessageErr := worker.SendMessage(id, echo, string(reply)); workerSendMessageErr != nil {
+					logz.Error("Error sending JSON-RPC response to BACKEND in WORKER", map[string]interface{}{
+						"context": "workerTask",
+						"error":   workerSendMessageErr.Error(),
+					})
+				}
+			}
+			continue
+		}
 		deserializedModel, deserializedModelErr := models.NewModelRegistryFromSerialized([]byte(payload))
 		if deserializedModelErr != nil {
 			logz.Error("Error deserializing payload in WORKER", map[string]interface{}{
@@ -211,7 +312,7 @@ func (b *BrokerImpl) workerTask() {
 
 		if tp.Name() == "PingImpl" {
 			response := fmt.Sprintf(`{"type":"ping","data":{"ping":"%v"}}`, "pong")
-			if _, workerSendMessageErr := worker.SendMessage(id, response); workerSendMessageErr != nil {
+			if _, workerSendMessageErr := worker.SendMessage(id, echo, response); workerSendMessageErr != nil {
 				logz.Error("Error sending response to BACKEND in WORKER", map[string]interface{}{
 					"context":  "workerTask",
 					"response": response,
@@ -232,6 +333,130 @@ func (b *BrokerImpl) workerTask() {
 		}
 	}
 }
+func (b *BrokerImpl) flushOutbound(worker *zmq4.Socket) {
+	for {
+		select {
+		case parts := <-b.outbound:
+			if _, sendErr := worker.SendMessage(parts...); sendErr != nil {
+				logz.Error("Error sending queued message in WORKER", map[string]interface{}{
+					"context": "flushOutbound",
+					"error":   sendErr.Error(),
+				})
+			}
+		default:
+			return
+		}
+	}
+}
+func (b *BrokerImpl) handleStreamFrame(id []string, frame streamFrame) {
+	key := strings.Join(id, "\x00") + "/" + frame.header.ID
+
+	b.streamsMu.Lock()
+	stream, exists := b.streams[key]
+	if !exists && frame.header.Kind == StreamFrameOpen {
+		routing := append([]string{}, id...)
+		stream = newBrokerStream(frame.header.ID, func(h StreamHeader, body []byte) error {
+			return b.queueStreamFrame(routing, stream, h, body)
+		}, nil)
+		b.streams[key] = stream
+		go b.serveStream(key, stream)
+	}
+	b.streamsMu.Unlock()
+
+	if stream == nil {
+		logz.Debug("Frame for unknown stream dropped in WORKER", map[string]interface{}{
+			"context": "handleStreamFrame",
+			"stream":  frame.header.ID,
+			"kind":    frame.header.Kind,
+		})
+		return
+	}
+	stream.deliver(frame)
+}
+
+// queueStreamFrame queues a frame of stream for the workers to send. It never blocks, as it may run on
+// the worker that drains the queue: when the queue is full an open stream fails, and its peer gets an
+// abort frame as soon as there is room for it.
+func (b *BrokerImpl) queueStreamFrame(routing []string, stream *brokerStream, h StreamHeader, body []byte) error {
+	parts, encodeErr := encodeStreamFrame(h, body)
+	if encodeErr != nil {
+		return encodeErr
+	}
+	select {
+	case b.outbound <- append([]interface{}{routing}, parts...):
+		return nil
+	default:
+	}
+	overflowErr := fmt.Errorf("stream %s: broker send queue is full", stream.id)
+	select {
+	case <-stream.closed:
+		return overflowErr
+	default:
+	}
+	// Aborts fail the stream with their own cause once sent.
+	if h.Kind != StreamFrameAbort {
+		h = StreamHeader{ID: stream.id, Kind: StreamFrameAbort, Error: overflowErr.Error()}
+		stream.fail(overflowErr)
+	}
+	abort, _ := encodeStreamFrame(h, nil)
+	go func() {
+		select {
+		case b.outbound <- append([]interface{}{routing}, abort...):
+		case <-time.After(StreamIdleTimeout):
+		}
+	}()
+	return overflowErr
+}
+func (b *BrokerImpl) serveStream(key string, stream *brokerStream) {
+	defer func() {
+		b.streamsMu.Lock()
+		delete(b.streams, key)
+		b.streamsMu.Unlock()
+	}()
+
+	reader := newStreamReader(stream)
+	tp, openErr := reader.awaitOpen()
+	writer := newStreamWriter(stream, tp)
+	if openErr != nil {
+		_ = writer.Abort(openErr)
+		return
+	}
+	handler, ok := getStreamHandler(tp)
+	if !ok {
+		_ = writer.Abort(fmt.Errorf("no stream handler registered for type %s", tp))
+		return
+	}
+	if handlerErr := handler(reader, writer); handlerErr != nil {
+		logz.Error("Error in stream handler", map[string]interface{}{
+			"context": "serveStream",
+			"stream":  stream.id,
+			"type":    tp,
+			"error":   handlerErr.Error(),
+		})
+		_ = writer.Abort(handlerErr)
+		return
+	}
+	if closeErr := writer.Close(); closeErr != nil {
+		logz.Error("Error closing reply stream", map[string]interface{}{
+			"context": "serveStream",
+			"stream":  stream.id,
+			"error":   closeErr.Error(),
+		})
+	}
+}
+
+// Address returns the address clients in this process connect to the broker FRONTEND on.
+func (b *BrokerImpl) Address() string {
+	return "tcp://127.0.0.1:" + b.brokerInfo.GetPort()
+}
+
+// RPC returns the JSON-RPC server answering JSON-RPC payloads sent to this broker, so a database
+// can be attached to it or it can also be exposed over HTTP.
+func (b *BrokerImpl) RPC() *RPCServer { return b.rpc }
+
+// Events returns the hub broker events are published on, e.g. "model.product.created" for changes
+// made through JSON-RPC.
+func (b *BrokerImpl) Events() *EventHub { return b.events }
 func (b *BrokerImpl) handleHeartbeats() {
 	ticker := time.NewTicker(HeartbeatInterval)
 	//defer ticker.Stop()
@@ -255,5 +480,6 @@ func (b *BrokerImpl) Stop() {
 	_ = b.frontend.Close()
 	_ = b.backend.Close()
 	_ = b.context.Term()
+	_ = b.recorder.Close()
 	logz.Info("Broker stopped", nil)
 }
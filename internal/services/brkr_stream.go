@@ -0,0 +1,391 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Streams carry a request or a reply split into numbered chunks. Every stream frame travels as
+// three message parts after the routing frames: StreamFrameMarker, a JSON StreamHeader and the body.
+// Within one direction seq 0 is the open frame, chunks follow and the end frame closes the sequence.
+// Flow control is credit based: a writer starts with no credit and may only send a chunk for each
+// credit granted by the reader, which grants StreamWindow when it sees the open frame and tops it
+// up as chunks are consumed.
+const (
+	StreamFrameMarker  = "KBX/STREAM/1"
+	StreamChunkSize    = 64 * 1024
+	StreamWindow       = 8
+	StreamIdleTimeout  = 30 * time.Second
+	StreamPollInterval = 50 * time.Millisecond
+)
+
+// ErrStreamPeerClosed is the error of a request writer whose reply already ended, e.g. because the
+// handler answered without reading the whole request.
+var ErrStreamPeerClosed = errors.New("stream closed by peer")
+
+type StreamFrameKind string
+
+const (
+	StreamFrameOpen   StreamFrameKind = "open"
+	StreamFrameChunk  StreamFrameKind = "chunk"
+	StreamFrameEnd    StreamFrameKind = "end"
+	StreamFrameCredit StreamFrameKind = "credit"
+	StreamFrameAbort  StreamFrameKind = "abort"
+)
+
+type StreamHeader struct {
+	ID     string          `json:"id"`
+	Seq    uint64          `json:"seq"`
+	Kind   StreamFrameKind `json:"kind"`
+	Type   string          `json:"type,omitempty"`
+	Credit int             `json:"credit,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// StreamHandler serves one streamed request on the worker side. The request body is read from req
+// and the reply is written to reply; returning an error aborts the reply stream.
+type StreamHandler func(req io.Reader, reply io.Writer) error
+
+var (
+	streamHandlersMu sync.RWMutex
+	streamHandlers   = map[string]StreamHandler{
+		"ping": func(req io.Reader, reply io.Writer) error {
+			_, copyErr := io.Copy(reply, req)
+			return copyErr
+		},
+	}
+)
+
+func RegisterStreamHandler(tp string, handler StreamHandler) error {
+	streamHandlersMu.Lock()
+	defer streamHandlersMu.Unlock()
+	if _, exists := streamHandlers[tp]; exists {
+		return fmt.Errorf("stream handler %s already registered", tp)
+	}
+	streamHandlers[tp] = handler
+	return nil
+}
+func getStreamHandler(tp string) (StreamHandler, bool) {
+	streamHandlersMu.RLock()
+	defer streamHandlersMu.RUnlock()
+	handler, ok := streamHandlers[tp]
+	return handler, ok
+}
+
+type streamFrame struct {
+	header StreamHeader
+	body   []byte
+}
+
+func encodeStreamFrame(h StreamHeader, body []byte) ([]interface{}, error) {
+	header, marshalErr := json.Marshal(h)
+	if marshalErr != nil {
+		return nil, fmt.Errorf("error marshalling stream header: %v", marshalErr)
+	}
+	if body == nil {
+		body = []byte{}
+	}
+	return []interface{}{StreamFrameMarker, header, body}, nil
+}
+
+// splitStreamMessage looks for the stream marker in a received message and returns the routing
+// frames that precede it together with the decoded frame.
+func splitStreamMessage(msg []string) (id []string, frame streamFrame, ok bool, err error) {
+	for idx, part := range msg {
+		if part != StreamFrameMarker {
+			continue
+		}
+		if len(msg) < idx+3 {
+			return nil, streamFrame{}, true, fmt.Errorf("malformed stream frame: expected header and body")
+		}
+		if unmarshalErr := json.Unmarshal([]byte(msg[idx+1]), &frame.header); unmarshalErr != nil {
+			return nil, streamFrame{}, true, fmt.Errorf("error unmarshalling stream header: %v", unmarshalErr)
+		}
+		frame.body = []byte(msg[idx+2])
+		return msg[:idx], frame, true, nil
+	}
+	return nil, streamFrame{}, false, nil
+}
+
+type brokerStream struct {
+	id       string
+	send     func(h StreamHeader, body []byte) error
+	incoming chan streamFrame
+	credits  chan struct{}
+	closed   chan struct{}
+	failErr  error
+	failOnce sync.Once
+	doneOnce sync.Once
+	onDone   func()
+}
+
+func newBrokerStream(id string, send func(h StreamHeader, body []byte) error, onDone func()) *brokerStream {
+	return &brokerStream{
+		id:       id,
+		send:     send,
+		incoming: make(chan streamFrame, StreamWindow*2),
+		credits:  make(chan struct{}, StreamWindow),
+		closed:   make(chan struct{}),
+		onDone:   onDone,
+	}
+}
+
+// deliver is called by the socket owner for every frame addressed to this stream. It never blocks:
+// a peer that ignores the credit window overflows the queue and gets the stream aborted. Frames of a
+// failed stream are dropped.
+func (s *brokerStream) deliver(f streamFrame) {
+	switch f.header.Kind {
+	case StreamFrameCredit:
+		for i := 0; i < f.header.Credit; i++ {
+			select {
+			case s.credits <- struct{}{}:
+			default:
+			}
+		}
+	case StreamFrameAbort:
+		s.fail(fmt.Errorf("stream %s aborted by peer: %s", s.id, f.header.Error))
+	default:
+		select {
+		case <-s.closed:
+		case s.incoming <- f:
+		default:
+			_ = s.send(StreamHeader{ID: s.id, Kind: StreamFrameAbort, Error: "credit window exceeded"}, nil)
+			s.fail(fmt.Errorf("stream %s overflowed its credit window", s.id))
+		}
+	}
+}
+func (s *brokerStream) fail(err error) {
+	s.failOnce.Do(func() {
+		s.failErr = err
+		close(s.closed)
+	})
+	s.finish()
+}
+func (s *brokerStream) finish() {
+	s.doneOnce.Do(func() {
+		if s.onDone != nil {
+			s.onDone()
+		}
+	})
+}
+func (s *brokerStream) next() (streamFrame, error) {
+	select {
+	case f := <-s.incoming:
+		return f, nil
+	case <-s.closed:
+		return streamFrame{}, s.failErr
+	case <-time.After(StreamIdleTimeout):
+		return streamFrame{}, fmt.Errorf("stream %s timed out waiting for data", s.id)
+	}
+}
+func (s *brokerStream) acquireCredit() error {
+	select {
+	case <-s.credits:
+		return nil
+	case <-s.closed:
+		return s.failErr
+	case <-time.After(StreamIdleTimeout):
+		return fmt.Errorf("stream %s timed out waiting for credit", s.id)
+	}
+}
+
+// StreamReader reassembles the chunks of one stream direction, in order, as an io.Reader.
+type StreamReader struct {
+	stream   *brokerStream
+	tp       string
+	held     map[uint64]streamFrame
+	next     uint64
+	consumed int
+	pending  []byte
+	done     bool
+	// final is set on the reader of a reply: its end frame ends the exchange, and with it the writer
+	// of the request.
+	final bool
+}
+
+func newStreamReader(s *brokerStream) *StreamReader {
+	return &StreamReader{stream: s, held: make(map[uint64]streamFrame)}
+}
+
+// Type returns the type announced by the peer in the open frame, once it has been read.
+func (r *StreamReader) Type() string { return r.tp }
+
+func (r *StreamReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		f, frameErr := r.nextInOrder()
+		if frameErr != nil {
+			return 0, frameErr
+		}
+		switch f.header.Kind {
+		case StreamFrameOpen:
+			r.tp = f.header.Type
+			if grantErr := r.grant(StreamWindow); grantErr != nil {
+				return 0, grantErr
+			}
+		case StreamFrameChunk:
+			r.pending = f.body
+			r.consumed++
+			if r.consumed >= StreamWindow/2 {
+				if grantErr := r.grant(r.consumed); grantErr != nil {
+					return 0, grantErr
+				}
+				r.consumed = 0
+			}
+		case StreamFrameEnd:
+			r.done = true
+			if r.final {
+				r.stream.fail(fmt.Errorf("stream %s: %w", r.stream.id, ErrStreamPeerClosed))
+			} else {
+				r.stream.finish()
+			}
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// awaitOpen consumes the open frame so the stream type is known before any body is read.
+func (r *StreamReader) awaitOpen() (string, error) {
+	for r.next == 0 {
+		f, frameErr := r.nextInOrder()
+		if frameErr != nil {
+			return "", frameErr
+		}
+		if f.header.Kind != StreamFrameOpen {
+			return "", fmt.Errorf("stream %s: expected open frame, got %s", r.stream.id, f.header.Kind)
+		}
+		r.tp = f.header.Type
+		if grantErr := r.grant(StreamWindow); grantErr != nil {
+			return "", grantErr
+		}
+	}
+	return r.tp, nil
+}
+func (r *StreamReader) nextInOrder() (streamFrame, error) {
+	for {
+		if f, ok := r.held[r.next]; ok {
+			delete(r.held, r.next)
+			r.next++
+			return f, nil
+		}
+		f, frameErr := r.stream.next()
+		if frameErr != nil {
+			return streamFrame{}, frameErr
+		}
+		if f.header.Seq < r.next {
+			continue
+		}
+		if len(r.held) >= StreamWindow*2 {
+			abortErr := fmt.Errorf("stream %s: too many frames out of order", r.stream.id)
+			r.stream.fail(abortErr)
+			return streamFrame{}, abortErr
+		}
+		r.held[f.header.Seq] = f
+	}
+}
+func (r *StreamReader) grant(credit int) error {
+	return r.stream.send(StreamHeader{ID: r.stream.id, Kind: StreamFrameCredit, Credit: credit}, nil)
+}
+
+// StreamWriter splits everything written to it into StreamChunkSize chunks, waiting for credit from
+// the reader before each one. Close flushes the last chunk and sends the end frame.
+type StreamWriter struct {
+	stream *brokerStream
+	tp     string
+	buf    []byte
+	seq    uint64
+	opened bool
+	closed bool
+}
+
+func newStreamWriter(s *brokerStream, tp string) *StreamWriter {
+	return &StreamWriter{stream: s, tp: tp, buf: make([]byte, 0, StreamChunkSize)}
+}
+
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	written := 0
+	for len(p) > 0 {
+		n := StreamChunkSize - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(w.buf) == StreamChunkSize {
+			if flushErr := w.flush(); flushErr != nil {
+				return written, flushErr
+			}
+		}
+	}
+	return written, nil
+}
+func (w *StreamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	if flushErr := w.flush(); flushErr != nil {
+		return flushErr
+	}
+	if openErr := w.open(); openErr != nil {
+		return openErr
+	}
+	w.closed = true
+	return w.stream.send(StreamHeader{ID: w.stream.id, Seq: w.seq, Kind: StreamFrameEnd}, nil)
+}
+
+// Abort tells the peer the stream failed; its reader returns an error carrying cause.
+func (w *StreamWriter) Abort(cause error) error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	msg := "aborted"
+	if cause != nil {
+		msg = cause.Error()
+	}
+	sendErr := w.stream.send(StreamHeader{ID: w.stream.id, Kind: StreamFrameAbort, Error: msg}, nil)
+	w.stream.fail(fmt.Errorf("stream %s aborted: %s", w.stream.id, msg))
+	return sendErr
+}
+func (w *StreamWriter) open() error {
+	if w.opened {
+		return nil
+	}
+	if sendErr := w.stream.send(StreamHeader{ID: w.stream.id, Seq: w.seq, Kind: StreamFrameOpen, Type: w.tp}, nil); sendErr != nil {
+		return sendErr
+	}
+	w.opened = true
+	w.seq++
+	return nil
+}
+func (w *StreamWriter) flush() error {
+	if openErr := w.open(); openErr != nil {
+		return openErr
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if creditErr := w.stream.acquireCredit(); creditErr != nil {
+		return creditErr
+	}
+	chunk := make([]byte, len(w.buf))
+	copy(chunk, w.buf)
+	if sendErr := w.stream.send(StreamHeader{ID: w.stream.id, Seq: w.seq, Kind: StreamFrameChunk}, chunk); sendErr != nil {
+		return sendErr
+	}
+	w.seq++
+	w.buf = w.buf[:0]
+	return nil
+}
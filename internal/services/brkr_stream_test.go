@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamTestMessage flattens queued parts the way a socket sends them.
+func streamTestMessage(parts []interface{}) []string {
+	var msg []string
+	for _, part := range parts {
+		switch value := part.(type) {
+		case []string:
+			msg = append(msg, value...)
+		case []byte:
+			msg = append(msg, string(value))
+		default:
+			msg = append(msg, fmt.Sprint(value))
+		}
+	}
+	return msg
+}
+
+// newStreamTestPair connects a BrokerClient to a BrokerImpl in memory: the frames they queue are
+// delivered to each other as their socket loops would.
+func newStreamTestPair(t *testing.T, outbound int) (*BrokerClient, *BrokerImpl) {
+	t.Helper()
+	client := &BrokerClient{
+		outbound: make(chan []interface{}, 256),
+		pending:  make(map[string]chan string),
+		streams:  make(map[string]*brokerStream),
+		done:     make(chan struct{}),
+	}
+	broker := &BrokerImpl{streams: make(map[string]*brokerStream), outbound: make(chan []interface{}, outbound)}
+	go func() {
+		for {
+			select {
+			case parts := <-client.outbound:
+				if _, frame, isStream, _ := splitStreamMessage(streamTestMessage(parts)); isStream {
+					broker.handleStreamFrame([]string{"client"}, frame)
+				}
+			case parts := <-broker.outbound:
+				if _, frame, isStream, _ := splitStreamMessage(streamTestMessage(parts)); isStream {
+					client.mu.Lock()
+					stream := client.streams[frame.header.ID]
+					client.mu.Unlock()
+					if stream != nil {
+						stream.deliver(frame)
+					}
+				}
+			case <-client.done:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { close(client.done) })
+	return client, broker
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"one byte", 1},
+		{"one chunk", StreamChunkSize},
+		{"past the credit window", StreamChunkSize*StreamWindow*2 + 17},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newStreamTestPair(t, 256)
+			body := bytes.Repeat([]byte("0123456789"), tt.size/10+1)[:tt.size]
+			var reply bytes.Buffer
+			if err := client.StreamRequest("ping", bytes.NewReader(body), &reply); err != nil {
+				t.Fatalf("StreamRequest() error = %v", err)
+			}
+			if !bytes.Equal(reply.Bytes(), body) {
+				t.Errorf("StreamRequest() echoed %d bytes, want the %d sent", reply.Len(), len(body))
+			}
+		})
+	}
+}
+
+func TestStreamUnknownType(t *testing.T) {
+	client, _ := newStreamTestPair(t, 256)
+	err := client.StreamRequest("unknown", strings.NewReader("x"), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "no stream handler") {
+		t.Errorf("StreamRequest() error = %v, want the handler error of the broker", err)
+	}
+}
+
+func TestBrokerStreamBackpressure(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks uint64
+	}{
+		{"credit grant with the queue full", 0},
+		{"chunks past the credit window", StreamWindow * 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := &BrokerImpl{streams: make(map[string]*brokerStream), outbound: make(chan []interface{}, 1)}
+			broker.outbound <- []interface{}{"queued"}
+
+			returned := make(chan struct{})
+			go func() {
+				broker.handleStreamFrame([]string{"client"}, streamFrame{header: StreamHeader{ID: "s1", Kind: StreamFrameOpen, Type: "ping"}})
+				for seq := uint64(1); seq <= tt.chunks; seq++ {
+					broker.handleStreamFrame([]string{"client"}, streamFrame{header: StreamHeader{ID: "s1", Seq: seq, Kind: StreamFrameChunk}, body: []byte("x")})
+				}
+				close(returned)
+			}()
+			select {
+			case <-returned:
+			case <-time.After(time.Second):
+				t.Fatal("handleStreamFrame() blocked on a full send queue")
+			}
+
+			deadline := time.Now().Add(time.Second)
+			for {
+				broker.streamsMu.Lock()
+				open := len(broker.streams)
+				broker.streamsMu.Unlock()
+				if open == 0 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("stream still open with its send queue full")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			<-broker.outbound
+			select {
+			case parts := <-broker.outbound:
+				_, frame, isStream, err := splitStreamMessage(streamTestMessage(parts))
+				if !isStream || err != nil || frame.header.Kind != StreamFrameAbort {
+					t.Errorf("frame after the queue drained = %+v, %v, want an abort", frame.header, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no abort frame sent once the queue drained")
+			}
+			select {
+			case parts := <-broker.outbound:
+				t.Errorf("frame after the abort = %v, want none", streamTestMessage(parts))
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+// streamTestHeadSize is what the "test.head" handler reads of a request before answering it.
+const streamTestHeadSize = 10
+
+func TestStreamReplyBeforeRequestEnd(t *testing.T) {
+	if _, registered := getStreamHandler("test.head"); !registered {
+		_ = RegisterStreamHandler("test.head", func(req io.Reader, reply io.Writer) error {
+			_, copyErr := io.CopyN(reply, req, streamTestHeadSize)
+			return copyErr
+		})
+	}
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"within one chunk", StreamChunkSize / 2},
+		{"past the credit window", StreamChunkSize * StreamWindow * 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newStreamTestPair(t, 256)
+			body := bytes.Repeat([]byte("x"), tt.size)
+			var reply bytes.Buffer
+			started := time.Now()
+			if err := client.StreamRequest("test.head", bytes.NewReader(body), &reply); err != nil {
+				t.Fatalf("StreamRequest() error = %v", err)
+			}
+			if reply.Len() != streamTestHeadSize || time.Since(started) > StreamIdleTimeout/2 {
+				t.Errorf("StreamRequest() replied %d bytes after %v, want %d at once", reply.Len(), time.Since(started), streamTestHeadSize)
+			}
+
+			writer, reader, err := client.OpenStream("test.head")
+			if err != nil {
+				t.Fatalf("OpenStream() error = %v", err)
+			}
+			writeErrCh := make(chan error, 1)
+			go func() {
+				_, writeErr := writer.Write(body)
+				if writeErr == nil {
+					writeErr = writer.Close()
+				}
+				writeErrCh <- writeErr
+			}()
+			if _, err = io.Copy(io.Discard, reader); err != nil {
+				t.Fatalf("reading the reply error = %v", err)
+			}
+			select {
+			case writeErr := <-writeErrCh:
+				if tt.size > StreamChunkSize*StreamWindow && !errors.Is(writeErr, ErrStreamPeerClosed) {
+					t.Errorf("writing past the reply error = %v, want %v", writeErr, ErrStreamPeerClosed)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("request writer still waiting for credit after the reply ended")
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/faelmori/logz"
+	"github.com/goccy/go-json"
+	"golang.org/x/net/websocket"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	WSGatewayPath       = "/ws"
+	WSMaxMessageSize    = 32 << 20
+	WSAccessTokenQuery  = "access_token"
+	WSAuthorizationType = "Bearer "
+)
+
+// WSMessage is the frame exchanged on a gateway connection. Clients send "request" (Payload is the
+// envelope forwarded to the broker), "subscribe"/"unsubscribe" (Topics) and "ping"; the gateway answers
+// with "reply", "subscribed", "unsubscribed", "pong" or "error", echoing ID, and pushes "event" frames.
+type WSMessage struct {
+	Op      string          `json:"op"`
+	ID      string          `json:"id,omitempty"`
+	Topics  []string        `json:"topics,omitempty"`
+	Topic   string          `json:"topic,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Data    interface{}     `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// WSGateway exposes the broker to clients that can't speak ZMQ, such as browsers. Every connection is
+// authenticated with an ID token issued by the TokenService, sent as a bearer Authorization header or,
+// since browsers can't set headers on WebSocket handshakes, as the access_token query parameter.
+// JSON-RPC requests are forwarded with the token of the connection, so they run in the tenant of its
+// user and are audited as made by that user whatever the client sent; only events of that tenant or
+// of no tenant are pushed. Connections share the broker client, which matches replies with requests.
+type WSGateway struct {
+	client *BrokerClient
+	events *EventHub
+	tokens models.TokenService
+}
+
+func NewWSGateway(client *BrokerClient, events *EventHub, tokens models.TokenService) (*WSGateway, error) {
+	if client == nil {
+		return nil, fmt.Errorf("websocket gateway requires a broker client")
+	}
+	if tokens == nil {
+		return nil, fmt.Errorf("websocket gateway requires a token service")
+	}
+	return &WSGateway{client: client, events: events, tokens: tokens}, nil
+}
+
+func (g *WSGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return
+	}
+	user, authErr := g.tokens.ValidateIDToken(token)
+	if authErr != nil {
+		http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	server := websocket.Server{
+		// Authentication replaces the origin check of websocket.Handler.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			g.serveConn(conn, user, token)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// ListenAndServe exposes the gateway on addr at WSGatewayPath. It blocks like http.ListenAndServe.
+func (g *WSGateway) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(WSGatewayPath, g)
+	logz.Info(fmt.Sprintf("WebSocket gateway listening on %s%s", addr, WSGatewayPath), nil)
+	return http.ListenAndServe(addr, mux)
+}
+
+type wsSession struct {
+	gateway *WSGateway
+	conn    *websocket.Conn
+	user    models.User
+	token   string
+	sendMu  sync.Mutex
+	sub     *EventSubscription
+	wg      sync.WaitGroup
+}
+
+func (g *WSGateway) serveConn(conn *websocket.Conn, user models.User, token string) {
+	conn.MaxPayloadBytes = WSMaxMessageSize
+	session := &wsSession{gateway: g, conn: conn, user: user, token: token}
+	defer func() {
+		if session.sub != nil {
+			session.sub.Close()
+		}
+		session.wg.Wait()
+		_ = conn.Close()
+	}()
+
+	for {
+		var raw string
+		if recvErr := websocket.Message.Receive(conn, &raw); recvErr != nil {
+			if recvErr != io.EOF {
+				logz.Debug("WebSocket connection closed", map[string]interface{}{
+					"context": "WSGateway",
+					"user":    user.GetID(),
+					"error":   recvErr.Error(),
+				})
+			}
+			return
+		}
+		var msg WSMessage
+		if unmarshalErr := json.Unmarshal([]byte(raw), &msg); unmarshalErr != nil {
+			session.send(WSMessage{Op: "error", Error: fmt.Sprintf("invalid message: %v", unmarshalErr)})
+			continue
+		}
+		// Tokens are checked again on every message so an expired token ends the session.
+		if _, authErr := g.tokens.ValidateIDToken(token); authErr != nil {
+			session.send(WSMessage{Op: "error", ID: msg.ID, Error: "token expired or revoked"})
+			return
+		}
+		session.handle(msg)
+	}
+}
+func (s *wsSession) handle(msg WSMessage) {
+	switch msg.Op {
+	case "request":
+		if len(msg.Payload) == 0 {
+			s.send(WSMessage{Op: "error", ID: msg.ID, Error: "request payload is required"})
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.forward(msg)
+		}()
+	case "subscribe":
+		if s.gateway.events == nil {
+			s.send(WSMessage{Op: "error", ID: msg.ID, Error: "event subscriptions are not available"})
+			return
+		}
+		if len(msg.Topics) == 0 {
+			s.send(WSMessage{Op: "error", ID: msg.ID, Error: "at least one topic is required"})
+			return
+		}
+		if s.sub == nil {
+			s.sub = s.gateway.events.Subscribe(msg.Topics...)
+			s.wg.Add(1)
+			go func(sub *EventSubscription) {
+				defer s.wg.Done()
+				for event := range sub.Events() {
+					if event.Tenant != "" && event.Tenant != s.user.GetTenantID() {
+						continue
+					}
+					s.send(WSMessage{Op: "event", Topic: event.Topic, Data: event})
+				}
+			}(s.sub)
+		} else {
+			s.sub.Add(msg.Topics...)
+		}
+		s.send(WSMessage{Op: "subscribed", ID: msg.ID, Topics: s.sub.Topics()})
+	case "unsubscribe":
+		if s.sub != nil {
+			s.sub.Remove(msg.Topics...)
+		}
+		var topics []string
+		if s.sub != nil {
+			topics = s.sub.Topics()
+		}
+		s.send(WSMessage{Op: "unsubscribed", ID: msg.ID, Topics: topics})
+	case "ping":
+		s.send(WSMessage{Op: "pong", ID: msg.ID})
+	default:
+		s.send(WSMessage{Op: "error", ID: msg.ID, Error: fmt.Sprintf("unknown op %q", msg.Op)})
+	}
+}
+
+// forward relays an envelope to the broker. A payload given as a JSON string is sent unquoted, so
+// clients can pass envelopes that are not JSON objects. JSON-RPC payloads of notifications only are
+// sent without waiting, and get no reply, like over HTTP.
+func (s *wsSession) forward(msg WSMessage) {
+	payload := string(msg.Payload)
+	var quoted string
+	if strings.HasPrefix(payload, `"`) && json.Unmarshal(msg.Payload, &quoted) == nil {
+		payload = quoted
+	}
+	if isRPCPayload(payload) {
+		stamped, answered, stampErr := s.stampRPC(payload)
+		if stampErr != nil {
+			s.send(WSMessage{Op: "error", ID: msg.ID, Error: fmt.Sprintf("invalid JSON-RPC payload: %v", stampErr)})
+			return
+		}
+		if !answered {
+			if sendErr := s.gateway.client.Send(stamped); sendErr != nil {
+				s.send(WSMessage{Op: "error", ID: msg.ID, Error: sendErr.Error()})
+			}
+			return
+		}
+		payload = stamped
+	}
+	reply, requestErr := s.gateway.client.Request(payload)
+	if requestErr != nil {
+		s.send(WSMessage{Op: "error", ID: msg.ID, Error: requestErr.Error()})
+		return
+	}
+	response := WSMessage{Op: "reply", ID: msg.ID}
+	if json.Valid([]byte(reply)) {
+		response.Payload = json.RawMessage(reply)
+	} else {
+		response.Data = reply
+	}
+	s.send(response)
+}
+
+// stampRPC sets the token of the JSON-RPC requests in payload to the session's, and reports whether
+// any of them is answered, i.e. is not a notification.
+func (s *wsSession) stampRPC(payload string) (string, bool, error) {
+	token, _ := json.Marshal(s.token)
+	answered := false
+	stamp := func(req map[string]json.RawMessage) {
+		if req == nil {
+			return
+		}
+		req["token"] = token
+		if _, hasID := req["id"]; hasID {
+			answered = true
+		}
+	}
+
+	trimmed := strings.TrimSpace(payload)
+	var stamped []byte
+	var marshalErr error
+	if strings.HasPrefix(trimmed, "[") {
+		var batch []map[string]json.RawMessage
+		if unmarshalErr := json.Unmarshal([]byte(trimmed), &batch); unmarshalErr != nil {
+			return "", false, unmarshalErr
+		}
+		for _, req := range batch {
+			stamp(req)
+		}
+		stamped, marshalErr = json.Marshal(batch)
+	} else {
+		var req map[string]json.RawMessage
+		if unmarshalErr := json.Unmarshal([]byte(trimmed), &req); unmarshalErr != nil {
+			return "", false, unmarshalErr
+		}
+		stamp(req)
+		stamped, marshalErr = json.Marshal(req)
+	}
+	if marshalErr != nil {
+		return "", false, marshalErr
+	}
+	return string(stamped), answered, nil
+}
+func (s *wsSession) send(msg WSMessage) {
+	data, marshalErr := json.Marshal(msg)
+	if marshalErr != nil {
+		data, _ = json.Marshal(WSMessage{Op: "error", ID: msg.ID, Error: marshalErr.Error()})
+	}
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if sendErr := websocket.Message.Send(s.conn, string(data)); sendErr != nil {
+		logz.Debug("Error writing to WebSocket connection", map[string]interface{}{
+			"context": "WSGateway",
+			"op":      msg.Op,
+			"error":   sendErr.Error(),
+		})
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, WSAuthorizationType) {
+		return strings.TrimSpace(strings.TrimPrefix(header, WSAuthorizationType))
+	}
+	return r.URL.Query().Get(WSAccessTokenQuery)
+}
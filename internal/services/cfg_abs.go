@@ -7,6 +7,7 @@ import (
 	"fmt"
 	glb "github.com/faelmori/gkbxsrv/internal/globals"
 	"github.com/faelmori/gkbxsrv/utils"
+	"github.com/faelmori/logz"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 	"io"
@@ -48,16 +49,17 @@ type ConfigService interface {
 }
 
 type ConfigServiceImpl struct {
-	Logger   *log.Logger  `json:"-"`
-	FilePath string       `json:"file_path"`
-	KeyPath  string       `json:"key_path"`
-	CertPath string       `json:"cert_path"`
-	Server   glb.Server   `json:"server"`
-	Database glb.Database `json:"database"`
-	JWT      glb.JWT      `json:"jwt"`
-	Redis    glb.Redis    `json:"redis"`
-	RabbitMQ glb.RabbitMQ `json:"rabbitmq"`
-	MongoDB  glb.MongoDB  `json:"mongodb"`
+	Logger    *log.Logger             `json:"-"`
+	FilePath  string                  `json:"file_path"`
+	KeyPath   string                  `json:"key_path"`
+	CertPath  string                  `json:"cert_path"`
+	Server    glb.Server              `json:"server"`
+	Database  glb.Database            `json:"database"`
+	Databases map[string]glb.Database `json:"databases,omitempty"`
+	JWT       glb.JWT                 `json:"jwt"`
+	Redis     glb.Redis               `json:"redis"`
+	RabbitMQ  glb.RabbitMQ            `json:"rabbitmq"`
+	MongoDB   glb.MongoDB             `json:"mongodb"`
 }
 
 func (c *ConfigServiceImpl) calculateMD5Hash(filePath string) (string, error) {
@@ -314,16 +316,22 @@ func (c *ConfigServiceImpl) SetupConfig() error {
 			WriteTimeout: 10,
 		},
 		Database: glb.Database{
-			Type:             "postgresql",
-			Driver:           "postgres",
-			ConnectionString: fmt.Sprintf("postgres://kubex_adm:%s@localhost:5432/kubex_db", password),
-			Dsn:              fmt.Sprintf("postgres://kubex_adm:%s@localhost:5432/kubex_db", password),
-			Path:             os.ExpandEnv(`$HOME/.kubex/volumes/postgresql`),
-			Host:             "localhost",
-			Port:             "5432",
-			Username:         "kubex_adm",
-			Password:         password,
-			Name:             "kubex_db",
+			Type:            "postgresql",
+			Driver:          "postgres",
+			Path:            os.ExpandEnv(`$HOME/.kubex/volumes/postgresql`),
+			Host:            "localhost",
+			Port:            "5432",
+			Username:        "kubex_adm",
+			Password:        password,
+			Name:            "kubex_db",
+			TimeZone:        DefaultDBTimeZone,
+			SSLMode:         DefaultDBSSLMode,
+			MaxOpenConns:    DefaultDBMaxOpenConns,
+			MaxIdleConns:    DefaultDBMaxIdleConns,
+			ConnMaxLifetime: DefaultDBConnMaxLifetime,
+			ConnMaxIdleTime: DefaultDBConnMaxIdleTime,
+			SeedOnSetup:     true,
+			Audit:           true,
 		},
 		JWT: glb.JWT{
 			RefreshSecret:         refreshSecret,
@@ -375,9 +383,47 @@ func (c *ConfigServiceImpl) SetupConfig() error {
 		return dbErr
 	}
 
+	if c.Database.SeedOnSetup {
+		c.seedOnSetup()
+	}
+
 	return nil
 }
 
+// seedOnSetup writes the base seed file, with a generated admin password, and applies the seeds. The
+// database may still be starting, so failures are only logged; "gkbxsrv database seed" applies them later.
+// The password is only kept in the seed file, whose path is logged: the operator signs in as admin
+// with it, then changes it and removes it from the file.
+func (c *ConfigServiceImpl) seedOnSetup() {
+	adminPass, adminPassErr := crt.GenerateRandomKey(16)
+	if adminPassErr != nil {
+		logz.Warn("Seed data not applied", map[string]interface{}{"context": "SetupConfig", "error": adminPassErr.Error()})
+		return
+	}
+	seedsDir := filepath.Join(filepath.Dir(c.FilePath), DefaultSeedsDirName)
+	seedFile, writeErr := WriteDefaultSeeds(seedsDir, adminPass)
+	if writeErr != nil {
+		logz.Warn("Seed data not applied", map[string]interface{}{"context": "SetupConfig", "error": writeErr.Error()})
+		return
+	}
+	if seedFile != "" {
+		logz.Info("Generated admin password written to the seed file: change it after the first login and remove it from the file", map[string]interface{}{
+			"context": "SetupConfig",
+			"path":    seedFile,
+		})
+	}
+	_ = Fs.SetSetupCacheFlag("kubex_seed_files")
+	result, seedErr := NewDatabaseService(c.FilePath).Seed()
+	if seedErr != nil {
+		logz.Warn("Seed data not applied, run gkbxsrv database seed once the database is up", map[string]interface{}{
+			"context": "SetupConfig",
+			"error":   seedErr.Error(),
+		})
+		return
+	}
+	logz.Info("Seed data applied", map[string]interface{}{"context": "SetupConfig", "created": result.Created})
+}
+
 func (c *ConfigServiceImpl) SetConfigProperty(key string, value interface{}) error {
 	viper.Set(key, value)
 	return nil
@@ -436,16 +482,22 @@ func (c *ConfigServiceImpl) SetupConfigFromDbService() error {
 			WriteTimeout: 10,
 		},
 		Database: glb.Database{
-			Type:             "postgresql",
-			Driver:           "postgres",
-			ConnectionString: fmt.Sprintf("postgres://kubex_adm:%s@127.0.0.1:5432/kubex_db", password),
-			Dsn:              fmt.Sprintf("postgres://kubex_adm:%s@127.0.0.1:5432/kubex_db", password),
-			Path:             os.ExpandEnv(`$HOME/.kubex/volumes/postgresql`),
-			Host:             "127.0.0.1",
-			Port:             "5432",
-			Username:         "kubex_adm",
-			Password:         password,
-			Name:             "kubex_db",
+			Type:            "postgresql",
+			Driver:          "postgres",
+			Path:            os.ExpandEnv(`$HOME/.kubex/volumes/postgresql`),
+			Host:            "127.0.0.1",
+			Port:            "5432",
+			Username:        "kubex_adm",
+			Password:        password,
+			Name:            "kubex_db",
+			TimeZone:        DefaultDBTimeZone,
+			SSLMode:         DefaultDBSSLMode,
+			MaxOpenConns:    DefaultDBMaxOpenConns,
+			MaxIdleConns:    DefaultDBMaxIdleConns,
+			ConnMaxLifetime: DefaultDBConnMaxLifetime,
+			ConnMaxIdleTime: DefaultDBConnMaxIdleTime,
+			SeedOnSetup:     true,
+			Audit:           true,
 		},
 		JWT: glb.JWT{
 			RefreshSecret:         refreshSecret,
@@ -1,21 +1,15 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	glb "github.com/faelmori/gkbxsrv/internal/globals"
-	"github.com/faelmori/gkbxsrv/internal/models"
-	"github.com/goccy/go-json"
-	"github.com/godror/godror"
-	dsn2 "github.com/godror/godror/dsn"
 	"github.com/spf13/viper"
-	"golang.org/x/net/context"
-	"gorm.io/driver/mysql"
-	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite"
-	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -43,20 +37,40 @@ type IDatabaseService interface {
 	WaitForDatabase(timeout time.Duration, maxRetries int) error
 	InitialHealthCheck() (*gorm.DB, error)
 	SetConnection(db *gorm.DB)
+	Migrator() (*Migrator, error)
+	MigrationsDir() string
+	Stats() (PoolStats, error)
+	StartSupervisor(ctx context.Context) error
+	StopSupervisor()
+	Health() DBHealth
+	HealthEvents() *EventHub
+	Replicas() []ReplicaStatus
+	SeedsDir() string
+	Seed() (SeedResult, error)
+	ModelsDir() string
+	LoadModels() ([]string, error)
+	WithTx(ctx context.Context, fn func(tx Repos) error) error
 }
 
 type DatabaseServiceImpl struct {
 	fs        FileSystemService
 	db        *gorm.DB
+	dbMu      sync.RWMutex
 	mtx       *sync.Mutex
 	wg        *sync.WaitGroup
 	dbCfg     glb.Database
-	dbChanCtl chan string
-	dbChanErr chan error
 	dbChanSig chan os.Signal
-	mdRepo    *glb.GenericRepo
 	dbStats   sql.DBStats
 	lastStats sql.DBStats
+	statsAt   time.Time
+	registry  *ConnectionRegistry
+	regOnce   sync.Once
+	health    DBHealth
+	healthMu  sync.RWMutex
+	healthHub *EventHub
+	supCancel context.CancelFunc
+	supDone   chan struct{}
+	replicas  *ReplicaResolver
 }
 
 func (d *DatabaseServiceImpl) LoadViperConfig() {
@@ -69,49 +83,88 @@ func (d *DatabaseServiceImpl) LoadViperConfig() {
 	d.dbCfg.Type = vpDBCfg["type"]
 	d.dbCfg.Dsn = vpDBCfg["dsn"]
 	d.dbCfg.ConnectionString = vpDBCfg["connection_string"]
+	d.dbCfg.Driver = vpDBCfg["driver"]
+	d.dbCfg.Path = vpDBCfg["path"]
+	d.dbCfg.TimeZone = vpDBCfg["timezone"]
+	d.dbCfg.SSLMode = vpDBCfg["ssl_mode"]
+	d.dbCfg.MaxOpenConns, _ = strconv.Atoi(vpDBCfg["max_open_conns"])
+	d.dbCfg.MaxIdleConns, _ = strconv.Atoi(vpDBCfg["max_idle_conns"])
+	d.dbCfg.ConnMaxLifetime = vpDBCfg["conn_max_lifetime"]
+	d.dbCfg.ConnMaxIdleTime = vpDBCfg["conn_max_idle_time"]
+	d.dbCfg.Audit, _ = strconv.ParseBool(vpDBCfg["audit"])
+	d.dbCfg.MultiTenant, _ = strconv.ParseBool(vpDBCfg["multi_tenant"])
 }
 
 func (d *DatabaseServiceImpl) ConnectDB() error {
 	d.LoadViperConfig()
-	if d.dbCfg.Dsn == "" {
-		if d.dbCfg.ConnectionString != "" {
-			d.dbCfg.Dsn = d.dbCfg.ConnectionString
-		} else {
-			d.dbCfg.Dsn = fmt.Sprintf(
-				"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=America/Sao_Paulo",
-				d.dbCfg.Host, d.dbCfg.Port, d.dbCfg.Username, d.dbCfg.Password, d.dbCfg.Name,
-			)
-		}
+	if _, modelsErr := d.LoadModels(); modelsErr != nil {
+		return fmt.Errorf("❌ Erro ao carregar os modelos dinâmicos: %v", modelsErr)
 	}
-	db, err := gorm.Open(postgres.Open(d.dbCfg.Dsn), &gorm.Config{})
+	db, err := OpenConnection(d.dbCfg)
 	if err != nil {
 		return fmt.Errorf("❌ Erro ao conectar ao banco de dados: %v", err)
 	}
-	d.db = db
+	if replicasErr := d.useReplicas(db); replicasErr != nil {
+		return replicasErr
+	}
+	d.swapDB(db)
 	return nil
 }
 func (d *DatabaseServiceImpl) GetDB() (*gorm.DB, error) {
-	if d.db == nil {
-		d.LoadViperConfig()
-		if err := d.ConnectDB(); err != nil {
-			return nil, fmt.Errorf("❌ Erro ao conectar ao banco de dados: %v", err)
-		} else {
-			return d.db, nil
-		}
+	if db := d.currentDB(); db != nil {
+		return db, nil
 	}
-	return d.db, nil
+	d.LoadViperConfig()
+	if err := d.ConnectDB(); err != nil {
+		return nil, fmt.Errorf("❌ Erro ao conectar ao banco de dados: %v", err)
+	}
+	return d.currentDB(), nil
 }
 func (d *DatabaseServiceImpl) CloseDBConnection() error {
-	sqlDB, err := d.db.DB()
+	d.StopSupervisor()
+	if closeErr := d.connections().CloseAll(); closeErr != nil {
+		return closeErr
+	}
+	db := d.swapDB(nil)
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
 		return fmt.Errorf("❌ Erro ao obter a conexão SQL: %v", err)
 	}
 	return sqlDB.Close()
 }
+
+// Reconnect reloads the config and replaces the primary connection with a new one, closing the
+// previous pool once the new one answers a ping.
 func (d *DatabaseServiceImpl) Reconnect() error {
 	d.LoadViperConfig()
-	d.dbChanCtl <- "reconnect"
-	return <-d.dbChanErr
+	db, openErr := OpenConnection(d.dbCfg)
+	if openErr != nil {
+		return fmt.Errorf("❌ Erro ao reconectar ao banco de dados: %v", openErr)
+	}
+	sqlDB, sqlDBErr := db.DB()
+	if sqlDBErr != nil {
+		return fmt.Errorf("❌ Erro ao obter a conexão SQL: %v", sqlDBErr)
+	}
+	if pingErr := sqlDB.Ping(); pingErr != nil {
+		_ = sqlDB.Close()
+		return fmt.Errorf("❌ Erro ao reconectar ao banco de dados: %v", pingErr)
+	}
+	if replicasErr := d.useReplicas(db); replicasErr != nil {
+		_ = sqlDB.Close()
+		return replicasErr
+	}
+	if previous := d.swapDB(db); previous != nil {
+		if previousSQL, previousErr := previous.DB(); previousErr == nil {
+			_ = previousSQL.Close()
+		}
+	}
+	if d.supervisorRunning() {
+		d.setHealth(DBStateConnected, nil, 0)
+	}
+	return nil
 }
 func (d *DatabaseServiceImpl) IsConnected() error {
 	if d != nil {
@@ -119,216 +172,147 @@ func (d *DatabaseServiceImpl) IsConnected() error {
 			return fmt.Errorf("❌ Erro ao verificar a conexão com o banco de dados: %v", err)
 		}
 		return nil
-	} else {
-		mtx := &sync.Mutex{}
-		mtx.Lock()
-		cfg := NewConfigSrv(d.fs.GetConfigFilePath(), d.fs.GetDefaultKeyPath(), d.fs.GetDefaultCertPath())
-		dbCfg := cfg.GetDatabaseConfig()
-		d = &DatabaseServiceImpl{
-			fs:        NewFileSystemSrv(""),
-			db:        nil,
-			mtx:       &sync.Mutex{},
-			wg:        &sync.WaitGroup{},
-			dbCfg:     dbCfg,
-			dbChanCtl: make(chan string),
-			dbChanErr: make(chan error),
-			dbChanSig: make(chan os.Signal),
-			mdRepo:    nil,
-			dbStats:   sql.DBStats{},
-			lastStats: sql.DBStats{},
-		}
-		db, dbErr := d.OpenDB()
-		if dbErr != nil {
-			return fmt.Errorf("❌ Erro ao conectar ao banco de dados: %v", dbErr)
-		}
-		d.db = db
-		mtx.Unlock()
-		return nil
 	}
+	return fmt.Errorf("❌ Database service is nil")
 }
 func (d *DatabaseServiceImpl) GetDBConfig(name string) (glb.Database, error) {
 	d.LoadViperConfig()
-	return d.dbCfg, nil
+	if normalizeConnectionName(name) == DefaultConnectionName {
+		return d.dbCfg, nil
+	}
+	cfg, ok := d.connections().Config(name)
+	if !ok {
+		if reloadErr := d.connections().LoadFromViper(); reloadErr != nil {
+			return glb.Database{}, reloadErr
+		}
+		if cfg, ok = d.connections().Config(name); !ok {
+			return glb.Database{}, fmt.Errorf("database connection %q is not configured", name)
+		}
+	}
+	return cfg, nil
 }
 
 func (d *DatabaseServiceImpl) GetHost() (string, error) {
-	d.dbChanCtl <- "getHost"
-	err := <-d.dbChanErr
-	if err != nil {
-		return "", err
-	}
+	d.LoadViperConfig()
 	return d.dbCfg.Host, nil
 }
+
+// GetConnection returns the named connection from the `databases:` config, opening it on first use.
+// An empty name or DefaultConnectionName returns the primary connection.
 func (d *DatabaseServiceImpl) GetConnection(client string) (*gorm.DB, error) {
-	d.LoadViperConfig()
-	return d.db, nil
+	if normalizeConnectionName(client) == DefaultConnectionName {
+		return d.GetDB()
+	}
+	if _, cfgErr := d.GetDBConfig(client); cfgErr != nil {
+		return nil, cfgErr
+	}
+	return d.connections().Get(client)
+}
+
+// connections returns the registry of named connections, loading the `databases:` config on first use.
+func (d *DatabaseServiceImpl) connections() *ConnectionRegistry {
+	d.regOnce.Do(func() {
+		if d.registry == nil {
+			d.registry = NewConnectionRegistry()
+		}
+		if loadErr := d.registry.LoadFromViper(); loadErr != nil {
+			fmt.Printf("Erro ao carregar as conexões nomeadas: %v\n", loadErr)
+		}
+	})
+	return d.registry
 }
 func (d *DatabaseServiceImpl) OpenDB() (*gorm.DB, error) {
 	d.LoadViperConfig()
-	if d.db != nil {
-		return d.db, nil
-	}
-	var db *gorm.DB
-	var dbErr error
-	switch d.dbCfg.Type {
-	case "oracle", "oci8", "goracle", "godror":
-		db, dbErr = d.ConnectOracle()
-	case "mysql", "mariadb":
-		db, dbErr = d.ConnectMySQL()
-	case "postgres", "postgresql":
-		db, dbErr = d.ConnectPostgres()
-	case "mssql", "sqlserver":
-		db, dbErr = d.ConnectMSSQL()
-	case "sqlite", "sqlite3":
-		db, dbErr = d.ConnectSQLite()
-	default:
-		db, dbErr = d.ConnectSQLite()
+	if db := d.currentDB(); db != nil {
+		return db, nil
 	}
-	if dbErr != nil {
-		return nil, fmt.Errorf("❌ Erro ao conectar ao banco de dados: %v", dbErr)
+	if dbErr := d.ConnectDB(); dbErr != nil {
+		return nil, dbErr
 	}
+	db := d.currentDB()
 
-	if migrateErr := d.db.AutoMigrate(models.ModelList...); migrateErr != nil {
-		os.Exit(1)
-		return nil, migrateErr
-	}
-	return db, nil
-}
-func (d *DatabaseServiceImpl) loadModelsFromCache(generatedFilesPath []string) ([]interface{}, error) {
-	var modelsList []interface{}
-	for _, modelPath := range generatedFilesPath {
-		readFile, readFileErr := os.ReadFile(modelPath)
-		if readFileErr != nil {
-			return nil, readFileErr
-		}
-		jsonContent, jsonContentErr := json.Marshal(readFile)
-		if jsonContentErr != nil {
-			return nil, jsonContentErr
+	// Pending migrations are applied on open unless database.auto_migrate is false.
+	if !viper.IsSet("database.auto_migrate") || viper.GetBool("database.auto_migrate") {
+		migrator, migratorErr := d.Migrator()
+		if migratorErr != nil {
+			return nil, migratorErr
 		}
-		jsonModelStructRef, jsonModelStructRefErr := d.loadModelFromJSONContent(jsonContent)
-		if jsonModelStructRefErr != nil {
-			return nil, jsonModelStructRefErr
+		if _, migrateErr := migrator.Up(0); migrateErr != nil {
+			return nil, fmt.Errorf("❌ Erro ao migrar o banco de dados: %v", migrateErr)
 		}
-		modelsList = append(modelsList, jsonModelStructRef)
 	}
-	return modelsList, nil
+	return db, nil
 }
 
-func (d *DatabaseServiceImpl) loadModelFromJSONContent(jsonContent []byte) (interface{}, error) {
-	var modelStructRef interface{}
-	jsonContentStr := string(jsonContent)
-	if jsonContentStr != "" {
-		if jsonUnmarshalErr := json.Unmarshal([]byte(jsonContentStr), &modelStructRef); jsonUnmarshalErr != nil {
-			return nil, jsonUnmarshalErr
-		}
+// Migrator returns the schema migrator of the primary connection, reading SQL migrations from
+// database.migrations_dir or, by default, the migrations directory next to the config file.
+func (d *DatabaseServiceImpl) Migrator() (*Migrator, error) {
+	db, dbErr := d.GetDB()
+	if dbErr != nil {
+		return nil, dbErr
 	}
-	return modelStructRef, nil
+	return NewMigrator(db, d.MigrationsDir()), nil
 }
-
-func (d *DatabaseServiceImpl) ConnectMySQL() (*gorm.DB, error) {
-	d.LoadViperConfig()
-	var dsn string
-	if d.dbCfg.Dsn != "" {
-		dsn = d.dbCfg.Dsn
-	} else {
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			d.dbCfg.Username, d.dbCfg.Password, d.dbCfg.Host, d.dbCfg.Port, d.dbCfg.Name,
-		)
+func (d *DatabaseServiceImpl) MigrationsDir() string {
+	if dir := viper.GetString("database.migrations_dir"); dir != "" {
+		return os.ExpandEnv(dir)
 	}
-	d.dbCfg.Dsn = dsn
-	var dbErr error
-	d.db, dbErr = gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if dbErr != nil {
-		return d.InitialHealthCheck()
+	if cfgFile := viper.ConfigFileUsed(); cfgFile != "" {
+		return filepath.Join(filepath.Dir(cfgFile), DefaultMigrationsDirName)
+	}
+	if d.fs != nil {
+		return filepath.Join(d.fs.GetDefaultConfigDir(), DefaultMigrationsDirName)
 	}
-	return d.db, nil
+	return DefaultMigrationsDirName
+}
+func (d *DatabaseServiceImpl) ConnectMySQL() (*gorm.DB, error) {
+	return d.connectWithDriver(DriverMySQL)
 }
 func (d *DatabaseServiceImpl) ConnectPostgres() (*gorm.DB, error) {
-	d.LoadViperConfig()
-	if d.db == nil {
-		var dsn string
-		if d.dbCfg.Dsn != "" {
-			dsn = d.dbCfg.Dsn
-		} else {
-			dsn = fmt.Sprintf(
-				"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=America/Sao_Paulo TLS=false",
-				d.dbCfg.Host, d.dbCfg.Port, d.dbCfg.Username, d.dbCfg.Password, d.dbCfg.Name,
-			)
-		}
-		d.dbCfg.Dsn = dsn
-		var dbErr error
-		d.db, dbErr = gorm.Open(postgres.Open(d.dbCfg.Dsn), &gorm.Config{})
-		if dbErr != nil {
-			return d.InitialHealthCheck()
-		}
-	}
-	return d.db, nil
+	return d.connectWithDriver(DriverPostgres)
 }
 func (d *DatabaseServiceImpl) ConnectSQLite() (*gorm.DB, error) {
-	d.LoadViperConfig()
-	var dbErr error
-	d.db, dbErr = gorm.Open(sqlite.Open("gorm.db"), &gorm.Config{})
-	if dbErr != nil {
-		return nil, fmt.Errorf("❌ Erro ao conectar ao banco de dados: %v", dbErr)
-	}
-	return d.db, nil
+	return d.connectWithDriver(DriverSQLite)
 }
 func (d *DatabaseServiceImpl) ConnectMSSQL() (*gorm.DB, error) {
-	d.LoadViperConfig()
-	var dsn string
-	if d.dbCfg.Dsn != "" {
-		dsn = d.dbCfg.Dsn
-	} else {
-		dsn = fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s",
-			d.dbCfg.Username, d.dbCfg.Password, d.dbCfg.Host, d.dbCfg.Port, d.dbCfg.Name,
-		)
-	}
-	d.dbCfg.Dsn = dsn
-	var dbErr error
-	d.db, dbErr = gorm.Open(sqlserver.Open(dsn), &gorm.Config{})
-	if dbErr != nil {
-		return d.InitialHealthCheck()
-	}
-	return d.db, nil
+	return d.connectWithDriver(DriverSQLServer)
 }
 func (d *DatabaseServiceImpl) ConnectOracle() (*gorm.DB, error) {
+	return d.connectWithDriver(DriverOracle)
+}
+
+// connectWithDriver opens the configured database forcing its driver, for callers that pick one explicitly.
+func (d *DatabaseServiceImpl) connectWithDriver(driver string) (*gorm.DB, error) {
 	d.LoadViperConfig()
-	dsn := viper.GetString("database.connection_string")
-	if dsn == "" {
-		dsn = fmt.Sprintf("%s/%s@%s:%s/%s", d.dbCfg.Username, d.dbCfg.Password, d.dbCfg.Host, d.dbCfg.Port, d.dbCfg.Name)
-	}
-	var blSA sql.NullBool
-	_ = blSA.Scan(true)
-	dsnCP := dsn2.ConnectionParams{}
-	cmParams := dsn2.CommonParams{}
-	dsnCP.Username = d.dbCfg.Username
-	dsnCP.Password = dsn2.NewPassword(d.dbCfg.Password)
-	dsnCP.Timezone = time.Local
-	dsnCP.StandaloneConnection = blSA
-	cmParams.ConnectString = fmt.Sprintf("%s:%s/%s", d.dbCfg.Host, d.dbCfg.Port, d.dbCfg.Name)
-	dsnCP.CommonParams = cmParams
-	oracle := godror.NewConnector(dsnCP)
-	oraConn, oraConnErr := oracle.Connect(context.Background())
-	if oraConnErr != nil {
-		return nil, oraConnErr
-	}
-	var dbErr error
-	d.db, dbErr = gorm.Open(oraConn.(gorm.Dialector), &gorm.Config{})
+	cfg := d.dbCfg
+	cfg.Driver = driver
+	db, dbErr := OpenConnection(cfg)
 	if dbErr != nil {
-		return d.InitialHealthCheck()
+		return nil, fmt.Errorf("❌ Erro ao conectar ao banco de dados: %v", dbErr)
+	}
+	if replicasErr := d.useReplicas(db); replicasErr != nil {
+		return nil, replicasErr
 	}
-	return d.db, nil
+	d.swapDB(db)
+	return db, nil
 }
+
+// CheckDatabaseHealth reports the state of the supervisor when one is running and pings the database
+// otherwise.
 func (d *DatabaseServiceImpl) CheckDatabaseHealth() error {
-	d.LoadViperConfig()
-	if d != nil {
-		d.LoadViperConfig()
-		if d.db == nil {
-			return fmt.Errorf("❌ Database connection is nil")
+	if d == nil {
+		return fmt.Errorf("❌ Database connection is nil")
+	}
+	if d.supervisorRunning() {
+		// DBStateUnknown only lasts until the first ping of a supervisor that just started.
+		switch health := d.Health(); health.State {
+		case DBStateConnected:
+			return nil
+		case DBStateDegraded, DBStateDown:
+			return fmt.Errorf("❌ Database is %s: %s", health.State, health.Error)
 		}
-		return d.db.Raw("SELECT 1").Error
 	}
-	return fmt.Errorf("❌ Database connection is nil")
+	return d.ping(context.Background(), DefaultDBHealthTimeout)
 }
 func (d *DatabaseServiceImpl) WaitForDatabase(timeout time.Duration, maxRetries int) error {
 	d.LoadViperConfig()
@@ -350,56 +334,51 @@ func (d *DatabaseServiceImpl) InitialHealthCheck() (*gorm.DB, error) {
 	if waitErr := d.WaitForDatabase(timeout, maxRetries); waitErr != nil {
 		return nil, fmt.Errorf("❌ Erro ao verificar a saúde do banco de dados: %v", waitErr)
 	} else {
-		return d.db, nil
+		return d.currentDB(), nil
 	}
 }
 func (d *DatabaseServiceImpl) SetConnection(db *gorm.DB) {
 	d.LoadViperConfig()
 	if db != nil {
-		d.db = db
+		d.swapDB(db)
 	} else {
 		fmt.Println("Database connection is nil")
 	}
 }
 
+// ServiceHandler starts the health supervisor, if it isn't running yet, and returns a channel that
+// receives a DBHealth for every state change. The channel is closed when the supervisor stops.
 func (d *DatabaseServiceImpl) ServiceHandler() chan interface{} {
 	d.LoadViperConfig()
-	for {
-		select {
-		case dbCtl := <-d.dbChanCtl:
-			switch dbCtl {
-			case "reconnect":
-				db := *d.db
-				dbClientB, dbClientBErr := db.DB()
-				if dbClientBErr != nil {
-					d.dbChanErr <- &glb.ValidationError{Message: dbClientBErr.Error(), Field: "dbChanCtl_reconnect"}
-				} else {
-					if dbClientBErrB := dbClientB.Close(); dbClientBErrB != nil {
-						d.dbChanErr <- &glb.ValidationError{Message: dbClientBErrB.Error(), Field: "dbChanCtl_reconnect"}
-					} else {
-						d.dbChanErr <- nil
-					}
-				}
-			case "isConnected":
-				if _, dbErr := d.db.DB(); dbErr != nil {
-					d.dbChanErr <- &glb.ValidationError{Message: dbErr.Error(), Field: "dbChanCtl_isConnected"}
-				} else {
-					d.dbChanErr <- nil
+	sub := d.HealthEvents().Subscribe(DBHealthTopicPrefix + "*")
+	if startErr := d.StartSupervisor(context.Background()); startErr != nil {
+		fmt.Printf("Erro ao iniciar o supervisor do banco de dados: %v\n", startErr)
+	}
+	d.healthMu.RLock()
+	done := d.supDone
+	d.healthMu.RUnlock()
+	states := make(chan interface{}, EventBufferSize)
+	go func() {
+		defer close(states)
+		defer sub.Close()
+		for {
+			select {
+			case event, ok := <-sub.Events():
+				if !ok {
+					return
 				}
-			case "getHost":
-				dbCfgB := &d.dbCfg
-				d.dbChanErr <- &glb.ValidationError{Message: dbCfgB.Host, Field: "dbChanCtl_getHost"}
-			case "close":
-				dbB, dbBErr := d.db.DB()
-				if dbBErr != nil {
-					d.dbChanErr <- &glb.ValidationError{Message: dbBErr.Error(), Field: "dbChanCtl_close"}
-				} else {
-					_ = dbB.Close()
-					d.dbChanErr <- nil
+				// A caller that stopped reading must not keep the forwarder past the supervisor.
+				select {
+				case states <- event.Data:
+				case <-done:
+					return
 				}
+			case <-done:
+				return
 			}
 		}
-	}
+	}()
+	return states
 }
 
 func NewDatabaseService(configFileArg string) IDatabaseService {
@@ -417,15 +396,9 @@ func NewDatabaseService(configFileArg string) IDatabaseService {
 		mtx:       &sync.Mutex{},
 		wg:        &sync.WaitGroup{},
 		dbCfg:     glb.Database{},
-		dbChanCtl: make(chan string, 10),
-		dbChanErr: make(chan error, 10),
 		dbChanSig: make(chan os.Signal, 1),
 	}
 	databaseService.LoadViperConfig()
-	db, dbErr := databaseService.OpenDB()
-	if dbErr != nil {
-	} else {
-		databaseService.db = db
-	}
+	_, _ = databaseService.OpenDB()
 	return databaseService
 }
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// With database.audit enabled, every create, update and delete of a model is recorded in audit_logs,
+// in the transaction of the write itself, with the changed columns and the actor set with WithActor.
+// Fields tagged audit:"-" are reported as changed without their values. Updates and deletes without
+// conditions, allowed with AllowGlobalUpdate, are not recorded.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+
+	auditHook     = "gkbxsrv:audit"
+	auditSkipKey  = "gkbxsrv:audit_skip"
+	auditRowsKey  = "gkbxsrv:audit_rows"
+	auditRedacted = "[redacted]"
+)
+
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Model     string    `gorm:"type:varchar(100);not null;index:idx_audit_logs_record,priority:1" json:"model"`
+	RecordID  string    `gorm:"type:varchar(255);not null;index:idx_audit_logs_record,priority:2" json:"record_id"`
+	Operation string    `gorm:"type:varchar(20);not null" json:"operation"`
+	Changes   string    `gorm:"type:text" json:"changes"`
+	Actor     string    `gorm:"type:varchar(255);index" json:"actor"`
+	CreatedAt time.Time `gorm:"not null;index" json:"created_at"`
+	models.Tenant
+}
+
+func (AuditLog) TableName() string { return "audit_logs" }
+
+// AuditChange is the value of a column before and after a write; Before is nil on create and After on
+// a hard delete.
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Diff decodes Changes, keyed by column.
+func (a AuditLog) Diff() (map[string]AuditChange, error) {
+	changes := make(map[string]AuditChange)
+	if a.Changes == "" {
+		return changes, nil
+	}
+	if unmarshalErr := json.Unmarshal([]byte(a.Changes), &changes); unmarshalErr != nil {
+		return nil, fmt.Errorf("error decoding audit changes: %v", unmarshalErr)
+	}
+	return changes, nil
+}
+
+type auditActorCtxKey struct{}
+
+// WithActor marks ctx so the writes run with db.WithContext(ctx) are audited as made by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorCtxKey{}, actor)
+}
+
+// WithUser is WithActor for an authenticated user, e.g. the one of a validated ID token.
+func WithUser(ctx context.Context, user models.User) context.Context {
+	if user == nil {
+		return ctx
+	}
+	return WithActor(ctx, user.GetUsername())
+}
+
+// ActorFromContext returns the actor set with WithActor, or "" when there is none.
+func ActorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(auditActorCtxKey{}).(string)
+	return actor
+}
+
+// SkipAudit returns a session of db whose writes are not audited, e.g. to restore a backup.
+func SkipAudit(db *gorm.DB) *gorm.DB {
+	return db.Set(auditSkipKey, true).Session(&gorm.Session{})
+}
+
+// AuditHistory returns the audit entries matching q, newest first unless q sorts them otherwise.
+func AuditHistory(db *gorm.DB, q models.Query) ([]AuditLog, models.PageInfo, error) {
+	if len(q.Sort) == 0 {
+		q.Sort = []string{"-created_at", "-id"}
+	}
+	var logs []AuditLog
+	info, findErr := models.FindQuery(UsePrimary(db), &logs, q)
+	if findErr != nil {
+		return nil, models.PageInfo{}, findErr
+	}
+	return logs, info, nil
+}
+
+// useAudit registers the callbacks that feed audit_logs. The snapshots taken before updates and
+// deletes run in the transaction of the write, after the Before hooks.
+func useAudit(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if createErr := callbacks.Create().After("gorm:create").Before("gorm:after_create").Register(auditHook+":create", auditCreated); createErr != nil {
+		return createErr
+	}
+	if beforeErr := callbacks.Update().After("gorm:before_update").Before("gorm:update").Register(auditHook+":before_update", auditSnapshot); beforeErr != nil {
+		return beforeErr
+	}
+	if updateErr := callbacks.Update().After("gorm:update").Before("gorm:after_update").Register(auditHook+":update", auditUpdated); updateErr != nil {
+		return updateErr
+	}
+	if beforeErr := callbacks.Delete().After("gorm:before_delete").Before("gorm:delete").Register(auditHook+":before_delete", auditSnapshot); beforeErr != nil {
+		return beforeErr
+	}
+	return callbacks.Delete().After("gorm:delete").Before("gorm:after_delete").Register(auditHook+":delete", auditDeleted)
+}
+
+// auditRow is a row as seen by the audit: its primary key and its values by column.
+type auditRow struct {
+	id     string
+	values map[string]interface{}
+}
+
+func auditEnabled(tx *gorm.DB) bool {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.DryRun {
+		return false
+	}
+	if skip, ok := tx.Get(auditSkipKey); ok && skip == true {
+		return false
+	}
+	switch tx.Statement.Schema.Table {
+	case (AuditLog{}).TableName(), (SchemaMigration{}).TableName(), (SchemaMigrationLock{}).TableName():
+		return false
+	}
+	return true
+}
+
+func auditCreated(tx *gorm.DB) {
+	// An upsert whose conflict was left untouched wrote nothing.
+	if !auditEnabled(tx) || tx.Statement.RowsAffected == 0 {
+		return
+	}
+	sch := tx.Statement.Schema
+	var entries []AuditLog
+	auditEach(tx.Statement.ReflectValue, func(rv reflect.Value) {
+		row := auditRowOf(tx, sch, rv)
+		entries = append(entries, auditEntry(tx, AuditCreate, row, auditDiff(sch, nil, row.values)))
+	})
+	auditWrite(tx, entries)
+}
+
+// auditSnapshot keeps the rows an update or a delete is about to change.
+func auditSnapshot(tx *gorm.DB) {
+	if !auditEnabled(tx) {
+		return
+	}
+	query, filtered := affectedRows(tx)
+	if !filtered {
+		return
+	}
+	rows, loadErr := auditLoad(tx, query)
+	if loadErr != nil {
+		_ = tx.AddError(fmt.Errorf("error reading rows for the audit log: %v", loadErr))
+		return
+	}
+	tx.InstanceSet(auditRowsKey, rows)
+}
+
+// affectedRows returns a query of the rows an update or a delete is about to change, or false when
+// the statement has no conditions.
+func affectedRows(tx *gorm.DB) (*gorm.DB, bool) {
+	sch := tx.Statement.Schema
+	query := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Model(reflect.New(sch.ModelType).Interface())
+	if tx.Statement.Unscoped {
+		query = query.Unscoped()
+	}
+	filtered := false
+	if where, ok := tx.Statement.Clauses["WHERE"]; ok {
+		if conds, isWhere := where.Expression.(clause.Where); isWhere && len(conds.Exprs) > 0 {
+			query, filtered = query.Clauses(conds), true
+		}
+	}
+	// Updates and deletes of a loaded model are filtered by its primary key.
+	if pk := sch.PrioritizedPrimaryField; pk != nil {
+		var ids []interface{}
+		auditEach(tx.Statement.ReflectValue, func(rv reflect.Value) {
+			if id, isZero := pk.ValueOf(tx.Statement.Context, rv); !isZero {
+				ids = append(ids, id)
+			}
+		})
+		if len(ids) > 0 {
+			query, filtered = query.Where(clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Values: ids}), true
+		}
+	}
+	return query, filtered
+}
+
+func auditUpdated(tx *gorm.DB) {
+	before, ok := auditSnapshotRows(tx)
+	if !ok {
+		return
+	}
+	after, reloadErr := auditReload(tx, before)
+	if reloadErr != nil {
+		_ = tx.AddError(reloadErr)
+		return
+	}
+	sch := tx.Statement.Schema
+	softDelete := models.SoftDeleteField(sch)
+	var entries []AuditLog
+	for _, row := range before {
+		current, found := after[row.id]
+		if !found {
+			continue
+		}
+		changes := auditDiff(sch, row.values, current.values)
+		if len(changes) == 0 {
+			continue
+		}
+		operation := AuditUpdate
+		if softDelete != nil && auditIsSet(row.values[softDelete.DBName]) && !auditIsSet(current.values[softDelete.DBName]) {
+			operation = AuditRestore
+		}
+		entries = append(entries, auditEntry(tx, operation, current, changes))
+	}
+	auditWrite(tx, entries)
+}
+
+func auditDeleted(tx *gorm.DB) {
+	before, ok := auditSnapshotRows(tx)
+	if !ok {
+		return
+	}
+	sch := tx.Statement.Schema
+	after := map[string]auditRow{}
+	if models.SoftDeleteField(sch) != nil && !tx.Statement.Unscoped {
+		// A soft delete is an update of deleted_at.
+		var reloadErr error
+		if after, reloadErr = auditReload(tx, before); reloadErr != nil {
+			_ = tx.AddError(reloadErr)
+			return
+		}
+	}
+	var entries []AuditLog
+	for _, row := range before {
+		var values map[string]interface{}
+		if current, found := after[row.id]; found {
+			values = current.values
+		}
+		entries = append(entries, auditEntry(tx, AuditDelete, row, auditDiff(sch, row.values, values)))
+	}
+	auditWrite(tx, entries)
+}
+
+func auditSnapshotRows(tx *gorm.DB) ([]auditRow, bool) {
+	if !auditEnabled(tx) {
+		return nil, false
+	}
+	value, ok := tx.InstanceGet(auditRowsKey)
+	if !ok {
+		return nil, false
+	}
+	rows, ok := value.([]auditRow)
+	return rows, ok && len(rows) > 0
+}
+
+func auditLoad(tx *gorm.DB, query *gorm.DB) ([]auditRow, error) {
+	sch := tx.Statement.Schema
+	list := reflect.New(reflect.SliceOf(reflect.PointerTo(sch.ModelType)))
+	if findErr := query.Find(list.Interface()).Error; findErr != nil {
+		return nil, findErr
+	}
+	rows := make([]auditRow, 0, list.Elem().Len())
+	auditEach(list.Elem(), func(rv reflect.Value) {
+		rows = append(rows, auditRowOf(tx, sch, rv))
+	})
+	return rows, nil
+}
+
+// auditReload reads rows again, deleted or not, after the write.
+func auditReload(tx *gorm.DB, rows []auditRow) (map[string]auditRow, error) {
+	sch := tx.Statement.Schema
+	pk := sch.PrioritizedPrimaryField
+	if pk == nil {
+		return map[string]auditRow{}, nil
+	}
+	ids := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.values[pk.DBName])
+	}
+	query := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Unscoped().
+		Model(reflect.New(sch.ModelType).Interface()).
+		Where(clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Values: ids})
+	reloaded, loadErr := auditLoad(tx, query)
+	if loadErr != nil {
+		return nil, fmt.Errorf("error reading rows for the audit log: %v", loadErr)
+	}
+	byID := make(map[string]auditRow, len(reloaded))
+	for _, row := range reloaded {
+		byID[row.id] = row
+	}
+	return byID, nil
+}
+
+func auditEach(rv reflect.Value, fn func(rv reflect.Value)) {
+	rv = reflect.Indirect(rv)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if item := reflect.Indirect(rv.Index(i)); item.Kind() == reflect.Struct {
+				fn(item)
+			}
+		}
+	case reflect.Struct:
+		fn(rv)
+	}
+}
+
+func auditRowOf(tx *gorm.DB, sch *schema.Schema, rv reflect.Value) auditRow {
+	row := auditRow{values: make(map[string]interface{}, len(sch.DBNames))}
+	for _, field := range sch.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		value, _ := field.ValueOf(tx.Statement.Context, rv)
+		row.values[field.DBName] = value
+	}
+	ids := make([]string, 0, len(sch.PrimaryFields))
+	for _, field := range sch.PrimaryFields {
+		ids = append(ids, fmt.Sprint(reflect.Indirect(reflect.ValueOf(row.values[field.DBName]))))
+	}
+	row.id = strings.Join(ids, ",")
+	return row
+}
+
+// auditDiff returns the columns whose values differ between before and after; either may be nil.
+func auditDiff(sch *schema.Schema, before, after map[string]interface{}) map[string]AuditChange {
+	changes := make(map[string]AuditChange)
+	for _, field := range sch.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		change := AuditChange{Before: auditValue(before, field.DBName), After: auditValue(after, field.DBName)}
+		beforeJSON, _ := json.Marshal(change.Before)
+		afterJSON, _ := json.Marshal(change.After)
+		if string(beforeJSON) == string(afterJSON) {
+			continue
+		}
+		if field.Tag.Get("audit") == "-" {
+			change = AuditChange{Before: auditRedactedValue(change.Before), After: auditRedactedValue(change.After)}
+		}
+		changes[field.DBName] = change
+	}
+	return changes
+}
+func auditValue(values map[string]interface{}, column string) interface{} {
+	if values == nil {
+		return nil
+	}
+	value := values[column]
+	if !auditIsSet(value) {
+		return nil
+	}
+	return value
+}
+func auditRedactedValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return auditRedacted
+}
+
+// auditIsSet tells nil pointers and invalid gorm.DeletedAt values apart from actual values.
+func auditIsSet(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if deletedAt, ok := value.(gorm.DeletedAt); ok {
+		return deletedAt.Valid
+	}
+	rv := reflect.ValueOf(value)
+	return rv.Kind() != reflect.Ptr || !rv.IsNil()
+}
+
+// auditEntry records a change of row; the entry belongs to the tenant of the row, if any.
+func auditEntry(tx *gorm.DB, operation string, row auditRow, changes map[string]AuditChange) AuditLog {
+	data, _ := json.Marshal(changes)
+	entry := AuditLog{
+		Model:     auditModelName(tx.Statement.Schema),
+		RecordID:  row.id,
+		Operation: operation,
+		Changes:   string(data),
+		Actor:     ActorFromContext(tx.Statement.Context),
+		CreatedAt: time.Now().UTC(),
+	}
+	if field := models.TenantField(tx.Statement.Schema); field != nil {
+		entry.TenantID, _ = row.values[field.DBName].(string)
+	}
+	return entry
+}
+
+// auditModelName is the name the model is registered under, or its table.
+func auditModelName(sch *schema.Schema) string {
+	for name, typ := range models.ModelRegistryMap {
+		if typ == sch.ModelType {
+			return name
+		}
+	}
+	return sch.Table
+}
+
+func auditWrite(tx *gorm.DB, entries []AuditLog) {
+	if len(entries) == 0 {
+		return
+	}
+	if createErr := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Create(&entries).Error; createErr != nil {
+		_ = tx.AddError(fmt.Errorf("error writing audit log: %v", createErr))
+	}
+}
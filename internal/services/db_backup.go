@@ -0,0 +1,383 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A backup archive is gzip compressed JSONL. Every line is a models.ModelRegistryImpl envelope: a
+// BackupHeaderType line first, then one line per row ({"type":"product","data":{...}}) grouped by
+// model, and a BackupTrailerType line with the row counts, so truncated archives are detected.
+const (
+	BackupFormat        = "gkbxsrv-backup"
+	BackupFormatVersion = 1
+	BackupHeaderType    = "gkbxsrv.backup.header"
+	BackupTrailerType   = "gkbxsrv.backup.trailer"
+	BackupBatchSize     = 500
+	BackupFileExtension = ".jsonl.gz"
+)
+
+// BackupManifest is the header of an archive. SchemaVersion is the latest migration applied to the
+// source database; a restore refuses archives newer than its target schema.
+type BackupManifest struct {
+	Format        string    `json:"format"`
+	FormatVersion int       `json:"format_version"`
+	SchemaVersion int64     `json:"schema_version"`
+	Driver        string    `json:"driver"`
+	CreatedAt     time.Time `json:"created_at"`
+	Models        []string  `json:"models"`
+}
+
+type backupTrailer struct {
+	Counts map[string]int64 `json:"counts"`
+}
+
+// BackupResult describes a written or restored archive.
+type BackupResult struct {
+	Manifest BackupManifest   `json:"manifest"`
+	Counts   map[string]int64 `json:"counts"`
+}
+
+type BackupOptions struct {
+	// Models limits the backup to these ModelRegistryMap names; empty means every model with a table.
+	Models []string
+}
+
+type RestoreOptions struct {
+	// Clean deletes the rows of the archived models before restoring. Without it the restore fails
+	// when one of their tables has rows.
+	Clean bool
+	// Force restores archives whose schema version is newer than the target schema.
+	Force bool
+}
+
+type backupModel struct {
+	name string
+	typ  reflect.Type
+}
+
+// backupModels lists the registered models that have a table, in ModelList order first (the order
+// tables are created in, so parents come before children) and then by name.
+func backupModels(db *gorm.DB, only []string) ([]backupModel, error) {
+	wanted := make(map[string]bool, len(only))
+	for _, name := range only {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := models.ModelRegistryMap[name]; !ok {
+			return nil, fmt.Errorf("model %s not found", name)
+		}
+		wanted[name] = true
+	}
+	nameOf := make(map[reflect.Type]string, len(models.ModelRegistryMap))
+	names := make([]string, 0, len(models.ModelRegistryMap))
+	for name, typ := range models.ModelRegistryMap {
+		nameOf[typ] = name
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ordered []backupModel
+	seen := make(map[string]bool)
+	add := func(name string, typ reflect.Type) {
+		if seen[name] || (len(wanted) > 0 && !wanted[name]) {
+			return
+		}
+		seen[name] = true
+		if db.Migrator().HasTable(reflect.New(typ).Interface()) {
+			ordered = append(ordered, backupModel{name: name, typ: typ})
+		}
+	}
+	for _, model := range models.ModelList {
+		typ := reflect.TypeOf(model)
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if name, ok := nameOf[typ]; ok {
+			add(name, typ)
+		}
+	}
+	for _, name := range names {
+		add(name, models.ModelRegistryMap[name])
+	}
+	return ordered, nil
+}
+
+// Backup writes every registered model of db to w as a compressed archive. Rows are read on the
+// primary inside one transaction, which is a consistent snapshot on Postgres and MySQL. Hooks are
+// skipped so rows are archived as stored, e.g. with the password hashes UserImpl.AfterFind clears,
+// and soft deleted rows and the rows of every tenant are archived too.
+func Backup(db *gorm.DB, w io.Writer, opts BackupOptions) (BackupResult, error) {
+	db = asSystem(UsePrimary(db)).Session(&gorm.Session{SkipHooks: true})
+	list, listErr := backupModels(db, opts.Models)
+	if listErr != nil {
+		return BackupResult{}, listErr
+	}
+	schemaVersion, versionErr := NewMigrator(db, "").Version()
+	if versionErr != nil {
+		return BackupResult{}, versionErr
+	}
+
+	result := BackupResult{
+		Manifest: BackupManifest{
+			Format:        BackupFormat,
+			FormatVersion: BackupFormatVersion,
+			SchemaVersion: schemaVersion,
+			Driver:        db.Dialector.Name(),
+			CreatedAt:     time.Now().UTC(),
+		},
+		Counts: make(map[string]int64, len(list)),
+	}
+	for _, model := range list {
+		result.Manifest.Models = append(result.Manifest.Models, model.name)
+	}
+
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	if encErr := enc.Encode(models.ModelRegistryImpl{Tp: BackupHeaderType, Dt: result.Manifest}); encErr != nil {
+		return result, fmt.Errorf("error writing backup: %v", encErr)
+	}
+
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range list {
+			rows := reflect.New(reflect.SliceOf(reflect.PtrTo(model.typ)))
+			batchErr := tx.Unscoped().Model(reflect.New(model.typ).Interface()).FindInBatches(rows.Interface(), BackupBatchSize, func(batch *gorm.DB, _ int) error {
+				slice := rows.Elem()
+				for i := 0; i < slice.Len(); i++ {
+					if encErr := enc.Encode(models.ModelRegistryImpl{Tp: model.name, Dt: slice.Index(i).Interface()}); encErr != nil {
+						return encErr
+					}
+				}
+				result.Counts[model.name] += int64(slice.Len())
+				return nil
+			}).Error
+			if batchErr != nil {
+				return fmt.Errorf("error backing up %s: %v", model.name, batchErr)
+			}
+		}
+		return nil
+	}, backupTxOptions(db))
+	if txErr != nil {
+		return result, txErr
+	}
+
+	if encErr := enc.Encode(models.ModelRegistryImpl{Tp: BackupTrailerType, Dt: backupTrailer{Counts: result.Counts}}); encErr != nil {
+		return result, fmt.Errorf("error writing backup: %v", encErr)
+	}
+	if closeErr := gz.Close(); closeErr != nil {
+		return result, fmt.Errorf("error writing backup: %v", closeErr)
+	}
+	return result, nil
+}
+
+func backupTxOptions(db *gorm.DB) *sql.TxOptions {
+	switch db.Dialector.Name() {
+	case DriverPostgres, DriverMySQL:
+		return &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	default:
+		return nil
+	}
+}
+
+type backupLine struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Restore loads an archive written by Backup into db, which may use another driver than the source.
+// Everything is restored in one transaction. Model hooks are skipped so data such as password hashes
+// is stored as archived, and the restored rows are not audited.
+func Restore(db *gorm.DB, r io.Reader, opts RestoreOptions) (BackupResult, error) {
+	db = SkipAudit(asSystem(UsePrimary(db)))
+	gz, gzErr := gzip.NewReader(r)
+	if gzErr != nil {
+		return BackupResult{}, fmt.Errorf("error reading backup: %v", gzErr)
+	}
+	defer gz.Close()
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+
+	next := func() (backupLine, bool, error) {
+		if !scanner.Scan() {
+			if scanErr := scanner.Err(); scanErr != nil {
+				return backupLine{}, false, fmt.Errorf("error reading backup: %v", scanErr)
+			}
+			return backupLine{}, false, nil
+		}
+		var line backupLine
+		if unmarshalErr := json.Unmarshal(scanner.Bytes(), &line); unmarshalErr != nil {
+			return backupLine{}, false, fmt.Errorf("error reading backup: %v", unmarshalErr)
+		}
+		return line, true, nil
+	}
+
+	var result BackupResult
+	header, ok, headerErr := next()
+	if headerErr != nil {
+		return result, headerErr
+	}
+	if !ok || header.Type != BackupHeaderType {
+		return result, fmt.Errorf("not a %s archive", BackupFormat)
+	}
+	if unmarshalErr := json.Unmarshal(header.Data, &result.Manifest); unmarshalErr != nil {
+		return result, fmt.Errorf("error reading backup header: %v", unmarshalErr)
+	}
+	if result.Manifest.Format != BackupFormat || result.Manifest.FormatVersion > BackupFormatVersion {
+		return result, fmt.Errorf("unsupported backup format %s v%d", result.Manifest.Format, result.Manifest.FormatVersion)
+	}
+	targetVersion, versionErr := NewMigrator(db, "").Version()
+	if versionErr != nil {
+		return result, versionErr
+	}
+	if result.Manifest.SchemaVersion > targetVersion && !opts.Force {
+		return result, fmt.Errorf("backup schema version %d is newer than the database (%d); apply migrations first",
+			result.Manifest.SchemaVersion, targetVersion)
+	}
+
+	list := make([]backupModel, 0, len(result.Manifest.Models))
+	for _, name := range result.Manifest.Models {
+		typ, known := models.ModelRegistryMap[name]
+		if !known {
+			return result, fmt.Errorf("backup contains unknown model %s", name)
+		}
+		list = append(list, backupModel{name: name, typ: typ})
+	}
+	result.Counts = make(map[string]int64, len(list))
+
+	txErr := db.Session(&gorm.Session{SkipHooks: true}).Transaction(func(tx *gorm.DB) error {
+		if prepareErr := prepareRestore(tx, list, opts.Clean); prepareErr != nil {
+			return prepareErr
+		}
+
+		var current *backupModel
+		var batch reflect.Value
+		flush := func() error {
+			if current == nil || batch.Len() == 0 {
+				return nil
+			}
+			if createErr := tx.CreateInBatches(batch.Interface(), BackupBatchSize).Error; createErr != nil {
+				return fmt.Errorf("error restoring %s: %v", current.name, createErr)
+			}
+			result.Counts[current.name] += int64(batch.Len())
+			batch = reflect.MakeSlice(batch.Type(), 0, BackupBatchSize)
+			return nil
+		}
+
+		for {
+			line, more, lineErr := next()
+			if lineErr != nil {
+				return lineErr
+			}
+			if !more {
+				return fmt.Errorf("backup is truncated: trailer not found")
+			}
+			if line.Type == BackupTrailerType {
+				if flushErr := flush(); flushErr != nil {
+					return flushErr
+				}
+				var trailer backupTrailer
+				if unmarshalErr := json.Unmarshal(line.Data, &trailer); unmarshalErr != nil {
+					return fmt.Errorf("error reading backup trailer: %v", unmarshalErr)
+				}
+				for _, model := range list {
+					if trailer.Counts[model.name] != result.Counts[model.name] {
+						return fmt.Errorf("backup of %s is incomplete: %d rows restored, %d archived",
+							model.name, result.Counts[model.name], trailer.Counts[model.name])
+					}
+				}
+				return resetSequences(tx, list)
+			}
+			if current == nil || current.name != line.Type {
+				if flushErr := flush(); flushErr != nil {
+					return flushErr
+				}
+				current = nil
+				for i := range list {
+					if list[i].name == line.Type {
+						current = &list[i]
+					}
+				}
+				if current == nil {
+					return fmt.Errorf("backup row of model %s missing from its header", line.Type)
+				}
+				batch = reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(current.typ)), 0, BackupBatchSize)
+			}
+			row := reflect.New(current.typ)
+			if unmarshalErr := json.Unmarshal(line.Data, row.Interface()); unmarshalErr != nil {
+				return fmt.Errorf("error reading %s row: %v", current.name, unmarshalErr)
+			}
+			batch = reflect.Append(batch, row)
+			if batch.Len() >= BackupBatchSize {
+				if flushErr := flush(); flushErr != nil {
+					return flushErr
+				}
+			}
+		}
+	})
+	return result, txErr
+}
+
+// prepareRestore checks the target tables exist and are empty, deleting their rows first (children
+// before parents) when clean is set.
+func prepareRestore(tx *gorm.DB, list []backupModel, clean bool) error {
+	for _, model := range list {
+		if !tx.Migrator().HasTable(reflect.New(model.typ).Interface()) {
+			return fmt.Errorf("table of model %s does not exist; apply migrations first", model.name)
+		}
+	}
+	if clean {
+		for i := len(list) - 1; i >= 0; i-- {
+			deleteErr := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(reflect.New(list[i].typ).Interface()).Error
+			if deleteErr != nil {
+				return fmt.Errorf("error cleaning %s: %v", list[i].name, deleteErr)
+			}
+		}
+		return nil
+	}
+	for _, model := range list {
+		var count int64
+		if countErr := tx.Unscoped().Model(reflect.New(model.typ).Interface()).Count(&count).Error; countErr != nil {
+			return fmt.Errorf("error counting %s: %v", model.name, countErr)
+		}
+		if count > 0 {
+			return fmt.Errorf("table of model %s is not empty (%d rows); restore with clean to replace it", model.name, count)
+		}
+	}
+	return nil
+}
+
+// resetSequences moves Postgres sequences of auto-increment keys past the restored ids; the other
+// drivers adjust their counters on explicit inserts.
+func resetSequences(tx *gorm.DB, list []backupModel) error {
+	if tx.Dialector.Name() != DriverPostgres {
+		return nil
+	}
+	for _, model := range list {
+		stmt := &gorm.Statement{DB: tx}
+		if parseErr := stmt.Parse(reflect.New(model.typ).Interface()); parseErr != nil {
+			return parseErr
+		}
+		field := stmt.Schema.PrioritizedPrimaryField
+		if field == nil || !field.AutoIncrement || (field.DataType != schema.Int && field.DataType != schema.Uint) {
+			continue
+		}
+		resetErr := tx.Exec(
+			fmt.Sprintf("SELECT setval(pg_get_serial_sequence(?, ?), COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)",
+				tx.Statement.Quote(field.DBName), tx.Statement.Quote(stmt.Schema.Table)),
+			stmt.Schema.Table, field.DBName,
+		).Error
+		if resetErr != nil {
+			return fmt.Errorf("error resetting sequence of %s: %v", model.name, resetErr)
+		}
+	}
+	return nil
+}
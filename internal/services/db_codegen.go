@@ -0,0 +1,323 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/utils"
+	"go/format"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// ModelGenOptions selects what GenerateModels reads and how the code is written.
+type ModelGenOptions struct {
+	Package string   // package of the generated files, "models" by default
+	Tables  []string // tables to generate, every table but the migration ones by default
+}
+
+// GeneratedModel is the Go source of one table: a struct with gorm tags, its TableName, a BeforeCreate
+// hook filling uuid primary keys, and a repository built on models.Repository like ProductRepoImpl.
+type GeneratedModel struct {
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Source []byte `json:"-"`
+}
+
+type genField struct {
+	Name, Type, Tag, JSON string
+	PrimaryKey, Tenant    bool
+}
+type genModel struct {
+	Package, Table, Name, Recv string
+	Fields                     []genField
+	PK                         *genField
+	UUIDKey, HasTime           bool
+}
+
+var genIdentifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// GenerateModels introspects the tables of db and returns the Go source of a model per table. Column
+// types are mapped with utils.DBTypeToGoType; nullable columns become pointers.
+func GenerateModels(db *gorm.DB, opts ModelGenOptions) ([]GeneratedModel, error) {
+	if opts.Package == "" {
+		opts.Package = "models"
+	}
+	migrator := UsePrimary(db).Migrator()
+	tables := opts.Tables
+	if len(tables) == 0 {
+		all, tablesErr := migrator.GetTables()
+		if tablesErr != nil {
+			return nil, fmt.Errorf("error listing tables: %v", tablesErr)
+		}
+		for _, table := range all {
+			if table != (SchemaMigration{}).TableName() && table != (SchemaMigrationLock{}).TableName() && !strings.HasPrefix(table, "sqlite_") {
+				tables = append(tables, table)
+			}
+		}
+		sort.Strings(tables)
+	}
+
+	generated := make([]GeneratedModel, 0, len(tables))
+	for _, table := range tables {
+		if !migrator.HasTable(table) {
+			return nil, fmt.Errorf("table %s not found", table)
+		}
+		model, modelErr := genModelOf(migrator, opts.Package, table)
+		if modelErr != nil {
+			return nil, modelErr
+		}
+		var src bytes.Buffer
+		if execErr := genTemplate.Execute(&src, model); execErr != nil {
+			return nil, fmt.Errorf("error generating model %s: %v", model.Name, execErr)
+		}
+		formatted, formatErr := format.Source(src.Bytes())
+		if formatErr != nil {
+			return nil, fmt.Errorf("error formatting model %s: %v", model.Name, formatErr)
+		}
+		generated = append(generated, GeneratedModel{
+			Table:  table,
+			Name:   model.Name,
+			File:   genSnakeName(model.Name) + ".go",
+			Source: formatted,
+		})
+	}
+	return generated, nil
+}
+
+// WriteGeneratedModels writes files to dir and returns their paths. Existing files are only replaced
+// with force.
+func WriteGeneratedModels(dir string, files []GeneratedModel, force bool) ([]string, error) {
+	if mkdirErr := os.MkdirAll(dir, 0755); mkdirErr != nil {
+		return nil, fmt.Errorf("error creating output directory: %v", mkdirErr)
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		path := filepath.Join(dir, file.File)
+		if _, statErr := os.Stat(path); statErr == nil && !force {
+			return paths, fmt.Errorf("%s already exists (use --force to replace it)", path)
+		}
+		if writeErr := os.WriteFile(path, file.Source, 0644); writeErr != nil {
+			return paths, fmt.Errorf("error writing %s: %v", path, writeErr)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func genModelOf(migrator gorm.Migrator, pkg, table string) (genModel, error) {
+	columns, columnsErr := migrator.ColumnTypes(table)
+	if columnsErr != nil {
+		return genModel{}, fmt.Errorf("error reading columns of %s: %v", table, columnsErr)
+	}
+	model := genModel{Package: pkg, Table: table, Name: genGoName(genSingular(table))}
+	if model.Recv = strings.ToLower(model.Name[:1]); model.Recv == "g" {
+		// g is the receiver of the repository methods.
+		model.Recv = "m"
+	}
+
+	// Not every driver reports indexes; the struct is still usable without their tags.
+	indexTags := make(map[string][]string)
+	if indexes, indexesErr := migrator.GetIndexes(table); indexesErr == nil {
+		for _, index := range indexes {
+			if pk, _ := index.PrimaryKey(); pk || strings.HasPrefix(index.Name(), "sqlite_autoindex_") {
+				continue
+			}
+			kind := "index"
+			if unique, _ := index.Unique(); unique {
+				kind = "uniqueIndex"
+			}
+			cols := index.Columns()
+			for i, col := range cols {
+				tag := kind + ":" + index.Name()
+				if len(cols) > 1 {
+					tag += fmt.Sprintf(",priority:%d", i+1)
+				}
+				indexTags[col] = append(indexTags[col], tag)
+			}
+		}
+	}
+
+	used := make(map[string]bool, len(columns))
+	var pks []genField
+	for _, column := range columns {
+		field := genField{Name: genGoName(column.Name()), JSON: column.Name()}
+		for used[field.Name] {
+			field.Name += "_"
+		}
+		used[field.Name] = true
+
+		dbType := column.DatabaseTypeName()
+		if full, ok := column.ColumnType(); ok && full != "" {
+			dbType = full
+		}
+		if strings.Count(dbType, "(") != strings.Count(dbType, ")") {
+			// The sqlite driver cuts definitions like numeric(12,2) at the comma.
+			dbType = strings.TrimSpace(dbType[:strings.Index(dbType, "(")])
+			if precision, scale, ok := column.DecimalSize(); ok && precision > 0 {
+				dbType = fmt.Sprintf("%s(%d,%d)", dbType, precision, scale)
+			}
+		}
+		field.Type = utils.DBTypeToGoType(dbType)
+		if field.Type == "interface{}" {
+			// Unknown types are kept as text; the type tag preserves the column definition.
+			field.Type = "string"
+		}
+
+		tags := []string{"column:" + column.Name(), "type:" + dbType}
+		pk, _ := column.PrimaryKey()
+		autoIncrement, autoIncrementOk := column.AutoIncrement()
+		nullable, nullableOk := column.Nullable()
+		if pk {
+			field.PrimaryKey = true
+			tags = append(tags, "primaryKey")
+			if autoIncrementOk && !autoIncrement && field.Type != "string" {
+				tags = append(tags, "autoIncrement:false")
+			}
+		} else if nullableOk && !nullable {
+			tags = append(tags, "not null")
+		} else if field.Type != "[]byte" {
+			field.Type = "*" + field.Type
+		}
+		if unique, _ := column.Unique(); unique && !pk {
+			tags = append(tags, "unique")
+		}
+		if def, ok := column.DefaultValue(); ok && def != "" && !autoIncrement && !strings.Contains(strings.ToLower(def), "nextval(") {
+			if idx := strings.Index(def, "::"); idx > 0 {
+				def = def[:idx]
+			}
+			tags = append(tags, "default:"+def)
+		}
+		tags = append(tags, indexTags[column.Name()]...)
+		field.Tag = strings.Join(tags, ";")
+		// A tenant_id column isolates the rows by tenant, like the one of models.Tenant.
+		field.Tenant = column.Name() == "tenant_id" && field.Type == "string"
+		if strings.Contains(field.Type, "time.Time") {
+			model.HasTime = true
+		}
+		model.Fields = append(model.Fields, field)
+		if pk {
+			pks = append(pks, field)
+		}
+	}
+	if len(pks) == 1 {
+		model.PK = &pks[0]
+		model.UUIDKey = pks[0].Type == "string"
+	}
+	return model, nil
+}
+
+func genGoName(name string) string {
+	var sb strings.Builder
+	for _, part := range genIdentifier.Split(name, -1) {
+		if part == "" {
+			continue
+		}
+		if strings.EqualFold(part, "id") {
+			sb.WriteString("ID")
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+	goName := sb.String()
+	if goName == "" || unicode.IsDigit([]rune(goName)[0]) {
+		goName = "X" + goName
+	}
+	return goName
+}
+
+func genSnakeName(name string) string {
+	var sb strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			sb.WriteRune('_')
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+func genSingular(table string) string {
+	if idx := strings.LastIndex(table, "."); idx >= 0 {
+		table = table[idx+1:]
+	}
+	lower := strings.ToLower(table)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(table) > 3:
+		return table[:len(table)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return table[:len(table)-2]
+	case strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss"):
+		return table[:len(table)-1]
+	}
+	return table
+}
+
+var genTemplate = template.Must(template.New("model").Parse(`// Generated by gkbxsrv models generate from table {{.Table}}.
+
+package {{.Package}}
+
+import (
+	gkbxmodels "github.com/faelmori/gkbxsrv/models"
+{{- if .UUIDKey}}
+	"github.com/google/uuid"
+{{- end}}
+	"gorm.io/gorm"
+{{- if .HasTime}}
+	"time"
+{{- end}}
+)
+
+type {{.Name}}Repo interface {
+	Create({{.Recv}} *{{.Name}}) (*{{.Name}}, error)
+	FindOne(where ...interface{}) (*{{.Name}}, error)
+	FindAll(where ...interface{}) ([]*{{.Name}}, error)
+{{- if .PK}}
+	Update({{.Recv}} *{{.Name}}) (*{{.Name}}, error)
+	Delete(id {{.PK.Type}}) error
+{{- end}}
+	Count(where ...interface{}) (int64, error)
+	Paginate(opts gkbxmodels.PageOptions, where ...interface{}) (*gkbxmodels.Page[{{.Name}}], error)
+	Search(q gkbxmodels.Query) (*gkbxmodels.Page[{{.Name}}], error)
+	Close() error
+}
+
+type {{.Name}}RepoImpl struct {
+	*gkbxmodels.Repository[{{.Name}}]
+}
+
+func NewGorm{{.Name}}Repo(db *gorm.DB) *{{.Name}}RepoImpl {
+	return &{{.Name}}RepoImpl{gkbxmodels.NewRepository[{{.Name}}](db)}
+}
+{{if .PK}}
+func (g *{{.Name}}RepoImpl) Delete(id {{.PK.Type}}) error {
+	return g.Repository.Delete(id)
+}
+{{end}}
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`" + `gorm:"{{.Tag}}" json:"{{.JSON}}"{{if .Tenant}} tenant:"scope"{{end}}` + "`" + `
+{{- end}}
+}
+
+func ({{.Recv}} *{{.Name}}) TableName() string {
+	return "{{.Table}}"
+}
+{{if .UUIDKey}}
+func ({{.Recv}} *{{.Name}}) BeforeCreate(tx *gorm.DB) (err error) {
+	if {{.Recv}}.{{.PK.Name}} == "" {
+		{{.Recv}}.{{.PK.Name}} = uuid.New().String()
+	}
+	return nil
+}
+{{end -}}
+`))
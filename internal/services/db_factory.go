@@ -0,0 +1,409 @@
+package services
+
+import (
+	"fmt"
+	glb "github.com/faelmori/gkbxsrv/internal/globals"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	dsn2 "github.com/godror/godror/dsn"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDBTimeZone = "America/Sao_Paulo"
+	DefaultDBSSLMode  = "disable"
+	DefaultSQLitePath = "gorm.db"
+)
+
+// Pool defaults written to new config files. A zero or empty value in a config keeps the database/sql
+// default for that setting.
+const (
+	DefaultDBMaxOpenConns    = 25
+	DefaultDBMaxIdleConns    = 10
+	DefaultDBConnMaxLifetime = "30m"
+	DefaultDBConnMaxIdleTime = "5m"
+)
+
+const (
+	DriverPostgres  = "postgres"
+	DriverMySQL     = "mysql"
+	DriverSQLite    = "sqlite"
+	DriverSQLServer = "sqlserver"
+	DriverOracle    = "oracle"
+)
+
+var driverAliases = map[string]string{
+	"postgres":   DriverPostgres,
+	"postgresql": DriverPostgres,
+	"pg":         DriverPostgres,
+	"pgx":        DriverPostgres,
+	"mysql":      DriverMySQL,
+	"mariadb":    DriverMySQL,
+	"sqlite":     DriverSQLite,
+	"sqlite3":    DriverSQLite,
+	"mssql":      DriverSQLServer,
+	"sqlserver":  DriverSQLServer,
+	"oracle":     DriverOracle,
+	"oci8":       DriverOracle,
+	"goracle":    DriverOracle,
+	"godror":     DriverOracle,
+}
+
+// DSNBuilder turns a database config into the DSN its driver expects.
+type DSNBuilder func(cfg glb.Database) (string, error)
+
+// DialectorFactory wraps a DSN in the GORM dialector of a driver.
+type DialectorFactory func(dsn string) gorm.Dialector
+
+type driverSpec struct {
+	dsn       DSNBuilder
+	dialector DialectorFactory
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]driverSpec{
+		DriverPostgres:  {dsn: buildPostgresDSN, dialector: postgres.Open},
+		DriverMySQL:     {dsn: buildMySQLDSN, dialector: mysql.Open},
+		DriverSQLite:    {dsn: buildSQLiteDSN, dialector: sqlite.Open},
+		DriverSQLServer: {dsn: buildSQLServerDSN, dialector: sqlserver.Open},
+		// No GORM dialector for Oracle ships with this module; applications that link one register it
+		// with RegisterDialector(DriverOracle, ...).
+		DriverOracle: {dsn: buildOracleDSN},
+	}
+)
+
+// RegisterDialector plugs the GORM dialector for a driver, e.g. an Oracle dialector, or replaces the
+// built-in one. The driver keeps its DSN builder unless it is unknown, in which case the configured
+// Dsn/ConnectionString is used as is.
+func RegisterDialector(driver string, factory DialectorFactory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	name := strings.ToLower(driver)
+	if canonical, ok := driverAliases[name]; ok {
+		name = canonical
+	}
+	spec, exists := drivers[name]
+	if !exists {
+		spec.dsn = buildRawDSN
+		driverAliases[name] = name
+	}
+	spec.dialector = factory
+	drivers[name] = spec
+}
+
+// ResolveDriver returns the canonical driver name of a config. Driver wins over Type when both are set;
+// an empty config resolves to SQLite, as OpenDB always did.
+func ResolveDriver(cfg glb.Database) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Driver))
+	if name == "" {
+		name = strings.ToLower(strings.TrimSpace(cfg.Type))
+	}
+	if name == "" {
+		return DriverSQLite, nil
+	}
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	if canonical, ok := driverAliases[name]; ok {
+		return canonical, nil
+	}
+	return "", fmt.Errorf("unsupported database driver %q (supported: %s)", name, strings.Join(supportedDrivers(), ", "))
+}
+
+// BuildDSN returns the DSN the configured driver would be opened with.
+func BuildDSN(cfg glb.Database) (string, error) {
+	driver, driverErr := ResolveDriver(cfg)
+	if driverErr != nil {
+		return "", driverErr
+	}
+	driversMu.RLock()
+	spec := drivers[driver]
+	driversMu.RUnlock()
+	return spec.dsn(cfg)
+}
+
+// NewDialector resolves the driver of cfg, builds its DSN and wraps it in the matching GORM dialector.
+func NewDialector(cfg glb.Database) (gorm.Dialector, error) {
+	driver, driverErr := ResolveDriver(cfg)
+	if driverErr != nil {
+		return nil, driverErr
+	}
+	driversMu.RLock()
+	spec := drivers[driver]
+	driversMu.RUnlock()
+	if spec.dialector == nil {
+		return nil, fmt.Errorf("database driver %q has no GORM dialector registered; link one and call RegisterDialector", driver)
+	}
+	dsn, dsnErr := spec.dsn(cfg)
+	if dsnErr != nil {
+		return nil, dsnErr
+	}
+	return spec.dialector(dsn), nil
+}
+
+// OpenConnection opens a GORM connection for any supported driver, with optimistic locking of the
+// models.Versioned models, order statuses changed only through transitions and order totals kept in
+// step with their items. With cfg.Audit, its writes are recorded in audit_logs; with
+// cfg.MultiTenant, tenant models are isolated by the tenant of the context.
+func OpenConnection(cfg glb.Database) (*gorm.DB, error) {
+	dialector, dialectorErr := NewDialector(cfg)
+	if dialectorErr != nil {
+		return nil, dialectorErr
+	}
+	db, openErr := gorm.Open(dialector, &gorm.Config{})
+	if openErr != nil {
+		return nil, fmt.Errorf("error opening %s database: %v", dialector.Name(), openErr)
+	}
+	if poolErr := ApplyPoolConfig(db, cfg); poolErr != nil {
+		return nil, poolErr
+	}
+	if modelsErr := useDynamicModels(db); modelsErr != nil {
+		return nil, modelsErr
+	}
+	if versionErr := useVersioning(db); versionErr != nil {
+		return nil, versionErr
+	}
+	if orderErr := useOrderTransitions(db); orderErr != nil {
+		return nil, orderErr
+	}
+	if totalsErr := useOrderTotals(db); totalsErr != nil {
+		return nil, totalsErr
+	}
+	if cfg.Audit {
+		if auditErr := useAudit(db); auditErr != nil {
+			return nil, auditErr
+		}
+	}
+	if cfg.MultiTenant {
+		if tenantErr := useTenants(db); tenantErr != nil {
+			return nil, tenantErr
+		}
+	}
+	return db, nil
+}
+
+// ApplyPoolConfig sets the connection pool limits of cfg on an open connection.
+func ApplyPoolConfig(db *gorm.DB, cfg glb.Database) error {
+	sqlDB, sqlDBErr := db.DB()
+	if sqlDBErr != nil {
+		return fmt.Errorf("error getting SQL connection: %v", sqlDBErr)
+	}
+	lifetime, lifetimeErr := parsePoolDuration("conn_max_lifetime", cfg.ConnMaxLifetime)
+	if lifetimeErr != nil {
+		return lifetimeErr
+	}
+	idleTime, idleTimeErr := parsePoolDuration("conn_max_idle_time", cfg.ConnMaxIdleTime)
+	if idleTimeErr != nil {
+		return idleTimeErr
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if lifetime > 0 {
+		sqlDB.SetConnMaxLifetime(lifetime)
+	}
+	if idleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(idleTime)
+	}
+	return nil
+}
+func parsePoolDuration(field, value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	duration, parseErr := time.ParseDuration(value)
+	if parseErr != nil {
+		return 0, fmt.Errorf("invalid database %s %q: %v", field, value, parseErr)
+	}
+	return duration, nil
+}
+
+func supportedDrivers() []string {
+	names := make([]string, 0, len(driverAliases))
+	for alias := range driverAliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
+}
+func explicitDSN(cfg glb.Database) string {
+	if cfg.Dsn != "" {
+		return cfg.Dsn
+	}
+	return cfg.ConnectionString
+}
+func buildRawDSN(cfg glb.Database) (string, error) {
+	if dsn := explicitDSN(cfg); dsn != "" {
+		return dsn, nil
+	}
+	return "", fmt.Errorf("database driver %q requires dsn or connection_string", cfg.Driver)
+}
+func dbPort(cfg glb.Database, fallback string) string {
+	if cfg.Port == nil {
+		return fallback
+	}
+	port := strings.TrimSpace(fmt.Sprint(cfg.Port))
+	if port == "" || port == "0" {
+		return fallback
+	}
+	return port
+}
+func dbHost(cfg glb.Database) string {
+	if cfg.Host == "" {
+		return "localhost"
+	}
+	return cfg.Host
+}
+func dbTimeZone(cfg glb.Database) string {
+	if cfg.TimeZone == "" {
+		return DefaultDBTimeZone
+	}
+	return cfg.TimeZone
+}
+func dbSSLMode(cfg glb.Database) string {
+	if cfg.SSLMode == "" {
+		return DefaultDBSSLMode
+	}
+	return strings.ToLower(cfg.SSLMode)
+}
+
+func buildPostgresDSN(cfg glb.Database) (string, error) {
+	if dsn := explicitDSN(cfg); dsn != "" {
+		return dsn, nil
+	}
+	switch dbSSLMode(cfg) {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		return "", fmt.Errorf("invalid postgres ssl_mode %q", cfg.SSLMode)
+	}
+	params := [][2]string{
+		{"host", dbHost(cfg)},
+		{"port", dbPort(cfg, "5432")},
+		{"user", cfg.Username},
+		{"password", cfg.Password},
+		{"dbname", cfg.Name},
+		{"sslmode", dbSSLMode(cfg)},
+		{"TimeZone", dbTimeZone(cfg)},
+	}
+	parts := make([]string, 0, len(params))
+	for _, param := range params {
+		if param[1] == "" {
+			continue
+		}
+		parts = append(parts, param[0]+"="+quotePostgresValue(param[1]))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// quotePostgresValue quotes key/value DSN values that contain spaces, quotes or backslashes.
+func quotePostgresValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + escaped + "'"
+}
+
+func buildMySQLDSN(cfg glb.Database) (string, error) {
+	if dsn := explicitDSN(cfg); dsn != "" {
+		return dsn, nil
+	}
+	loc, locErr := time.LoadLocation(dbTimeZone(cfg))
+	if locErr != nil {
+		return "", fmt.Errorf("invalid mysql timezone %q: %v", dbTimeZone(cfg), locErr)
+	}
+	mc := mysqlDriver.NewConfig()
+	mc.User = cfg.Username
+	mc.Passwd = cfg.Password
+	mc.Net = "tcp"
+	mc.Addr = net.JoinHostPort(dbHost(cfg), dbPort(cfg, "3306"))
+	mc.DBName = cfg.Name
+	mc.ParseTime = true
+	mc.Loc = loc
+	mc.Params = map[string]string{"charset": "utf8mb4"}
+	switch dbSSLMode(cfg) {
+	case "disable":
+		mc.TLSConfig = "false"
+	case "allow", "prefer":
+		mc.TLSConfig = "preferred"
+	case "require":
+		mc.TLSConfig = "skip-verify"
+	case "verify-ca", "verify-full":
+		mc.TLSConfig = "true"
+	default:
+		return "", fmt.Errorf("invalid mysql ssl_mode %q", cfg.SSLMode)
+	}
+	return mc.FormatDSN(), nil
+}
+
+// buildSQLiteDSN prefers Database.Path, since config files written by older versions fill Dsn with a
+// server URL.
+func buildSQLiteDSN(cfg glb.Database) (string, error) {
+	if cfg.Path != "" {
+		return cfg.Path, nil
+	}
+	if dsn := explicitDSN(cfg); dsn != "" {
+		return dsn, nil
+	}
+	return DefaultSQLitePath, nil
+}
+
+func buildSQLServerDSN(cfg glb.Database) (string, error) {
+	if dsn := explicitDSN(cfg); dsn != "" {
+		return dsn, nil
+	}
+	query := url.Values{}
+	if cfg.Name != "" {
+		query.Set("database", cfg.Name)
+	}
+	switch dbSSLMode(cfg) {
+	case "disable":
+		query.Set("encrypt", "disable")
+	case "allow", "prefer":
+		query.Set("encrypt", "false")
+	case "require":
+		query.Set("encrypt", "true")
+		query.Set("TrustServerCertificate", "true")
+	case "verify-ca", "verify-full":
+		query.Set("encrypt", "true")
+	default:
+		return "", fmt.Errorf("invalid sqlserver ssl_mode %q", cfg.SSLMode)
+	}
+	u := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(cfg.Username, cfg.Password),
+		Host:     net.JoinHostPort(dbHost(cfg), dbPort(cfg, "1433")),
+		RawQuery: query.Encode(),
+	}
+	return u.String(), nil
+}
+
+func buildOracleDSN(cfg glb.Database) (string, error) {
+	if dsn := explicitDSN(cfg); dsn != "" {
+		return dsn, nil
+	}
+	loc, locErr := time.LoadLocation(dbTimeZone(cfg))
+	if locErr != nil {
+		return "", fmt.Errorf("invalid oracle timezone %q: %v", dbTimeZone(cfg), locErr)
+	}
+	var params dsn2.ConnectionParams
+	params.Username = cfg.Username
+	params.Password = dsn2.NewPassword(cfg.Password)
+	params.ConnectString = fmt.Sprintf("%s/%s", net.JoinHostPort(dbHost(cfg), dbPort(cfg, "1521")), cfg.Name)
+	params.Timezone = loc
+	params.StandaloneConnection = dsn2.Bool(true)
+	return params.StringWithPassword(), nil
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	glb "github.com/faelmori/gkbxsrv/internal/globals"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+)
+
+func TestResolveDriver(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     glb.Database
+		want    string
+		wantErr bool
+	}{
+		{"empty config", glb.Database{}, DriverSQLite, false},
+		{"driver alias", glb.Database{Driver: "PostgreSQL"}, DriverPostgres, false},
+		{"type fallback", glb.Database{Type: "mariadb"}, DriverMySQL, false},
+		{"driver wins over type", glb.Database{Driver: "mssql", Type: "postgres"}, DriverSQLServer, false},
+		{"oracle alias", glb.Database{Driver: "godror"}, DriverOracle, false},
+		{"unsupported", glb.Database{Driver: "mongodb"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveDriver(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveDriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveDriver() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPostgresDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     glb.Database
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			cfg:  glb.Database{Username: "kubex", Name: "kubex_db"},
+			want: "host=localhost port=5432 user=kubex dbname=kubex_db sslmode=disable TimeZone=" + DefaultDBTimeZone,
+		},
+		{
+			name: "structured fields",
+			cfg:  glb.Database{Host: "db", Port: 6543, Username: "u", Password: "p", Name: "n", SSLMode: "REQUIRE", TimeZone: "UTC"},
+			want: "host=db port=6543 user=u password=p dbname=n sslmode=require TimeZone=UTC",
+		},
+		{
+			name: "quoted values",
+			cfg:  glb.Database{Username: "u", Password: `it's a\secret`, Name: "n", TimeZone: "UTC"},
+			want: `host=localhost port=5432 user=u password='it\'s a\\secret' dbname=n sslmode=disable TimeZone=UTC`,
+		},
+		{
+			name: "explicit dsn",
+			cfg:  glb.Database{Dsn: "postgres://u:p@db/n", Host: "ignored"},
+			want: "postgres://u:p@db/n",
+		},
+		{
+			name: "connection string",
+			cfg:  glb.Database{ConnectionString: "postgres://u:p@db/n"},
+			want: "postgres://u:p@db/n",
+		},
+		{
+			name:    "invalid ssl mode",
+			cfg:     glb.Database{SSLMode: "sometimes"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildPostgresDSN(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildPostgresDSN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("buildPostgresDSN() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildMySQLDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      glb.Database
+		wantAddr string
+		wantTLS  string
+		wantLoc  string
+		wantErr  bool
+	}{
+		{"defaults", glb.Database{Username: "u", Name: "n"}, "localhost:3306", "false", DefaultDBTimeZone, false},
+		{"structured fields", glb.Database{Host: "db", Port: "3307", Username: "u", Name: "n", SSLMode: "verify-full", TimeZone: "UTC"}, "db:3307", "true", "UTC", false},
+		{"preferred tls", glb.Database{Username: "u", Name: "n", SSLMode: "prefer"}, "localhost:3306", "preferred", DefaultDBTimeZone, false},
+		{"invalid ssl mode", glb.Database{SSLMode: "sometimes"}, "", "", "", true},
+		{"invalid timezone", glb.Database{TimeZone: "Nowhere/Town"}, "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := buildMySQLDSN(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildMySQLDSN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			parsed, parseErr := mysqlDriver.ParseDSN(dsn)
+			if parseErr != nil {
+				t.Fatalf("ParseDSN(%q) error = %v", dsn, parseErr)
+			}
+			if parsed.Addr != tt.wantAddr || parsed.User != tt.cfg.Username || parsed.DBName != tt.cfg.Name {
+				t.Errorf("buildMySQLDSN() = %q, want address %s, user %s and database %s", dsn, tt.wantAddr, tt.cfg.Username, tt.cfg.Name)
+			}
+			if parsed.TLSConfig != tt.wantTLS {
+				t.Errorf("buildMySQLDSN() tls = %q, want %q", parsed.TLSConfig, tt.wantTLS)
+			}
+			if parsed.Loc.String() != tt.wantLoc || !parsed.ParseTime {
+				t.Errorf("buildMySQLDSN() loc = %s, parseTime = %v, want %s and true", parsed.Loc, parsed.ParseTime, tt.wantLoc)
+			}
+		})
+	}
+
+	if dsn, _ := buildMySQLDSN(glb.Database{Dsn: "u:p@tcp(db)/n"}); dsn != "u:p@tcp(db)/n" {
+		t.Errorf("buildMySQLDSN() = %q, want the explicit dsn", dsn)
+	}
+}
+
+func TestBuildSQLiteDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  glb.Database
+		want string
+	}{
+		{"default path", glb.Database{}, DefaultSQLitePath},
+		{"path wins over dsn", glb.Database{Path: "/tmp/app.db", Dsn: "postgres://u@db/n"}, "/tmp/app.db"},
+		{"explicit dsn", glb.Database{Dsn: "file::memory:?cache=shared"}, "file::memory:?cache=shared"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildSQLiteDSN(tt.cfg)
+			if err != nil || got != tt.want {
+				t.Errorf("buildSQLiteDSN() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSQLServerDSN(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       glb.Database
+		wantHost  string
+		wantQuery url.Values
+		wantErr   bool
+	}{
+		{
+			name:      "defaults",
+			cfg:       glb.Database{Username: "sa", Password: "p@ss", Name: "n"},
+			wantHost:  "localhost:1433",
+			wantQuery: url.Values{"database": {"n"}, "encrypt": {"disable"}},
+		},
+		{
+			name:      "require tls",
+			cfg:       glb.Database{Host: "db", Port: 1444, Username: "sa", Name: "n", SSLMode: "require"},
+			wantHost:  "db:1444",
+			wantQuery: url.Values{"database": {"n"}, "encrypt": {"true"}, "TrustServerCertificate": {"true"}},
+		},
+		{
+			name:    "invalid ssl mode",
+			cfg:     glb.Database{SSLMode: "sometimes"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := buildSQLServerDSN(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildSQLServerDSN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			u, parseErr := url.Parse(dsn)
+			if parseErr != nil {
+				t.Fatalf("url.Parse(%q) error = %v", dsn, parseErr)
+			}
+			password, _ := u.User.Password()
+			if u.Scheme != "sqlserver" || u.Host != tt.wantHost || u.User.Username() != tt.cfg.Username || password != tt.cfg.Password {
+				t.Errorf("buildSQLServerDSN() = %q, want host %s and the credentials of the config", dsn, tt.wantHost)
+			}
+			if got := u.Query(); got.Encode() != tt.wantQuery.Encode() {
+				t.Errorf("buildSQLServerDSN() query = %s, want %s", got.Encode(), tt.wantQuery.Encode())
+			}
+		})
+	}
+}
+
+func TestBuildOracleDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      glb.Database
+		contains []string
+		wantErr  bool
+	}{
+		{
+			name:     "defaults",
+			cfg:      glb.Database{Username: "scott", Password: "tiger", Name: "ORCL", TimeZone: "UTC"},
+			contains: []string{"user=scott ", "password=tiger ", "connectString=localhost:1521/ORCL ", "timezone=UTC"},
+		},
+		{
+			name:     "structured fields",
+			cfg:      glb.Database{Host: "db", Port: "1522", Username: "scott", Name: "XE", TimeZone: "UTC"},
+			contains: []string{"connectString=db:1522/XE ", "standaloneConnection=1"},
+		},
+		{
+			name:     "explicit dsn",
+			cfg:      glb.Database{Dsn: `user="a" connectString="db/x"`},
+			contains: []string{`user="a" connectString="db/x"`},
+		},
+		{
+			name:    "invalid timezone",
+			cfg:     glb.Database{TimeZone: "Nowhere/Town"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := buildOracleDSN(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildOracleDSN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, part := range tt.contains {
+				if !strings.Contains(dsn, part) {
+					t.Errorf("buildOracleDSN() = %q, want it to contain %s", dsn, part)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildDSN(t *testing.T) {
+	dsn, err := BuildDSN(glb.Database{Driver: "pg", Username: "u", Name: "n", TimeZone: "UTC"})
+	if err != nil || dsn != "host=localhost port=5432 user=u dbname=n sslmode=disable TimeZone=UTC" {
+		t.Errorf("BuildDSN() = %q, %v, want the postgres DSN of the config", dsn, err)
+	}
+	if _, err = BuildDSN(glb.Database{Driver: "mongodb"}); err == nil {
+		t.Error("BuildDSN() of an unsupported driver succeeded")
+	}
+	if _, err = buildRawDSN(glb.Database{Driver: "custom"}); err == nil {
+		t.Error("buildRawDSN() without a dsn succeeded")
+	}
+}
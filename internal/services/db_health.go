@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"github.com/faelmori/logz"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"math/rand"
+	"time"
+)
+
+// Supervisor defaults, overridable with database.health_interval, database.health_timeout,
+// database.reconnect_backoff_min, database.reconnect_backoff_max and database.health_down_after.
+const (
+	DefaultDBHealthInterval    = 15 * time.Second
+	DefaultDBHealthTimeout     = 5 * time.Second
+	DefaultDBReconnectMinDelay = 1 * time.Second
+	DefaultDBReconnectMaxDelay = 1 * time.Minute
+	DefaultDBHealthDownAfter   = 3
+)
+
+// DBHealthTopicPrefix prefixes the topics health changes are published on, e.g. "database.down".
+const DBHealthTopicPrefix = "database."
+
+type DBHealthState string
+
+const (
+	DBStateUnknown   DBHealthState = "unknown"
+	DBStateConnected DBHealthState = "connected"
+	// DBStateDegraded means pings are failing and the supervisor is reconnecting.
+	DBStateDegraded DBHealthState = "degraded"
+	// DBStateDown means the database stayed unreachable for database.health_down_after checks in a row.
+	DBStateDown DBHealthState = "down"
+)
+
+// DBHealth is the state last observed by the supervisor. It is the data of the events published on
+// HealthEvents.
+type DBHealth struct {
+	State    DBHealthState `json:"state"`
+	Error    string        `json:"error,omitempty"`
+	Since    time.Time     `json:"since"`
+	Failures int           `json:"failures"`
+}
+
+type dbSupervisorConfig struct {
+	interval  time.Duration
+	timeout   time.Duration
+	minDelay  time.Duration
+	maxDelay  time.Duration
+	downAfter int
+}
+
+func loadDBSupervisorConfig() dbSupervisorConfig {
+	cfg := dbSupervisorConfig{
+		interval:  viper.GetDuration("database.health_interval"),
+		timeout:   viper.GetDuration("database.health_timeout"),
+		minDelay:  viper.GetDuration("database.reconnect_backoff_min"),
+		maxDelay:  viper.GetDuration("database.reconnect_backoff_max"),
+		downAfter: viper.GetInt("database.health_down_after"),
+	}
+	if cfg.interval <= 0 {
+		cfg.interval = DefaultDBHealthInterval
+	}
+	if cfg.timeout <= 0 {
+		cfg.timeout = DefaultDBHealthTimeout
+	}
+	if cfg.minDelay <= 0 {
+		cfg.minDelay = DefaultDBReconnectMinDelay
+	}
+	if cfg.maxDelay < cfg.minDelay {
+		cfg.maxDelay = DefaultDBReconnectMaxDelay
+		if cfg.maxDelay < cfg.minDelay {
+			cfg.maxDelay = cfg.minDelay
+		}
+	}
+	if cfg.downAfter <= 0 {
+		cfg.downAfter = DefaultDBHealthDownAfter
+	}
+	return cfg
+}
+
+// backoff returns the delay before reconnect attempt n (0 based): an exponential step capped at
+// maxDelay, with jitter so many services losing the same database don't retry in lockstep.
+func (c dbSupervisorConfig) backoff(attempt int) time.Duration {
+	step := c.minDelay
+	for i := 0; i < attempt && step < c.maxDelay; i++ {
+		step *= 2
+	}
+	if step > c.maxDelay {
+		step = c.maxDelay
+	}
+	return c.minDelay/2 + time.Duration(rand.Int63n(int64(step-c.minDelay/2)+1))
+}
+
+// StartSupervisor pings the primary connection every database.health_interval until ctx is done,
+// reconnecting with exponential backoff when pings fail. State changes are published on HealthEvents.
+// Read replicas are checked on the same schedule.
+// Calling it while a supervisor is running is a no-op.
+func (d *DatabaseServiceImpl) StartSupervisor(ctx context.Context) error {
+	d.healthMu.Lock()
+	if d.supCancel != nil {
+		d.healthMu.Unlock()
+		return nil
+	}
+	supCtx, cancel := context.WithCancel(ctx)
+	d.supCancel = cancel
+	done := make(chan struct{})
+	d.supDone = done
+	d.healthMu.Unlock()
+
+	cfg := loadDBSupervisorConfig()
+	go func() {
+		defer close(done)
+		defer func() {
+			d.healthMu.Lock()
+			d.supCancel = nil
+			d.healthMu.Unlock()
+			cancel()
+		}()
+		d.supervise(supCtx, cfg)
+	}()
+	return nil
+}
+
+// StopSupervisor stops a running supervisor and waits for it to return.
+func (d *DatabaseServiceImpl) StopSupervisor() {
+	d.healthMu.Lock()
+	cancel, done := d.supCancel, d.supDone
+	d.healthMu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Health returns the state last observed by the supervisor; it is DBStateUnknown until one runs.
+func (d *DatabaseServiceImpl) Health() DBHealth {
+	d.healthMu.RLock()
+	defer d.healthMu.RUnlock()
+	if d.health.State == "" {
+		return DBHealth{State: DBStateUnknown}
+	}
+	return d.health
+}
+
+// HealthEvents is the hub health changes are published on; subscribe to "database.*" for all of them.
+func (d *DatabaseServiceImpl) HealthEvents() *EventHub {
+	d.healthMu.Lock()
+	defer d.healthMu.Unlock()
+	if d.healthHub == nil {
+		d.healthHub = NewEventHub()
+	}
+	return d.healthHub
+}
+
+func (d *DatabaseServiceImpl) supervisorRunning() bool {
+	d.healthMu.RLock()
+	defer d.healthMu.RUnlock()
+	return d.supCancel != nil
+}
+
+func (d *DatabaseServiceImpl) supervise(ctx context.Context, cfg dbSupervisorConfig) {
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+	for {
+		if pingErr := d.ping(ctx, cfg.timeout); pingErr == nil {
+			d.setHealth(DBStateConnected, nil, 0)
+		} else if ctx.Err() == nil {
+			d.recoverConnection(ctx, cfg, pingErr)
+		}
+		d.checkReplicas(ctx, cfg.timeout)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recoverConnection retries until a ping succeeds or ctx is done. database/sql redials broken
+// connections on its own, so the existing pool is kept and handed out repos stay valid; a new one is
+// only opened when there is none.
+func (d *DatabaseServiceImpl) recoverConnection(ctx context.Context, cfg dbSupervisorConfig, cause error) {
+	failures := 1
+	for attempt := 0; ; attempt++ {
+		state := DBStateDegraded
+		if failures >= cfg.downAfter {
+			state = DBStateDown
+		}
+		d.setHealth(state, cause, failures)
+
+		delay := cfg.backoff(attempt)
+		logz.Warn("Database unreachable, reconnecting", map[string]interface{}{
+			"context":  "DatabaseService",
+			"state":    string(state),
+			"failures": failures,
+			"retry_in": delay.String(),
+			"error":    cause.Error(),
+		})
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if d.currentDB() == nil {
+			if connectErr := d.ConnectDB(); connectErr != nil {
+				cause = connectErr
+				failures++
+				continue
+			}
+		}
+		if cause = d.ping(ctx, cfg.timeout); cause == nil {
+			d.setHealth(DBStateConnected, nil, 0)
+			logz.Info("Database connection recovered", map[string]interface{}{
+				"context":  "DatabaseService",
+				"attempts": attempt + 1,
+			})
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		failures++
+	}
+}
+
+func (d *DatabaseServiceImpl) ping(ctx context.Context, timeout time.Duration) error {
+	return pingDB(ctx, d.currentDB(), timeout)
+}
+
+// setHealth records the state and publishes it when it changed. Failures is updated in place while
+// the state stays the same, without an event.
+func (d *DatabaseServiceImpl) setHealth(state DBHealthState, cause error, failures int) {
+	d.healthMu.Lock()
+	changed := d.health.State != state
+	if changed {
+		d.health.Since = time.Now()
+	}
+	d.health.State = state
+	d.health.Failures = failures
+	d.health.Error = ""
+	if cause != nil {
+		d.health.Error = cause.Error()
+	}
+	health, hub := d.health, d.healthHub
+	d.healthMu.Unlock()
+
+	if changed {
+		hub.Publish(DBHealthTopicPrefix+string(state), health)
+	}
+}
+
+func (d *DatabaseServiceImpl) currentDB() *gorm.DB {
+	d.dbMu.RLock()
+	defer d.dbMu.RUnlock()
+	return d.db
+}
+
+// swapDB replaces the primary connection and returns the previous one.
+func (d *DatabaseServiceImpl) swapDB(db *gorm.DB) *gorm.DB {
+	d.dbMu.Lock()
+	defer d.dbMu.Unlock()
+	previous := d.db
+	d.db = db
+	return previous
+}
@@ -0,0 +1,796 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	glb "github.com/faelmori/gkbxsrv/internal/globals"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Migrations are applied in Version order and recorded in schema_migrations. They come from two
+// sources merged by version: Go migrations registered with RegisterMigration, and SQL files in the
+// migrations directory named <version>_<name>.up.sql / .down.sql, optionally for a single driver as
+// <version>_<name>.<driver>.up.sql, which wins over the generic file. Statements in a SQL file are
+// separated by a ";" at the end of a line.
+const (
+	DefaultMigrationsDirName = "migrations"
+	MigrationLockTimeout     = 10 * time.Minute // a lock older than this is considered abandoned
+	MigrationLockWait        = 30 * time.Second
+	MigrationVersionLayout   = "20060102150405"
+)
+
+type MigrationFunc func(tx *gorm.DB) error
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      MigrationFunc
+	Down    MigrationFunc
+	source  string
+}
+
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"type:varchar(255);not null" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
+
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+type SchemaMigrationLock struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"type:varchar(64);not null"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigrationLock) TableName() string { return "schema_migrations_lock" }
+
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Source    string     `json:"source"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = map[int64]Migration{
+		1: {
+			Version: 1,
+			Name:    "initial_schema",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&userV1{}, &productV1{}, &customerV1{}, &orderV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&orderV1{}, &customerV1{}, &productV1{}, &userV1{})
+			},
+		},
+		// Databases created before roles, warehouses and inventory joined models.ModelList.
+		2: {
+			Version: 2,
+			Name:    "roles_warehouses_inventory",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&roleV2{}, &warehouseV2{}, &inventoryV2{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&inventoryV2{}, &warehouseV2{}, &roleV2{})
+			},
+		},
+		3: {
+			Version: 3,
+			Name:    "audit_logs_soft_delete",
+			Up: func(tx *gorm.DB) error {
+				if migrateErr := tx.AutoMigrate(&auditLogV3{}); migrateErr != nil {
+					return migrateErr
+				}
+				return addColumns(tx, &softDeleteColumnsV3{}, softDeleteTablesV3...)
+			},
+			Down: func(tx *gorm.DB) error {
+				if dropErr := dropColumns(tx, &softDeleteColumnsV3{}, softDeleteTablesV3...); dropErr != nil {
+					return dropErr
+				}
+				return tx.Migrator().DropTable(&auditLogV3{})
+			},
+		},
+		4: {
+			Version: 4,
+			Name:    "tenants",
+			Up: func(tx *gorm.DB) error {
+				return addColumns(tx, &tenantColumnsV4{}, tenantTablesV4...)
+			},
+			Down: func(tx *gorm.DB) error {
+				return dropColumns(tx, &tenantColumnsV4{}, tenantTablesV4...)
+			},
+		},
+		5: {
+			Version: 5,
+			Name:    "versions",
+			Up: func(tx *gorm.DB) error {
+				return addColumns(tx, &versionColumnsV5{}, versionTablesV5...)
+			},
+			Down: func(tx *gorm.DB) error {
+				return dropColumns(tx, &versionColumnsV5{}, versionTablesV5...)
+			},
+		},
+		// The movement ledger and reservations of the inventory service. Inventories of the same product
+		// and warehouse are merged for the new unique index. Movements recorded before
+		// models.MovementType get the matching type, and issues a negative quantity.
+		6: {
+			Version: 6,
+			Name:    "inventory_ledger",
+			Up: func(tx *gorm.DB) error {
+				if mergeErr := mergeInventoryDuplicates(tx); mergeErr != nil {
+					return mergeErr
+				}
+				if migrateErr := addColumns(tx, &inventoryColumnsV6{}, "inventory"); migrateErr != nil {
+					return migrateErr
+				}
+				if migrateErr := tx.AutoMigrate(&inventoryMovementV6{}, &stockReservationV6{}); migrateErr != nil {
+					return migrateErr
+				}
+				movements := SkipAudit(tx).Table("inventory_movements")
+				for legacy, movementType := range models.LegacyMovementTypes {
+					if updateErr := movements.Session(&gorm.Session{}).Where("movement_type = ?", legacy).Update("movement_type", movementType).Error; updateErr != nil {
+						return updateErr
+					}
+				}
+				if updateErr := movements.Session(&gorm.Session{}).Where("movement_type = ? AND quantity > 0", models.MovementIssue).Update("quantity", gorm.Expr("0 - quantity")).Error; updateErr != nil {
+					return updateErr
+				}
+				transfers := []string{"transferência", "transferencia"}
+				if updateErr := movements.Session(&gorm.Session{}).Where("movement_type IN ? AND quantity < 0", transfers).Update("movement_type", models.MovementTransferOut).Error; updateErr != nil {
+					return updateErr
+				}
+				return movements.Session(&gorm.Session{}).Where("movement_type IN ?", transfers).Update("movement_type", models.MovementTransferIn).Error
+			},
+			Down: func(tx *gorm.DB) error {
+				if dropErr := tx.Migrator().DropTable(&stockReservationV6{}, &inventoryMovementV6{}); dropErr != nil {
+					return dropErr
+				}
+				inventory := tx.Table("inventory").Migrator()
+				if inventory.HasIndex(&inventoryColumnsV6{}, "idx_inventory_product_warehouse") {
+					if dropErr := inventory.DropIndex(&inventoryColumnsV6{}, "idx_inventory_product_warehouse"); dropErr != nil {
+						return dropErr
+					}
+				}
+				return inventory.DropColumn(&inventoryColumnsV6{}, "Reserved")
+			},
+		},
+		7: {
+			Version: 7,
+			Name:    "order_status_history",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&orderStatusHistoryV7{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&orderStatusHistoryV7{})
+			},
+		},
+		8: {
+			Version: 8,
+			Name:    "order_items",
+			Up: func(tx *gorm.DB) error {
+				if migrateErr := tx.AutoMigrate(&orderItemV8{}); migrateErr != nil {
+					return migrateErr
+				}
+				return addColumns(tx, &orderTotalsColumnsV8{}, "orders")
+			},
+			Down: func(tx *gorm.DB) error {
+				if dropErr := tx.Migrator().DropTable(&orderItemV8{}); dropErr != nil {
+					return dropErr
+				}
+				return dropColumns(tx, &orderTotalsColumnsV8{}, "orders")
+			},
+		},
+		9: {
+			Version: 9,
+			Name:    "count_sessions",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&countSessionV9{}, &countLineV9{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&countLineV9{}, &countSessionV9{})
+			},
+		},
+		// The category tree, product variants with barcodes, and stock per variant. Products get the
+		// categories of their depart and category names.
+		10: {
+			Version: 10,
+			Name:    "catalog",
+			Up: func(tx *gorm.DB) error {
+				if migrateErr := tx.AutoMigrate(&categoryV10{}, &productVariantV10{}); migrateErr != nil {
+					return migrateErr
+				}
+				for _, columns := range []struct {
+					columns interface{}
+					tables  []string
+				}{
+					{&productCategoryColumnsV10{}, []string{"products"}},
+					{&inventoryColumnsV10{}, []string{"inventory"}},
+					{&countLineColumnsV10{}, []string{"count_lines"}},
+					{&movementVariantColumnsV10{}, []string{"inventory_movements"}},
+					{&variantColumnsV10{}, variantTablesV10},
+				} {
+					if addErr := addColumns(tx, columns.columns, columns.tables...); addErr != nil {
+						return addErr
+					}
+				}
+				// The unique indexes of stock and counts now include the variant.
+				for _, index := range []struct {
+					columns interface{}
+					table   string
+					name    string
+				}{{&inventoryColumnsV10{}, "inventory", "idx_inventory_product_warehouse"}, {&countLineColumnsV10{}, "count_lines", "idx_count_lines_session_product"}} {
+					migrator := tx.Table(index.table).Migrator()
+					if migrator.HasIndex(index.columns, index.name) {
+						if dropErr := migrator.DropIndex(index.columns, index.name); dropErr != nil {
+							return dropErr
+						}
+					}
+					if createErr := migrator.CreateIndex(index.columns, index.name); createErr != nil {
+						return createErr
+					}
+				}
+				return categorizeProducts(tx)
+			},
+			Down: func(tx *gorm.DB) error {
+				// Stock and counts of variants would collide on the unique indexes without the variant.
+				for _, table := range []string{"inventory", "count_lines"} {
+					var rows int64
+					if countErr := tx.Table(table).Where("variant_id <> ''").Count(&rows).Error; countErr != nil {
+						return countErr
+					}
+					if rows > 0 {
+						return fmt.Errorf("error rolling back the catalog: %d rows of %s hold variants", rows, table)
+					}
+				}
+				if dropErr := tx.Migrator().DropTable(&productVariantV10{}, &categoryV10{}); dropErr != nil {
+					return dropErr
+				}
+				if dropErr := dropColumns(tx, &productCategoryColumnsV10{}, "products"); dropErr != nil {
+					return dropErr
+				}
+				if dropErr := dropColumns(tx, &movementVariantColumnsV10{}, "inventory_movements"); dropErr != nil {
+					return dropErr
+				}
+				if dropErr := dropColumns(tx, &variantColumnsV10{}, variantTablesV10...); dropErr != nil {
+					return dropErr
+				}
+				for _, index := range []struct {
+					columns interface{}
+					table   string
+					name    string
+					before  string
+				}{
+					{&inventoryColumnsV10{}, "inventory", "idx_inventory_product_warehouse", "product_id, warehouse_id"},
+					{&countLineColumnsV10{}, "count_lines", "idx_count_lines_session_product", "session_id, product_id"},
+				} {
+					migrator := tx.Table(index.table).Migrator()
+					if dropErr := migrator.DropIndex(index.columns, index.name); dropErr != nil {
+						return dropErr
+					}
+					if dropErr := migrator.DropColumn(index.columns, "VariantID"); dropErr != nil {
+						return dropErr
+					}
+					if createErr := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)", index.name, index.table, index.before)).Error; createErr != nil {
+						return createErr
+					}
+				}
+				return nil
+			},
+		},
+	}
+)
+
+// mergeInventoryDuplicates folds the inventories of the same product and warehouse into the oldest of
+// them: it gets the sum of their quantities and their movements, and the others are deleted.
+func mergeInventoryDuplicates(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable("inventory") {
+		return nil
+	}
+	tx = SkipAudit(tx)
+	var groups []struct {
+		ProductID   string
+		WarehouseID string
+	}
+	if findErr := tx.Table("inventory").Select("product_id, warehouse_id").Group("product_id, warehouse_id").Having("COUNT(*) > 1").Find(&groups).Error; findErr != nil {
+		return findErr
+	}
+	movements := tx.Migrator().HasTable("inventory_movements")
+	for _, group := range groups {
+		var rows []struct {
+			ID       string
+			Quantity float64
+		}
+		if findErr := tx.Table("inventory").Select("id, quantity").Where("product_id = ? AND warehouse_id = ?", group.ProductID, group.WarehouseID).Order("created_at, id").Find(&rows).Error; findErr != nil {
+			return findErr
+		}
+		keep, quantity := rows[0].ID, 0.0
+		merged := make([]string, 0, len(rows)-1)
+		for _, row := range rows {
+			quantity += row.Quantity
+			if row.ID != keep {
+				merged = append(merged, row.ID)
+			}
+		}
+		if movements {
+			if updateErr := tx.Table("inventory_movements").Where("inventory_id IN ?", merged).Update("inventory_id", keep).Error; updateErr != nil {
+				return updateErr
+			}
+		}
+		if updateErr := tx.Table("inventory").Where("id = ?", keep).Update("quantity", quantity).Error; updateErr != nil {
+			return updateErr
+		}
+		if deleteErr := tx.Exec("DELETE FROM inventory WHERE id IN ?", merged).Error; deleteErr != nil {
+			return deleteErr
+		}
+	}
+	return nil
+}
+
+// categorizeProducts files the products without a category under the categories of their depart and
+// category names, depart at the top, creating them in the tenant of the products with the Path and
+// Depth the hooks of models.Category would give them.
+func categorizeProducts(tx *gorm.DB) error {
+	tx = SkipAudit(tx)
+	var groups []struct {
+		TenantID string
+		Depart   string
+		Category string
+	}
+	if findErr := tx.Table("products").Distinct("tenant_id", "depart", "category").Where("category_id IS NULL").Find(&groups).Error; findErr != nil {
+		return findErr
+	}
+	categoryFor := func(tenantID, name string, parent *categoryV10) (*categoryV10, error) {
+		category := categoryV10{Name: name, Path: "/", TenantID: tenantID, Version: 1}
+		query := tx.Where("tenant_id = ? AND name = ?", tenantID, name)
+		if parent == nil {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			category.ParentID, category.Path, category.Depth = &parent.ID, parent.Path, parent.Depth+1
+			query = query.Where("parent_id = ?", parent.ID)
+		}
+		result := query.Limit(1).Find(&category)
+		if result.Error != nil || result.RowsAffected > 0 {
+			return &category, result.Error
+		}
+		if createErr := tx.Create(&category).Error; createErr != nil {
+			return nil, createErr
+		}
+		category.Path += fmt.Sprintf("%d/", category.ID)
+		return &category, tx.Model(&category).UpdateColumn("path", category.Path).Error
+	}
+	for _, group := range groups {
+		var category *categoryV10
+		for _, name := range []string{strings.TrimSpace(group.Depart), strings.TrimSpace(group.Category)} {
+			if name == "" {
+				continue
+			}
+			var categoryErr error
+			if category, categoryErr = categoryFor(group.TenantID, name, category); categoryErr != nil {
+				return fmt.Errorf("error creating category %q: %v", name, categoryErr)
+			}
+		}
+		if category == nil {
+			continue
+		}
+		if updateErr := tx.Table("products").Where("tenant_id = ? AND depart = ? AND category = ? AND category_id IS NULL", group.TenantID, group.Depart, group.Category).Update("category_id", category.ID).Error; updateErr != nil {
+			return updateErr
+		}
+	}
+	return nil
+}
+
+// RegisterMigration adds a Go migration. Versions must be unique; timestamps (MigrationVersionLayout)
+// keep them ordered across packages.
+func RegisterMigration(m Migration) error {
+	if m.Version <= 0 {
+		return fmt.Errorf("migration %s: version must be positive", m.Name)
+	}
+	if m.Up == nil {
+		return fmt.Errorf("migration %d_%s: up function is required", m.Version, m.Name)
+	}
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	if existing, exists := migrations[m.Version]; exists {
+		return fmt.Errorf("migration version %d already registered by %s", m.Version, existing.Name)
+	}
+	migrations[m.Version] = m
+	return nil
+}
+
+type Migrator struct {
+	db     *gorm.DB
+	dir    string
+	driver string
+	owner  string
+}
+
+func NewMigrator(db *gorm.DB, dir string) *Migrator {
+	// Applied versions are read on the primary, never on a replica that may lag behind. Migrations see
+	// the rows of every tenant.
+	return &Migrator{db: asSystem(UsePrimary(db)), dir: dir, driver: db.Dialector.Name(), owner: uuid.New().String()}
+}
+
+// Migrations returns every known migration in version order.
+func (m *Migrator) Migrations() ([]Migration, error) {
+	byVersion := make(map[int64]Migration)
+	migrationsMu.RLock()
+	for version, migration := range migrations {
+		migration.source = "go"
+		byVersion[version] = migration
+	}
+	migrationsMu.RUnlock()
+
+	fileMigrations, loadErr := loadSQLMigrations(m.dir, m.driver)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	for version, migration := range fileMigrations {
+		if existing, exists := byVersion[version]; exists {
+			return nil, fmt.Errorf("migration version %d defined twice: %s (%s) and %s (%s)",
+				version, existing.Name, existing.source, migration.Name, migration.source)
+		}
+		byVersion[version] = migration
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		list = append(list, migration)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Up applies pending migrations, at most steps of them when steps > 0, then creates or updates the
+// tables of the dynamic models.
+func (m *Migrator) Up(steps int) ([]Migration, error) {
+	var applied []Migration
+	lockErr := m.withLock(func() error {
+		all, listErr := m.Migrations()
+		if listErr != nil {
+			return listErr
+		}
+		done, doneErr := m.applied()
+		if doneErr != nil {
+			return doneErr
+		}
+		for _, migration := range all {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if steps > 0 && len(applied) >= steps {
+				break
+			}
+			if runErr := m.run(migration, true); runErr != nil {
+				return runErr
+			}
+			applied = append(applied, migration)
+		}
+		return MigrateDynamicModels(m.db)
+	})
+	return applied, lockErr
+}
+
+// Down reverts the latest applied migrations, steps of them (at least one).
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	var reverted []Migration
+	lockErr := m.withLock(func() error {
+		all, listErr := m.Migrations()
+		if listErr != nil {
+			return listErr
+		}
+		done, doneErr := m.applied()
+		if doneErr != nil {
+			return doneErr
+		}
+		known := make(map[int64]Migration, len(all))
+		for _, migration := range all {
+			known[migration.Version] = migration
+		}
+		versions := make([]int64, 0, len(done))
+		for version := range done {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if len(reverted) >= steps {
+				break
+			}
+			migration, ok := known[version]
+			if !ok {
+				return fmt.Errorf("migration %d_%s is applied but no longer defined", version, done[version].Name)
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d_%s has no down step", migration.Version, migration.Name)
+			}
+			if runErr := m.run(migration, false); runErr != nil {
+				return runErr
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, lockErr
+}
+
+// Version returns the latest applied migration version, 0 when none is.
+func (m *Migrator) Version() (int64, error) {
+	if !m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return 0, nil
+	}
+	var version int64
+	if rowErr := m.db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Row().Scan(&version); rowErr != nil {
+		return 0, fmt.Errorf("error reading schema_migrations: %v", rowErr)
+	}
+	return version, nil
+}
+
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if migrateErr := m.db.AutoMigrate(&SchemaMigration{}); migrateErr != nil {
+		return nil, fmt.Errorf("error creating schema_migrations: %v", migrateErr)
+	}
+	all, listErr := m.Migrations()
+	if listErr != nil {
+		return nil, listErr
+	}
+	done, doneErr := m.applied()
+	if doneErr != nil {
+		return nil, doneErr
+	}
+	status := make([]MigrationStatus, 0, len(all))
+	for _, migration := range all {
+		st := MigrationStatus{Version: migration.Version, Name: migration.Name, Source: migration.source}
+		if record, ok := done[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			st.Applied = true
+			st.AppliedAt = &appliedAt
+			delete(done, migration.Version)
+		}
+		status = append(status, st)
+	}
+	for _, record := range done {
+		appliedAt := record.AppliedAt
+		status = append(status, MigrationStatus{Version: record.Version, Name: record.Name, Source: "missing", Applied: true, AppliedAt: &appliedAt})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, nil
+}
+
+// CreateMigration writes empty up/down SQL files for a new migration in dir and returns their paths.
+// When driver is set the files only apply to that driver.
+func CreateMigration(dir, name, driver string) (string, string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", fmt.Errorf("migration name is required")
+	}
+	if mkdirErr := os.MkdirAll(dir, 0755); mkdirErr != nil {
+		return "", "", fmt.Errorf("error creating migrations directory: %v", mkdirErr)
+	}
+	base := fmt.Sprintf("%s_%s", time.Now().UTC().Format(MigrationVersionLayout), name)
+	if driver != "" {
+		resolved, driverErr := ResolveDriver(glb.Database{Driver: driver})
+		if driverErr != nil {
+			return "", "", driverErr
+		}
+		base += "." + resolved
+	}
+	upPath := filepath.Join(dir, base+".up.sql")
+	downPath := filepath.Join(dir, base+".down.sql")
+	for _, path := range []string{upPath, downPath} {
+		header := fmt.Sprintf("-- %s\n", filepath.Base(path))
+		if writeErr := os.WriteFile(path, []byte(header), 0644); writeErr != nil {
+			return "", "", fmt.Errorf("error writing migration file: %v", writeErr)
+		}
+	}
+	return upPath, downPath, nil
+}
+
+func (m *Migrator) run(migration Migration, up bool) error {
+	step, direction := migration.Up, "up"
+	if !up {
+		step, direction = migration.Down, "down"
+	}
+	txErr := m.db.Transaction(func(tx *gorm.DB) error {
+		if stepErr := step(tx); stepErr != nil {
+			return stepErr
+		}
+		if up {
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		}
+		return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+	})
+	if txErr != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %v", migration.Version, migration.Name, direction, txErr)
+	}
+	return nil
+}
+func (m *Migrator) applied() (map[int64]SchemaMigration, error) {
+	var records []SchemaMigration
+	if findErr := m.db.Order("version").Find(&records).Error; findErr != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %v", findErr)
+	}
+	done := make(map[int64]SchemaMigration, len(records))
+	for _, record := range records {
+		done[record.Version] = record
+	}
+	return done, nil
+}
+
+// withLock runs fn holding the row of schema_migrations_lock, so two processes never migrate the
+// same database concurrently. A lock left behind by a crashed process expires after MigrationLockTimeout.
+func (m *Migrator) withLock(fn func() error) error {
+	if migrateErr := m.db.AutoMigrate(&SchemaMigration{}, &SchemaMigrationLock{}); migrateErr != nil {
+		return fmt.Errorf("error creating migration tables: %v", migrateErr)
+	}
+
+	deadline := time.Now().Add(MigrationLockWait)
+	for {
+		lock := SchemaMigrationLock{ID: 1, Owner: m.owner, LockedAt: time.Now().UTC()}
+		if createErr := m.db.Create(&lock).Error; createErr == nil {
+			break
+		}
+		var held SchemaMigrationLock
+		if findErr := m.db.First(&held, 1).Error; findErr == nil && time.Since(held.LockedAt) > MigrationLockTimeout {
+			m.db.Where("id = ? AND owner = ?", 1, held.Owner).Delete(&SchemaMigrationLock{})
+			continue
+		} else if findErr != nil && !errors.Is(findErr, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("error reading migration lock: %v", findErr)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for migration lock held by %s since %s", held.Owner, held.LockedAt.Format(time.RFC3339))
+		}
+		time.Sleep(time.Second)
+	}
+	defer m.db.Where("id = ? AND owner = ?", 1, m.owner).Delete(&SchemaMigrationLock{})
+
+	return fn()
+}
+
+var sqlMigrationFile = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_\-]+?)(?:\.([a-z0-9]+))?\.(up|down)\.sql$`)
+
+func loadSQLMigrations(dir, driver string) (map[int64]Migration, error) {
+	result := make(map[int64]Migration)
+	if dir == "" {
+		return result, nil
+	}
+	entries, readErr := os.ReadDir(dir)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return result, nil
+		}
+		return nil, fmt.Errorf("error reading migrations directory: %v", readErr)
+	}
+
+	// Driver specific files (upDrv/downDrv) take precedence over generic ones.
+	type fileSet struct {
+		name           string
+		up, down       string
+		upDrv, downDrv string
+	}
+	sets := make(map[int64]*fileSet)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := sqlMigrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, parseErr := strconv.ParseInt(match[1], 10, 64)
+		if parseErr != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", entry.Name(), parseErr)
+		}
+		fileDriver := match[3]
+		if fileDriver != "" {
+			resolved, driverErr := ResolveDriver(glb.Database{Driver: fileDriver})
+			if driverErr != nil {
+				return nil, fmt.Errorf("migration %s: %v", entry.Name(), driverErr)
+			}
+			if resolved != driver {
+				continue
+			}
+		}
+		set, ok := sets[version]
+		if !ok {
+			set = &fileSet{name: match[2]}
+			sets[version] = set
+		} else if set.name != match[2] {
+			return nil, fmt.Errorf("migration version %d used by %s and %s", version, set.name, match[2])
+		}
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case match[4] == "up" && fileDriver != "":
+			set.upDrv = path
+		case match[4] == "up":
+			set.up = path
+		case fileDriver != "":
+			set.downDrv = path
+		default:
+			set.down = path
+		}
+	}
+
+	for version, set := range sets {
+		upPath, downPath := set.up, set.down
+		if set.upDrv != "" {
+			upPath = set.upDrv
+		}
+		if set.downDrv != "" {
+			downPath = set.downDrv
+		}
+		if upPath == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", version, set.name)
+		}
+		migration := Migration{Version: version, Name: set.name, Up: sqlMigrationFunc(upPath), source: filepath.Base(upPath)}
+		if downPath != "" {
+			migration.Down = sqlMigrationFunc(downPath)
+		}
+		result[version] = migration
+	}
+	return result, nil
+}
+func sqlMigrationFunc(path string) MigrationFunc {
+	return func(tx *gorm.DB) error {
+		statements, readErr := readSQLStatements(path)
+		if readErr != nil {
+			return readErr
+		}
+		for _, statement := range statements {
+			if execErr := tx.Exec(statement).Error; execErr != nil {
+				return fmt.Errorf("%s: %v", filepath.Base(path), execErr)
+			}
+		}
+		return nil
+	}
+}
+func readSQLStatements(path string) ([]string, error) {
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var statements []string
+	var current strings.Builder
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if current.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, scanErr
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements, nil
+}
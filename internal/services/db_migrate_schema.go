@@ -0,0 +1,380 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The schemas of the built-in migrations, frozen as each version created them. Migrations must not
+// use the live models: a field added to a model later would otherwise be created by an older version,
+// and the migration that adds it would find it there. Tables a migration creates are snapshotted
+// whole; columns it adds to existing tables are snapshotted as column sets applied by table name.
+
+// Version 1: initial_schema.
+
+type userV1 struct {
+	ID       string `gorm:"type:uuid;primaryKey"`
+	Name     string `gorm:"type:varchar(255);not null"`
+	Username string `gorm:"type:varchar(255);unique;not null"`
+	Password string `gorm:"type:varchar(255);not null"`
+	Email    string `gorm:"type:varchar(255);unique;not null"`
+	Phone    string `gorm:"type:varchar(20)"`
+	RoleID   uint   `gorm:"type:integer;default:2"`
+	Document string `gorm:"type:varchar(20)"`
+	Address  string `gorm:"type:text"`
+	City     string `gorm:"type:varchar(100)"`
+	State    string `gorm:"type:varchar(50)"`
+	Country  string `gorm:"type:varchar(50)"`
+	Zip      string `gorm:"type:varchar(20)"`
+	Birth    string `gorm:"type:date"`
+	Avatar   string `gorm:"type:varchar(255)"`
+	Picture  string `gorm:"type:varchar(255)"`
+	Active   bool   `gorm:"type:boolean;default:true"`
+}
+
+func (userV1) TableName() string { return "users" }
+
+type productV1 struct {
+	ID       uint    `gorm:"primaryKey"`
+	Name     string  `gorm:"not null"`
+	Depart   string  `gorm:"not null"`
+	Category string  `gorm:"not null"`
+	Price    float64 `gorm:"not null"`
+	Cost     float64 `gorm:"not null"`
+	Stock    int     `gorm:"not null"`
+	Reserve  int     `gorm:"not null"`
+	Balance  int     `gorm:"not null"`
+	Synced   bool    `gorm:"not null"`
+	LastSync string  `gorm:"not null"`
+}
+
+func (productV1) TableName() string { return "products" }
+
+type customerV1 struct {
+	ID      string `gorm:"primaryKey"`
+	Name    string `gorm:"name;required"`
+	Address string `gorm:"address;omitempty"`
+	Region  string `gorm:"region;omitempty"`
+	Phone   string `gorm:"phone;omitempty"`
+	Email   string `gorm:"email;omitempty"`
+	Score   int    `gorm:"score;omitempty"`
+	Seller  int    `gorm:"seller;default:0"`
+	Active  bool   `gorm:"active;default:true"`
+}
+
+func (customerV1) TableName() string { return "customers" }
+
+type orderV1 struct {
+	ID                string    `gorm:"type:uuid;primaryKey"`
+	OrderNumber       string    `gorm:"type:varchar(50);unique;not null"`
+	CustomerID        uint      `gorm:"type:uuid;not null"`
+	Status            string    `gorm:"type:varchar(50);not null;default:'draft'"`
+	OrderDate         time.Time `gorm:"type:timestamp;not null;default:current_timestamp"`
+	EstimatedDelivery time.Time `gorm:"type:timestamp"`
+	ActualDelivery    time.Time `gorm:"type:timestamp"`
+	TotalAmount       float64   `gorm:"type:decimal(15,2);not null;default:0"`
+	CreatedAt         time.Time `gorm:"type:timestamp;not null;default:current_timestamp"`
+	UpdatedAt         time.Time `gorm:"type:timestamp;not null;default:current_timestamp"`
+}
+
+func (orderV1) TableName() string { return "orders" }
+
+// Version 2: roles_warehouses_inventory.
+
+type roleV2 struct {
+	ID          string `gorm:"primaryKey"`
+	Name        string `gorm:"unique"`
+	Description string
+	Active      bool `gorm:"default:true"`
+}
+
+func (roleV2) TableName() string { return "roles" }
+
+type warehouseV2 struct {
+	ID         string `gorm:"type:uuid;primaryKey"`
+	Code       string `gorm:"type:varchar(50);unique;not null"`
+	Name       string `gorm:"type:varchar(255);not null"`
+	Address    string `gorm:"type:text"`
+	City       string `gorm:"type:varchar(100)"`
+	State      string `gorm:"type:varchar(50)"`
+	Country    string `gorm:"type:varchar(50);default:'Brasil'"`
+	PostalCode string `gorm:"type:varchar(20)"`
+	Active     bool   `gorm:"type:boolean;default:true"`
+}
+
+func (warehouseV2) TableName() string { return "warehouses" }
+
+type inventoryV2 struct {
+	ID            string    `gorm:"type:uuid;primaryKey"`
+	ProductID     string    `gorm:"type:uuid;not null"`
+	WarehouseID   string    `gorm:"type:uuid;not null"`
+	Quantity      float64   `gorm:"type:decimal(15,3);not null;default:0"`
+	Status        string    `gorm:"type:varchar(50);not null;default:'available'"`
+	LastCountDate time.Time `gorm:"type:timestamp;not null;default:current_timestamp"`
+	CreatedAt     time.Time `gorm:"type:timestamp;not null;default:current_timestamp"`
+	UpdatedAt     time.Time `gorm:"type:timestamp;not null;default:current_timestamp"`
+}
+
+func (inventoryV2) TableName() string { return "inventory" }
+
+// Version 3: audit_logs_soft_delete.
+
+type auditLogV3 struct {
+	ID        uint      `gorm:"primaryKey"`
+	Model     string    `gorm:"type:varchar(100);not null;index:idx_audit_logs_record,priority:1"`
+	RecordID  string    `gorm:"type:varchar(255);not null;index:idx_audit_logs_record,priority:2"`
+	Operation string    `gorm:"type:varchar(20);not null"`
+	Changes   string    `gorm:"type:text"`
+	Actor     string    `gorm:"type:varchar(255);index"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
+
+func (auditLogV3) TableName() string { return "audit_logs" }
+
+var softDeleteTablesV3 = []string{"products", "customers", "orders", "warehouses"}
+
+type softDeleteColumnsV3 struct {
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// Version 4: tenants.
+
+var tenantTablesV4 = []string{"users", "products", "customers", "orders", "warehouses", "inventory", "audit_logs"}
+
+type tenantColumnsV4 struct {
+	TenantID string `gorm:"type:varchar(64);not null;default:'';index"`
+}
+
+// Version 5: versions.
+
+var versionTablesV5 = []string{"products", "customers", "orders", "warehouses", "inventory"}
+
+type versionColumnsV5 struct {
+	Version int64 `gorm:"not null;default:1"`
+}
+
+// Version 6: inventory_ledger.
+
+type inventoryColumnsV6 struct {
+	ProductID   string  `gorm:"type:uuid;not null;uniqueIndex:idx_inventory_product_warehouse,priority:1"`
+	WarehouseID string  `gorm:"type:uuid;not null;uniqueIndex:idx_inventory_product_warehouse,priority:2"`
+	Reserved    float64 `gorm:"type:decimal(15,3);not null;default:0"`
+}
+
+type inventoryMovementV6 struct {
+	ID                string    `gorm:"type:uuid;primaryKey"`
+	InventoryID       string    `gorm:"type:uuid;not null;index"`
+	ProductID         string    `gorm:"type:uuid;not null;index"`
+	WarehouseID       string    `gorm:"type:uuid;index"`
+	Quantity          float64   `gorm:"type:decimal(15,3);not null"`
+	Balance           float64   `gorm:"type:decimal(15,3);not null;default:0"`
+	MovementType      string    `gorm:"type:varchar(50);not null"`
+	TransferID        string    `gorm:"type:varchar(36);index"`
+	ReferenceDocument string    `gorm:"type:varchar(100)"`
+	Reason            string    `gorm:"type:text"`
+	CreatedAt         time.Time `gorm:"type:timestamp;not null;default:current_timestamp"`
+	UpdatedAt         time.Time `gorm:"type:timestamp;not null;default:current_timestamp"`
+	TenantID          string    `gorm:"type:varchar(64);not null;default:'';index"`
+}
+
+func (inventoryMovementV6) TableName() string { return "inventory_movements" }
+
+type stockReservationV6 struct {
+	ID          string    `gorm:"type:uuid;primaryKey"`
+	InventoryID string    `gorm:"type:uuid;not null;index"`
+	ProductID   string    `gorm:"type:uuid;not null"`
+	WarehouseID string    `gorm:"type:uuid;not null"`
+	OrderID     string    `gorm:"type:varchar(36);index"`
+	Quantity    float64   `gorm:"type:decimal(15,3);not null"`
+	Status      string    `gorm:"type:varchar(20);not null;default:'active';index:idx_stock_reservations_expiry,priority:1"`
+	ExpiresAt   time.Time `gorm:"not null;index:idx_stock_reservations_expiry,priority:2"`
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+	TenantID    string    `gorm:"type:varchar(64);not null;default:'';index"`
+	Version     int64     `gorm:"not null;default:1"`
+}
+
+func (stockReservationV6) TableName() string { return "stock_reservations" }
+
+// Version 7: order_status_history.
+
+type orderStatusHistoryV7 struct {
+	ID         uint      `gorm:"primaryKey"`
+	OrderID    string    `gorm:"type:varchar(36);not null;index"`
+	FromStatus string    `gorm:"type:varchar(50);not null"`
+	ToStatus   string    `gorm:"type:varchar(50);not null"`
+	Actor      string    `gorm:"type:varchar(255)"`
+	Reason     string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"not null;index"`
+	TenantID   string    `gorm:"type:varchar(64);not null;default:'';index"`
+}
+
+func (orderStatusHistoryV7) TableName() string { return "order_status_history" }
+
+// Version 8: order_items.
+
+type orderItemV8 struct {
+	ID             string    `gorm:"type:uuid;primaryKey"`
+	OrderID        string    `gorm:"type:uuid;not null;index"`
+	ProductID      uint      `gorm:"not null;index"`
+	Description    string    `gorm:"type:varchar(255)"`
+	Quantity       string    `gorm:"type:decimal(15,3);not null"`
+	UnitPrice      string    `gorm:"type:decimal(15,4);not null"`
+	DiscountRate   string    `gorm:"type:decimal(7,4);not null;default:0"`
+	DiscountAmount string    `gorm:"type:decimal(15,2);not null;default:0"`
+	TaxRate        string    `gorm:"type:decimal(7,4);not null;default:0"`
+	Subtotal       string    `gorm:"type:decimal(15,2);not null;default:0"`
+	DiscountTotal  string    `gorm:"type:decimal(15,2);not null;default:0"`
+	TaxAmount      string    `gorm:"type:decimal(15,2);not null;default:0"`
+	Total          string    `gorm:"type:decimal(15,2);not null;default:0"`
+	CreatedAt      time.Time `gorm:"not null"`
+	UpdatedAt      time.Time `gorm:"not null"`
+	TenantID       string    `gorm:"type:varchar(64);not null;default:'';index"`
+}
+
+func (orderItemV8) TableName() string { return "order_items" }
+
+type orderTotalsColumnsV8 struct {
+	Subtotal      string `gorm:"type:decimal(15,2);not null;default:0"`
+	DiscountTotal string `gorm:"type:decimal(15,2);not null;default:0"`
+	TaxTotal      string `gorm:"type:decimal(15,2);not null;default:0"`
+}
+
+// Version 9: count_sessions.
+
+type countSessionV9 struct {
+	ID          string `gorm:"type:uuid;primaryKey"`
+	WarehouseID string `gorm:"type:uuid;not null;index"`
+	Status      string `gorm:"type:varchar(20);not null;default:'open';index"`
+	Reference   string `gorm:"type:varchar(100)"`
+	Notes       string `gorm:"type:text"`
+	Partial     bool   `gorm:"not null;default:false"`
+	StartedBy   string `gorm:"type:varchar(255)"`
+	PostedBy    string `gorm:"type:varchar(255)"`
+	PostedAt    *time.Time
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+	TenantID    string    `gorm:"type:varchar(64);not null;default:'';index"`
+	Version     int64     `gorm:"not null;default:1"`
+}
+
+func (countSessionV9) TableName() string { return "count_sessions" }
+
+type countLineV9 struct {
+	ID          string   `gorm:"type:uuid;primaryKey"`
+	SessionID   string   `gorm:"type:uuid;not null;uniqueIndex:idx_count_lines_session_product,priority:1"`
+	ProductID   string   `gorm:"type:uuid;not null;uniqueIndex:idx_count_lines_session_product,priority:2"`
+	InventoryID string   `gorm:"type:varchar(36)"`
+	Expected    float64  `gorm:"type:decimal(15,3);not null;default:0"`
+	Counted     *float64 `gorm:"type:decimal(15,3)"`
+	Variance    float64  `gorm:"type:decimal(15,3);not null;default:0"`
+	Reason      string   `gorm:"type:text"`
+	CountedBy   string   `gorm:"type:varchar(255)"`
+	CountedAt   *time.Time
+	MovementID  string    `gorm:"type:varchar(36)"`
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+	TenantID    string    `gorm:"type:varchar(64);not null;default:'';index"`
+}
+
+func (countLineV9) TableName() string { return "count_lines" }
+
+// Version 10: catalog.
+
+type categoryV10 struct {
+	ID          uint      `gorm:"primaryKey"`
+	ParentID    *uint     `gorm:"index"`
+	Name        string    `gorm:"type:varchar(100);not null"`
+	Description string    `gorm:"type:text"`
+	Path        string    `gorm:"type:varchar(255);not null;default:'/';index"`
+	Depth       int       `gorm:"not null;default:0"`
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+	TenantID    string    `gorm:"type:varchar(64);not null;default:'';index"`
+	Version     int64     `gorm:"not null;default:1"`
+}
+
+func (categoryV10) TableName() string { return "categories" }
+
+type productVariantV10 struct {
+	ID          string    `gorm:"type:uuid;primaryKey"`
+	ProductID   uint      `gorm:"not null;index"`
+	SKU         string    `gorm:"type:varchar(64);not null;index"`
+	Name        string    `gorm:"type:varchar(255)"`
+	Size        string    `gorm:"type:varchar(50)"`
+	Color       string    `gorm:"type:varchar(50)"`
+	Barcode     string    `gorm:"type:varchar(14);index"`
+	BarcodeType string    `gorm:"type:varchar(10)"`
+	Price       float64   `gorm:"type:decimal(15,2);not null;default:0"`
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+	TenantID    string    `gorm:"type:varchar(64);not null;default:'';index"`
+	Version     int64     `gorm:"not null;default:1"`
+}
+
+func (productVariantV10) TableName() string { return "product_variants" }
+
+type productCategoryColumnsV10 struct {
+	CategoryID *uint `gorm:"index"`
+}
+
+type inventoryColumnsV10 struct {
+	ProductID   string `gorm:"type:uuid;not null;uniqueIndex:idx_inventory_product_warehouse,priority:1"`
+	VariantID   string `gorm:"type:varchar(36);not null;default:'';uniqueIndex:idx_inventory_product_warehouse,priority:2"`
+	WarehouseID string `gorm:"type:uuid;not null;uniqueIndex:idx_inventory_product_warehouse,priority:3"`
+}
+
+type countLineColumnsV10 struct {
+	SessionID string `gorm:"type:uuid;not null;uniqueIndex:idx_count_lines_session_product,priority:1"`
+	ProductID string `gorm:"type:uuid;not null;uniqueIndex:idx_count_lines_session_product,priority:2"`
+	VariantID string `gorm:"type:varchar(36);not null;default:'';uniqueIndex:idx_count_lines_session_product,priority:3"`
+}
+
+type movementVariantColumnsV10 struct {
+	VariantID string `gorm:"type:varchar(36);index"`
+}
+
+var variantTablesV10 = []string{"stock_reservations", "order_items"}
+
+type variantColumnsV10 struct {
+	VariantID string `gorm:"type:varchar(36)"`
+}
+
+// addColumns adds the columns of the snapshot columns, and their indexes, to each of tables.
+func addColumns(tx *gorm.DB, columns interface{}, tables ...string) error {
+	for _, table := range tables {
+		if migrateErr := tx.Table(table).AutoMigrate(columns); migrateErr != nil {
+			return fmt.Errorf("error adding columns to %s: %v", table, migrateErr)
+		}
+	}
+	return nil
+}
+
+// dropColumns drops the indexes and then the columns of the snapshot columns from each of tables.
+func dropColumns(tx *gorm.DB, columns interface{}, tables ...string) error {
+	for _, table := range tables {
+		stmt := &gorm.Statement{DB: tx}
+		if parseErr := stmt.ParseWithSpecialTableName(columns, table); parseErr != nil {
+			return parseErr
+		}
+		migrator := tx.Table(table).Migrator()
+		for name := range stmt.Schema.ParseIndexes() {
+			if migrator.HasIndex(columns, name) {
+				if dropErr := migrator.DropIndex(columns, name); dropErr != nil {
+					return fmt.Errorf("error dropping index %s: %v", name, dropErr)
+				}
+			}
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || !migrator.HasColumn(columns, field.DBName) {
+				continue
+			}
+			if dropErr := migrator.DropColumn(columns, field.DBName); dropErr != nil {
+				return fmt.Errorf("error dropping column %s.%s: %v", table, field.DBName, dropErr)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	glb "github.com/faelmori/gkbxsrv/internal/globals"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestMergeInventoryDuplicates(t *testing.T) {
+	db, openErr := OpenConnection(glb.Database{Driver: "sqlite", Path: t.TempDir() + "/inventory.db"})
+	if openErr != nil {
+		t.Fatalf("OpenConnection() error = %v", openErr)
+	}
+	for _, statement := range []string{
+		"CREATE TABLE inventory (id TEXT PRIMARY KEY, product_id TEXT NOT NULL, warehouse_id TEXT NOT NULL, quantity DECIMAL(15,3) NOT NULL DEFAULT 0, created_at TIMESTAMP NOT NULL)",
+		"CREATE TABLE inventory_movements (id TEXT PRIMARY KEY, inventory_id TEXT NOT NULL, quantity DECIMAL(15,3) NOT NULL)",
+		"INSERT INTO inventory VALUES ('a', 'p1', 'w1', 5, '2024-01-02'), ('b', 'p1', 'w1', 3, '2024-01-01'), ('c', 'p1', 'w1', 2, '2024-01-03'), ('d', 'p1', 'w2', 7, '2024-01-01')",
+		"INSERT INTO inventory_movements VALUES ('m1', 'a', 5), ('m2', 'b', 3), ('m3', 'c', 2), ('m4', 'd', 7)",
+	} {
+		if execErr := db.Exec(statement).Error; execErr != nil {
+			t.Fatalf("Exec(%q) error = %v", statement, execErr)
+		}
+	}
+
+	if mergeErr := mergeInventoryDuplicates(asSystem(db)); mergeErr != nil {
+		t.Fatalf("mergeInventoryDuplicates() error = %v", mergeErr)
+	}
+
+	var rows []struct {
+		ID       string
+		Quantity float64
+	}
+	if findErr := db.Table("inventory").Select("id, quantity").Order("id").Find(&rows).Error; findErr != nil {
+		t.Fatalf("Find() error = %v", findErr)
+	}
+	if len(rows) != 2 || rows[0].ID != "b" || rows[0].Quantity != 10 || rows[1].ID != "d" || rows[1].Quantity != 7 {
+		t.Errorf("inventory after the merge = %+v, want b with 10 and d with 7", rows)
+	}
+	var moved int64
+	if countErr := db.Table("inventory_movements").Where("inventory_id = ?", "b").Count(&moved).Error; countErr != nil || moved != 3 {
+		t.Errorf("movements of the kept inventory = %d, %v, want 3", moved, countErr)
+	}
+	if createErr := db.Exec("CREATE UNIQUE INDEX idx_inventory_product_warehouse ON inventory (product_id, warehouse_id)").Error; createErr != nil {
+		t.Errorf("unique index after the merge error = %v", createErr)
+	}
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	dir := t.TempDir()
+	db, openErr := OpenConnection(glb.Database{Driver: "sqlite", Path: dir + "/schema.db"})
+	if openErr != nil {
+		t.Fatalf("OpenConnection() error = %v", openErr)
+	}
+	migrator := NewMigrator(db, dir)
+	if _, upErr := migrator.Up(0); upErr != nil {
+		t.Fatalf("Up() error = %v", upErr)
+	}
+
+	for _, model := range append(append([]interface{}{}, models.ModelList...), &AuditLog{}) {
+		stmt := &gorm.Statement{DB: db}
+		if parseErr := stmt.Parse(model); parseErr != nil {
+			t.Fatalf("Parse(%T) error = %v", model, parseErr)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("%s.%s of %T is not created by the migrations", stmt.Schema.Table, field.DBName, model)
+			}
+		}
+		for name := range stmt.Schema.ParseIndexes() {
+			if !db.Migrator().HasIndex(model, name) {
+				t.Errorf("index %s of %T is not created by the migrations", name, model)
+			}
+		}
+	}
+
+	if _, downErr := migrator.Down(len(migrations)); downErr != nil {
+		t.Fatalf("Down() error = %v", downErr)
+	}
+	tables, tablesErr := db.Migrator().GetTables()
+	if tablesErr != nil {
+		t.Fatalf("GetTables() error = %v", tablesErr)
+	}
+	for _, table := range tables {
+		if table != "schema_migrations" && table != "schema_migrations_lock" && !strings.HasPrefix(table, "sqlite_") {
+			t.Errorf("table %s is left after rolling every migration back", table)
+		}
+	}
+}
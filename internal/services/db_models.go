@@ -0,0 +1,202 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Dynamic models are declared one per file (yaml, yml or json) in the models directory, e.g.
+//
+//	# supplier.yaml
+//	name: supplier
+//	timestamps: true
+//	fields:
+//	  - {name: name, type: string, size: 120, required: true, unique: true}
+//	  - {name: rating, type: int, default: "0"}
+//	indexes:
+//	  - {fields: [rating, name]}
+//
+// They are registered in models.ModelRegistryMap when the primary connection is opened, their tables
+// are created and kept up to date by Migrator.Up, and the broker, RPC, seeds and backups handle them
+// like the built-in models.
+const (
+	DefaultModelsDirName = "models"
+	dynamicModelsHook    = "gkbxsrv:dynamic_models"
+)
+
+// ModelsDir is database.models_dir or, by default, the models directory next to the config file.
+func (d *DatabaseServiceImpl) ModelsDir() string {
+	if dir := viper.GetString("database.models_dir"); dir != "" {
+		return os.ExpandEnv(dir)
+	}
+	if cfgFile := viper.ConfigFileUsed(); cfgFile != "" {
+		return filepath.Join(filepath.Dir(cfgFile), DefaultModelsDirName)
+	}
+	if d.fs != nil {
+		return filepath.Join(d.fs.GetDefaultConfigDir(), DefaultModelsDirName)
+	}
+	return DefaultModelsDirName
+}
+
+// LoadModels registers the model definitions of ModelsDir and returns their names. Loading the same
+// definitions again is a no-op.
+func (d *DatabaseServiceImpl) LoadModels() ([]string, error) {
+	dir := d.ModelsDir()
+	entries, readErr := os.ReadDir(dir)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading models directory: %v", readErr)
+	}
+	var files []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	instances, loadErr := d.loadModelsFromCache(files)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	names := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		if def, ok := models.DynamicModelDefinition(reflect.TypeOf(instance)); ok {
+			names = append(names, def.Name)
+		}
+	}
+	return names, nil
+}
+
+// loadModelsFromCache registers the definition files at generatedFilesPath and returns a new instance
+// of each model, like the entries of models.ModelList.
+func (d *DatabaseServiceImpl) loadModelsFromCache(generatedFilesPath []string) ([]interface{}, error) {
+	var modelsList []interface{}
+	for _, modelPath := range generatedFilesPath {
+		readFile, readFileErr := os.ReadFile(modelPath)
+		if readFileErr != nil {
+			return nil, fmt.Errorf("error reading model file %s: %v", modelPath, readFileErr)
+		}
+		jsonContent := readFile
+		if !strings.EqualFold(filepath.Ext(modelPath), ".json") {
+			var content interface{}
+			if yamlErr := yaml.Unmarshal(readFile, &content); yamlErr != nil {
+				return nil, fmt.Errorf("error decoding model file %s: %v", modelPath, yamlErr)
+			}
+			var jsonContentErr error
+			if jsonContent, jsonContentErr = json.Marshal(content); jsonContentErr != nil {
+				return nil, fmt.Errorf("error decoding model file %s: %v", modelPath, jsonContentErr)
+			}
+		}
+		jsonModelStructRef, jsonModelStructRefErr := d.loadModelFromJSONContent(jsonContent)
+		if jsonModelStructRefErr != nil {
+			return nil, fmt.Errorf("error loading model file %s: %v", modelPath, jsonModelStructRefErr)
+		}
+		modelsList = append(modelsList, jsonModelStructRef)
+	}
+	return modelsList, nil
+}
+
+// loadModelFromJSONContent registers the models.ModelDefinition in jsonContent and returns a new
+// instance of its type.
+func (d *DatabaseServiceImpl) loadModelFromJSONContent(jsonContent []byte) (interface{}, error) {
+	var def models.ModelDefinition
+	decoder := json.NewDecoder(bytes.NewReader(jsonContent))
+	decoder.DisallowUnknownFields()
+	if decodeErr := decoder.Decode(&def); decodeErr != nil {
+		return nil, decodeErr
+	}
+	def.Name = strings.ToLower(strings.TrimSpace(def.Name))
+	typ, registerErr := models.RegisterDynamicModel(def)
+	if registerErr != nil {
+		return nil, registerErr
+	}
+	return reflect.New(typ).Interface(), nil
+}
+
+// BindDynamicModels sets the table of every registered dynamic model in the schema cache of db.
+// Their struct types have no TableName method, so without it GORM has no table to use for them.
+// OpenConnection binds the models known when the connection is opened; models registered later
+// need a call on the connections already open.
+func BindDynamicModels(db *gorm.DB) error {
+	for _, def := range models.DynamicModelDefinitions() {
+		if bindErr := bindDynamicModel(db, def); bindErr != nil {
+			return bindErr
+		}
+	}
+	return nil
+}
+func bindDynamicModel(db *gorm.DB, def models.ModelDefinition) error {
+	typ, ok := models.ModelRegistryMap[def.Name]
+	if !ok {
+		return fmt.Errorf("model %s not found", def.Name)
+	}
+	stmt := &gorm.Statement{DB: db}
+	if parseErr := stmt.Parse(reflect.New(typ).Interface()); parseErr != nil {
+		return fmt.Errorf("error parsing model %s: %v", def.Name, parseErr)
+	}
+	// The schema is cached per connection and shared by its sessions and transactions.
+	stmt.Schema.Name, stmt.Schema.Table = def.Name, def.TableName()
+	return nil
+}
+
+// MigrateDynamicModels creates the tables of the registered dynamic models and adds the columns and
+// indexes missing from existing ones. Like AutoMigrate, it never drops anything.
+func MigrateDynamicModels(db *gorm.DB) error {
+	if bindErr := BindDynamicModels(db); bindErr != nil {
+		return bindErr
+	}
+	for _, def := range models.DynamicModelDefinitions() {
+		if migrateErr := db.AutoMigrate(reflect.New(models.ModelRegistryMap[def.Name]).Interface()); migrateErr != nil {
+			return fmt.Errorf("error migrating model %s: %v", def.Name, migrateErr)
+		}
+	}
+	return nil
+}
+
+// useDynamicModels fills the uuid primary key of dynamic models on create, which built-in models do
+// in their BeforeCreate hook.
+func useDynamicModels(db *gorm.DB) error {
+	if bindErr := BindDynamicModels(db); bindErr != nil {
+		return bindErr
+	}
+	return db.Callback().Create().Before("gorm:create").Register(dynamicModelsHook+":uuid", func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.Schema == nil {
+			return
+		}
+		def, ok := models.DynamicModelDefinition(tx.Statement.Schema.ModelType)
+		if !ok || def.IDType == models.DynamicIDTypeSerial {
+			return
+		}
+		field := tx.Statement.Schema.PrioritizedPrimaryField
+		setID := func(rv reflect.Value) {
+			if _, isZero := field.ValueOf(tx.Statement.Context, rv); isZero {
+				_ = field.Set(tx.Statement.Context, rv, uuid.New().String())
+			}
+		}
+		switch rv := tx.Statement.ReflectValue; rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				setID(reflect.Indirect(rv.Index(i)))
+			}
+		case reflect.Struct:
+			setID(rv)
+		}
+	})
+}
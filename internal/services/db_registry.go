@@ -0,0 +1,184 @@
+package services
+
+import (
+	"fmt"
+	glb "github.com/faelmori/gkbxsrv/internal/globals"
+	"github.com/goccy/go-json"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultConnectionName names the primary connection configured under `database:`. Extra connections
+// live under `databases:`, keyed by name:
+//
+//	"databases": {
+//	  "reporting": {"type": "postgresql", "host": "replica", ...},
+//	  "legacy":    {"type": "oracle", "dsn": "..."}
+//	}
+const DefaultConnectionName = "default"
+
+// ConnectionRegistry holds named database configs and opens each connection the first time it is asked for.
+type ConnectionRegistry struct {
+	mu      sync.Mutex
+	configs map[string]glb.Database
+	conns   map[string]*gorm.DB
+	opening map[string]*sync.Mutex
+}
+
+func NewConnectionRegistry() *ConnectionRegistry {
+	return &ConnectionRegistry{
+		configs: make(map[string]glb.Database),
+		conns:   make(map[string]*gorm.DB),
+		opening: make(map[string]*sync.Mutex),
+	}
+}
+
+func normalizeConnectionName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return DefaultConnectionName
+	}
+	return name
+}
+
+// Register adds or replaces the config of a named connection. An already open connection keeps being
+// served until Close is called for it.
+func (r *ConnectionRegistry) Register(name string, cfg glb.Database) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[normalizeConnectionName(name)] = cfg
+}
+
+// LoadFromViper registers every entry of the `databases:` config map.
+func (r *ConnectionRegistry) LoadFromViper() error {
+	raw := viper.GetStringMap("databases")
+	if len(raw) == 0 {
+		return nil
+	}
+	data, marshalErr := json.Marshal(raw)
+	if marshalErr != nil {
+		return fmt.Errorf("error reading databases config: %v", marshalErr)
+	}
+	var configs map[string]glb.Database
+	if unmarshalErr := json.Unmarshal(data, &configs); unmarshalErr != nil {
+		return fmt.Errorf("error reading databases config: %v", unmarshalErr)
+	}
+	for name, cfg := range configs {
+		r.Register(name, cfg)
+	}
+	return nil
+}
+
+func (r *ConnectionRegistry) Config(name string) (glb.Database, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cfg, ok := r.configs[normalizeConnectionName(name)]
+	return cfg, ok
+}
+
+func (r *ConnectionRegistry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.configs))
+	for name := range r.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the connection for name, opening it on first use. Concurrent callers of the same name
+// wait for a single open instead of racing to create several pools.
+func (r *ConnectionRegistry) Get(name string) (*gorm.DB, error) {
+	name = normalizeConnectionName(name)
+
+	r.mu.Lock()
+	if db, ok := r.conns[name]; ok {
+		r.mu.Unlock()
+		return db, nil
+	}
+	cfg, ok := r.configs[name]
+	if !ok {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("database connection %q is not configured", name)
+	}
+	lock, ok := r.opening[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.opening[name] = lock
+	}
+	r.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	r.mu.Lock()
+	if db, ok := r.conns[name]; ok {
+		r.mu.Unlock()
+		return db, nil
+	}
+	r.mu.Unlock()
+
+	db, openErr := OpenConnection(cfg)
+	if openErr != nil {
+		return nil, fmt.Errorf("error opening database connection %q: %v", name, openErr)
+	}
+
+	r.mu.Lock()
+	r.conns[name] = db
+	r.mu.Unlock()
+	return db, nil
+}
+
+// Set registers an already open connection under name, e.g. the primary one.
+func (r *ConnectionRegistry) Set(name string, db *gorm.DB) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conns[normalizeConnectionName(name)] = db
+}
+
+func (r *ConnectionRegistry) Close(name string) error {
+	name = normalizeConnectionName(name)
+	r.mu.Lock()
+	db, ok := r.conns[name]
+	delete(r.conns, name)
+	r.mu.Unlock()
+	if !ok || db == nil {
+		return nil
+	}
+	sqlDB, sqlDBErr := db.DB()
+	if sqlDBErr != nil {
+		return sqlDBErr
+	}
+	return sqlDB.Close()
+}
+
+// CloseAll closes every open connection except the ones listed in keep.
+func (r *ConnectionRegistry) CloseAll(keep ...string) error {
+	skip := make(map[string]bool, len(keep))
+	for _, name := range keep {
+		skip[normalizeConnectionName(name)] = true
+	}
+	r.mu.Lock()
+	names := make([]string, 0, len(r.conns))
+	for name := range r.conns {
+		if !skip[name] {
+			names = append(names, name)
+		}
+	}
+	r.mu.Unlock()
+
+	var errs []string
+	for _, name := range names {
+		if closeErr := r.Close(name); closeErr != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, closeErr))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error closing database connections: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/faelmori/logz"
+	"github.com/go-sql-driver/mysql"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ReplicaResolverName is the name the replica router is registered under with gorm.DB.Use.
+const ReplicaResolverName = "gkbxsrv:replicas"
+
+const forcePrimaryKey = "gkbxsrv:force_primary"
+
+type forcePrimaryCtxKey struct{}
+
+// UsePrimary makes the queries of db go to the primary, e.g. to read back a row right after writing it.
+// The result is a new session, safe to keep and reuse like db.
+func UsePrimary(db *gorm.DB) *gorm.DB {
+	return db.Set(forcePrimaryKey, true).Session(&gorm.Session{})
+}
+
+// WithPrimary marks ctx so queries run with db.WithContext(ctx) go to the primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryCtxKey{}, true)
+}
+
+// ReplicaStatus describes a read replica as last seen by a health check.
+type ReplicaStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+type dbReplica struct {
+	name    string
+	mu      sync.RWMutex
+	db      *gorm.DB
+	pool    gorm.ConnPool
+	healthy bool
+	err     error
+}
+
+func (r *dbReplica) connPool() (gorm.ConnPool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool, r.healthy && r.pool != nil
+}
+
+// fail takes the replica out of the rotation until a health check finds it up again.
+func (r *dbReplica) fail(cause error) {
+	r.mu.Lock()
+	wasHealthy := r.healthy
+	r.healthy, r.err = false, cause
+	r.mu.Unlock()
+	if wasHealthy {
+		logz.Warn("Read replica unhealthy, reads fall back", map[string]interface{}{
+			"context": "ReplicaResolver",
+			"replica": r.name,
+			"error":   cause.Error(),
+		})
+	}
+}
+
+// ReplicaResolver is a GORM plugin that sends reads (Find, First, Scan, Row...) to healthy replicas in
+// round robin and leaves writes, raw Exec calls and transactions on the primary. With no healthy
+// replica, reads fall back to the primary. A read failing with a connection error takes its replica
+// out of the rotation, until CheckHealth finds it up again, and is run again on the primary.
+// Repositories built on the primary *gorm.DB use it as is.
+type ReplicaResolver struct {
+	primary  gorm.ConnPool
+	replicas []*dbReplica
+	open     func(name string) (*gorm.DB, error)
+	next     uint64
+}
+
+// NewReplicaResolver routes reads to the named connections, opened with open. A replica that can't be
+// opened starts unhealthy and is opened again by CheckHealth.
+func NewReplicaResolver(names []string, open func(name string) (*gorm.DB, error)) *ReplicaResolver {
+	resolver := &ReplicaResolver{open: open}
+	for _, name := range names {
+		replica := &dbReplica{name: normalizeConnectionName(name)}
+		if db, openErr := open(replica.name); openErr != nil {
+			replica.err = openErr
+			logz.Warn("Read replica unavailable, reads go to the primary", map[string]interface{}{
+				"context": "ReplicaResolver",
+				"replica": replica.name,
+				"error":   openErr.Error(),
+			})
+		} else {
+			replica.db, replica.pool, replica.healthy = db, db.ConnPool, true
+		}
+		resolver.replicas = append(resolver.replicas, replica)
+	}
+	return resolver
+}
+
+func (r *ReplicaResolver) Name() string { return ReplicaResolverName }
+
+func (r *ReplicaResolver) Initialize(db *gorm.DB) error {
+	r.primary = db.ConnPool
+	if queryErr := db.Callback().Query().Before("gorm:query").Register(ReplicaResolverName+":query", r.route); queryErr != nil {
+		return queryErr
+	}
+	if failoverErr := db.Callback().Query().After("gorm:query").Before("gorm:preload").Register(ReplicaResolverName+":query_failover", r.failover(callbacks.Query)); failoverErr != nil {
+		return failoverErr
+	}
+	if rowErr := db.Callback().Row().Before("gorm:row").Register(ReplicaResolverName+":row", r.route); rowErr != nil {
+		return rowErr
+	}
+	return db.Callback().Row().After("gorm:row").Register(ReplicaResolverName+":row_failover", r.failover(callbacks.RowQuery))
+}
+
+func (r *ReplicaResolver) route(db *gorm.DB) {
+	if db.Error != nil || db.Statement.ConnPool != r.primary {
+		// Transactions and explicit connection pools keep the connection they run on.
+		return
+	}
+	if force, ok := db.Get(forcePrimaryKey); ok && force == true {
+		return
+	}
+	if ctx := db.Statement.Context; ctx != nil && ctx.Value(forcePrimaryCtxKey{}) == true {
+		return
+	}
+	if pool := r.pick(); pool != nil {
+		db.Statement.ConnPool = pool
+	}
+}
+
+// failover runs a read that failed on a replica with a connection error again on the primary.
+func (r *ReplicaResolver) failover(run func(db *gorm.DB)) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error == nil || db.Statement.ConnPool == r.primary || !isConnectionError(db.Error) {
+			return
+		}
+		replica := r.replicaOf(db.Statement.ConnPool)
+		if replica == nil {
+			return
+		}
+		replica.fail(db.Error)
+		db.Error = nil
+		db.Statement.ConnPool = r.primary
+		run(db)
+	}
+}
+func (r *ReplicaResolver) replicaOf(pool gorm.ConnPool) *dbReplica {
+	for _, replica := range r.replicas {
+		replica.mu.RLock()
+		found := replica.pool == pool
+		replica.mu.RUnlock()
+		if found {
+			return replica
+		}
+	}
+	return nil
+}
+
+func (r *ReplicaResolver) pick() gorm.ConnPool {
+	n := uint64(len(r.replicas))
+	if n == 0 {
+		return nil
+	}
+	start := atomic.AddUint64(&r.next, 1)
+	for i := uint64(0); i < n; i++ {
+		if pool, ok := r.replicas[(start+i)%n].connPool(); ok {
+			return pool
+		}
+	}
+	return nil
+}
+
+// CheckHealth pings every replica, opening the ones that failed to open, and updates which of them
+// take reads.
+func (r *ReplicaResolver) CheckHealth(ctx context.Context, timeout time.Duration) {
+	for _, replica := range r.replicas {
+		replica.mu.RLock()
+		db, wasHealthy := replica.db, replica.healthy
+		replica.mu.RUnlock()
+
+		var checkErr error
+		if db == nil {
+			db, checkErr = r.open(replica.name)
+		}
+		if checkErr == nil {
+			checkErr = pingDB(ctx, db, timeout)
+		}
+
+		replica.mu.Lock()
+		if db != nil {
+			replica.db, replica.pool = db, db.ConnPool
+		}
+		replica.healthy, replica.err = checkErr == nil, checkErr
+		replica.mu.Unlock()
+
+		if wasHealthy && checkErr != nil {
+			logz.Warn("Read replica unhealthy, reads fall back", map[string]interface{}{
+				"context": "ReplicaResolver",
+				"replica": replica.name,
+				"error":   checkErr.Error(),
+			})
+		} else if !wasHealthy && checkErr == nil {
+			logz.Info("Read replica healthy again", map[string]interface{}{
+				"context": "ReplicaResolver",
+				"replica": replica.name,
+			})
+		}
+	}
+}
+
+func (r *ReplicaResolver) Status() []ReplicaStatus {
+	status := make([]ReplicaStatus, 0, len(r.replicas))
+	for _, replica := range r.replicas {
+		replica.mu.RLock()
+		st := ReplicaStatus{Name: replica.name, Healthy: replica.healthy}
+		if replica.err != nil {
+			st.Error = replica.err.Error()
+		}
+		replica.mu.RUnlock()
+		status = append(status, st)
+	}
+	return status
+}
+
+// isConnectionError tells the errors of a connection that is lost or can't be made apart from the
+// errors of the query itself.
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, fragment := range []string{"connection refused", "connection reset", "broken pipe", "bad connection", "database is closed", "server closed the connection"} {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func pingDB(ctx context.Context, db *gorm.DB, timeout time.Duration) error {
+	if db == nil {
+		return fmt.Errorf("❌ Database connection is nil")
+	}
+	sqlDB, sqlDBErr := db.DB()
+	if sqlDBErr != nil {
+		return fmt.Errorf("❌ Erro ao obter a conexão SQL: %v", sqlDBErr)
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return sqlDB.PingContext(pingCtx)
+}
+
+// useReplicas plugs the replicas listed in database.replicas, names of `databases:` connections, into
+// a freshly opened primary connection.
+func (d *DatabaseServiceImpl) useReplicas(db *gorm.DB) error {
+	names := viper.GetStringSlice("database.replicas")
+	if len(names) == 0 {
+		d.setReplicas(nil)
+		return nil
+	}
+	resolver := NewReplicaResolver(names, d.connections().Get)
+	if useErr := db.Use(resolver); useErr != nil {
+		return fmt.Errorf("❌ Erro ao configurar as réplicas de leitura: %v", useErr)
+	}
+	d.setReplicas(resolver)
+	return nil
+}
+func (d *DatabaseServiceImpl) setReplicas(resolver *ReplicaResolver) {
+	d.dbMu.Lock()
+	defer d.dbMu.Unlock()
+	d.replicas = resolver
+}
+
+// Replicas returns the health of the configured read replicas; it is empty when reads aren't split.
+func (d *DatabaseServiceImpl) Replicas() []ReplicaStatus {
+	d.dbMu.RLock()
+	resolver := d.replicas
+	d.dbMu.RUnlock()
+	if resolver == nil {
+		return nil
+	}
+	return resolver.Status()
+}
+func (d *DatabaseServiceImpl) checkReplicas(ctx context.Context, timeout time.Duration) {
+	d.dbMu.RLock()
+	resolver := d.replicas
+	d.dbMu.RUnlock()
+	if resolver != nil {
+		resolver.CheckHealth(ctx, timeout)
+	}
+}
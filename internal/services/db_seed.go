@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/goccy/go-json"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Seed files live in the seeds directory (database.seeds_dir, by default next to the config file) and
+// are applied in file name order. Each is a YAML or JSON list of sets:
+//
+//	# 00_base.yaml
+//	- model: role            # a models.ModelRegistryMap name
+//	  key: [name]            # fields identifying an existing record; the primary key when omitted
+//	  records:
+//	    - {_ref: admin, id: "1", name: admin}
+//	- model: user
+//	  key: [username]
+//	  records:
+//	    - {username: admin, password: "${GKBXSRV_ADMIN_PASSWORD}", role_id: "@role.admin"}
+//
+// Records whose key already exists are left untouched, so seeding twice changes nothing. String values
+// are expanded with os.ExpandEnv; "@model.ref" is replaced by the primary key of the record named ref
+// by _ref, "@model.ref.field" by one of its fields, and a leading "@@" escapes a literal "@".
+const (
+	DefaultSeedsDirName = "seeds"
+	SeedRefField        = "_ref"
+)
+
+type SeedSet struct {
+	Model   string                   `json:"model" yaml:"model"`
+	Key     []string                 `json:"key,omitempty" yaml:"key,omitempty"`
+	Records []map[string]interface{} `json:"records" yaml:"records"`
+}
+
+type SeedResult struct {
+	Files   []string       `json:"files"`
+	Created map[string]int `json:"created"`
+	Skipped map[string]int `json:"skipped"`
+}
+
+// LoadSeedFiles reads every .yaml, .yml and .json file of dir in name order. A missing dir has no seeds.
+func LoadSeedFiles(dir string) ([]string, [][]SeedSet, error) {
+	entries, readErr := os.ReadDir(dir)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("error reading seeds directory: %v", readErr)
+	}
+	var files []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	sets := make([][]SeedSet, 0, len(files))
+	for _, file := range files {
+		data, fileErr := os.ReadFile(file)
+		if fileErr != nil {
+			return nil, nil, fmt.Errorf("error reading seed file %s: %v", file, fileErr)
+		}
+		var fileSets []SeedSet
+		var decodeErr error
+		if strings.EqualFold(filepath.Ext(file), ".json") {
+			decodeErr = json.Unmarshal(data, &fileSets)
+		} else {
+			decodeErr = yaml.Unmarshal(data, &fileSets)
+		}
+		if decodeErr != nil {
+			return nil, nil, fmt.Errorf("error decoding seed file %s: %v", file, decodeErr)
+		}
+		sets = append(sets, fileSets)
+	}
+	return files, sets, nil
+}
+
+// Seed applies the seed files of dir to db in one transaction.
+func Seed(db *gorm.DB, dir string) (SeedResult, error) {
+	result := SeedResult{Created: make(map[string]int), Skipped: make(map[string]int)}
+	files, sets, loadErr := LoadSeedFiles(dir)
+	if loadErr != nil {
+		return result, loadErr
+	}
+	result.Files = files
+	if len(files) == 0 {
+		return result, nil
+	}
+
+	seeder := &seeder{refs: make(map[string]seedRecord)}
+	txErr := asSystem(UsePrimary(db)).Transaction(func(tx *gorm.DB) error {
+		seeder.tx = tx
+		for i, fileSets := range sets {
+			for _, set := range fileSets {
+				if setErr := seeder.apply(set, &result); setErr != nil {
+					return fmt.Errorf("%s: %v", filepath.Base(files[i]), setErr)
+				}
+			}
+		}
+		return nil
+	})
+	return result, txErr
+}
+
+type seedRecord struct {
+	value  reflect.Value
+	schema *schema.Schema
+}
+
+type seeder struct {
+	tx   *gorm.DB
+	refs map[string]seedRecord
+}
+
+func (s *seeder) apply(set SeedSet, result *SeedResult) error {
+	model := strings.ToLower(strings.TrimSpace(set.Model))
+	typ, ok := models.ModelRegistryMap[model]
+	if !ok {
+		return fmt.Errorf("model %s not found", set.Model)
+	}
+	stmt := &gorm.Statement{DB: s.tx}
+	if parseErr := stmt.Parse(reflect.New(typ).Interface()); parseErr != nil {
+		return fmt.Errorf("model %s: %v", model, parseErr)
+	}
+	sch := stmt.Schema
+
+	for i, raw := range set.Records {
+		label := fmt.Sprintf("%s record %d", model, i+1)
+		record := make(map[string]interface{}, len(raw))
+		ref := ""
+		for name, value := range raw {
+			if name == SeedRefField {
+				ref = fmt.Sprint(value)
+				continue
+			}
+			field := seedField(sch, name)
+			if field == nil {
+				return fmt.Errorf("%s: model %s has no field %s", label, model, name)
+			}
+			resolved, resolveErr := s.resolve(value, field)
+			if resolveErr != nil {
+				return fmt.Errorf("%s: %s: %v", label, name, resolveErr)
+			}
+			record[name] = resolved
+		}
+		if ref != "" {
+			label = model + "." + ref
+		}
+
+		instance := reflect.New(typ)
+		data, marshalErr := json.Marshal(record)
+		if marshalErr != nil {
+			return fmt.Errorf("%s: %v", label, marshalErr)
+		}
+		if unmarshalErr := json.Unmarshal(data, instance.Interface()); unmarshalErr != nil {
+			return fmt.Errorf("%s: %v", label, unmarshalErr)
+		}
+
+		conditions, keyErr := seedKey(sch, set.Key, instance)
+		if keyErr != nil {
+			return fmt.Errorf("%s: %v", label, keyErr)
+		}
+		existing := reflect.New(typ)
+		// A soft-deleted copy counts as existing: seeding never brings deleted rows back.
+		findErr := s.tx.Unscoped().Where(conditions).Limit(1).Find(existing.Interface())
+		if findErr.Error != nil {
+			return fmt.Errorf("%s: %v", label, findErr.Error)
+		}
+		if findErr.RowsAffected > 0 {
+			result.Skipped[model]++
+		} else {
+			if createErr := s.tx.Create(instance.Interface()).Error; createErr != nil {
+				return fmt.Errorf("%s: %v", label, createErr)
+			}
+			result.Created[model]++
+			// Read the row back: hooks may have changed the instance after it was written.
+			existing = reflect.New(typ)
+			if reloadErr := s.tx.Unscoped().Where(conditions).First(existing.Interface()).Error; reloadErr != nil {
+				return fmt.Errorf("%s: %v", label, reloadErr)
+			}
+		}
+		if ref != "" {
+			s.refs[model+"."+ref] = seedRecord{value: existing.Elem(), schema: sch}
+		}
+	}
+	return nil
+}
+
+// seedField finds a field by JSON name, column or Go name.
+func seedField(sch *schema.Schema, name string) *schema.Field {
+	for _, field := range sch.Fields {
+		if jsonName := strings.Split(field.Tag.Get("json"), ",")[0]; jsonName == name {
+			return field
+		}
+	}
+	return sch.LookUpField(name)
+}
+
+// seedKey builds the conditions that find an existing copy of instance.
+func seedKey(sch *schema.Schema, key []string, instance reflect.Value) (map[string]interface{}, error) {
+	var fields []*schema.Field
+	if len(key) == 0 {
+		for _, field := range sch.PrimaryFields {
+			if _, zero := field.ValueOf(context.Background(), instance.Elem()); zero {
+				return nil, fmt.Errorf("set a key or the primary key %s, so the record can be found again", field.DBName)
+			}
+			fields = append(fields, field)
+		}
+	}
+	for _, name := range key {
+		field := seedField(sch, name)
+		if field == nil {
+			return nil, fmt.Errorf("key field %s not found", name)
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("model has no primary key; set a key")
+	}
+	conditions := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		value, _ := field.ValueOf(context.Background(), instance.Elem())
+		conditions[field.DBName] = value
+	}
+	return conditions, nil
+}
+
+func (s *seeder) resolve(value interface{}, field *schema.Field) (interface{}, error) {
+	str, isString := value.(string)
+	if !isString {
+		return value, nil
+	}
+	if strings.HasPrefix(str, "@@") {
+		return str[1:], nil
+	}
+	if !strings.HasPrefix(str, "@") {
+		return coerceSeedValue(os.ExpandEnv(str), field)
+	}
+	parts := strings.SplitN(str[1:], ".", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid reference %s, expected @model.ref or @model.ref.field", str)
+	}
+	target, ok := s.refs[strings.ToLower(parts[0])+"."+parts[1]]
+	if !ok {
+		return nil, fmt.Errorf("reference %s not seeded yet", str)
+	}
+	refField := target.schema.PrioritizedPrimaryField
+	if len(parts) == 3 {
+		refField = seedField(target.schema, parts[2])
+	}
+	if refField == nil {
+		return nil, fmt.Errorf("reference %s: field not found", str)
+	}
+	resolved := target.value.FieldByIndex(refField.StructField.Index).Interface()
+	return coerceSeedValue(resolved, field)
+}
+
+// coerceSeedValue converts numbers to strings and numeric strings to numbers when the target field
+// needs it, e.g. a string role ID into the uint UserImpl.RoleID.
+func coerceSeedValue(value interface{}, field *schema.Field) (interface{}, error) {
+	kind := field.IndirectFieldType.Kind()
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if str, ok := value.(string); ok {
+			return strconv.ParseInt(strings.TrimSpace(str), 10, 64)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if str, ok := value.(string); ok {
+			return strconv.ParseUint(strings.TrimSpace(str), 10, 64)
+		}
+	case reflect.Float32, reflect.Float64:
+		if str, ok := value.(string); ok {
+			return strconv.ParseFloat(strings.TrimSpace(str), 64)
+		}
+	case reflect.Bool:
+		if str, ok := value.(string); ok {
+			return strconv.ParseBool(strings.TrimSpace(str))
+		}
+	case reflect.String:
+		if _, ok := value.(string); !ok && value != nil {
+			return fmt.Sprint(value), nil
+		}
+	}
+	return value, nil
+}
+
+// SeedsDir resolves database.seeds_dir, falling back to the seeds directory next to the config file.
+func (d *DatabaseServiceImpl) SeedsDir() string {
+	if dir := viper.GetString("database.seeds_dir"); dir != "" {
+		return os.ExpandEnv(dir)
+	}
+	if cfgFile := viper.ConfigFileUsed(); cfgFile != "" {
+		return filepath.Join(filepath.Dir(cfgFile), DefaultSeedsDirName)
+	}
+	if d.fs != nil {
+		return filepath.Join(d.fs.GetDefaultConfigDir(), DefaultSeedsDirName)
+	}
+	return DefaultSeedsDirName
+}
+
+// Seed applies the seed files of SeedsDir to the primary connection.
+func (d *DatabaseServiceImpl) Seed() (SeedResult, error) {
+	db, dbErr := d.GetDB()
+	if dbErr != nil {
+		return SeedResult{}, dbErr
+	}
+	return Seed(db, d.SeedsDir())
+}
+
+const defaultSeedFileName = "00_base.yaml"
+
+const defaultSeedTemplate = `# Base data applied by "gkbxsrv database seed". Records are matched by their key and never
+# overwritten, so applying this file again changes nothing.
+# The admin password below is in plain text: change it after the first login and remove it from
+# this file.
+- model: role
+  key: [name]
+  records:
+    - {_ref: admin, id: "1", name: admin, description: Administrators, active: true}
+    - {_ref: user, id: "2", name: user, description: Users, active: true}
+- model: user
+  key: [username]
+  records:
+    - _ref: admin
+      name: Administrator
+      username: admin
+      email: admin@kubex.local
+      password: %s
+      role_id: "@role.admin"
+      active: true
+- model: warehouse
+  key: [code]
+  records:
+    - {_ref: main, code: MAIN, name: Main warehouse, country: Brasil, active: true}
+- model: product
+  key: [name]
+  records:
+    - {name: Sample product A, depart: General, category: Samples, price: 10.50, cost: 6.30, stock: 100, balance: 100}
+    - {name: Sample product B, depart: General, category: Samples, price: 25.00, cost: 15.00, stock: 40, balance: 40}
+`
+
+// WriteDefaultSeeds writes the base seed file (roles, an admin user with adminPassword, a warehouse
+// and sample products) to dir, unless dir already has seed files. It returns the path written, if any.
+// The password stays in the file in plain text until it is removed from it.
+func WriteDefaultSeeds(dir, adminPassword string) (string, error) {
+	files, _, loadErr := LoadSeedFiles(dir)
+	if loadErr != nil {
+		return "", loadErr
+	}
+	if len(files) > 0 {
+		return "", nil
+	}
+	if mkdirErr := os.MkdirAll(dir, 0700); mkdirErr != nil {
+		return "", fmt.Errorf("error creating seeds directory: %v", mkdirErr)
+	}
+	password, marshalErr := json.Marshal(adminPassword)
+	if marshalErr != nil {
+		return "", marshalErr
+	}
+	path := filepath.Join(dir, defaultSeedFileName)
+	// The file holds the admin password, like the config file holds the database one.
+	if writeErr := os.WriteFile(path, []byte(fmt.Sprintf(defaultSeedTemplate, password)), 0600); writeErr != nil {
+		return "", fmt.Errorf("error writing seed file: %v", writeErr)
+	}
+	return path, nil
+}
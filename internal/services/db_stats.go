@@ -0,0 +1,61 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PoolStats is a sample of the primary connection pool. Delta holds the change since the previous
+// sample; on the first call it covers everything since the pool was opened. Gauges such as
+// OpenConnections or InUse may go negative in Delta, counters such as WaitCount only grow.
+type PoolStats struct {
+	Current   sql.DBStats   `json:"current"`
+	Delta     sql.DBStats   `json:"delta"`
+	SampledAt time.Time     `json:"sampled_at"`
+	Interval  time.Duration `json:"interval"`
+}
+
+// Stats samples the pool of the primary connection and returns it along with the delta to the
+// previous sample.
+func (d *DatabaseServiceImpl) Stats() (PoolStats, error) {
+	db, dbErr := d.GetDB()
+	if dbErr != nil {
+		return PoolStats{}, dbErr
+	}
+	sqlDB, sqlDBErr := db.DB()
+	if sqlDBErr != nil {
+		return PoolStats{}, fmt.Errorf("❌ Erro ao obter a conexão SQL: %v", sqlDBErr)
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	now := time.Now()
+	d.lastStats = d.dbStats
+	d.dbStats = sqlDB.Stats()
+	stats := PoolStats{
+		Current:   d.dbStats,
+		Delta:     diffDBStats(d.dbStats, d.lastStats),
+		SampledAt: now,
+	}
+	if !d.statsAt.IsZero() {
+		stats.Interval = now.Sub(d.statsAt)
+	}
+	d.statsAt = now
+	return stats, nil
+}
+
+func diffDBStats(current, previous sql.DBStats) sql.DBStats {
+	return sql.DBStats{
+		MaxOpenConnections: current.MaxOpenConnections - previous.MaxOpenConnections,
+		OpenConnections:    current.OpenConnections - previous.OpenConnections,
+		InUse:              current.InUse - previous.InUse,
+		Idle:               current.Idle - previous.Idle,
+		WaitCount:          current.WaitCount - previous.WaitCount,
+		WaitDuration:       current.WaitDuration - previous.WaitDuration,
+		MaxIdleClosed:      current.MaxIdleClosed - previous.MaxIdleClosed,
+		MaxIdleTimeClosed:  current.MaxIdleTimeClosed - previous.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  current.MaxLifetimeClosed - previous.MaxLifetimeClosed,
+	}
+}
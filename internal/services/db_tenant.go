@@ -0,0 +1,210 @@
+package services
+
+import (
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+// With database.multi_tenant enabled, every query, update and delete of a model embedding
+// models.Tenant is restricted to the tenant of its context, and creates are stamped with it. A
+// statement on such a model without a tenant fails with models.ErrTenantRequired unless it runs with a
+// models.SystemContext. Raw SQL is passed through as written.
+const tenantHook = "gkbxsrv:tenant"
+
+// useTenants registers the tenant callbacks. Updates and deletes are scoped before the Before hooks,
+// so the audit snapshot only sees the rows of the tenant.
+func useTenants(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if createErr := callbacks.Create().Before("gorm:before_create").Register(tenantHook+":create", tenantCreate); createErr != nil {
+		return createErr
+	}
+	if queryErr := callbacks.Query().Before("gorm:query").Register(tenantHook+":query", tenantQuery); queryErr != nil {
+		return queryErr
+	}
+	if rowErr := callbacks.Row().Before("gorm:row").Register(tenantHook+":row", tenantQuery); rowErr != nil {
+		return rowErr
+	}
+	if updateErr := callbacks.Update().Before("gorm:before_update").Register(tenantHook+":update", tenantUpdate); updateErr != nil {
+		return updateErr
+	}
+	return callbacks.Delete().Before("gorm:before_delete").Register(tenantHook+":delete", tenantDelete)
+}
+
+// tenantsEnabled tells whether db isolates tenants, i.e. was opened with database.multi_tenant.
+func tenantsEnabled(db *gorm.DB) bool {
+	return db.Callback().Query().Get(tenantHook+":query") != nil
+}
+
+// asSystem lifts tenant isolation on db, for the tools that work on the rows of every tenant such as
+// migrations, backups and seeds.
+func asSystem(db *gorm.DB) *gorm.DB {
+	return db.WithContext(models.SystemContext(db.Statement.Context))
+}
+
+// tenantOf returns the tenant column of the statement and the tenant it runs in; ok is false when the
+// statement is not scoped, or failed for lack of a tenant.
+func tenantOf(tx *gorm.DB) (field *schema.Field, tenantID string, ok bool) {
+	if tx.Error != nil || tx.Statement.SQL.Len() > 0 {
+		return nil, "", false
+	}
+	if field = tenantFieldOf(tx); field == nil || models.IsSystemContext(tx.Statement.Context) {
+		return nil, "", false
+	}
+	if tenantID, ok = models.TenantFromContext(tx.Statement.Context); !ok {
+		_ = tx.AddError(models.ErrTenantRequired)
+		return nil, "", false
+	}
+	return field, tenantID, true
+}
+
+// tenantFieldOf finds the tenant column of the model, or of the registered model whose table a
+// db.Table statement reads.
+func tenantFieldOf(tx *gorm.DB) *schema.Field {
+	if tx.Statement.Schema != nil {
+		return models.TenantField(tx.Statement.Schema)
+	}
+	if tx.Statement.Table == "" {
+		return nil
+	}
+	for _, typ := range models.ModelRegistryMap {
+		stmt := &gorm.Statement{DB: tx}
+		if stmt.Parse(reflect.New(typ).Interface()) == nil && stmt.Schema.Table == tx.Statement.Table {
+			return models.TenantField(stmt.Schema)
+		}
+	}
+	return nil
+}
+
+func tenantCreate(tx *gorm.DB) {
+	field, tenantID, ok := tenantOf(tx)
+	if !ok {
+		return
+	}
+	tenantStamp(tx, field, tenantID, tx.Statement.ReflectValue, true)
+	tenantStampMap(tx, field, tenantID, tx.Statement.Dest, true)
+
+	// An upsert must not update the row of another tenant holding the same key.
+	if c, found := tx.Statement.Clauses["ON CONFLICT"]; found {
+		onConflict, isOnConflict := c.Expression.(clause.OnConflict)
+		if !isOnConflict || onConflict.DoNothing || (!onConflict.UpdateAll && len(onConflict.DoUpdates) == 0) {
+			return
+		}
+		switch tx.Dialector.Name() {
+		case "mysql", "sqlserver":
+			_ = tx.AddError(fmt.Errorf("error creating %s: upserts are not supported on %s with multi-tenant isolation", tx.Statement.Table, tx.Dialector.Name()))
+			return
+		}
+		onConflict.Where.Exprs = append(onConflict.Where.Exprs, tenantCondition(field, tenantID))
+		tx.Statement.AddClause(onConflict)
+	}
+}
+
+func tenantQuery(tx *gorm.DB) {
+	if field, tenantID, ok := tenantOf(tx); ok {
+		tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{tenantCondition(field, tenantID)}})
+	}
+}
+
+func tenantUpdate(tx *gorm.DB) {
+	field, tenantID, ok := tenantOf(tx)
+	if !ok {
+		return
+	}
+	// Save writes every column: a model without a tenant keeps the one of the context.
+	tenantStamp(tx, field, tenantID, tx.Statement.ReflectValue, true)
+	tenantStamp(tx, field, tenantID, reflect.ValueOf(tx.Statement.Dest), false)
+	tenantStampMap(tx, field, tenantID, tx.Statement.Dest, false)
+	tenantWhere(tx, field, tenantID)
+}
+
+func tenantDelete(tx *gorm.DB) {
+	field, tenantID, ok := tenantOf(tx)
+	if !ok {
+		return
+	}
+	tenantStamp(tx, field, tenantID, tx.Statement.ReflectValue, false)
+	tenantWhere(tx, field, tenantID)
+}
+
+// tenantWhere scopes an update or a delete. Statements without conditions are left to GORM, which
+// rejects them unless AllowGlobalUpdate is set.
+func tenantWhere(tx *gorm.DB, field *schema.Field, tenantID string) {
+	_, hasWhere := tx.Statement.Clauses["WHERE"]
+	if !hasWhere && !tx.AllowGlobalUpdate && !tenantHasKey(tx) {
+		return
+	}
+	tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{tenantCondition(field, tenantID)}})
+}
+
+func tenantHasKey(tx *gorm.DB) bool {
+	pk := tx.Statement.Schema
+	if pk == nil || pk.PrioritizedPrimaryField == nil {
+		return false
+	}
+	found := false
+	auditEach(tx.Statement.ReflectValue, func(rv reflect.Value) {
+		if rv.Type() != pk.ModelType {
+			return
+		}
+		if _, isZero := pk.PrioritizedPrimaryField.ValueOf(tx.Statement.Context, rv); !isZero {
+			found = true
+		}
+	})
+	return found
+}
+
+func tenantCondition(field *schema.Field, tenantID string) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenantID}
+}
+
+// tenantStamp sets the tenant of the models in rv when fill is set and they have none, and fails the
+// statement when one belongs to another tenant.
+func tenantStamp(tx *gorm.DB, field *schema.Field, tenantID string, rv reflect.Value, fill bool) {
+	auditEach(rv, func(item reflect.Value) {
+		if item.Type() != field.Schema.ModelType {
+			return
+		}
+		current, isZero := field.ValueOf(tx.Statement.Context, item)
+		switch {
+		case isZero && fill && item.CanAddr():
+			if setErr := field.Set(tx.Statement.Context, item, tenantID); setErr != nil {
+				_ = tx.AddError(fmt.Errorf("error setting tenant: %v", setErr))
+			}
+		case !isZero && current != tenantID:
+			_ = tx.AddError(models.ErrTenantMismatch)
+		}
+	})
+}
+
+// tenantStampMap does the same for creates and updates from maps.
+func tenantStampMap(tx *gorm.DB, field *schema.Field, tenantID string, dest interface{}, fill bool) {
+	var rows []map[string]interface{}
+	switch values := dest.(type) {
+	case map[string]interface{}:
+		rows = []map[string]interface{}{values}
+	case *map[string]interface{}:
+		rows = []map[string]interface{}{*values}
+	case []map[string]interface{}:
+		rows = values
+	case *[]map[string]interface{}:
+		rows = *values
+	}
+	for _, row := range rows {
+		key := field.DBName
+		if _, found := row[key]; !found {
+			key = field.Name
+		}
+		value, found := row[key]
+		if fill && (!found || value == nil || value == "") {
+			row[field.DBName] = tenantID
+			continue
+		}
+		if found && fmt.Sprint(value) != tenantID {
+			_ = tx.AddError(models.ErrTenantMismatch)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	glb "github.com/faelmori/gkbxsrv/internal/globals"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	dir := t.TempDir()
+	db, openErr := OpenConnection(glb.Database{Driver: "sqlite", Path: dir + "/tenants.db", MultiTenant: true})
+	if openErr != nil {
+		t.Fatalf("OpenConnection() error = %v", openErr)
+	}
+	if _, upErr := NewMigrator(db, dir).Up(0); upErr != nil {
+		t.Fatalf("Up() error = %v", upErr)
+	}
+	if !tenantsEnabled(db) {
+		t.Fatal("tenantsEnabled() = false, want true")
+	}
+	newProduct := func(name string) *models.Product {
+		return &models.Product{Name: name, Depart: "D", Category: "C", Price: 2, Cost: 1, Stock: 1, Reserve: 1, Balance: 1}
+	}
+	tenantA := db.WithContext(models.WithTenant(context.Background(), "a"))
+	tenantB := db.WithContext(models.WithTenant(context.Background(), "b"))
+	system := asSystem(db)
+
+	productA := newProduct("A")
+	if err := tenantA.Create(productA).Error; err != nil || productA.TenantID != "a" {
+		t.Fatalf("Create() = tenant %q, %v, want a", productA.TenantID, err)
+	}
+	if err := tenantB.Create(newProduct("B")).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		run      func() *gorm.DB
+		wantErr  error
+		wantRows int64
+	}{
+		{"query without tenant", func() *gorm.DB { return db.Find(&[]models.Product{}) }, models.ErrTenantRequired, 0},
+		{"create without tenant", func() *gorm.DB { return db.Create(newProduct("X")) }, models.ErrTenantRequired, 0},
+		{"create for another tenant", func() *gorm.DB {
+			product := newProduct("X")
+			product.TenantID = "b"
+			return tenantA.Create(product)
+		}, models.ErrTenantMismatch, 0},
+		{"query own tenant", func() *gorm.DB { return tenantA.Find(&[]models.Product{}) }, nil, 1},
+		{"query another tenant by key", func() *gorm.DB { return tenantB.Find(&[]models.Product{}, productA.ID) }, nil, 0},
+		{"count own tenant", func() *gorm.DB {
+			var count int64
+			tx := tenantA.Model(&models.Product{}).Count(&count)
+			tx.RowsAffected = count
+			return tx
+		}, nil, 1},
+		{"update from another tenant", func() *gorm.DB {
+			return tenantB.Model(&models.Product{}).Where("id = ?", productA.ID).Update("name", "stolen")
+		}, nil, 0},
+		{"update with a map for another tenant", func() *gorm.DB {
+			return tenantA.Model(&models.Product{}).Where("id = ?", productA.ID).Updates(map[string]interface{}{"tenant_id": "b"})
+		}, models.ErrTenantMismatch, 0},
+		{"save a row of another tenant", func() *gorm.DB {
+			product := *productA
+			product.Name = "stolen"
+			return tenantB.Save(&product)
+		}, models.ErrTenantMismatch, 0},
+		{"delete from another tenant", func() *gorm.DB {
+			return tenantB.Where("id = ?", productA.ID).Delete(&models.Product{})
+		}, nil, 0},
+		{"shared model without tenant", func() *gorm.DB { return db.Find(&[]models.RoleImpl{}) }, nil, 0},
+		{"system sees every tenant", func() *gorm.DB { return system.Find(&[]models.Product{}) }, nil, 2},
+		{"update own tenant", func() *gorm.DB {
+			return tenantA.Model(&models.Product{}).Where("id = ?", productA.ID).Update("name", "renamed")
+		}, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := tt.run()
+			if !errors.Is(tx.Error, tt.wantErr) || (tt.wantErr == nil && tx.RowsAffected != tt.wantRows) {
+				t.Errorf("error, rows = %v, %d, want %v, %d", tx.Error, tx.RowsAffected, tt.wantErr, tt.wantRows)
+			}
+		})
+	}
+
+	var stored models.Product
+	if err := system.First(&stored, productA.ID).Error; err != nil || stored.Name != "renamed" || stored.TenantID != "a" {
+		t.Errorf("product after isolation = %q of %q, %v, want renamed of a", stored.Name, stored.TenantID, err)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Retries of WithTx: how many times a transaction is run again, and the backoff before the first retry.
+const (
+	TxMaxRetries   = 3
+	TxRetryBackoff = 25 * time.Millisecond // doubled on each retry, with jitter
+)
+
+// Repos hands out the model repositories bound to one connection or transaction.
+type Repos interface {
+	DB() *gorm.DB
+	Users() models.UserRepo
+	Roles() models.RoleRepo
+	Products() *models.ProductRepoImpl
+	ProductVariants() *models.ProductVariantRepoImpl
+	Categories() *models.CategoryRepoImpl
+	Customers() models.CustomerRepo
+	Orders() *models.OrderRepoImpl
+	Warehouses() *models.WarehouseRepoImpl
+	Inventory() *models.InventoryRepoImpl
+	InventoryMovements() *models.InventoryMovementRepoImpl
+	// WithTx runs fn in a savepoint when the repositories are bound to a transaction, and in a new
+	// transaction otherwise. A savepoint rolled back by fn's error leaves the outer transaction usable.
+	WithTx(ctx context.Context, fn func(tx Repos) error) error
+}
+
+type reposImpl struct {
+	db *gorm.DB
+}
+
+// NewRepos binds the model repositories to db.
+func NewRepos(db *gorm.DB) Repos {
+	return &reposImpl{db: db}
+}
+
+func (r *reposImpl) DB() *gorm.DB                          { return r.db }
+func (r *reposImpl) Users() models.UserRepo                { return models.NewUserRepo(r.db) }
+func (r *reposImpl) Roles() models.RoleRepo                { return models.NewRoleRepo(r.db) }
+func (r *reposImpl) Products() *models.ProductRepoImpl     { return models.NewGormProductRepo(r.db) }
+func (r *reposImpl) Customers() models.CustomerRepo        { return models.NewCustomerRepo(r.db) }
+func (r *reposImpl) Orders() *models.OrderRepoImpl         { return models.NewOrderRepo(r.db) }
+func (r *reposImpl) Warehouses() *models.WarehouseRepoImpl { return models.NewWarehouseRepo(r.db) }
+func (r *reposImpl) Inventory() *models.InventoryRepoImpl  { return models.NewInventoryRepo(r.db) }
+func (r *reposImpl) InventoryMovements() *models.InventoryMovementRepoImpl {
+	return models.NewInventoryMovementRepo(r.db)
+}
+func (r *reposImpl) ProductVariants() *models.ProductVariantRepoImpl {
+	return models.NewProductVariantRepo(r.db)
+}
+func (r *reposImpl) Categories() *models.CategoryRepoImpl { return models.NewCategoryRepo(r.db) }
+
+func (r *reposImpl) WithTx(ctx context.Context, fn func(tx Repos) error) error {
+	if committer, inTx := r.db.Statement.ConnPool.(gorm.TxCommitter); inTx && committer != nil {
+		// GORM turns a transaction inside a transaction into a savepoint.
+		return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(NewRepos(tx))
+		})
+	}
+	return WithTx(r.db, ctx, fn)
+}
+
+// WithTx runs fn as a unit of work in one transaction of db on the primary. fn gets Repos bound to the
+// transaction, so writes through several repositories commit or roll back together; Repos.WithTx
+// nests a savepoint. ctx is the context of every statement of the transaction, e.g. with a tenant or
+// an actor. Transactions aborted by a serialization failure or a deadlock are run again from the
+// start, up to TxMaxRetries times, so fn must not have effects outside the database.
+func WithTx(db *gorm.DB, ctx context.Context, fn func(tx Repos) error, opts ...*sql.TxOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db = UsePrimary(db).WithContext(ctx)
+	backoff := TxRetryBackoff
+	for attempt := 0; ; attempt++ {
+		txErr := db.Transaction(func(tx *gorm.DB) error {
+			return fn(NewRepos(tx))
+		}, opts...)
+		if txErr == nil || attempt >= TxMaxRetries || !isRetryableTxError(txErr) {
+			return txErr
+		}
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error retrying transaction: %v (last error: %v)", ctx.Err(), txErr)
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryableTxError tells the errors of a transaction that may succeed when run again: Postgres
+// serialization failures (40001) and deadlocks (40P01), MySQL deadlocks (1213) and lock wait timeouts
+// (1205), SQL Server deadlock victims (1205) and snapshot conflicts (3960), and busy SQLite databases.
+func isRetryableTxError(err error) bool {
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		switch state.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+	var mssqlErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &mssqlErr) {
+		return mssqlErr.SQLErrorNumber() == 1205 || mssqlErr.SQLErrorNumber() == 3960
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "database is locked") || strings.Contains(message, "database table is locked")
+}
+
+func (d *DatabaseServiceImpl) WithTx(ctx context.Context, fn func(tx Repos) error) error {
+	db, dbErr := d.GetDB()
+	if dbErr != nil {
+		return dbErr
+	}
+	return WithTx(db, ctx, fn)
+}
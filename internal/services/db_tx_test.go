@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sql state " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+type sqlServerError int32
+
+func (e sqlServerError) Error() string         { return fmt.Sprintf("sql server error %d", int32(e)) }
+func (e sqlServerError) SQLErrorNumber() int32 { return int32(e) }
+
+func TestIsRetryableTxError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"postgres serialization failure", sqlStateError("40001"), true},
+		{"postgres deadlock", sqlStateError("40P01"), true},
+		{"postgres unique violation", sqlStateError("23505"), false},
+		{"wrapped postgres deadlock", fmt.Errorf("error saving: %w", sqlStateError("40P01")), true},
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213}, true},
+		{"mysql lock wait timeout", &mysql.MySQLError{Number: 1205}, true},
+		{"mysql duplicate entry", &mysql.MySQLError{Number: 1062}, false},
+		{"sql server deadlock victim", sqlServerError(1205), true},
+		{"sql server snapshot conflict", sqlServerError(3960), true},
+		{"sql server constraint", sqlServerError(2627), false},
+		{"sqlite busy", errors.New("database is locked (5) (SQLITE_BUSY)"), true},
+		{"sqlite table locked", errors.New("Database table is locked"), true},
+		{"other error", errors.New("record not found"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableTxError(tt.err); got != tt.want {
+				t.Errorf("isRetryableTxError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
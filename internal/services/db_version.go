@@ -0,0 +1,142 @@
+package services
+
+import (
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+// Optimistic locking of the models embedding models.Versioned; see there. The checked version is kept
+// on the statement between the callbacks.
+const (
+	versionHook = "gkbxsrv:version"
+	versionKey  = "gkbxsrv:version_expected"
+)
+
+// useVersioning registers the optimistic locking callbacks. The version condition is added before the
+// Before hooks, so the audit snapshot is only taken of the row at that version.
+func useVersioning(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if createErr := callbacks.Create().Before("gorm:before_create").Register(versionHook+":create", versionCreate); createErr != nil {
+		return createErr
+	}
+	if beforeErr := callbacks.Update().Before("gorm:before_update").Register(versionHook+":before_update", versionBeforeUpdate); beforeErr != nil {
+		return beforeErr
+	}
+	return callbacks.Update().After("gorm:update").Before("gorm:after_update").Register(versionHook+":update", versionUpdated)
+}
+
+func versionFieldOf(tx *gorm.DB) *schema.Field {
+	if tx.Error != nil || tx.DryRun || tx.Statement.Schema == nil || tx.Statement.SQL.Len() > 0 {
+		return nil
+	}
+	return models.VersionField(tx.Statement.Schema)
+}
+
+// versionCreate starts new rows at version 1.
+func versionCreate(tx *gorm.DB) {
+	field := versionFieldOf(tx)
+	if field == nil {
+		return
+	}
+	auditEach(tx.Statement.ReflectValue, func(rv reflect.Value) {
+		if rv.Type() != field.Schema.ModelType || !rv.CanAddr() {
+			return
+		}
+		if _, isZero := field.ValueOf(tx.Statement.Context, rv); isZero {
+			_ = field.Set(tx.Statement.Context, rv, int64(1))
+		}
+	})
+}
+
+func versionBeforeUpdate(tx *gorm.DB) {
+	field := versionFieldOf(tx)
+	if field == nil {
+		return
+	}
+	column := clause.Column{Name: field.DBName}
+	rv := tx.Statement.ReflectValue
+	var expected int64
+	if rv.Kind() == reflect.Struct && rv.Type() == field.Schema.ModelType && rv.CanAddr() {
+		expected = field.ReflectValueOf(tx.Statement.Context, rv).Int()
+	}
+	if expected == 0 {
+		// Unknown version: not checked. Column updates still move the row to the next version, and a
+		// zero version is never written.
+		if values, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+			delete(values, field.Name)
+			values[field.DBName] = gorm.Expr("? + 1", column)
+		} else {
+			tx.Statement.Omits = append(tx.Statement.Omits, field.DBName)
+		}
+		return
+	}
+
+	tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: expected}}})
+	versionSet(tx, field, expected+1)
+	if values, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+		delete(values, field.Name)
+		values[field.DBName] = expected + 1
+	}
+	if len(tx.Statement.Selects) > 0 && !versionSelected(tx.Statement.Selects, field) {
+		tx.Statement.Selects = append(tx.Statement.Selects, field.DBName)
+	}
+	tx.InstanceSet(versionKey, expected)
+}
+
+// versionUpdated fails an update that matched no row at the expected version. The model keeps that
+// version, so it can be reloaded and the update tried again.
+func versionUpdated(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(versionKey)
+	if !ok {
+		return
+	}
+	expected := value.(int64)
+	if tx.Error == nil && tx.Statement.RowsAffected > 0 {
+		return
+	}
+	field := models.VersionField(tx.Statement.Schema)
+	versionSet(tx, field, expected)
+	if tx.Error != nil {
+		return
+	}
+
+	var id interface{}
+	if pk := tx.Statement.Schema.PrioritizedPrimaryField; pk != nil {
+		id, _ = pk.ValueOf(tx.Statement.Context, tx.Statement.ReflectValue)
+		var count int64
+		countErr := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Unscoped().
+			Model(reflect.New(tx.Statement.Schema.ModelType).Interface()).
+			Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Value: id}).
+			Count(&count).Error
+		if countErr == nil && count == 0 {
+			_ = tx.AddError(gorm.ErrRecordNotFound)
+			return
+		}
+	}
+	_ = tx.AddError(&models.ConflictError{Table: tx.Statement.Schema.Table, ID: id, Version: expected})
+}
+
+// versionSet sets the version of the updated model, and of a distinct model given to Updates.
+func versionSet(tx *gorm.DB, field *schema.Field, version int64) {
+	targets := []reflect.Value{tx.Statement.ReflectValue}
+	if dest := reflect.Indirect(reflect.ValueOf(tx.Statement.Dest)); dest.IsValid() {
+		targets = append(targets, dest)
+	}
+	for _, rv := range targets {
+		if rv.Kind() == reflect.Struct && rv.Type() == field.Schema.ModelType && rv.CanAddr() {
+			_ = field.Set(tx.Statement.Context, rv, version)
+		}
+	}
+}
+
+func versionSelected(selects []string, field *schema.Field) bool {
+	for _, name := range selects {
+		if name == "*" || name == field.DBName || name == field.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestVersionLocking(t *testing.T) {
+	db := openOrderTestDB(t)
+	product := &models.Product{Name: "P", Depart: "D", Category: "C", Price: 2, Cost: 1, Stock: 1, Reserve: 1, Balance: 1}
+	if err := db.Create(product).Error; err != nil || product.Version != 1 {
+		t.Fatalf("Create() = version %d, %v, want 1", product.Version, err)
+	}
+	stale := *product
+	gone := &models.Product{Name: "G", Depart: "D", Category: "C", Price: 2, Cost: 1, Stock: 1, Reserve: 1, Balance: 1}
+	if err := db.Create(gone).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	goneCopy := *gone
+	if err := db.Unscoped().Delete(gone).Error; err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		run         func() (int64, error)
+		wantErr     error
+		wantVersion int64
+	}{
+		{"save at the current version", func() (int64, error) {
+			product.Name = "renamed"
+			err := db.Save(product).Error
+			return product.Version, err
+		}, nil, 2},
+		{"save at a stale version", func() (int64, error) {
+			stale.Name = "lost"
+			err := db.Save(&stale).Error
+			return stale.Version, err
+		}, models.ErrConflict, 1},
+		{"column update without a version", func() (int64, error) {
+			if err := db.Model(&models.Product{}).Where("id = ?", product.ID).Updates(map[string]interface{}{"name": "unchecked"}).Error; err != nil {
+				return 0, err
+			}
+			var stored models.Product
+			err := db.First(&stored, product.ID).Error
+			return stored.Version, err
+		}, nil, 3},
+		{"update of a deleted row", func() (int64, error) {
+			goneCopy.Name = "ghost"
+			err := db.Save(&goneCopy).Error
+			return goneCopy.Version, err
+		}, gorm.ErrRecordNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := tt.run()
+			if !errors.Is(err, tt.wantErr) || version != tt.wantVersion {
+				t.Errorf("error, version = %v, %d, want %v, %d", err, version, tt.wantErr, tt.wantVersion)
+			}
+			var stored models.Product
+			if loadErr := db.First(&stored, product.ID).Error; loadErr != nil {
+				t.Fatalf("First() error = %v", loadErr)
+			}
+			if tt.wantErr == nil && stored.Version != tt.wantVersion {
+				t.Errorf("stored version = %d, want %d", stored.Version, tt.wantVersion)
+			}
+		})
+	}
+
+	var stored models.Product
+	if err := db.First(&stored, product.ID).Error; err != nil || stored.Name != "unchecked" {
+		t.Errorf("stored name = %q, %v, want unchecked", stored.Name, err)
+	}
+	var conflict *models.ConflictError
+	stale.Name = "lost"
+	if err := db.Save(&stale).Error; !errors.As(err, &conflict) || conflict.Version != 1 {
+		t.Errorf("Save() error = %v, want a conflict at version 1", err)
+	}
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	conflict := &models.ConflictError{Table: "products", ID: 1, Version: 1}
+	other := errors.New("other")
+	tests := []struct {
+		name      string
+		attempts  int
+		results   []error
+		wantErr   error
+		wantCalls int
+	}{
+		{"success", 3, []error{nil}, nil, 1},
+		{"conflict then success", 3, []error{conflict, conflict, nil}, nil, 3},
+		{"conflict every time", 2, []error{conflict, conflict, nil}, models.ErrConflict, 2},
+		{"other error", 3, []error{other, nil}, other, 1},
+		{"default attempts", 0, []error{conflict, conflict, conflict, nil}, models.ErrConflict, models.DefaultConflictRetries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := models.RetryOnConflict(tt.attempts, func() error {
+				calls++
+				return tt.results[calls-1]
+			})
+			if !errors.Is(err, tt.wantErr) || calls != tt.wantCalls {
+				t.Errorf("RetryOnConflict() = %v after %d calls, want %v after %d", err, calls, tt.wantErr, tt.wantCalls)
+			}
+		})
+	}
+}
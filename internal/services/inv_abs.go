@@ -0,0 +1,573 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// InventoryReservationTTL is how long a reservation holds stock when ReservationInput.TTL is not set.
+const InventoryReservationTTL = 30 * time.Minute
+
+var (
+	ErrInsufficientStock  = errors.New("insufficient stock")
+	ErrReservationClosed  = errors.New("reservation is no longer active")
+	ErrReservationExpired = errors.New("reservation has expired")
+)
+
+// InsufficientStockError is the error of a movement or a reservation that would take the available
+// quantity of an inventory, on hand minus reserved, below zero.
+type InsufficientStockError struct {
+	ProductID   string
+	VariantID   string
+	WarehouseID string
+	Available   float64
+	Requested   float64
+}
+
+func (e *InsufficientStockError) Error() string {
+	item := "product " + e.ProductID
+	if e.VariantID != "" {
+		item += " variant " + e.VariantID
+	}
+	return fmt.Sprintf("%s in warehouse %s: %.3f available, %.3f requested: %v", item, e.WarehouseID, e.Available, e.Requested, ErrInsufficientStock)
+}
+func (e *InsufficientStockError) Unwrap() error { return ErrInsufficientStock }
+
+// StockInput is a receipt, an issue or an adjustment of Quantity of a product, or of a variant of it,
+// in a warehouse. Quantity is positive except for adjustments, where it is the signed difference.
+type StockInput struct {
+	ProductID     string  `json:"product_id"`
+	VariantID     string  `json:"variant_id,omitempty"`
+	WarehouseID   string  `json:"warehouse_id"`
+	Quantity      float64 `json:"quantity"`
+	Reference     string  `json:"reference,omitempty"`
+	Reason        string  `json:"reason,omitempty"`
+	AllowNegative bool    `json:"allow_negative,omitempty"`
+}
+
+type TransferInput struct {
+	ProductID       string  `json:"product_id"`
+	VariantID       string  `json:"variant_id,omitempty"`
+	FromWarehouseID string  `json:"from_warehouse_id"`
+	ToWarehouseID   string  `json:"to_warehouse_id"`
+	Quantity        float64 `json:"quantity"`
+	Reference       string  `json:"reference,omitempty"`
+	Reason          string  `json:"reason,omitempty"`
+	AllowNegative   bool    `json:"allow_negative,omitempty"`
+}
+
+// ReservationInput holds Quantity of a product, or of a variant of it, in a warehouse for an order
+// during TTL, or InventoryReservationTTL. AllowNegative lets the reservation exceed the available
+// quantity.
+type ReservationInput struct {
+	ProductID     string        `json:"product_id"`
+	VariantID     string        `json:"variant_id,omitempty"`
+	WarehouseID   string        `json:"warehouse_id"`
+	OrderID       string        `json:"order_id"`
+	Quantity      float64       `json:"quantity"`
+	TTL           time.Duration `json:"ttl,omitempty"`
+	AllowNegative bool          `json:"allow_negative,omitempty"`
+}
+
+// InventoryService moves stock. Every operation updates Inventory.Quantity (and Reserved) and writes
+// the InventoryMovement ledger in one transaction, so the balance of the last movement of an inventory
+// is always its quantity. Concurrent writers of the same inventory are serialized by its version: the
+// loser starts over from a fresh read. Quantities never go negative unless the input allows it.
+//
+// The service works in the tenant of ctx, and joins the transaction of db when there is one.
+type InventoryService interface {
+	Receive(ctx context.Context, in StockInput) (*models.InventoryMovement, error)
+	Issue(ctx context.Context, in StockInput) (*models.InventoryMovement, error)
+	Adjust(ctx context.Context, in StockInput) (*models.InventoryMovement, error)
+	// Transfer returns the outgoing and the incoming movements, which share a TransferID.
+	Transfer(ctx context.Context, in TransferInput) ([]*models.InventoryMovement, error)
+	Reserve(ctx context.Context, in ReservationInput) (*models.StockReservation, error)
+	// Release gives the stock of an active reservation back; an expired one is closed as expired.
+	Release(ctx context.Context, reservationID string) (*models.StockReservation, error)
+	// Consume issues the stock of an active reservation, e.g. when its order ships. An expired
+	// reservation is closed as expired and fails with ErrReservationExpired.
+	Consume(ctx context.Context, reservationID string, reference string) (*models.InventoryMovement, error)
+	ReleaseOrder(ctx context.Context, orderID string) (int64, error)
+	ConsumeOrder(ctx context.Context, orderID string, reference string) ([]*models.InventoryMovement, error)
+	// ExpireReservations releases the active reservations expired at now and returns how many.
+	// Reservations are also expired before stock is taken from or reserved in their inventory, and
+	// as they are released or consumed.
+	ExpireReservations(ctx context.Context, now time.Time) (int64, error)
+	// Stock returns the stock of a product, not of its variants, in a warehouse.
+	Stock(ctx context.Context, productID, warehouseID string) (*models.Inventory, error)
+	// VariantStock returns the stock of a variant in a warehouse, or in every warehouse.
+	VariantStock(ctx context.Context, variantID, warehouseID string) ([]*models.Inventory, error)
+	Reservations(ctx context.Context, orderID string) ([]*models.StockReservation, error)
+	Ledger(ctx context.Context, q models.Query) (*models.Page[models.InventoryMovement], error)
+}
+
+type InventoryServiceImpl struct {
+	db *gorm.DB
+}
+
+func NewInventoryService(db *gorm.DB) InventoryService {
+	return &InventoryServiceImpl{db: db}
+}
+
+// run runs fn in a transaction, from the start again when it loses an update race.
+func (s *InventoryServiceImpl) run(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return models.RetryOnConflict(models.DefaultConflictRetries, func() error {
+		return WithTx(s.db, ctx, func(tx Repos) error {
+			return fn(tx.DB())
+		})
+	})
+}
+
+func (s *InventoryServiceImpl) Receive(ctx context.Context, in StockInput) (*models.InventoryMovement, error) {
+	if in.Quantity <= 0 {
+		return nil, &models.ValidationError{Field: "quantity", Message: "Quantity must be positive"}
+	}
+	return s.post(ctx, in, models.MovementReceipt, in.Quantity)
+}
+
+func (s *InventoryServiceImpl) Issue(ctx context.Context, in StockInput) (*models.InventoryMovement, error) {
+	if in.Quantity <= 0 {
+		return nil, &models.ValidationError{Field: "quantity", Message: "Quantity must be positive"}
+	}
+	return s.post(ctx, in, models.MovementIssue, -in.Quantity)
+}
+
+func (s *InventoryServiceImpl) Adjust(ctx context.Context, in StockInput) (*models.InventoryMovement, error) {
+	if in.Quantity == 0 {
+		return nil, &models.ValidationError{Field: "quantity", Message: "Quantity must not be zero"}
+	}
+	if in.Reason == "" {
+		return nil, &models.ValidationError{Field: "reason", Message: "Reason is required for adjustments"}
+	}
+	return s.post(ctx, in, models.MovementAdjustment, in.Quantity)
+}
+
+func (s *InventoryServiceImpl) post(ctx context.Context, in StockInput, movementType models.MovementType, delta float64) (*models.InventoryMovement, error) {
+	if in.ProductID == "" || in.WarehouseID == "" {
+		return nil, &models.ValidationError{Field: "product_id", Message: "Product and warehouse are required"}
+	}
+	var movement *models.InventoryMovement
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		inv, invErr := inventoryFor(tx, in.ProductID, in.VariantID, in.WarehouseID, delta > 0 || in.AllowNegative, -delta)
+		if invErr != nil {
+			return invErr
+		}
+		var moveErr error
+		movement, moveErr = inventoryMove(tx, inv, movementType, delta, 0, in.AllowNegative, "", in.Reference, in.Reason)
+		return moveErr
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	return movement, nil
+}
+
+func (s *InventoryServiceImpl) Transfer(ctx context.Context, in TransferInput) ([]*models.InventoryMovement, error) {
+	if in.ProductID == "" || in.FromWarehouseID == "" || in.ToWarehouseID == "" {
+		return nil, &models.ValidationError{Field: "product_id", Message: "Product and both warehouses are required"}
+	}
+	if in.FromWarehouseID == in.ToWarehouseID {
+		return nil, &models.ValidationError{Field: "to_warehouse_id", Message: "Transfer must be between different warehouses"}
+	}
+	if in.Quantity <= 0 {
+		return nil, &models.ValidationError{Field: "quantity", Message: "Quantity must be positive"}
+	}
+	var movements []*models.InventoryMovement
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		from, fromErr := inventoryFor(tx, in.ProductID, in.VariantID, in.FromWarehouseID, in.AllowNegative, in.Quantity)
+		if fromErr != nil {
+			return fromErr
+		}
+		to, toErr := inventoryFor(tx, in.ProductID, in.VariantID, in.ToWarehouseID, true, 0)
+		if toErr != nil {
+			return toErr
+		}
+		transferID := uuid.New().String()
+		out, outErr := inventoryMove(tx, from, models.MovementTransferOut, -in.Quantity, 0, in.AllowNegative, transferID, in.Reference, in.Reason)
+		if outErr != nil {
+			return outErr
+		}
+		into, inErr := inventoryMove(tx, to, models.MovementTransferIn, in.Quantity, 0, in.AllowNegative, transferID, in.Reference, in.Reason)
+		if inErr != nil {
+			return inErr
+		}
+		movements = []*models.InventoryMovement{out, into}
+		return nil
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	return movements, nil
+}
+
+func (s *InventoryServiceImpl) Reserve(ctx context.Context, in ReservationInput) (*models.StockReservation, error) {
+	if in.ProductID == "" || in.WarehouseID == "" {
+		return nil, &models.ValidationError{Field: "product_id", Message: "Product and warehouse are required"}
+	}
+	if in.Quantity <= 0 {
+		return nil, &models.ValidationError{Field: "quantity", Message: "Quantity must be positive"}
+	}
+	ttl := in.TTL
+	if ttl <= 0 {
+		ttl = InventoryReservationTTL
+	}
+	var reservation *models.StockReservation
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		inv, invErr := inventoryFor(tx, in.ProductID, in.VariantID, in.WarehouseID, in.AllowNegative, in.Quantity)
+		if invErr != nil {
+			return invErr
+		}
+		now := time.Now().UTC()
+		if !in.AllowNegative && inv.Available() < in.Quantity {
+			return &InsufficientStockError{ProductID: inv.ProductID, VariantID: inv.VariantID, WarehouseID: inv.WarehouseID, Available: inv.Available(), Requested: in.Quantity}
+		}
+		if updateErr := inventoryUpdate(tx, inv, inv.Quantity, inv.Reserved+in.Quantity); updateErr != nil {
+			return updateErr
+		}
+		reservation = &models.StockReservation{
+			InventoryID: inv.ID,
+			ProductID:   inv.ProductID,
+			VariantID:   inv.VariantID,
+			WarehouseID: inv.WarehouseID,
+			OrderID:     in.OrderID,
+			Quantity:    in.Quantity,
+			Status:      models.ReservationActive,
+			ExpiresAt:   now.Add(ttl),
+			Tenant:      inv.Tenant,
+		}
+		return tx.Create(reservation).Error
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	return reservation, nil
+}
+
+func (s *InventoryServiceImpl) Release(ctx context.Context, reservationID string) (*models.StockReservation, error) {
+	var reservation *models.StockReservation
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		var findErr error
+		if reservation, findErr = activeReservation(tx, reservationID); findErr != nil {
+			return findErr
+		}
+		status := models.ReservationReleased
+		if reservationExpired(reservation, time.Now().UTC()) {
+			status = models.ReservationExpired
+		}
+		return closeReservation(tx, reservation, status)
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	return reservation, nil
+}
+
+func (s *InventoryServiceImpl) Consume(ctx context.Context, reservationID string, reference string) (*models.InventoryMovement, error) {
+	var movement *models.InventoryMovement
+	var expiredErr error
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		expiredErr = nil
+		reservation, findErr := activeReservation(tx, reservationID)
+		if findErr != nil {
+			return findErr
+		}
+		if reservationExpired(reservation, time.Now().UTC()) {
+			expiredErr = fmt.Errorf("error consuming reservation %s: %w", reservation.ID, ErrReservationExpired)
+			return closeReservation(tx, reservation, models.ReservationExpired)
+		}
+		var consumeErr error
+		movement, consumeErr = consumeReservation(tx, reservation, reference)
+		return consumeErr
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	if expiredErr != nil {
+		return nil, expiredErr
+	}
+	return movement, nil
+}
+
+func (s *InventoryServiceImpl) ReleaseOrder(ctx context.Context, orderID string) (int64, error) {
+	var released int64
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		released = 0
+		reservations, findErr := orderReservations(tx, orderID, true)
+		if findErr != nil {
+			return findErr
+		}
+		for _, reservation := range reservations {
+			if closeErr := closeReservation(tx, reservation, models.ReservationReleased); closeErr != nil {
+				return closeErr
+			}
+			released++
+		}
+		return nil
+	})
+	if runErr != nil {
+		return 0, runErr
+	}
+	return released, nil
+}
+
+func (s *InventoryServiceImpl) ConsumeOrder(ctx context.Context, orderID string, reference string) ([]*models.InventoryMovement, error) {
+	var movements []*models.InventoryMovement
+	var expiredErr error
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		movements, expiredErr = nil, nil
+		reservations, findErr := orderReservations(tx, orderID, true)
+		if findErr != nil {
+			return findErr
+		}
+		now := time.Now().UTC()
+		for _, reservation := range reservations {
+			if !reservationExpired(reservation, now) {
+				continue
+			}
+			if expiredErr == nil {
+				expiredErr = fmt.Errorf("error consuming reservation %s: %w", reservation.ID, ErrReservationExpired)
+			}
+			if closeErr := closeReservation(tx, reservation, models.ReservationExpired); closeErr != nil {
+				return closeErr
+			}
+		}
+		if expiredErr != nil {
+			return nil
+		}
+		for _, reservation := range reservations {
+			movement, consumeErr := consumeReservation(tx, reservation, reference)
+			if consumeErr != nil {
+				return consumeErr
+			}
+			movements = append(movements, movement)
+		}
+		return nil
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	if expiredErr != nil {
+		return nil, expiredErr
+	}
+	return movements, nil
+}
+
+func (s *InventoryServiceImpl) ExpireReservations(ctx context.Context, now time.Time) (int64, error) {
+	var expired int64
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		var expireErr error
+		expired, expireErr = expireReservations(tx, now.UTC(), "")
+		return expireErr
+	})
+	if runErr != nil {
+		return 0, runErr
+	}
+	return expired, nil
+}
+
+func (s *InventoryServiceImpl) Stock(ctx context.Context, productID, warehouseID string) (*models.Inventory, error) {
+	var inv models.Inventory
+	if findErr := UsePrimary(s.db).WithContext(ctx).Where("product_id = ? AND variant_id = '' AND warehouse_id = ?", productID, warehouseID).First(&inv).Error; findErr != nil {
+		return nil, findErr
+	}
+	return &inv, nil
+}
+
+func (s *InventoryServiceImpl) VariantStock(ctx context.Context, variantID, warehouseID string) ([]*models.Inventory, error) {
+	if variantID == "" {
+		return nil, &models.ValidationError{Field: "variant_id", Message: "Variant is required"}
+	}
+	query := UsePrimary(s.db).WithContext(ctx).Where("variant_id = ?", variantID)
+	if warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+	var inventories []*models.Inventory
+	if findErr := query.Order("warehouse_id").Find(&inventories).Error; findErr != nil {
+		return nil, findErr
+	}
+	return inventories, nil
+}
+
+func (s *InventoryServiceImpl) Reservations(ctx context.Context, orderID string) ([]*models.StockReservation, error) {
+	return orderReservations(s.db.WithContext(ctx), orderID, false)
+}
+
+func (s *InventoryServiceImpl) Ledger(ctx context.Context, q models.Query) (*models.Page[models.InventoryMovement], error) {
+	if len(q.Sort) == 0 {
+		q.Sort = []string{"-created_at"}
+	}
+	return models.NewInventoryMovementRepo(s.db).WithContext(ctx).Search(q)
+}
+
+// inventoryFor loads the inventory of a product, or of a variant of it, in a warehouse, creating an
+// empty one when create is set; otherwise taking requested from a missing inventory fails. A
+// concurrent create of the same inventory is absorbed by its unique index. The expired reservations
+// of the inventory are closed first, so that its available quantity is current.
+func inventoryFor(tx *gorm.DB, productID, variantID, warehouseID string, create bool, requested float64) (*models.Inventory, error) {
+	var inv models.Inventory
+	result := tx.Where("product_id = ? AND variant_id = ? AND warehouse_id = ?", productID, variantID, warehouseID).Limit(1).Find(&inv)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		expired, expireErr := expireReservations(tx, time.Now().UTC(), inv.ID)
+		if expireErr != nil {
+			return nil, expireErr
+		}
+		if expired > 0 {
+			if reloadErr := tx.First(&inv, "id = ?", inv.ID).Error; reloadErr != nil {
+				return nil, reloadErr
+			}
+		}
+		return &inv, nil
+	}
+	if !create {
+		return nil, &InsufficientStockError{ProductID: productID, VariantID: variantID, WarehouseID: warehouseID, Requested: requested}
+	}
+	if variantID != "" {
+		var variants int64
+		if countErr := tx.Model(&models.ProductVariant{}).Where("id = ? AND product_id = ?", variantID, productID).Count(&variants).Error; countErr != nil {
+			return nil, countErr
+		}
+		if variants == 0 {
+			return nil, &models.ValidationError{Field: "variant_id", Message: fmt.Sprintf("Variant %s of product %s not found", variantID, productID)}
+		}
+	}
+	inv = models.Inventory{ProductID: productID, VariantID: variantID, WarehouseID: warehouseID, Status: models.InventoryStatusAvailable, LastCountDate: time.Now().UTC()}
+	if createErr := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&inv).Error; createErr != nil {
+		return nil, createErr
+	}
+	inv = models.Inventory{}
+	if reloadErr := tx.Where("product_id = ? AND variant_id = ? AND warehouse_id = ?", productID, variantID, warehouseID).First(&inv).Error; reloadErr != nil {
+		return nil, reloadErr
+	}
+	return &inv, nil
+}
+
+// inventoryMove applies delta to the quantity of inv, and reservedDelta to its reserved quantity, and
+// records the movement. Movements that are not reservations can only take what is not reserved.
+func inventoryMove(tx *gorm.DB, inv *models.Inventory, movementType models.MovementType, delta, reservedDelta float64, allowNegative bool, transferID, reference, reason string) (*models.InventoryMovement, error) {
+	quantity, reserved := inv.Quantity+delta, inv.Reserved+reservedDelta
+	if delta < 0 && !allowNegative && (quantity < 0 || quantity-reserved < 0 && reservedDelta == 0) {
+		available := inv.Available()
+		if reservedDelta != 0 {
+			available = inv.Quantity
+		}
+		return nil, &InsufficientStockError{ProductID: inv.ProductID, VariantID: inv.VariantID, WarehouseID: inv.WarehouseID, Available: available, Requested: -delta}
+	}
+	if updateErr := inventoryUpdate(tx, inv, quantity, reserved); updateErr != nil {
+		return nil, updateErr
+	}
+	movement := &models.InventoryMovement{
+		ID:                uuid.New().String(),
+		InventoryID:       inv.ID,
+		ProductID:         inv.ProductID,
+		VariantID:         inv.VariantID,
+		WarehouseID:       inv.WarehouseID,
+		Quantity:          delta,
+		Balance:           inv.Quantity,
+		MovementType:      movementType,
+		TransferID:        transferID,
+		ReferenceDocument: reference,
+		Reason:            reason,
+		Tenant:            inv.Tenant,
+	}
+	if createErr := tx.Create(movement).Error; createErr != nil {
+		return nil, fmt.Errorf("error recording inventory movement: %w", createErr)
+	}
+	return movement, nil
+}
+
+// inventoryUpdate writes the quantities of inv at its version; a concurrent change fails it with a
+// models.ConflictError.
+func inventoryUpdate(tx *gorm.DB, inv *models.Inventory, quantity, reserved float64) error {
+	if reserved < 0 {
+		reserved = 0
+	}
+	return tx.Model(inv).Updates(map[string]interface{}{"quantity": quantity, "reserved": reserved}).Error
+}
+
+func activeReservation(tx *gorm.DB, reservationID string) (*models.StockReservation, error) {
+	var reservation models.StockReservation
+	if findErr := tx.First(&reservation, "id = ?", reservationID).Error; findErr != nil {
+		return nil, findErr
+	}
+	if reservation.Status != models.ReservationActive {
+		return nil, fmt.Errorf("error using reservation %s (%s): %w", reservation.ID, reservation.Status, ErrReservationClosed)
+	}
+	return &reservation, nil
+}
+
+func reservationExpired(reservation *models.StockReservation, now time.Time) bool {
+	return !reservation.ExpiresAt.After(now)
+}
+
+func orderReservations(tx *gorm.DB, orderID string, activeOnly bool) ([]*models.StockReservation, error) {
+	if orderID == "" {
+		return nil, &models.ValidationError{Field: "order_id", Message: "Order is required"}
+	}
+	query := tx.Where("order_id = ?", orderID)
+	if activeOnly {
+		query = query.Where("status = ?", models.ReservationActive)
+	}
+	var reservations []*models.StockReservation
+	if findErr := query.Order("created_at").Find(&reservations).Error; findErr != nil {
+		return nil, findErr
+	}
+	return reservations, nil
+}
+
+// closeReservation gives the stock of an active reservation back to its inventory.
+func closeReservation(tx *gorm.DB, reservation *models.StockReservation, status models.ReservationStatus) error {
+	var inv models.Inventory
+	if findErr := tx.First(&inv, "id = ?", reservation.InventoryID).Error; findErr != nil {
+		return findErr
+	}
+	if updateErr := inventoryUpdate(tx, &inv, inv.Quantity, inv.Reserved-reservation.Quantity); updateErr != nil {
+		return updateErr
+	}
+	return tx.Model(reservation).Updates(map[string]interface{}{"status": status}).Error
+}
+
+func consumeReservation(tx *gorm.DB, reservation *models.StockReservation, reference string) (*models.InventoryMovement, error) {
+	var inv models.Inventory
+	if findErr := tx.First(&inv, "id = ?", reservation.InventoryID).Error; findErr != nil {
+		return nil, findErr
+	}
+	if reference == "" {
+		reference = reservation.OrderID
+	}
+	movement, moveErr := inventoryMove(tx, &inv, models.MovementIssue, -reservation.Quantity, -reservation.Quantity, false, "", reference, "reservation "+reservation.ID)
+	if moveErr != nil {
+		return nil, moveErr
+	}
+	if updateErr := tx.Model(reservation).Updates(map[string]interface{}{"status": models.ReservationConsumed}).Error; updateErr != nil {
+		return nil, updateErr
+	}
+	return movement, nil
+}
+
+// expireReservations closes the active reservations expired at now, of inventoryID or of every
+// inventory.
+func expireReservations(tx *gorm.DB, now time.Time, inventoryID string) (int64, error) {
+	query := tx.Where("status = ? AND expires_at <= ?", models.ReservationActive, now)
+	if inventoryID != "" {
+		query = query.Where("inventory_id = ?", inventoryID)
+	}
+	var reservations []*models.StockReservation
+	if findErr := query.Find(&reservations).Error; findErr != nil {
+		return 0, findErr
+	}
+	for _, reservation := range reservations {
+		if closeErr := closeReservation(tx, reservation, models.ReservationExpired); closeErr != nil {
+			return 0, closeErr
+		}
+	}
+	return int64(len(reservations)), nil
+}
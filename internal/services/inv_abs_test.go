@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/faelmori/gkbxsrv/internal/models"
+)
+
+func TestInventoryExpiredReservations(t *testing.T) {
+	db := openOrderTestDB(t)
+	inventory := NewInventoryService(db)
+	ctx := context.Background()
+	stock := StockInput{ProductID: "7d1c6a8e-0000-4000-8000-000000000001", WarehouseID: "7d1c6a8e-0000-4000-8000-000000000002"}
+
+	in := stock
+	in.Quantity = 10
+	if _, err := inventory.Receive(ctx, in); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	reserve := func(quantity float64) *models.StockReservation {
+		t.Helper()
+		reservation, err := inventory.Reserve(ctx, ReservationInput{ProductID: stock.ProductID, WarehouseID: stock.WarehouseID, OrderID: "order", Quantity: quantity, TTL: time.Millisecond})
+		if err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+		return reservation
+	}
+	status := func(reservation *models.StockReservation) models.ReservationStatus {
+		t.Helper()
+		var stored models.StockReservation
+		if err := db.First(&stored, "id = ?", reservation.ID).Error; err != nil {
+			t.Fatalf("First() error = %v", err)
+		}
+		return stored.Status
+	}
+
+	issued := reserve(6)
+	time.Sleep(5 * time.Millisecond)
+	in.Quantity = 8
+	if _, err := inventory.Issue(ctx, in); err != nil {
+		t.Fatalf("Issue() past an expired reservation error = %v", err)
+	}
+	if got := status(issued); got != models.ReservationExpired {
+		t.Errorf("reservation status after Issue() = %s, want %s", got, models.ReservationExpired)
+	}
+
+	released := reserve(1)
+	time.Sleep(5 * time.Millisecond)
+	closed, err := inventory.Release(ctx, released.ID)
+	if err != nil || closed.Status != models.ReservationExpired {
+		t.Errorf("Release() of an expired reservation = %v, %v, want it closed as expired", closed, err)
+	}
+
+	consumed := reserve(1)
+	time.Sleep(5 * time.Millisecond)
+	if _, err = inventory.Consume(ctx, consumed.ID, ""); !errors.Is(err, ErrReservationExpired) {
+		t.Errorf("Consume() of an expired reservation error = %v, want %v", err, ErrReservationExpired)
+	}
+	if got := status(consumed); got != models.ReservationExpired {
+		t.Errorf("reservation status after Consume() = %s, want %s", got, models.ReservationExpired)
+	}
+
+	inv, err := inventory.Stock(ctx, stock.ProductID, stock.WarehouseID)
+	if err != nil || inv.Quantity != 2 || inv.Reserved != 0 {
+		t.Errorf("Stock() = %+v, %v, want 2 on hand and none reserved", inv, err)
+	}
+}
@@ -0,0 +1,492 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CountAdjustmentReason is the reason of the adjustments posted for variances counted without one.
+const CountAdjustmentReason = "cycle count"
+
+var (
+	ErrCountSessionClosed = errors.New("count session is not open")
+	ErrCountInProgress    = errors.New("products are already being counted")
+)
+
+// CountStartInput starts a count of a warehouse, or of ProductIDs in the warehouse.
+type CountStartInput struct {
+	WarehouseID string   `json:"warehouse_id"`
+	ProductIDs  []string `json:"product_ids,omitempty"`
+	Reference   string   `json:"reference,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+}
+
+// CountInput is the counted quantity of a product, or of a variant of it, which may be given by its
+// barcode instead. Reason, when set, is the reason of the adjustment posted for its variance.
+type CountInput struct {
+	ProductID string  `json:"product_id,omitempty"`
+	VariantID string  `json:"variant_id,omitempty"`
+	Barcode   string  `json:"barcode,omitempty"`
+	Counted   float64 `json:"counted"`
+	Reason    string  `json:"reason,omitempty"`
+}
+
+// item names the product or the variant counted, for errors.
+func (c CountInput) item() string {
+	switch {
+	case c.Barcode != "":
+		return "barcode " + c.Barcode
+	case c.VariantID != "":
+		return "product " + c.ProductID + " variant " + c.VariantID
+	}
+	return "product " + c.ProductID
+}
+
+// CountPostInput is the default reason of the adjustments of a post. With ZeroUncounted the products
+// that were not counted are taken as counted at zero; otherwise they are left as they are.
+type CountPostInput struct {
+	Reason        string `json:"reason,omitempty"`
+	ZeroUncounted bool   `json:"zero_uncounted,omitempty"`
+}
+
+// CountReport is the variance report of a count session. Shortage is the sum of the negative
+// variances and Overage of the positive ones; Accuracy is the share of the counted lines without
+// variance.
+type CountReport struct {
+	Session     *models.CountSession `json:"session"`
+	Lines       []*models.CountLine  `json:"lines"`
+	Counted     int                  `json:"counted"`
+	Uncounted   int                  `json:"uncounted"`
+	Variances   int                  `json:"variances"`
+	Shortage    float64              `json:"shortage"`
+	Overage     float64              `json:"overage"`
+	NetVariance float64              `json:"net_variance"`
+	Accuracy    float64              `json:"accuracy"`
+}
+
+// CountService runs cycle counts. Counts are of the stock as it was when the session started: posting
+// adjusts each inventory by its variance, so the movements made while counting are kept. A product is
+// counted by one open session of its warehouse at a time.
+//
+// The service works in the tenant of ctx, and joins the transaction of db when there is one.
+type CountService interface {
+	Start(ctx context.Context, in CountStartInput) (*models.CountSession, error)
+	// Record enters counts; counting a product again replaces its count. Products found in a warehouse
+	// counted in full are added with nothing expected.
+	Record(ctx context.Context, sessionID string, counts []CountInput) ([]*models.CountLine, error)
+	// Import records the counts of a CSV file; see ReadCounts.
+	Import(ctx context.Context, sessionID string, r io.Reader) ([]*models.CountLine, error)
+	Report(ctx context.Context, sessionID string) (*CountReport, error)
+	// Post adjusts the stock by the variances, stamps Inventory.LastCountDate of the counted products
+	// and closes the session.
+	Post(ctx context.Context, sessionID string, in CountPostInput) (*CountReport, error)
+	Cancel(ctx context.Context, sessionID string) (*models.CountSession, error)
+	// Sessions lists the sessions of a warehouse, or of every warehouse, newest first.
+	Sessions(ctx context.Context, warehouseID string, status models.CountStatus) ([]*models.CountSession, error)
+}
+
+type CountServiceImpl struct {
+	db *gorm.DB
+}
+
+func NewCountService(db *gorm.DB) CountService {
+	return &CountServiceImpl{db: db}
+}
+
+// run runs fn as the inventory service runs its movements, which posting a count makes.
+func (s *CountServiceImpl) run(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return (&InventoryServiceImpl{db: s.db}).run(ctx, fn)
+}
+
+func (s *CountServiceImpl) Start(ctx context.Context, in CountStartInput) (*models.CountSession, error) {
+	if in.WarehouseID == "" {
+		return nil, &models.ValidationError{Field: "warehouse_id", Message: "Warehouse is required"}
+	}
+	productIDs := make([]string, 0, len(in.ProductIDs))
+	seen := map[string]bool{}
+	for _, productID := range in.ProductIDs {
+		if productID = strings.TrimSpace(productID); productID != "" && !seen[productID] {
+			productIDs = append(productIDs, productID)
+			seen[productID] = true
+		}
+	}
+	var session *models.CountSession
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		if busyErr := countInProgress(tx, in.WarehouseID, productIDs); busyErr != nil {
+			return busyErr
+		}
+		session = &models.CountSession{
+			WarehouseID: in.WarehouseID,
+			Status:      models.CountOpen,
+			Reference:   in.Reference,
+			Notes:       in.Notes,
+			Partial:     len(productIDs) > 0,
+			StartedBy:   ActorFromContext(ctx),
+		}
+		if createErr := tx.Create(session).Error; createErr != nil {
+			return createErr
+		}
+
+		query := tx.Where("warehouse_id = ?", in.WarehouseID)
+		if session.Partial {
+			query = query.Where("product_id IN ?", productIDs)
+		}
+		var inventories []*models.Inventory
+		if findErr := query.Order("product_id").Find(&inventories).Error; findErr != nil {
+			return findErr
+		}
+		lines := make([]*models.CountLine, 0, len(inventories)+len(productIDs))
+		frozen := map[string]bool{}
+		for _, inv := range inventories {
+			lines = append(lines, &models.CountLine{SessionID: session.ID, ProductID: inv.ProductID, VariantID: inv.VariantID, InventoryID: inv.ID, Expected: inv.Quantity, Tenant: session.Tenant})
+			frozen[inv.ProductID] = true
+		}
+		// Products without stock in the warehouse are expected at zero.
+		for _, productID := range productIDs {
+			if !frozen[productID] {
+				lines = append(lines, &models.CountLine{SessionID: session.ID, ProductID: productID, Tenant: session.Tenant})
+			}
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(lines, 100).Error
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	return session, nil
+}
+
+func (s *CountServiceImpl) Record(ctx context.Context, sessionID string, counts []CountInput) ([]*models.CountLine, error) {
+	if len(counts) == 0 {
+		return nil, &models.ValidationError{Field: "counts", Message: "At least one count is required"}
+	}
+	for i, count := range counts {
+		if count.ProductID == "" && count.Barcode == "" {
+			return nil, &models.ValidationError{Field: "product_id", Message: fmt.Sprintf("Product or barcode is required (count %d)", i+1)}
+		}
+		if count.Counted < 0 || math.IsNaN(count.Counted) || math.IsInf(count.Counted, 0) {
+			return nil, &models.ValidationError{Field: "counted", Message: fmt.Sprintf("Counted quantity of %s must not be negative", count.item())}
+		}
+	}
+	var recorded []*models.CountLine
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		recorded = nil
+		session, sessionErr := openCountSession(tx, sessionID)
+		if sessionErr != nil {
+			return sessionErr
+		}
+		now := time.Now().UTC()
+		// Touching the session serializes the counts with a concurrent post.
+		if touchErr := tx.Model(session).Updates(map[string]interface{}{"updated_at": now}).Error; touchErr != nil {
+			return touchErr
+		}
+		actor := ActorFromContext(ctx)
+		for _, count := range counts {
+			if count.Barcode != "" {
+				variant, findErr := models.NewProductVariantRepo(tx).FindByBarcode(count.Barcode)
+				if findErr != nil {
+					return fmt.Errorf("error finding %s: %w", count.item(), findErr)
+				}
+				count.ProductID, count.VariantID = strconv.FormatUint(uint64(variant.ProductID), 10), variant.ID
+			}
+			var line models.CountLine
+			result := tx.Where("session_id = ? AND product_id = ? AND variant_id = ?", session.ID, count.ProductID, count.VariantID).Limit(1).Find(&line)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				if session.Partial {
+					return &models.ValidationError{Field: "product_id", Message: fmt.Sprintf("Session %s does not count %s", session.ID, count.item())}
+				}
+				line = models.CountLine{SessionID: session.ID, ProductID: count.ProductID, VariantID: count.VariantID, Tenant: session.Tenant}
+			}
+			counted := countRound(count.Counted)
+			line.Counted = &counted
+			line.Variance = countRound(counted - line.Expected)
+			if count.Reason != "" {
+				line.Reason = count.Reason
+			}
+			line.CountedBy, line.CountedAt = actor, &now
+			if saveErr := tx.Save(&line).Error; saveErr != nil {
+				return fmt.Errorf("error recording the count of %s: %v", count.item(), saveErr)
+			}
+			recorded = append(recorded, &line)
+		}
+		return nil
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	return recorded, nil
+}
+
+func (s *CountServiceImpl) Import(ctx context.Context, sessionID string, r io.Reader) ([]*models.CountLine, error) {
+	counts, readErr := ReadCounts(r)
+	if readErr != nil {
+		return nil, readErr
+	}
+	return s.Record(ctx, sessionID, counts)
+}
+
+func (s *CountServiceImpl) Report(ctx context.Context, sessionID string) (*CountReport, error) {
+	db := UsePrimary(s.db).WithContext(ctx)
+	var session models.CountSession
+	if findErr := db.First(&session, "id = ?", sessionID).Error; findErr != nil {
+		return nil, findErr
+	}
+	lines, linesErr := countLines(db, session.ID)
+	if linesErr != nil {
+		return nil, linesErr
+	}
+	return newCountReport(&session, lines), nil
+}
+
+func (s *CountServiceImpl) Post(ctx context.Context, sessionID string, in CountPostInput) (*CountReport, error) {
+	var report *CountReport
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		session, sessionErr := openCountSession(tx, sessionID)
+		if sessionErr != nil {
+			return sessionErr
+		}
+		lines, linesErr := countLines(tx, session.ID)
+		if linesErr != nil {
+			return linesErr
+		}
+		now := time.Now().UTC()
+		actor := ActorFromContext(ctx)
+		reference := session.Reference
+		if reference == "" {
+			reference = "count " + session.ID
+		}
+		for _, line := range lines {
+			if !line.IsCounted() {
+				if !in.ZeroUncounted {
+					continue
+				}
+				zero := 0.0
+				line.Counted, line.Variance = &zero, countRound(zero-line.Expected)
+				line.CountedBy, line.CountedAt = actor, &now
+			}
+			if postErr := postCountLine(tx, session, line, reference, in.Reason, now); postErr != nil {
+				return postErr
+			}
+		}
+		if updateErr := tx.Model(session).Updates(map[string]interface{}{"status": models.CountPosted, "posted_at": now, "posted_by": actor}).Error; updateErr != nil {
+			return updateErr
+		}
+		session.Status, session.PostedAt, session.PostedBy = models.CountPosted, &now, actor
+		report = newCountReport(session, lines)
+		return nil
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	return report, nil
+}
+
+func (s *CountServiceImpl) Cancel(ctx context.Context, sessionID string) (*models.CountSession, error) {
+	var session *models.CountSession
+	runErr := s.run(ctx, func(tx *gorm.DB) error {
+		var sessionErr error
+		if session, sessionErr = openCountSession(tx, sessionID); sessionErr != nil {
+			return sessionErr
+		}
+		if updateErr := tx.Model(session).Updates(map[string]interface{}{"status": models.CountCancelled}).Error; updateErr != nil {
+			return updateErr
+		}
+		session.Status = models.CountCancelled
+		return nil
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	return session, nil
+}
+
+func (s *CountServiceImpl) Sessions(ctx context.Context, warehouseID string, status models.CountStatus) ([]*models.CountSession, error) {
+	query := UsePrimary(s.db).WithContext(ctx)
+	if warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var sessions []*models.CountSession
+	if findErr := query.Order("created_at DESC").Find(&sessions).Error; findErr != nil {
+		return nil, findErr
+	}
+	return sessions, nil
+}
+
+// ReadCounts reads counts from CSV records of product_id, counted and an optional reason. A first
+// record naming the columns, e.g. "product_id,counted,reason" or "barcode,counted", is a header and
+// may put them in any order and add variant_id; "quantity" is taken for "counted".
+func ReadCounts(r io.Reader) ([]CountInput, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	columns := map[string]int{"product_id": 0, "counted": 1, "reason": 2}
+	var counts []CountInput
+	for record := 1; ; record++ {
+		fields, readErr := reader.Read()
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("error reading counts: %v", readErr)
+		}
+		if record == 1 && countHeader(fields) {
+			columns = map[string]int{}
+			for i, name := range fields {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if name == "quantity" {
+					name = "counted"
+				}
+				columns[name] = i
+			}
+			_, hasProduct := columns["product_id"]
+			if _, hasBarcode := columns["barcode"]; !hasProduct && !hasBarcode {
+				return nil, fmt.Errorf("error reading counts: header has no product_id or barcode column")
+			}
+			if _, ok := columns["counted"]; !ok {
+				return nil, fmt.Errorf("error reading counts: header has no counted column")
+			}
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		if len(fields) == 1 && strings.TrimSpace(fields[0]) == "" {
+			continue // blank line
+		}
+		counted, parseErr := strconv.ParseFloat(field("counted"), 64)
+		if parseErr != nil {
+			return nil, fmt.Errorf("error reading counts, record %d: invalid counted quantity %q", record, field("counted"))
+		}
+		counts = append(counts, CountInput{ProductID: field("product_id"), VariantID: field("variant_id"), Barcode: field("barcode"), Counted: counted, Reason: field("reason")})
+	}
+	return counts, nil
+}
+
+func countHeader(fields []string) bool {
+	for _, name := range fields {
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "product_id" || name == "barcode" {
+			return true
+		}
+	}
+	return false
+}
+
+// countInProgress fails when an open session of the warehouse counts any of productIDs, or when
+// productIDs is empty (the whole warehouse) and any session of the warehouse is open.
+func countInProgress(tx *gorm.DB, warehouseID string, productIDs []string) error {
+	var sessions []*models.CountSession
+	if findErr := tx.Where("warehouse_id = ? AND status = ?", warehouseID, models.CountOpen).Find(&sessions).Error; findErr != nil {
+		return findErr
+	}
+	for _, session := range sessions {
+		if !session.Partial || len(productIDs) == 0 {
+			return fmt.Errorf("error starting count of warehouse %s (session %s is open): %w", warehouseID, session.ID, ErrCountInProgress)
+		}
+		var overlap int64
+		if countErr := tx.Model(&models.CountLine{}).Where("session_id = ? AND product_id IN ?", session.ID, productIDs).Count(&overlap).Error; countErr != nil {
+			return countErr
+		}
+		if overlap > 0 {
+			return fmt.Errorf("error starting count of warehouse %s (session %s is open): %w", warehouseID, session.ID, ErrCountInProgress)
+		}
+	}
+	return nil
+}
+
+func openCountSession(tx *gorm.DB, sessionID string) (*models.CountSession, error) {
+	var session models.CountSession
+	if findErr := tx.First(&session, "id = ?", sessionID).Error; findErr != nil {
+		return nil, findErr
+	}
+	if session.Status != models.CountOpen {
+		return nil, fmt.Errorf("error using count session %s (%s): %w", session.ID, session.Status, ErrCountSessionClosed)
+	}
+	return &session, nil
+}
+
+func countLines(tx *gorm.DB, sessionID string) ([]*models.CountLine, error) {
+	var lines []*models.CountLine
+	if findErr := tx.Where("session_id = ?", sessionID).Order("product_id, variant_id").Find(&lines).Error; findErr != nil {
+		return nil, findErr
+	}
+	return lines, nil
+}
+
+// postCountLine adjusts the inventory of a counted line by its variance and stamps its last count.
+func postCountLine(tx *gorm.DB, session *models.CountSession, line *models.CountLine, reference, reason string, now time.Time) error {
+	if line.InventoryID == "" && line.Variance == 0 {
+		// Nothing expected and nothing found.
+		return tx.Save(line).Error
+	}
+	inv, invErr := inventoryFor(tx, line.ProductID, line.VariantID, session.WarehouseID, true, 0)
+	if invErr != nil {
+		return invErr
+	}
+	if line.Variance != 0 {
+		if line.Reason != "" {
+			reason = line.Reason
+		} else if reason == "" {
+			reason = CountAdjustmentReason
+		}
+		movement, moveErr := inventoryMove(tx, inv, models.MovementAdjustment, line.Variance, 0, true, "", reference, reason)
+		if moveErr != nil {
+			return moveErr
+		}
+		line.MovementID = movement.ID
+	}
+	if updateErr := tx.Model(inv).Updates(map[string]interface{}{"last_count_date": now}).Error; updateErr != nil {
+		return updateErr
+	}
+	line.InventoryID = inv.ID
+	return tx.Save(line).Error
+}
+
+func newCountReport(session *models.CountSession, lines []*models.CountLine) *CountReport {
+	report := &CountReport{Session: session, Lines: lines}
+	for _, line := range lines {
+		if !line.IsCounted() {
+			report.Uncounted++
+			continue
+		}
+		report.Counted++
+		switch {
+		case line.Variance < 0:
+			report.Variances++
+			report.Shortage += line.Variance
+		case line.Variance > 0:
+			report.Variances++
+			report.Overage += line.Variance
+		}
+	}
+	report.Shortage, report.Overage = countRound(report.Shortage), countRound(report.Overage)
+	report.NetVariance = countRound(report.Shortage + report.Overage)
+	if report.Counted > 0 {
+		report.Accuracy = float64(report.Counted-report.Variances) / float64(report.Counted)
+	}
+	return report
+}
+
+// countRound rounds a quantity to the three decimals it is stored with.
+func countRound(quantity float64) float64 {
+	return math.Round(quantity*1000) / 1000
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/faelmori/gkbxsrv/internal/models"
+)
+
+func TestCountPost(t *testing.T) {
+	const (
+		warehouse = "7d1c6a8e-0000-4000-8000-0000000000a0"
+		productA  = "7d1c6a8e-0000-4000-8000-0000000000a1"
+		productB  = "7d1c6a8e-0000-4000-8000-0000000000a2"
+		productC  = "7d1c6a8e-0000-4000-8000-0000000000a3"
+		productD  = "7d1c6a8e-0000-4000-8000-0000000000a4"
+	)
+	tests := []struct {
+		name          string
+		counts        []CountInput
+		zeroUncounted bool
+		want          map[string]float64
+		wantVariances int
+		wantShortage  float64
+		wantOverage   float64
+	}{
+		{
+			name:   "shortage and overage",
+			counts: []CountInput{{ProductID: productA, Counted: 8}, {ProductID: productB, Counted: 7}},
+			// A also received 2 while counting, which the post keeps.
+			want:          map[string]float64{productA: 10, productB: 7, productC: 3},
+			wantVariances: 2, wantShortage: -2, wantOverage: 2,
+		},
+		{
+			name:          "uncounted taken as zero",
+			counts:        []CountInput{{ProductID: productA, Counted: 10}},
+			zeroUncounted: true,
+			want:          map[string]float64{productA: 12, productB: 0, productC: 0},
+			wantVariances: 2, wantShortage: -8,
+		},
+		{
+			name:          "product found without stock",
+			counts:        []CountInput{{ProductID: productD, Counted: 4}},
+			want:          map[string]float64{productA: 12, productB: 5, productC: 3, productD: 4},
+			wantVariances: 1, wantOverage: 4,
+		},
+		{
+			name:   "fractional quantities",
+			counts: []CountInput{{ProductID: productC, Counted: 2.9994}},
+			want:   map[string]float64{productA: 12, productB: 5, productC: 2.999},
+			// Counts keep the three decimals of the stock.
+			wantVariances: 1, wantShortage: -0.001,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openOrderTestDB(t)
+			ctx := context.Background()
+			inventory := NewInventoryService(db)
+			counts := NewCountService(db)
+			for productID, quantity := range map[string]float64{productA: 10, productB: 5, productC: 3} {
+				if _, err := inventory.Receive(ctx, StockInput{ProductID: productID, WarehouseID: warehouse, Quantity: quantity}); err != nil {
+					t.Fatalf("Receive() error = %v", err)
+				}
+			}
+			session, err := counts.Start(ctx, CountStartInput{WarehouseID: warehouse})
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if _, err = inventory.Receive(ctx, StockInput{ProductID: productA, WarehouseID: warehouse, Quantity: 2}); err != nil {
+				t.Fatalf("Receive() while counting error = %v", err)
+			}
+			if _, err = counts.Record(ctx, session.ID, tt.counts); err != nil {
+				t.Fatalf("Record() error = %v", err)
+			}
+
+			report, err := counts.Post(ctx, session.ID, CountPostInput{ZeroUncounted: tt.zeroUncounted})
+			if err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			if report.Session.Status != models.CountPosted || report.Variances != tt.wantVariances || report.Shortage != tt.wantShortage || report.Overage != tt.wantOverage {
+				t.Errorf("Post() report = %s, %d variances, shortage %v, overage %v, want posted, %d, %v, %v",
+					report.Session.Status, report.Variances, report.Shortage, report.Overage, tt.wantVariances, tt.wantShortage, tt.wantOverage)
+			}
+			for productID, want := range tt.want {
+				stock, stockErr := inventory.Stock(ctx, productID, warehouse)
+				if stockErr != nil || stock.Quantity != want {
+					t.Errorf("Stock(%s) after Post() = %v, %v, want %v", productID, stock, stockErr, want)
+				}
+			}
+			var adjustments int64
+			if err = db.Model(&models.InventoryMovement{}).Where("movement_type = ? AND reason = ?", models.MovementAdjustment, CountAdjustmentReason).Count(&adjustments).Error; err != nil || adjustments != int64(tt.wantVariances) {
+				t.Errorf("count adjustments = %d, %v, want %d", adjustments, err, tt.wantVariances)
+			}
+			if _, err = counts.Post(ctx, session.ID, CountPostInput{}); !errors.Is(err, ErrCountSessionClosed) {
+				t.Errorf("Post() again error = %v, want %v", err, ErrCountSessionClosed)
+			}
+		})
+	}
+}
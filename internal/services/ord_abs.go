@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"time"
+)
+
+// The status of an order only changes through OrderService.Transition: any other update that writes
+// a different status fails with a *models.TransitionError, and orders are created as drafts or
+// pending. Restores and seeds run in models.SystemContext and keep their statuses.
+const (
+	orderHook          = "gkbxsrv:order"
+	orderTransitionKey = "gkbxsrv:order_transition"
+)
+
+// TransitionInput carries the reason recorded in the status history and the stock to reserve for the
+// order as part of the transition: the entries of Reserve, whose OrderID is set to the order, and
+// with WarehouseID the quantity of every item of the order from that warehouse. A reservation is
+// needed to confirm an order holding none.
+type TransitionInput struct {
+	Reason      string             `json:"reason,omitempty"`
+	Reserve     []ReservationInput `json:"reserve,omitempty"`
+	WarehouseID string             `json:"warehouse_id,omitempty"`
+	TTL         time.Duration      `json:"ttl,omitempty"`
+}
+
+// OrderService moves orders through models.OrderTransitions. A transition checks its guards, runs its
+// side effects and records the change in the status history in one transaction:
+//
+//   - confirmed: reserves the stock of TransitionInput; the order must then hold an active
+//     reservation
+//   - shipped: the order must hold an active reservation, which is consumed (issued)
+//   - delivered: stamps ActualDelivery
+//   - cancelled: releases the reservations of the order
+type OrderService interface {
+	Transition(ctx context.Context, orderID string, to models.OrderStatus, in TransitionInput) (*models.Order, error)
+	History(ctx context.Context, orderID string) ([]*models.OrderStatusHistory, error)
+	Items(ctx context.Context, orderID string) ([]*models.OrderItem, error)
+	// Recalculate derives the totals of an order from its items again, e.g. after raw SQL writes.
+	Recalculate(ctx context.Context, orderID string) (models.OrderTotals, error)
+}
+
+type OrderServiceImpl struct {
+	db *gorm.DB
+}
+
+func NewOrderService(db *gorm.DB) OrderService {
+	return &OrderServiceImpl{db: db}
+}
+
+func (s *OrderServiceImpl) Transition(ctx context.Context, orderID string, to models.OrderStatus, in TransitionInput) (*models.Order, error) {
+	if !to.Valid() {
+		return nil, &models.ValidationError{Field: "status", Message: fmt.Sprintf("Unknown order status %q", to)}
+	}
+	var order models.Order
+	runErr := models.RetryOnConflict(models.DefaultConflictRetries, func() error {
+		return WithTx(s.db, ctx, func(tx Repos) error {
+			db := tx.DB()
+			order = models.Order{}
+			if findErr := db.First(&order, "id = ?", orderID).Error; findErr != nil {
+				return findErr
+			}
+			from := order.Status
+			if !from.CanTransition(to) {
+				return &models.TransitionError{From: from, To: to, Reason: "transition not allowed"}
+			}
+
+			inventory := NewInventoryService(db)
+			reservations, inputsErr := orderReservationInputs(db, &order, in)
+			if inputsErr != nil {
+				return inputsErr
+			}
+			for _, reservation := range reservations {
+				if _, reserveErr := inventory.Reserve(ctx, reservation); reserveErr != nil {
+					return reserveErr
+				}
+			}
+			if to == models.OrderStatusConfirmed || to == models.OrderStatusShipped {
+				if guardErr := orderReserved(db, &order, from, to); guardErr != nil {
+					return guardErr
+				}
+			}
+
+			values := map[string]interface{}{"status": to}
+			switch to {
+			case models.OrderStatusShipped:
+				if _, consumeErr := inventory.ConsumeOrder(ctx, order.ID, order.OrderNumber); consumeErr != nil {
+					return consumeErr
+				}
+			case models.OrderStatusDelivered:
+				values["actual_delivery"] = time.Now().UTC()
+			case models.OrderStatusCancelled:
+				if _, releaseErr := inventory.ReleaseOrder(ctx, order.ID); releaseErr != nil {
+					return releaseErr
+				}
+			}
+			if updateErr := db.Set(orderTransitionKey, true).Model(&order).Updates(values).Error; updateErr != nil {
+				return updateErr
+			}
+			history := &models.OrderStatusHistory{
+				OrderID:    order.ID,
+				FromStatus: from,
+				ToStatus:   to,
+				Actor:      ActorFromContext(ctx),
+				Reason:     in.Reason,
+				Tenant:     order.Tenant,
+			}
+			return db.Create(history).Error
+		})
+	})
+	if runErr != nil {
+		return nil, runErr
+	}
+	return &order, nil
+}
+
+func (s *OrderServiceImpl) History(ctx context.Context, orderID string) ([]*models.OrderStatusHistory, error) {
+	var history []*models.OrderStatusHistory
+	if findErr := s.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at, id").Find(&history).Error; findErr != nil {
+		return nil, findErr
+	}
+	return history, nil
+}
+
+func (s *OrderServiceImpl) Items(ctx context.Context, orderID string) ([]*models.OrderItem, error) {
+	var items []*models.OrderItem
+	if findErr := s.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at, id").Find(&items).Error; findErr != nil {
+		return nil, findErr
+	}
+	return items, nil
+}
+
+func (s *OrderServiceImpl) Recalculate(ctx context.Context, orderID string) (models.OrderTotals, error) {
+	var totals models.OrderTotals
+	txErr := WithTx(s.db, ctx, func(tx Repos) error {
+		var recalcErr error
+		totals, recalcErr = RecalculateOrder(tx.DB(), orderID)
+		return recalcErr
+	})
+	return totals, txErr
+}
+
+// orderReservationInputs lists the stock a transition reserves for order.
+func orderReservationInputs(db *gorm.DB, order *models.Order, in TransitionInput) ([]ReservationInput, error) {
+	reservations := make([]ReservationInput, 0, len(in.Reserve))
+	for _, reservation := range in.Reserve {
+		reservation.OrderID = order.ID
+		if reservation.TTL == 0 {
+			reservation.TTL = in.TTL
+		}
+		reservations = append(reservations, reservation)
+	}
+	if in.WarehouseID == "" {
+		return reservations, nil
+	}
+	var items []*models.OrderItem
+	if findErr := db.Where("order_id = ?", order.ID).Order("created_at, id").Find(&items).Error; findErr != nil {
+		return nil, findErr
+	}
+	for _, item := range items {
+		reservations = append(reservations, ReservationInput{
+			ProductID:   fmt.Sprintf("%d", item.ProductID),
+			VariantID:   item.VariantID,
+			WarehouseID: in.WarehouseID,
+			OrderID:     order.ID,
+			Quantity:    item.Quantity.Float64(),
+			TTL:         in.TTL,
+		})
+	}
+	return reservations, nil
+}
+
+// orderReserved is the guard of the transitions that need stock held for the order.
+func orderReserved(db *gorm.DB, order *models.Order, from, to models.OrderStatus) error {
+	var count int64
+	if countErr := db.Model(&models.StockReservation{}).
+		Where("order_id = ? AND status = ? AND expires_at > ?", order.ID, models.ReservationActive, time.Now().UTC()).
+		Count(&count).Error; countErr != nil {
+		return countErr
+	}
+	if count == 0 {
+		return &models.TransitionError{From: from, To: to, Reason: "order holds no active stock reservation"}
+	}
+	return nil
+}
+
+// useOrderTransitions registers the callbacks that keep order statuses out of plain creates and
+// updates.
+func useOrderTransitions(db *gorm.DB) error {
+	if createErr := db.Callback().Create().Before("gorm:before_create").Register(orderHook+":create", orderCreateGuard); createErr != nil {
+		return createErr
+	}
+	return db.Callback().Update().Before("gorm:before_update").Register(orderHook+":update", orderStatusGuard)
+}
+
+// orderCreateGuard fails creates of orders in a status other than draft or pending, outside
+// models.SystemContext.
+func orderCreateGuard(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.ModelType != reflect.TypeOf(models.Order{}) {
+		return
+	}
+	if models.IsSystemContext(tx.Statement.Context) {
+		return
+	}
+	for _, status := range orderStatusesCreated(tx) {
+		if status == "" || status == models.OrderStatusDraft || status == models.OrderStatusPending {
+			continue
+		}
+		if !status.Valid() {
+			_ = tx.AddError(&models.ValidationError{Field: "status", Message: fmt.Sprintf("Unknown order status %q", status)})
+		} else {
+			_ = tx.AddError(&models.TransitionError{To: status, Reason: "orders are created as draft or pending"})
+		}
+		return
+	}
+}
+
+// orderStatusesCreated returns the statuses of the orders a create writes; "" stands for the column
+// default.
+func orderStatusesCreated(tx *gorm.DB) []models.OrderStatus {
+	field := tx.Statement.Schema.LookUpField("status")
+	switch dest := tx.Statement.Dest.(type) {
+	case map[string]interface{}:
+		return []models.OrderStatus{orderStatusOfMap(dest, field.DBName, field.Name)}
+	case []map[string]interface{}:
+		statuses := make([]models.OrderStatus, 0, len(dest))
+		for _, values := range dest {
+			statuses = append(statuses, orderStatusOfMap(values, field.DBName, field.Name))
+		}
+		return statuses
+	}
+	var statuses []models.OrderStatus
+	rv := tx.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Struct:
+		value, _ := field.ValueOf(tx.Statement.Context, rv)
+		statuses = append(statuses, value.(models.OrderStatus))
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			value, _ := field.ValueOf(tx.Statement.Context, reflect.Indirect(rv.Index(i)))
+			statuses = append(statuses, value.(models.OrderStatus))
+		}
+	}
+	return statuses
+}
+
+func orderStatusOfMap(values map[string]interface{}, names ...string) models.OrderStatus {
+	for _, name := range names {
+		if value, found := values[name]; found && value != nil {
+			return models.OrderStatus(fmt.Sprint(value))
+		}
+	}
+	return ""
+}
+
+func orderStatusGuard(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.ModelType != reflect.TypeOf(models.Order{}) {
+		return
+	}
+	if _, transition := tx.Get(orderTransitionKey); transition {
+		return
+	}
+	to, written := orderStatusWritten(tx)
+	if !written {
+		return
+	}
+
+	pk := tx.Statement.Schema.PrioritizedPrimaryField
+	id, isZero := pk.ValueOf(tx.Statement.Context, tx.Statement.ReflectValue)
+	if tx.Statement.ReflectValue.Kind() != reflect.Struct || isZero {
+		_ = tx.AddError(&models.TransitionError{To: to, Reason: "order statuses change through transitions"})
+		return
+	}
+	var current models.Order
+	findErr := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Unscoped().
+		Select(pk.DBName, "status").
+		Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Value: id}).
+		Limit(1).Find(&current).Error
+	if findErr != nil {
+		_ = tx.AddError(findErr)
+		return
+	}
+	if current.ID != "" && current.Status != to {
+		_ = tx.AddError(&models.TransitionError{From: current.Status, To: to, Reason: "order statuses change through transitions"})
+	}
+}
+
+// orderStatusWritten returns the status an update writes, if any.
+func orderStatusWritten(tx *gorm.DB) (models.OrderStatus, bool) {
+	field := tx.Statement.Schema.LookUpField("status")
+	selected, restricted := tx.Statement.SelectAndOmitColumns(false, true)
+	if updated, found := selected[field.DBName]; (found && !updated) || (!found && restricted) {
+		return "", false
+	}
+	if values, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+		value, found := values[field.DBName]
+		if !found {
+			value, found = values[field.Name]
+		}
+		if !found {
+			return "", false
+		}
+		return models.OrderStatus(fmt.Sprint(value)), true
+	}
+	dest := reflect.Indirect(reflect.ValueOf(tx.Statement.Dest))
+	if dest.Kind() != reflect.Struct || dest.Type() != field.Schema.ModelType {
+		return "", false
+	}
+	value, isZero := field.ValueOf(tx.Statement.Context, dest)
+	if isZero {
+		return "", false
+	}
+	return value.(models.OrderStatus), true
+}
+
+// Order totals follow their items: every create, update and delete of an OrderItem derives the
+// amounts of the lines of the orders it touched again and writes their sums to the orders, in the
+// transaction of the write. Items only change while their order is a draft, outside
+// models.SystemContext.
+const (
+	orderTotalsKey  = "gkbxsrv:order_totals"
+	orderTouchedKey = "gkbxsrv:order_touched"
+)
+
+// ErrOrderItemsLocked is the error of a write to the items of an order past draft.
+var ErrOrderItemsLocked = errors.New("order items only change while the order is a draft")
+
+// useOrderTotals registers the callbacks that recalculate orders when their items change.
+func useOrderTotals(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if createErr := callbacks.Create().After("gorm:create").Before("gorm:after_create").Register(orderHook+":items_create", orderItemsWritten); createErr != nil {
+		return createErr
+	}
+	if beforeErr := callbacks.Update().After("gorm:before_update").Before("gorm:update").Register(orderHook+":items_before_update", orderItemsTouched); beforeErr != nil {
+		return beforeErr
+	}
+	if updateErr := callbacks.Update().After("gorm:update").Before("gorm:after_update").Register(orderHook+":items_update", orderItemsWritten); updateErr != nil {
+		return updateErr
+	}
+	if beforeErr := callbacks.Delete().After("gorm:before_delete").Before("gorm:delete").Register(orderHook+":items_before_delete", orderItemsTouched); beforeErr != nil {
+		return beforeErr
+	}
+	return callbacks.Delete().After("gorm:delete").Before("gorm:after_delete").Register(orderHook+":items_delete", orderItemsWritten)
+}
+
+func orderItemsStatement(tx *gorm.DB) bool {
+	if tx.Error != nil || tx.DryRun || tx.Statement.Schema == nil || tx.Statement.Schema.ModelType != reflect.TypeOf(models.OrderItem{}) {
+		return false
+	}
+	_, recalculating := tx.Get(orderTotalsKey)
+	return !recalculating
+}
+
+// orderItemsTouched keeps the orders of the items an update or a delete is about to change.
+func orderItemsTouched(tx *gorm.DB) {
+	if !orderItemsStatement(tx) {
+		return
+	}
+	query, filtered := affectedRows(tx)
+	if !filtered {
+		return
+	}
+	var orderIDs []string
+	if pluckErr := query.Distinct().Pluck("order_id", &orderIDs).Error; pluckErr != nil {
+		_ = tx.AddError(fmt.Errorf("error reading the orders of the items: %v", pluckErr))
+		return
+	}
+	tx.InstanceSet(orderTouchedKey, orderIDs)
+}
+
+func orderItemsWritten(tx *gorm.DB) {
+	if !orderItemsStatement(tx) || tx.Statement.RowsAffected == 0 {
+		return
+	}
+	orderIDs := map[string]bool{}
+	if touched, ok := tx.InstanceGet(orderTouchedKey); ok {
+		for _, orderID := range touched.([]string) {
+			orderIDs[orderID] = true
+		}
+	}
+	// Items moved to another order, and new items.
+	auditEach(tx.Statement.ReflectValue, func(rv reflect.Value) {
+		if item, ok := rv.Interface().(models.OrderItem); ok && item.OrderID != "" {
+			orderIDs[item.OrderID] = true
+		}
+	})
+	if values, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+		if orderID, found := values["order_id"]; found {
+			orderIDs[fmt.Sprint(orderID)] = true
+		}
+	}
+	if !models.IsSystemContext(tx.Statement.Context) {
+		for orderID := range orderIDs {
+			if lockErr := orderItemsEditable(tx, orderID); lockErr != nil {
+				_ = tx.AddError(lockErr)
+				return
+			}
+		}
+	}
+	db := tx.Session(&gorm.Session{NewDB: true})
+	for orderID := range orderIDs {
+		if _, recalcErr := RecalculateOrder(db, orderID); recalcErr != nil {
+			_ = tx.AddError(recalcErr)
+			return
+		}
+	}
+}
+
+// orderItemsEditable fails with ErrOrderItemsLocked when the order is past draft. Items of an order
+// that does not exist are left alone.
+func orderItemsEditable(tx *gorm.DB, orderID string) error {
+	var order models.Order
+	result := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Unscoped().Select("id", "status").Where("id = ?", orderID).Limit(1).Find(&order)
+	if result.Error != nil {
+		return fmt.Errorf("error reading order %s: %v", orderID, result.Error)
+	}
+	if result.RowsAffected == 0 || order.Status == models.OrderStatusDraft {
+		return nil
+	}
+	return fmt.Errorf("order %s is %s: %w", orderID, order.Status, ErrOrderItemsLocked)
+}
+
+// RecalculateOrder derives the amounts of the items of an order again and writes their sums to the
+// order. Only the rows whose amounts changed are written.
+func RecalculateOrder(db *gorm.DB, orderID string) (models.OrderTotals, error) {
+	db = db.Set(orderTotalsKey, true).Session(&gorm.Session{})
+	var items []*models.OrderItem
+	if findErr := db.Where("order_id = ?", orderID).Order("created_at, id").Find(&items).Error; findErr != nil {
+		return models.OrderTotals{}, fmt.Errorf("error reading the items of order %s: %v", orderID, findErr)
+	}
+	for _, item := range items {
+		stored := *item
+		if calcErr := item.Calculate(); calcErr != nil {
+			return models.OrderTotals{}, fmt.Errorf("error calculating order item %s: %w", item.ID, calcErr)
+		}
+		if item.Subtotal == stored.Subtotal && item.DiscountTotal == stored.DiscountTotal && item.TaxAmount == stored.TaxAmount && item.Total == stored.Total {
+			continue
+		}
+		if updateErr := db.Session(&gorm.Session{SkipHooks: true}).Model(item).UpdateColumns(map[string]interface{}{
+			"subtotal":       item.Subtotal,
+			"discount_total": item.DiscountTotal,
+			"tax_amount":     item.TaxAmount,
+			"total":          item.Total,
+		}).Error; updateErr != nil {
+			return models.OrderTotals{}, fmt.Errorf("error updating order item %s: %v", item.ID, updateErr)
+		}
+	}
+	totals, sumErr := models.SumOrderItems(items)
+	if sumErr != nil {
+		return models.OrderTotals{}, fmt.Errorf("error adding up the items of order %s: %w", orderID, sumErr)
+	}
+
+	var order models.Order
+	result := db.Unscoped().Where("id = ?", orderID).Limit(1).Find(&order)
+	if result.Error != nil {
+		return totals, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return totals, nil
+	}
+	if order.Subtotal == totals.Subtotal && order.DiscountTotal == totals.DiscountTotal && order.TaxTotal == totals.TaxTotal && order.TotalAmount == totals.Total {
+		return totals, nil
+	}
+	updateErr := db.Unscoped().Model(&order).Updates(map[string]interface{}{
+		"subtotal":       totals.Subtotal,
+		"discount_total": totals.DiscountTotal,
+		"tax_total":      totals.TaxTotal,
+		"total_amount":   totals.Total,
+	}).Error
+	if updateErr != nil {
+		return totals, fmt.Errorf("error updating the totals of order %s: %v", orderID, updateErr)
+	}
+	return totals, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	glb "github.com/faelmori/gkbxsrv/internal/globals"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+func openOrderTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dir := t.TempDir()
+	db, openErr := OpenConnection(glb.Database{Driver: "sqlite", Path: dir + "/orders.db"})
+	if openErr != nil {
+		t.Fatalf("OpenConnection() error = %v", openErr)
+	}
+	if _, upErr := NewMigrator(db, dir).Up(0); upErr != nil {
+		t.Fatalf("Up() error = %v", upErr)
+	}
+	return db
+}
+
+func TestOrderCreateGuard(t *testing.T) {
+	db := openOrderTestDB(t)
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		status    models.OrderStatus
+		wantErr   error
+		wantValid bool
+	}{
+		{"default status", context.Background(), "", nil, false},
+		{"draft", context.Background(), models.OrderStatusDraft, nil, false},
+		{"pending", context.Background(), models.OrderStatusPending, nil, false},
+		{"confirmed", context.Background(), models.OrderStatusConfirmed, models.ErrInvalidTransition, false},
+		{"delivered", context.Background(), models.OrderStatusDelivered, models.ErrInvalidTransition, false},
+		{"unknown", context.Background(), "archived", nil, true},
+		{"system context", models.SystemContext(context.Background()), models.OrderStatusDelivered, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &models.Order{OrderNumber: "SO-" + tt.name, CustomerID: 1, Status: tt.status}
+			err := db.WithContext(tt.ctx).Create(order).Error
+			var validationErr *models.ValidationError
+			switch {
+			case tt.wantValid:
+				if !errors.As(err, &validationErr) {
+					t.Errorf("Create() error = %v, want a validation error", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Create() error = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("Create() error = %v", err)
+			}
+		})
+	}
+
+	batch := []models.Order{{OrderNumber: "SO-batch-1", CustomerID: 1}, {OrderNumber: "SO-batch-2", CustomerID: 1, Status: models.OrderStatusShipped}}
+	if err := db.Create(&batch).Error; !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("Create() of a batch error = %v, want %v", err, models.ErrInvalidTransition)
+	}
+	if err := db.Model(&models.Order{}).Create(map[string]interface{}{"id": "b0c5d1f2-0000-4000-8000-000000000001", "order_number": "SO-map", "customer_id": 1, "status": "shipped"}).Error; !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("Create() from a map error = %v, want %v", err, models.ErrInvalidTransition)
+	}
+}
+
+func TestOrderStatusGuard(t *testing.T) {
+	db := openOrderTestDB(t)
+	order := &models.Order{OrderNumber: "SO-1", CustomerID: 1}
+	if err := db.Create(order).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := db.Model(order).Update("status", models.OrderStatusConfirmed).Error; !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("Update() of the status error = %v, want %v", err, models.ErrInvalidTransition)
+	}
+	if err := db.Model(order).Update("order_number", "SO-2").Error; err != nil {
+		t.Errorf("Update() of another column error = %v", err)
+	}
+	moved, err := NewOrderService(db).Transition(context.Background(), order.ID, models.OrderStatusPending, TransitionInput{Reason: "test"})
+	if err != nil || moved.Status != models.OrderStatusPending {
+		t.Fatalf("Transition() = %v, %v, want a pending order", moved, err)
+	}
+	if _, err = NewOrderService(db).Transition(context.Background(), order.ID, models.OrderStatusShipped, TransitionInput{}); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("Transition() to shipped error = %v, want %v", err, models.ErrInvalidTransition)
+	}
+}
+
+func TestOrderItemsLocked(t *testing.T) {
+	db := openOrderTestDB(t)
+	ctx := context.Background()
+	product := &models.Product{Name: "P", Depart: "D", Category: "C", Price: 2, Cost: 1, Stock: 1, Reserve: 1, Balance: 1}
+	if err := db.Create(product).Error; err != nil {
+		t.Fatalf("Create() of the product error = %v", err)
+	}
+	order := &models.Order{OrderNumber: "SO-1", CustomerID: 1}
+	if err := db.Create(order).Error; err != nil {
+		t.Fatalf("Create() of the order error = %v", err)
+	}
+	item := &models.OrderItem{OrderID: order.ID, ProductID: product.ID, Quantity: models.MustParseDecimal("1"), UnitPrice: models.MustParseDecimal("2")}
+	if err := db.Create(item).Error; err != nil {
+		t.Fatalf("Create() of an item of a draft order error = %v", err)
+	}
+	if _, err := NewOrderService(db).Transition(ctx, order.ID, models.OrderStatusPending, TransitionInput{}); err != nil {
+		t.Fatalf("Transition() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		write   func(db *gorm.DB) error
+		wantErr error
+	}{
+		{"create", ctx, func(db *gorm.DB) error {
+			return db.Create(&models.OrderItem{OrderID: order.ID, ProductID: product.ID, Quantity: models.MustParseDecimal("1"), UnitPrice: models.MustParseDecimal("2")}).Error
+		}, ErrOrderItemsLocked},
+		{"update", ctx, func(db *gorm.DB) error {
+			return db.Model(item).Update("quantity", models.MustParseDecimal("5")).Error
+		}, ErrOrderItemsLocked},
+		{"delete", ctx, func(db *gorm.DB) error { return db.Delete(item).Error }, ErrOrderItemsLocked},
+		{"update in the system context", models.SystemContext(ctx), func(db *gorm.DB) error {
+			return db.Model(item).Update("description", "fixed").Error
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(db.WithContext(tt.ctx)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("write error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	items, err := NewOrderService(db).Items(ctx, order.ID)
+	if err != nil || len(items) != 1 || items[0].Quantity.String() != "1" {
+		t.Errorf("Items() = %v, %v, want the one item unchanged", items, err)
+	}
+
+	_, err = NewRPCServer(db).Call("orderitem.update", json.RawMessage(fmt.Sprintf(`{"id":%q,"order_id":%q,"product_id":%d,"quantity":9,"unit_price":2}`, item.ID, order.ID, product.ID)))
+	if got := rpcErrorCode(err); got != RPCConflict {
+		t.Errorf("Call(orderitem.update) error = %v, want code %d", err, RPCConflict)
+	}
+}
@@ -417,21 +417,35 @@ func GetGoType(data interface{}) string {
 }
 
 // DBTypeToGoType converte um tipo de dados de banco de dados para um tipo de dados em Go
-// dbType: tipo de dados do banco de dados
+// dbType: tipo de dados do banco de dados, com ou sem tamanho (ex.: "varchar(50)", "int4", "timestamptz")
 // Retorna o tipo de dados correspondente em Go como string
 func DBTypeToGoType(dbType string) string {
-	switch dbType {
-	case "NUMBER", "REAL", "DECIMAL", "NUMERIC", "FLOAT":
+	normalized := strings.ToUpper(strings.TrimSpace(dbType))
+	if idx := strings.Index(normalized, "("); idx >= 0 {
+		if end := strings.Index(normalized[idx:], ")"); end >= 0 {
+			normalized = normalized[:idx] + normalized[idx+end+1:]
+		} else {
+			normalized = normalized[:idx]
+		}
+	}
+	normalized = strings.Join(strings.Fields(strings.TrimSuffix(normalized, " UNSIGNED")), " ")
+	switch normalized {
+	case "NUMBER", "REAL", "DECIMAL", "NUMERIC", "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE", "DOUBLE PRECISION", "MONEY":
 		return "float64"
-	case "VARCHAR", "TEXT", "VARCHAR2":
+	case "VARCHAR", "TEXT", "VARCHAR2", "CHAR", "CHARACTER", "CHARACTER VARYING", "BPCHAR", "NCHAR", "NVARCHAR",
+		"NTEXT", "TINYTEXT", "MEDIUMTEXT", "LONGTEXT", "CITEXT", "UUID", "UNIQUEIDENTIFIER", "JSON", "JSONB", "ENUM":
 		return "string"
-	case "INT", "INTEGER":
+	case "INT", "INTEGER", "INT4", "MEDIUMINT", "SERIAL":
 		return "int"
-	case "DATE", "DATETIME", "TIMESTAMP":
+	case "BIGINT", "INT8", "BIGSERIAL":
+		return "int64"
+	case "SMALLINT", "INT2", "SMALLSERIAL":
+		return "int16"
+	case "DATE", "DATETIME", "DATETIME2", "TIMESTAMP", "TIMESTAMPTZ", "TIMESTAMP WITH TIME ZONE", "TIMESTAMP WITHOUT TIME ZONE":
 		return "time.Time"
-	case "BOOLEAN", "BIT":
+	case "BOOLEAN", "BOOL", "BIT":
 		return "bool"
-	case "BLOB", "VARBINARY", "BYTEA":
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BYTEA", "IMAGE":
 		return "[]byte"
 	case "CLOB":
 		return "string"
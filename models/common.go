@@ -88,6 +88,7 @@ type Model interface {
 	SetID(id uuid.UUID)
 }
 
+// Deprecated: GenericRepo is untyped and has no implementation; use Repository[T].
 type GenericRepo interface {
 	Create(u interface{}) (interface{}, error)
 	FindOne(where ...interface{}) (interface{}, error)
@@ -22,3 +22,18 @@ func NewModelRegistry() ModelRegistry { return models.NewModelRegistry() }
 func NewModelRegistryFromModel(model interface{}) ModelRegistry {
 	return models.NewModelRegistryFromModel(model)
 }
+
+type ModelDefinition = models.ModelDefinition
+type FieldDefinition = models.FieldDefinition
+type IndexDefinition = models.IndexDefinition
+
+func RegisterDynamicModel(def ModelDefinition) (reflect.Type, error) {
+	return models.RegisterDynamicModel(def)
+}
+func DynamicModelDefinitions() []ModelDefinition { return models.DynamicModelDefinitions() }
+func DynamicModelDefinition(typ reflect.Type) (ModelDefinition, bool) {
+	return models.DynamicModelDefinition(typ)
+}
+
+// RegisteredModels returns the model registry, built-in and dynamic models by name.
+func RegisteredModels() map[string]reflect.Type { return models.ModelRegistryMap }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+)
+
+type Warehouse = models.Warehouse
+type Inventory = models.Inventory
+type InventoryStatus = models.InventoryStatus
+type InventoryMovement = models.InventoryMovement
+type MovementType = models.MovementType
+type StockReservation = models.StockReservation
+type ReservationStatus = models.ReservationStatus
+type CountSession = models.CountSession
+type CountStatus = models.CountStatus
+type CountLine = models.CountLine
+
+const (
+	MovementReceipt     = models.MovementReceipt
+	MovementIssue       = models.MovementIssue
+	MovementAdjustment  = models.MovementAdjustment
+	MovementTransferOut = models.MovementTransferOut
+	MovementTransferIn  = models.MovementTransferIn
+
+	ReservationActive   = models.ReservationActive
+	ReservationConsumed = models.ReservationConsumed
+	ReservationReleased = models.ReservationReleased
+	ReservationExpired  = models.ReservationExpired
+
+	CountOpen      = models.CountOpen
+	CountPosted    = models.CountPosted
+	CountCancelled = models.CountCancelled
+)
+
+func NewInventoryRepo(db *gorm.DB) *models.InventoryRepoImpl { return models.NewInventoryRepo(db) }
+func NewInventoryMovementRepo(db *gorm.DB) *models.InventoryMovementRepoImpl {
+	return models.NewInventoryMovementRepo(db)
+}
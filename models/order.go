@@ -8,8 +8,37 @@ import (
 
 type OrderRepo = models.OrderRepo
 type Order = models.Order
+type OrderStatus = models.OrderStatus
+type OrderStatusHistory = models.OrderStatusHistory
+type TransitionError = models.TransitionError
+type OrderItem = models.OrderItem
+type OrderTotals = models.OrderTotals
+type Decimal = models.Decimal
 
-func NewOrderRepo(db *gorm.DB) OrderRepo { return models.NewOrderRepo(db) }
+const DecimalScale = models.DecimalScale
+
+const (
+	OrderStatusDraft      = models.OrderStatusDraft
+	OrderStatusPending    = models.OrderStatusPending
+	OrderStatusConfirmed  = models.OrderStatusConfirmed
+	OrderStatusProcessing = models.OrderStatusProcessing
+	OrderStatusShipped    = models.OrderStatusShipped
+	OrderStatusDelivered  = models.OrderStatusDelivered
+	OrderStatusCancelled  = models.OrderStatusCancelled
+)
+
+var (
+	OrderTransitions     = models.OrderTransitions
+	ErrInvalidTransition = models.ErrInvalidTransition
+)
+
+func NewOrderRepo(db *gorm.DB) OrderRepo                     { return models.NewOrderRepo(db) }
+func NewOrderItemRepo(db *gorm.DB) *models.OrderItemRepoImpl { return models.NewOrderItemRepo(db) }
+func SumOrderItems(items []*OrderItem) (OrderTotals, error)  { return models.SumOrderItems(items) }
+func DecimalFromInt(n int64) (Decimal, error)                { return models.DecimalFromInt(n) }
+func DecimalFromFloat(f float64) Decimal                     { return models.DecimalFromFloat(f) }
+func ParseDecimal(s string) (Decimal, error)                 { return models.ParseDecimal(s) }
+func MustParseDecimal(s string) Decimal                      { return models.MustParseDecimal(s) }
 func OrderFactory(governmentID, customerID, sellerID, employerID int, orderDate, sellDate, dueDate, deliveryDate time.Time, shippingAddress, shippingRegion, shippingPhone, shippingEmail, shippingTracking, shippingCompany, billingAddress, billingRegion, billingPhone, billingEmail, billingTracking, billingCompany, orderStatus, governmentStatus, invoiceStatus, paymentStatus, shippingStatus, billingStatus string, total, discount, subtotal, tax, shipping, grandTotal float64, active bool) Order {
 	return models.OrderFactory()
 }
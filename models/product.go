@@ -7,6 +7,30 @@ import (
 
 type ProductRepo = models.ProductRepo
 type Product = models.Product
+type ProductVariant = models.ProductVariant
+type Category = models.Category
+type CategoryNode = models.CategoryNode
+type BarcodeType = models.BarcodeType
+
+const (
+	BarcodeEAN13 = models.BarcodeEAN13
+	BarcodeUPCA  = models.BarcodeUPCA
+)
+
+func NewProductVariantRepo(db *gorm.DB) *models.ProductVariantRepoImpl {
+	return models.NewProductVariantRepo(db)
+}
+func NewCategoryRepo(db *gorm.DB) *models.CategoryRepoImpl { return models.NewCategoryRepo(db) }
+func NewCategoryTree(categories []*Category) []*CategoryNode {
+	return models.NewCategoryTree(categories)
+}
+func NormalizeBarcode(code string) (string, BarcodeType, error) {
+	return models.NormalizeBarcode(code)
+}
+func BarcodeCheckDigit(digits string) int { return models.BarcodeCheckDigit(digits) }
+func FormatBarcode(gtin string, barcodeType BarcodeType) string {
+	return models.FormatBarcode(gtin, barcodeType)
+}
 
 func NewProductRepo(db *gorm.DB) ProductRepo { return models.NewGormProductRepo(db) }
 func ProductFactory(name, depart, category string, price, cost float64, stock, reserve, balance int) *Product {
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+)
+
+type Query = models.Query
+type Filter = models.Filter
+type PageInfo = models.PageInfo
+type ModelPage = models.ModelPage
+
+const (
+	OpEq    = models.OpEq
+	OpNe    = models.OpNe
+	OpGt    = models.OpGt
+	OpGte   = models.OpGte
+	OpLt    = models.OpLt
+	OpLte   = models.OpLte
+	OpLike  = models.OpLike
+	OpIn    = models.OpIn
+	OpNotIn = models.OpNotIn
+	OpNull  = models.OpNull
+)
+
+func ParseFilter(expr string) (Filter, error)       { return models.ParseFilter(expr) }
+func ParseFilters(exprs []string) ([]Filter, error) { return models.ParseFilters(exprs) }
+func FindQuery(db *gorm.DB, dest interface{}, q Query) (PageInfo, error) {
+	return models.FindQuery(db, dest, q)
+}
+func QueryModel(db *gorm.DB, name string, q Query) (*ModelPage, error) {
+	return models.QueryModel(db, name, q)
+}
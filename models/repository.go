@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+)
+
+type Repository[T any] = models.Repository[T]
+type Page[T any] = models.Page[T]
+type PageOptions = models.PageOptions
+
+const (
+	DefaultPageSize = models.DefaultPageSize
+	MaxPageSize     = models.MaxPageSize
+)
+
+func NewRepository[T any](db *gorm.DB) *Repository[T] { return models.NewRepository[T](db) }
+
+type SoftDelete = models.SoftDelete
+
+func RestoreRecord(db *gorm.DB, model interface{}, id interface{}) error {
+	return models.RestoreRecord(db, model, id)
+}
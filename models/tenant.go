@@ -0,0 +1,24 @@
+package models
+
+import (
+	"context"
+	"github.com/faelmori/gkbxsrv/internal/models"
+	"gorm.io/gorm"
+)
+
+type Tenant = models.Tenant
+
+var (
+	ErrTenantRequired = models.ErrTenantRequired
+	ErrTenantMismatch = models.ErrTenantMismatch
+)
+
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return models.WithTenant(ctx, tenantID)
+}
+func TenantFromContext(ctx context.Context) (string, bool) { return models.TenantFromContext(ctx) }
+func SystemContext(ctx context.Context) context.Context    { return models.SystemContext(ctx) }
+func IsSystemContext(ctx context.Context) bool             { return models.IsSystemContext(ctx) }
+func TenantScope(tenantID string) func(db *gorm.DB) *gorm.DB {
+	return models.TenantScope(tenantID)
+}
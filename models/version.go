@@ -0,0 +1,14 @@
+package models
+
+import "github.com/faelmori/gkbxsrv/internal/models"
+
+type Versioned = models.Versioned
+type ConflictError = models.ConflictError
+
+const DefaultConflictRetries = models.DefaultConflictRetries
+
+var ErrConflict = models.ErrConflict
+
+func RetryOnConflict(attempts int, fn func() error) error {
+	return models.RetryOnConflict(attempts, fn)
+}
@@ -1,11 +1,59 @@
 package services
 
-import fsys "github.com/faelmori/gkbxsrv/internal/services"
+import (
+	iModels "github.com/faelmori/gkbxsrv/internal/models"
+	fsys "github.com/faelmori/gkbxsrv/internal/services"
+	"gorm.io/gorm"
+)
 
 type Broker = fsys.BrokerImpl
 type BrokerInfo = fsys.BrokerInfoLock
 type BrokerManager = fsys.BrokerManager
+type BrokerZMQClient = fsys.BrokerClient
+type StreamHandler = fsys.StreamHandler
+type StreamReader = fsys.StreamReader
+type StreamWriter = fsys.StreamWriter
 
 func NewBrokerService(verbose bool, port string) (*Broker, error) { return fsys.NewBroker(verbose) }
 func NewBrokerManager() *BrokerManager                            { return fsys.NewBrokerManager() }
 func NewBrokerInfo(port string) *BrokerInfo                       { return fsys.NewBrokerInfo("", port) }
+func NewBrokerZMQClient(address string) (*BrokerZMQClient, error) {
+	return fsys.NewBrokerClient(address)
+}
+func RegisterStreamHandler(tp string, handler StreamHandler) error {
+	return fsys.RegisterStreamHandler(tp, handler)
+}
+
+type TrafficRecorder = fsys.TrafficRecorder
+type CaptureRecord = fsys.CaptureRecord
+type ReplayOptions = fsys.ReplayOptions
+type ReplayResult = fsys.ReplayResult
+
+func NewBrokerServiceWithCapture(verbose bool, capturePath string) (*Broker, error) {
+	return fsys.NewBrokerWithCapture(verbose, capturePath)
+}
+func ReplayCapture(path string, opts ReplayOptions) (*ReplayResult, error) {
+	return fsys.ReplayCapture(path, opts)
+}
+
+type RPCServer = fsys.RPCServer
+type RPCHandler = fsys.RPCHandler
+type RPCRequest = fsys.RPCRequest
+type RPCResponse = fsys.RPCResponse
+type RPCError = fsys.RPCError
+
+func NewRPCServer(db *gorm.DB) *RPCServer { return fsys.NewRPCServer(db) }
+func RegisterRPCHandler(method string, handler RPCHandler) error {
+	return fsys.RegisterRPCHandler(method, handler)
+}
+
+type EventHub = fsys.EventHub
+type BrokerEvent = fsys.BrokerEvent
+type EventSubscription = fsys.EventSubscription
+type WSGateway = fsys.WSGateway
+type WSMessage = fsys.WSMessage
+
+func NewEventHub() *EventHub { return fsys.NewEventHub() }
+func NewWSGateway(client *BrokerZMQClient, events *EventHub, tokens iModels.TokenService) (*WSGateway, error) {
+	return fsys.NewWSGateway(client, events, tokens)
+}
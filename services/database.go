@@ -1,7 +1,12 @@
 package services
 
 import (
+	"context"
+	"database/sql"
+	"github.com/faelmori/gkbxsrv/internal/models"
 	dbAbs "github.com/faelmori/gkbxsrv/internal/services"
+	"gorm.io/gorm"
+	"io"
 )
 
 type DatabaseService = dbAbs.IDatabaseService
@@ -9,3 +14,157 @@ type DatabaseService = dbAbs.IDatabaseService
 func NewDatabaseService(configFile string) DatabaseService {
 	return dbAbs.NewDatabaseService(configFile)
 }
+
+type DialectorFactory = dbAbs.DialectorFactory
+
+func BuildDSN(cfg Database) (string, error)         { return dbAbs.BuildDSN(cfg) }
+func OpenConnection(cfg Database) (*gorm.DB, error) { return dbAbs.OpenConnection(cfg) }
+func RegisterDialector(driver string, factory DialectorFactory) {
+	dbAbs.RegisterDialector(driver, factory)
+}
+
+type ConnectionRegistry = dbAbs.ConnectionRegistry
+
+const DefaultConnectionName = dbAbs.DefaultConnectionName
+
+func NewConnectionRegistry() *ConnectionRegistry { return dbAbs.NewConnectionRegistry() }
+
+type Migration = dbAbs.Migration
+type MigrationFunc = dbAbs.MigrationFunc
+type MigrationStatus = dbAbs.MigrationStatus
+type Migrator = dbAbs.Migrator
+
+func RegisterMigration(m Migration) error { return dbAbs.RegisterMigration(m) }
+func NewMigrator(db *gorm.DB, dir string) *Migrator {
+	return dbAbs.NewMigrator(db, dir)
+}
+func CreateMigration(dir, name, driver string) (string, string, error) {
+	return dbAbs.CreateMigration(dir, name, driver)
+}
+
+type PoolStats = dbAbs.PoolStats
+
+func ApplyPoolConfig(db *gorm.DB, cfg Database) error { return dbAbs.ApplyPoolConfig(db, cfg) }
+
+type DBHealth = dbAbs.DBHealth
+type DBHealthState = dbAbs.DBHealthState
+
+const (
+	DBHealthTopicPrefix = dbAbs.DBHealthTopicPrefix
+	DBStateUnknown      = dbAbs.DBStateUnknown
+	DBStateConnected    = dbAbs.DBStateConnected
+	DBStateDegraded     = dbAbs.DBStateDegraded
+	DBStateDown         = dbAbs.DBStateDown
+)
+
+type ReplicaResolver = dbAbs.ReplicaResolver
+type ReplicaStatus = dbAbs.ReplicaStatus
+
+func UsePrimary(db *gorm.DB) *gorm.DB                 { return dbAbs.UsePrimary(db) }
+func WithPrimary(ctx context.Context) context.Context { return dbAbs.WithPrimary(ctx) }
+
+type BackupManifest = dbAbs.BackupManifest
+type BackupOptions = dbAbs.BackupOptions
+type BackupResult = dbAbs.BackupResult
+type RestoreOptions = dbAbs.RestoreOptions
+
+const BackupFileExtension = dbAbs.BackupFileExtension
+
+func Backup(db *gorm.DB, w io.Writer, opts BackupOptions) (BackupResult, error) {
+	return dbAbs.Backup(db, w, opts)
+}
+func Restore(db *gorm.DB, r io.Reader, opts RestoreOptions) (BackupResult, error) {
+	return dbAbs.Restore(db, r, opts)
+}
+
+type SeedSet = dbAbs.SeedSet
+type SeedResult = dbAbs.SeedResult
+
+func Seed(db *gorm.DB, dir string) (SeedResult, error) { return dbAbs.Seed(db, dir) }
+func WriteDefaultSeeds(dir, adminPassword string) (string, error) {
+	return dbAbs.WriteDefaultSeeds(dir, adminPassword)
+}
+
+func BindDynamicModels(db *gorm.DB) error    { return dbAbs.BindDynamicModels(db) }
+func MigrateDynamicModels(db *gorm.DB) error { return dbAbs.MigrateDynamicModels(db) }
+
+type ModelGenOptions = dbAbs.ModelGenOptions
+type GeneratedModel = dbAbs.GeneratedModel
+
+func GenerateModels(db *gorm.DB, opts ModelGenOptions) ([]GeneratedModel, error) {
+	return dbAbs.GenerateModels(db, opts)
+}
+func WriteGeneratedModels(dir string, files []GeneratedModel, force bool) ([]string, error) {
+	return dbAbs.WriteGeneratedModels(dir, files, force)
+}
+
+type AuditLog = dbAbs.AuditLog
+type AuditChange = dbAbs.AuditChange
+
+const (
+	AuditCreate  = dbAbs.AuditCreate
+	AuditUpdate  = dbAbs.AuditUpdate
+	AuditDelete  = dbAbs.AuditDelete
+	AuditRestore = dbAbs.AuditRestore
+)
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return dbAbs.WithActor(ctx, actor)
+}
+func ActorFromContext(ctx context.Context) string { return dbAbs.ActorFromContext(ctx) }
+func SkipAudit(db *gorm.DB) *gorm.DB              { return dbAbs.SkipAudit(db) }
+func AuditHistory(db *gorm.DB, q models.Query) ([]AuditLog, models.PageInfo, error) {
+	return dbAbs.AuditHistory(db, q)
+}
+
+type Repos = dbAbs.Repos
+
+const (
+	TxMaxRetries   = dbAbs.TxMaxRetries
+	TxRetryBackoff = dbAbs.TxRetryBackoff
+)
+
+func NewRepos(db *gorm.DB) Repos { return dbAbs.NewRepos(db) }
+func WithTx(db *gorm.DB, ctx context.Context, fn func(tx Repos) error, opts ...*sql.TxOptions) error {
+	return dbAbs.WithTx(db, ctx, fn, opts...)
+}
+
+type InventoryService = dbAbs.InventoryService
+type InsufficientStockError = dbAbs.InsufficientStockError
+type StockInput = dbAbs.StockInput
+type TransferInput = dbAbs.TransferInput
+type ReservationInput = dbAbs.ReservationInput
+
+const InventoryReservationTTL = dbAbs.InventoryReservationTTL
+
+var (
+	ErrInsufficientStock  = dbAbs.ErrInsufficientStock
+	ErrReservationClosed  = dbAbs.ErrReservationClosed
+	ErrReservationExpired = dbAbs.ErrReservationExpired
+)
+
+func NewInventoryService(db *gorm.DB) InventoryService { return dbAbs.NewInventoryService(db) }
+
+type CountService = dbAbs.CountService
+type CountStartInput = dbAbs.CountStartInput
+type CountInput = dbAbs.CountInput
+type CountPostInput = dbAbs.CountPostInput
+type CountReport = dbAbs.CountReport
+
+const CountAdjustmentReason = dbAbs.CountAdjustmentReason
+
+var (
+	ErrCountSessionClosed = dbAbs.ErrCountSessionClosed
+	ErrCountInProgress    = dbAbs.ErrCountInProgress
+)
+
+func NewCountService(db *gorm.DB) CountService     { return dbAbs.NewCountService(db) }
+func ReadCounts(r io.Reader) ([]CountInput, error) { return dbAbs.ReadCounts(r) }
+
+type OrderService = dbAbs.OrderService
+type TransitionInput = dbAbs.TransitionInput
+
+func NewOrderService(db *gorm.DB) OrderService { return dbAbs.NewOrderService(db) }
+func RecalculateOrder(db *gorm.DB, orderID string) (models.OrderTotals, error) {
+	return dbAbs.RecalculateOrder(db, orderID)
+}